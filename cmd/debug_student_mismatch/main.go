@@ -20,11 +20,9 @@ func main() {
 	// Get a class with students
 	var classKey string
 	err := db.DB.QueryRow(`
-		SELECT cg.class_key 
-		FROM class_groups cg
-		JOIN scheduling s ON s.class_days = cg.class_days 
-			AND s.class_time::text = cg.class_time 
-			AND COALESCE(s.class_group_index, 1) = COALESCE(cg.class_number, 1)
+		SELECT class_key
+		FROM class_enrolments
+		WHERE left_at IS NULL
 		LIMIT 1
 	`).Scan(&classKey)
 	if err != nil {
@@ -0,0 +1,95 @@
+// Bulk lead import from CSV.
+//
+// Dry-runs by default: prints each row as new / duplicate / invalid without writing anything.
+// Pass --commit to create all new rows in a single transaction.
+//
+// CSV header must include name and phone; source, notes and level (1-8) are optional.
+//
+// Usage:
+//
+//	go run cmd/import-leads/main.go --file leads.csv
+//	go run cmd/import-leads/main.go --file leads.csv --commit --created-by admin@example.com
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"eighty-twenty-ops/internal/config"
+	"eighty-twenty-ops/internal/db"
+	"eighty-twenty-ops/internal/models"
+)
+
+func main() {
+	file := flag.String("file", "", "Path to the CSV file (required)")
+	commit := flag.Bool("commit", false, "Create the new leads (default is a dry run)")
+	createdBy := flag.String("created-by", "", "Email of the user recorded as creator (defaults to ADMIN_EMAIL)")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatalf("ERROR: --file is required. Usage: go run cmd/import-leads/main.go --file leads.csv [--commit]")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *file, err)
+	}
+	defer f.Close()
+
+	cfg := config.Load()
+	if err := db.Connect(cfg.DatabaseURL); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	sources, err := models.GetActiveLeadSourceNames()
+	if err != nil {
+		log.Fatalf("Failed to load lead sources: %v", err)
+	}
+	rows, err := models.ParseLeadImportCSV(f, sources)
+	if err != nil {
+		log.Fatalf("Failed to parse CSV: %v", err)
+	}
+
+	report, err := models.CheckLeadImportDuplicates(rows)
+	if err != nil {
+		log.Fatalf("Failed to check duplicates: %v", err)
+	}
+
+	for _, row := range report.Rows {
+		phone := row.Phone
+		if phone == "" {
+			phone = row.RawPhone
+		}
+		fmt.Printf("line %-4d %-10s %-30s %-14s %s\n", row.Line, row.Result, row.FullName, phone, row.Message)
+	}
+	fmt.Printf("\n%d new, %d duplicate, %d invalid\n", report.NewCount, report.Duplicates, report.Invalid)
+
+	if !*commit {
+		fmt.Println("Dry run only. Re-run with --commit to import the new rows.")
+		return
+	}
+	if report.NewCount == 0 {
+		fmt.Println("Nothing to import.")
+		return
+	}
+
+	email := *createdBy
+	if email == "" {
+		email = cfg.AdminEmail
+	}
+	var createdByUserID string
+	if user, err := models.GetUserByEmail(email); err == nil {
+		createdByUserID = user.ID.String()
+	} else {
+		log.Printf("WARNING: user %q not found, leads will have no creator", email)
+	}
+
+	committed, err := models.CommitLeadImport(rows, createdByUserID)
+	if err != nil {
+		log.Fatalf("Import failed, no leads were created: %v", err)
+	}
+	fmt.Printf("Imported %d leads.\n", committed.Imported)
+}
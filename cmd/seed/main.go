@@ -152,7 +152,7 @@ func main() {
 		_, err := db.DB.Exec(`
 			INSERT INTO leads (id, full_name, phone, source, notes, status, created_by_user_id, created_at, updated_at)
 			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		`, leadID, name, phone, sql.NullString{String: "seeder", Valid: true}, sql.NullString{String: "Demo data for testing classes board", Valid: true}, "ready_to_start", createdByID, now, now)
+		`, leadID, name, phone, sql.NullString{String: models.LeadSourceFallback, Valid: true}, sql.NullString{String: "Demo data for testing classes board", Valid: true}, "ready_to_start", createdByID, now, now)
 		if err != nil {
 			log.Printf("ERROR: Failed to insert lead %d (%s): %v", i, name, err)
 			continue
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"eighty-twenty-ops/internal/config"
 	"eighty-twenty-ops/internal/db"
@@ -74,6 +75,8 @@ func main() {
 	communityOfficerHandler := handlers.NewCommunityOfficerHandler(cfg)
 	hrHandler := handlers.NewHRHandler(cfg)
 	apiHandler := handlers.NewAPIHandler(cfg)
+	placementTestsHandler := handlers.NewPlacementTestsHandler(cfg)
+	reportsHandler := handlers.NewReportsHandler(cfg)
 
 	// Setup routes
 	mux := http.NewServeMux()
@@ -316,6 +319,25 @@ func main() {
 	}))
 	cfg.Debugf("ROUTE REGISTERED: /api/student-success/followups -> apiHandler.CreateFollowUp")
 
+	mux.HandleFunc("/api/student-success/paused", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"student_success", "mentor_head", "admin"}, cfg.SessionSecret)(apiHandler.GetPausedStudents)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /api/student-success/paused -> apiHandler.GetPausedStudents [student_success+mentor_head+admin]")
+
+	mux.HandleFunc("/api/student-success/pause", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		middleware.RequireAnyRole([]string{"student_success", "mentor_head", "admin"}, cfg.SessionSecret)(apiHandler.PauseStudent)(w, r)
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /api/student-success/pause -> apiHandler.PauseStudent [student_success+mentor_head+admin]")
+
+	mux.HandleFunc("/api/student-success/resume", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		middleware.RequireAnyRole([]string{"student_success", "mentor_head", "admin"}, cfg.SessionSecret)(apiHandler.ResumeStudent)(w, r)
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /api/student-success/resume -> apiHandler.ResumeStudent [student_success+mentor_head+admin]")
+
 	mux.HandleFunc("/api/student-success/resolve-absence", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		middleware.RequireAnyRole([]string{"student_success", "mentor_head", "admin"}, cfg.SessionSecret)(apiHandler.ResolveAbsence)(w, r)
 	}))
@@ -362,6 +384,16 @@ func main() {
 	}))
 	cfg.Debugf("ROUTE REGISTERED: /api/classes/* -> sessions list and completion")
 
+	// Lead routes /api/leads/{id}/status-history
+	mux.HandleFunc("/api/leads/", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/status-history") {
+			middleware.RequireAnyRole([]string{"admin", "moderator"}, cfg.SessionSecret)(apiHandler.GetLeadStatusHistory)(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /api/leads/:id/status-history -> apiHandler.GetLeadStatusHistory [admin+moderator]")
+
 	// Auth routes (public) - register BEFORE protected routes to ensure exact match
 	mux.HandleFunc("/login", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		cfg.Debugf("HANDLER: /login handler for %s %s", r.Method, r.URL.Path)
@@ -405,6 +437,252 @@ func main() {
 	}))
 	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/new -> preEnrolmentHandler (NewForm/Create) [admin+moderator]")
 
+	// /pre-enrolment/import - bulk CSV import, admin only
+	mux.HandleFunc("/pre-enrolment/import", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/import handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/import" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.ImportForm)(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.Import)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/import -> preEnrolmentHandler (ImportForm/Import) [admin only]")
+
+	// /pre-enrolment/sources - managed lead source list, admin only
+	mux.HandleFunc("/pre-enrolment/sources", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/sources handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/sources" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.Sources)(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.SaveSource)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/sources -> preEnrolmentHandler (Sources/SaveSource) [admin only]")
+
+	// /pre-enrolment/custom-fields - custom lead field registry, admin only
+	mux.HandleFunc("/pre-enrolment/custom-fields", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/custom-fields handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/custom-fields" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.CustomFields)(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.SaveCustomField)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/custom-fields -> preEnrolmentHandler (CustomFields/SaveCustomField) [admin only]")
+
+	// /pre-enrolment/prices - published price lists, admin only
+	mux.HandleFunc("/pre-enrolment/prices", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/prices handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/prices" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.Prices)(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.PublishPrices)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/prices -> preEnrolmentHandler (Prices/PublishPrices) [admin only]")
+
+	// /pre-enrolment/prices/offer-letter - offer validity and payment instructions, admin only
+	mux.HandleFunc("/pre-enrolment/prices/offer-letter", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/prices/offer-letter handler for %s %s", r.Method, r.URL.Path)
+		if r.Method == http.MethodPost {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.SaveOfferLetterSettings)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/prices/offer-letter -> preEnrolmentHandler.SaveOfferLetterSettings [admin only]")
+
+	// /pre-enrolment/referrers - referrer picker search (JSON), admin and moderator
+	mux.HandleFunc("/pre-enrolment/referrers", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/referrers handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/referrers" {
+			http.NotFound(w, r)
+			return
+		}
+		middleware.RequireAnyRole([]string{"admin", "moderator"}, cfg.SessionSecret)(preEnrolmentHandler.ReferrerSearch)(w, r)
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/referrers -> preEnrolmentHandler.ReferrerSearch [admin, moderator]")
+
+	// /pre-enrolment/promo-codes - promo codes, the discount approval threshold and referral rewards, admin only
+	mux.HandleFunc("/pre-enrolment/promo-codes", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/promo-codes handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/promo-codes" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.PromoCodes)(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.SavePromoCode)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/promo-codes -> preEnrolmentHandler (PromoCodes/SavePromoCode) [admin only]")
+
+	// /pre-enrolment/shipping - printed books to pack and shipment batches, admin only
+	mux.HandleFunc("/pre-enrolment/shipping", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/shipping handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/shipping" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.Shipping)(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.CreateShipmentBatch)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/shipping -> preEnrolmentHandler (Shipping/CreateShipmentBatch) [admin only]")
+
+	// /pre-enrolment/archived - archived leads (restore) and the retention purge, admin only
+	mux.HandleFunc("/pre-enrolment/archived", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/archived handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/archived" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.Archived)(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.PurgeArchived)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/archived -> preEnrolmentHandler (Archived/PurgeArchived) [admin only]")
+
+	// /pre-enrolment/views - saved list views (shared views and role defaults are admin only in the handler)
+	mux.HandleFunc("/pre-enrolment/views", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/views handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/views" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		middleware.RequireAnyRole([]string{"admin", "moderator"}, cfg.SessionSecret)(preEnrolmentHandler.SaveListView)(w, r)
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/views -> preEnrolmentHandler.SaveListView [admin, moderator]")
+
+	// /pre-enrolment/shipping/batches/{id}[/manifest] - one shipment batch and its packing manifest, admin only
+	mux.HandleFunc("/pre-enrolment/shipping/batches/", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/shipping/batches/ handler for %s %s", r.Method, r.URL.Path)
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.ShipmentBatch)(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.SaveShipmentBatch)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/shipping/batches/ -> preEnrolmentHandler (ShipmentBatch/SaveShipmentBatch) [admin only]")
+
+	// /pre-enrolment/payers - parents and guardians paying for one or more leads, admin only
+	mux.HandleFunc("/pre-enrolment/payers", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/payers handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/payers" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.Payers)(w, r)
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/payers -> preEnrolmentHandler.Payers [admin only]")
+
+	// /pre-enrolment/payers/{id} - one family: balances, payer details and family payments, admin only
+	mux.HandleFunc("/pre-enrolment/payers/", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/payers/ handler for %s %s", r.Method, r.URL.Path)
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.Payer)(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.SavePayer)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/payers/ -> preEnrolmentHandler (Payer/SavePayer) [admin only]")
+
+	// /pre-enrolment/tasks - follow-up task queue, admin + moderator (reassigning is admin only)
+	mux.HandleFunc("/pre-enrolment/tasks", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/tasks handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/tasks" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin", "moderator"}, cfg.SessionSecret)(preEnrolmentHandler.Tasks)(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.RequireAnyRole([]string{"admin", "moderator"}, cfg.SessionSecret)(preEnrolmentHandler.SaveTask)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/tasks -> preEnrolmentHandler (Tasks/SaveTask) [admin+moderator]")
+
+	// /pre-enrolment/export - CSV/XLSX of the filtered list, admin + moderator (moderators get no pricing columns)
+	mux.HandleFunc("/pre-enrolment/export", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/export handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/export" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin", "moderator"}, cfg.SessionSecret)(preEnrolmentHandler.Export)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/export -> preEnrolmentHandler.Export [admin+moderator]")
+
+	// /pre-enrolment/merge - merge a duplicate lead into another, admin only
+	mux.HandleFunc("/pre-enrolment/merge", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /pre-enrolment/merge handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/pre-enrolment/merge" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.MergeForm)(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(preEnrolmentHandler.Merge)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /pre-enrolment/merge -> preEnrolmentHandler (MergeForm/Merge) [admin only]")
+
 	// Routes with path parameters - handle manually (Go stdlib mux doesn't support {id})
 	// /pre-enrolment/{id} - GET allows admin+moderator, POST/Update/Status admin only
 	mux.HandleFunc("/pre-enrolment/", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
@@ -422,6 +700,17 @@ func main() {
 			return
 		}
 
+		// /pre-enrolment/{id}/offer-letters/{letterID}[.pdf] - stored offer letters, admin + moderator
+		if strings.Contains(r.URL.Path, "/offer-letters/") {
+			if r.Method != http.MethodGet {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			cfg.Debugf("  → Calling preEnrolmentHandler.OfferLetter")
+			middleware.RequireAnyRole([]string{"admin", "moderator"}, cfg.SessionSecret)(preEnrolmentHandler.OfferLetter)(w, r)
+			return
+		}
+
 		if r.Method == http.MethodGet {
 			cfg.Debugf("  → Calling preEnrolmentHandler.Detail")
 			// GET detail - allow admin + moderator (read-only for moderator)
@@ -489,6 +778,38 @@ func main() {
 	}))
 	cfg.Debugf("ROUTE REGISTERED: /classes/move -> classesHandler.Move [admin only]")
 
+	mux.HandleFunc("/classes/waitlist", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /classes/waitlist handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/classes/waitlist" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			cfg.Debugf("  → Calling classesHandler.Waitlist")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(classesHandler.Waitlist)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /classes/waitlist -> classesHandler.Waitlist [GET: admin only]")
+
+	mux.HandleFunc("/classes/waitlist/assign", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /classes/waitlist/assign handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/classes/waitlist/assign" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			cfg.Debugf("  → Calling classesHandler.AssignWaitlistGroup")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(classesHandler.AssignWaitlistGroup)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /classes/waitlist/assign -> classesHandler.AssignWaitlistGroup [POST: admin only]")
+
 	mux.HandleFunc("/classes/start-round", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		cfg.Debugf("HANDLER: /classes/start-round handler for %s %s", r.Method, r.URL.Path)
 		if r.URL.Path != "/classes/start-round" {
@@ -573,6 +894,41 @@ func main() {
 	}))
 	cfg.Debugf("ROUTE REGISTERED: /finance/new-expense -> financeHandler (NewExpenseForm/CreateExpense) [admin only]")
 
+	mux.HandleFunc("/finance/installments", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /finance/installments handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/finance/installments" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			cfg.Debugf("  → Calling financeHandler.SaveInstallmentSettings")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(financeHandler.SaveInstallmentSettings)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /finance/installments -> financeHandler.SaveInstallmentSettings [POST: admin only]")
+
+	mux.HandleFunc("/finance/materials", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /finance/materials handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/finance/materials" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			cfg.Debugf("  → Calling financeHandler.Materials")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(financeHandler.Materials)(w, r)
+		} else if r.Method == http.MethodPost {
+			cfg.Debugf("  → Calling financeHandler.SaveMaterial")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(financeHandler.SaveMaterial)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /finance/materials -> financeHandler (Materials/SaveMaterial) [admin only]")
+
 	// /finance/refund/{leadID} - dynamic route
 	mux.HandleFunc("/finance/refund/", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		cfg.Debugf("HANDLER: /finance/refund/ (dynamic) handler for %s %s", r.Method, r.URL.Path)
@@ -590,6 +946,165 @@ func main() {
 	}))
 	cfg.Debugf("ROUTE REGISTERED: /finance/refund/{leadID} -> financeHandler.CreateRefund [admin only]")
 
+	// Placement test calendar routes
+	mux.HandleFunc("/placement-tests", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /placement-tests handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/placement-tests" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			cfg.Debugf("  → Calling placementTestsHandler.Calendar")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(placementTestsHandler.Calendar)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /placement-tests -> placementTestsHandler.Calendar [admin only]")
+
+	mux.HandleFunc("/placement-tests/slots", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /placement-tests/slots handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/placement-tests/slots" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			cfg.Debugf("  → Calling placementTestsHandler.CreateSlot")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(placementTestsHandler.CreateSlot)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /placement-tests/slots -> placementTestsHandler.CreateSlot [admin only]")
+
+	mux.HandleFunc("/placement-tests/slots/delete", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /placement-tests/slots/delete handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/placement-tests/slots/delete" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			cfg.Debugf("  → Calling placementTestsHandler.DeleteSlot")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(placementTestsHandler.DeleteSlot)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /placement-tests/slots/delete -> placementTestsHandler.DeleteSlot [admin only]")
+
+	mux.HandleFunc("/placement-tests/agenda", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /placement-tests/agenda handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/placement-tests/agenda" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			cfg.Debugf("  → Calling placementTestsHandler.Agenda")
+			middleware.RequireAnyRole([]string{"admin", "mentor_head", "mentor"}, cfg.SessionSecret)(placementTestsHandler.Agenda)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /placement-tests/agenda -> placementTestsHandler.Agenda [admin, mentor_head, mentor (own agenda)]")
+
+	mux.HandleFunc("/placement-tests/questions", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /placement-tests/questions handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/placement-tests/questions" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			cfg.Debugf("  → Calling placementTestsHandler.Questions")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(placementTestsHandler.Questions)(w, r)
+		} else if r.Method == http.MethodPost {
+			cfg.Debugf("  → Calling placementTestsHandler.CreateQuestion")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(placementTestsHandler.CreateQuestion)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /placement-tests/questions -> placementTestsHandler.Questions (GET) / CreateQuestion (POST) [admin only]")
+
+	mux.HandleFunc("/placement-tests/questions/active", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /placement-tests/questions/active handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/placement-tests/questions/active" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			cfg.Debugf("  → Calling placementTestsHandler.SetQuestionActive")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(placementTestsHandler.SetQuestionActive)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /placement-tests/questions/active -> placementTestsHandler.SetQuestionActive [admin only]")
+
+	// Online placement test link (public) - the token in the path is the only credential
+	mux.HandleFunc("/online-test/", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /online-test/ handler for %s %s", r.Method, r.URL.Path)
+		if r.Method == http.MethodGet || r.Method == http.MethodPost {
+			placementTestsHandler.TakeTest(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /online-test/ -> placementTestsHandler.TakeTest [public, token]")
+
+	// Reports routes (admin only)
+	mux.HandleFunc("/reports/funnel", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /reports/funnel handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/reports/funnel" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			cfg.Debugf("  → Calling reportsHandler.Funnel")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(reportsHandler.Funnel)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /reports/funnel -> reportsHandler.Funnel [admin only]")
+
+	mux.HandleFunc("/api/reports/funnel", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /api/reports/funnel handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/api/reports/funnel" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(reportsHandler.FunnelJSON)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /api/reports/funnel -> reportsHandler.FunnelJSON [admin only]")
+
+	mux.HandleFunc("/reports/referrals", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cfg.Debugf("HANDLER: /reports/referrals handler for %s %s", r.Method, r.URL.Path)
+		if r.URL.Path != "/reports/referrals" {
+			cfg.Debugf("  → Path mismatch, returning 404")
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			cfg.Debugf("  → Calling reportsHandler.Referrals")
+			middleware.RequireAnyRole([]string{"admin"}, cfg.SessionSecret)(reportsHandler.Referrals)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	cfg.Debugf("ROUTE REGISTERED: /reports/referrals -> reportsHandler.Referrals [admin only]")
+
 	// Mentor Head routes - redirect to React app (backward compatibility)
 	mux.HandleFunc("/mentor-head", requestLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		cfg.Debugf("HANDLER: /mentor-head redirect for %s %s", r.Method, r.URL.Path)
@@ -909,6 +1424,9 @@ func main() {
 	cfg.Debugf("ROUTE REGISTRATION COMPLETE - All routes registered above")
 	cfg.Debugf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
+	// Background jobs
+	go runOfferExpiryJob()
+
 	// Start server
 	port := cfg.Port
 	if port == "" {
@@ -928,6 +1446,22 @@ func main() {
 	}
 }
 
+// runOfferExpiryJob moves unpaid leads whose offer has expired back to "tested",
+// once at startup and then every hour
+func runOfferExpiryJob() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		moved, err := models.ExpireOffers(time.Now())
+		if err != nil {
+			log.Printf("ERROR: Offer expiry job failed: %v", err)
+		} else if moved > 0 {
+			log.Printf("Offer expiry job: %d lead(s) moved back to tested", moved)
+		}
+		<-ticker.C
+	}
+}
+
 func seedAdminUser(cfg *config.Config) error {
 	// Check if admin user exists
 	_, err := models.GetUserByEmail(cfg.AdminEmail)
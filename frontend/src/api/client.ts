@@ -165,6 +165,20 @@ export interface StudentSuccessClassDetail {
   }
 }
 
+export interface PausedStudent {
+  lead_id: string
+  full_name: string
+  phone: string
+  level?: number
+  class_key?: string
+  reason: string
+  expected_return_date?: string
+  return_due: boolean
+  frozen_credits: number
+  paused_at: string
+  paused_by?: string
+}
+
 export type SubmitFeedbackRequest = {
   lead_id: string
   class_key: string
@@ -306,6 +320,21 @@ export const api = {
       body: JSON.stringify({ lead_id: leadID, class_key: classKey, session_number: sessionNumber, status }),
     }),
 
+  getPausedStudents: (): Promise<{ students: PausedStudent[] }> =>
+    fetchAPI('/student-success/paused'),
+
+  pauseStudent: (leadId: string, reason: string, expectedReturnDate: string = ''): Promise<{ ok: boolean }> =>
+    fetchAPI('/student-success/pause', {
+      method: 'POST',
+      body: JSON.stringify({ lead_id: leadId, reason, expected_return_date: expectedReturnDate }),
+    }),
+
+  resumeStudent: (leadId: string): Promise<{ ok: boolean }> =>
+    fetchAPI('/student-success/resume', {
+      method: 'POST',
+      body: JSON.stringify({ lead_id: leadId }),
+    }),
+
   markAttendance: (
     sessionId: string,
     leadId: string,
@@ -373,6 +402,8 @@ export interface AbsenceFeedItem {
   studentId: string
   studentName: string
   studentPhone: string
+  guardianName?: string
+  guardianPhone?: string
   status: string
   markedBy: string
   markedAt: string
@@ -12,6 +12,13 @@ export default function ClassWorkspace() {
   const [loading, setLoading] = useState(true)
   const [updating, setUpdating] = useState<string | null>(null)
   const [error, setError] = useState<string | null>(null)
+  const [canPause, setCanPause] = useState(false)
+
+  useEffect(() => {
+    api.getMe()
+      .then((me) => setCanPause(me.role === 'mentor_head' || me.role === 'admin' || me.role === 'student_success'))
+      .catch(() => setCanPause(false))
+  }, [])
 
   useEffect(() => {
     if (classKey) {
@@ -70,6 +77,25 @@ export default function ClassWorkspace() {
     }
   }
 
+  async function handlePauseStudent(student: Student) {
+    const reason = prompt(`Pause ${student.full_name}? Their class place is released and remaining credits are frozen.\n\nReason:`)
+    if (reason === null) return
+    if (!reason.trim()) {
+      alert('A pause reason is required.')
+      return
+    }
+    const expectedReturn = prompt('Expected return date (YYYY-MM-DD, optional):') || ''
+    try {
+      setUpdating(`pause-${student.lead_id}`)
+      await api.pauseStudent(student.lead_id, reason.trim(), expectedReturn.trim())
+      await loadClass(true)
+    } catch (err) {
+      alert(err instanceof Error ? err.message : 'Failed to pause student')
+    } finally {
+      setUpdating(null)
+    }
+  }
+
   if (loading && !classData) {
     return (
       <div style={{ padding: '40px', textAlign: 'center' }}>
@@ -242,6 +268,27 @@ export default function ClassWorkspace() {
                       No session selected or available.
                     </div>
                   )}
+
+                  {canPause && (
+                    <button
+                      disabled={updating === `pause-${student.lead_id}`}
+                      onClick={() => handlePauseStudent(student)}
+                      style={{
+                        marginTop: '12px',
+                        width: '100%',
+                        padding: '6px',
+                        borderRadius: '6px',
+                        border: '1px solid #ffc107',
+                        background: 'white',
+                        color: '#856404',
+                        fontWeight: 600,
+                        cursor: 'pointer',
+                        fontSize: '12px',
+                      }}
+                    >
+                      Pause student
+                    </button>
+                  )}
                 </div>
               )
             })}
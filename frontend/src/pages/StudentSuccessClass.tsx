@@ -739,6 +739,9 @@ function AbsenceFeed({ classKey, onOpenFollowUp, refreshNonce, triggerRefresh }:
                       <td style={{ padding: '12px' }}>
                         <div style={{ fontWeight: 600 }}>{item.studentName}</div>
                         <div style={{ fontSize: '12px', color: '#666' }}>{item.studentPhone}</div>
+                        {item.guardianPhone && (
+                          <div style={{ fontSize: '12px', color: '#666' }}>Guardian: {item.guardianName} · {item.guardianPhone}</div>
+                        )}
                       </td>
                       <td style={{ padding: '12px' }}>
                         <span style={{
@@ -788,7 +791,7 @@ function AbsenceFeed({ classKey, onOpenFollowUp, refreshNonce, triggerRefresh }:
                       <td style={{ padding: '12px' }}>
                         <div style={{ display: 'flex', gap: '8px' }}>
                           <a
-                            href={`https://wa.me/${item.studentPhone.replace(/\D/g, '')}`}
+                            href={`https://wa.me/${(item.guardianPhone || item.studentPhone).replace(/\D/g, '')}`}
                             target="_blank"
                             rel="noopener noreferrer"
                             title="Open WhatsApp"
@@ -946,6 +949,9 @@ function FollowUpsTab({ classKey, onOpenFollowUp, refreshNonce }: { classKey: st
                   <td style={{ padding: '12px' }}>
                     <div style={{ fontWeight: 600 }}>{item.student_name}</div>
                     <div style={{ fontSize: '12px', color: '#666' }}>{item.student_phone}</div>
+                    {item.guardian_phone && (
+                      <div style={{ fontSize: '12px', color: '#666' }}>Guardian: {item.guardian_name} · {item.guardian_phone}</div>
+                    )}
                   </td>
                   <td style={{ padding: '12px' }}>S{item.session_number}</td>
                   <td style={{ padding: '12px' }}>{item.attendance_status}</td>
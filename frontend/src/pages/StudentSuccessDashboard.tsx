@@ -1,6 +1,6 @@
 import { useEffect, useState } from 'react'
 import { useNavigate } from 'react-router-dom'
-import { api, type StudentSuccessClass } from '../api/client'
+import { api, type PausedStudent, type StudentSuccessClass } from '../api/client'
 
 interface Group {
   mentor_id?: string
@@ -11,6 +11,8 @@ interface Group {
 
 export default function StudentSuccessDashboard() {
   const [classes, setClasses] = useState<StudentSuccessClass[]>([])
+  const [paused, setPaused] = useState<PausedStudent[]>([])
+  const [resuming, setResuming] = useState<string | null>(null)
   const [loading, setLoading] = useState(true)
   const [error, setError] = useState<string | null>(null)
   const navigate = useNavigate()
@@ -29,8 +31,9 @@ export default function StudentSuccessDashboard() {
         setLoading(false)
         return
       }
-      const data = await api.getStudentSuccessClasses()
+      const [data, pausedData] = await Promise.all([api.getStudentSuccessClasses(), api.getPausedStudents()])
       setClasses(data.classes)
+      setPaused(pausedData.students)
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to load classes')
     } finally {
@@ -38,6 +41,19 @@ export default function StudentSuccessDashboard() {
     }
   }
 
+  async function handleResume(student: PausedStudent) {
+    if (!confirm(`Resume ${student.full_name}? They go back on the classes board for Level ${student.level ?? '?'}.`)) return
+    try {
+      setResuming(student.lead_id)
+      await api.resumeStudent(student.lead_id)
+      setPaused((list) => list.filter((p) => p.lead_id !== student.lead_id))
+    } catch (err) {
+      alert(err instanceof Error ? err.message : 'Failed to resume student')
+    } finally {
+      setResuming(null)
+    }
+  }
+
   function groupByMentor(list: StudentSuccessClass[]): Group[] {
     const mentorMap = new Map<string, Group>()
     const unassigned: Group = { classes: [] }
@@ -164,6 +180,65 @@ export default function StudentSuccessDashboard() {
           ))}
         </div>
       )}
+
+      <h2 style={{ fontSize: '18px', marginTop: '32px', marginBottom: '12px' }}>Paused students</h2>
+      {paused.length === 0 ? (
+        <div style={{ padding: '24px', background: '#f9f9f9', borderRadius: '8px', textAlign: 'center' }}>
+          <p>No paused students.</p>
+        </div>
+      ) : (
+        <table style={{ width: '100%', fontSize: '14px', borderCollapse: 'collapse', background: '#fff' }}>
+          <thead>
+            <tr style={{ borderBottom: '2px solid #E6E6E6', textAlign: 'left' }}>
+              <th style={{ padding: '8px' }}>Student</th>
+              <th style={{ padding: '8px' }}>Level</th>
+              <th style={{ padding: '8px' }}>Reason</th>
+              <th style={{ padding: '8px' }}>Expected back</th>
+              <th style={{ padding: '8px' }}>Frozen credits</th>
+              <th style={{ padding: '8px' }}></th>
+            </tr>
+          </thead>
+          <tbody>
+            {paused.map((p) => (
+              <tr key={p.lead_id} style={{ borderBottom: '1px solid #E6E6E6', background: p.return_due ? '#fff3cd' : undefined }}>
+                <td style={{ padding: '8px' }}>
+                  {p.full_name}
+                  <div style={{ fontSize: '12px', color: '#666' }}>{p.phone}</div>
+                </td>
+                <td style={{ padding: '8px' }}>{p.level ?? '—'}</td>
+                <td style={{ padding: '8px' }}>
+                  {p.reason}
+                  <div style={{ fontSize: '12px', color: '#666' }}>
+                    Since {p.paused_at.substring(0, 10)}{p.paused_by ? ` by ${p.paused_by}` : ''}
+                  </div>
+                </td>
+                <td style={{ padding: '8px' }}>
+                  {p.expected_return_date ?? 'Not set'}
+                  {p.return_due && <div style={{ fontSize: '12px', color: '#856404', fontWeight: 600 }}>Due back</div>}
+                </td>
+                <td style={{ padding: '8px' }}>{p.frozen_credits}</td>
+                <td style={{ padding: '8px' }}>
+                  <button
+                    disabled={resuming === p.lead_id}
+                    onClick={() => handleResume(p)}
+                    style={{
+                      padding: '6px 12px',
+                      background: '#28a745',
+                      color: 'white',
+                      border: 'none',
+                      borderRadius: '4px',
+                      cursor: 'pointer',
+                      fontSize: '13px',
+                    }}
+                  >
+                    Resume
+                  </button>
+                </td>
+              </tr>
+            ))}
+          </tbody>
+        </table>
+      )}
     </div>
   )
 }
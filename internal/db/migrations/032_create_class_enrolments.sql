@@ -0,0 +1,41 @@
+-- Create class_enrolments table: explicit record of which students belong to which class.
+-- Replaces deriving membership from scheduling + placement_tests + class_groups joins,
+-- which silently broke when assigned_level or class_time was edited mid-round.
+CREATE TABLE IF NOT EXISTS class_enrolments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    lead_id UUID NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    class_key TEXT NOT NULL REFERENCES class_groups(class_key) ON DELETE CASCADE,
+    round INTEGER,
+    joined_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    left_at TIMESTAMP WITH TIME ZONE,
+    left_reason TEXT, -- moved | round_closed | removed
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A student can only be actively enrolled in one class at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_class_enrolments_active_lead ON class_enrolments(lead_id) WHERE left_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_class_enrolments_class_key ON class_enrolments(class_key);
+CREATE INDEX IF NOT EXISTS idx_class_enrolments_lead_id ON class_enrolments(lead_id);
+
+-- Backfill from the previous join logic for every class whose round has started.
+-- Students in closed rounds are recorded as having left with reason 'round_closed'.
+INSERT INTO class_enrolments (lead_id, class_key, round, joined_at, left_at, left_reason)
+SELECT DISTINCT ON (s.lead_id)
+    s.lead_id,
+    cg.class_key,
+    CASE WHEN s.expected_round ~ '^[0-9]+$' THEN s.expected_round::INTEGER END,
+    COALESCE(cg.round_started_at, cg.updated_at, CURRENT_TIMESTAMP),
+    CASE WHEN cg.round_status = 'closed' THEN COALESCE(cg.round_closed_at, CURRENT_TIMESTAMP) END,
+    CASE WHEN cg.round_status = 'closed' THEN 'round_closed' END
+FROM scheduling s
+INNER JOIN placement_tests pt ON pt.lead_id = s.lead_id
+INNER JOIN class_groups cg ON (
+    cg.level = pt.assigned_level
+    AND cg.class_days = s.class_days
+    AND cg.class_time = s.class_time::text
+    AND COALESCE(cg.class_number, 1) = COALESCE(s.class_group_index, 1)
+)
+WHERE cg.round_status IN ('active', 'closed')
+   OR EXISTS (SELECT 1 FROM class_sessions cs WHERE cs.class_key = cg.class_key)
+ORDER BY s.lead_id, (cg.round_status = 'closed'), cg.round_started_at DESC NULLS LAST;
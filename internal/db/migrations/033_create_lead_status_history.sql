@@ -0,0 +1,19 @@
+-- Create lead_status_history table: one row per lead status change, with who/why
+CREATE TABLE IF NOT EXISTS lead_status_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    lead_id UUID NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    previous_status TEXT,
+    new_status TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT 'manual' CHECK (source IN ('manual', 'auto')),
+    reason TEXT,
+    changed_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_lead_status_history_lead_id ON lead_status_history(lead_id, changed_at);
+
+-- Seed one entry per existing lead so the timeline starts from its current status
+INSERT INTO lead_status_history (lead_id, previous_status, new_status, source, reason, changed_by_user_id, changed_at)
+SELECT l.id, NULL, l.status, 'auto', 'Status at history start', NULL, COALESCE(l.updated_at, l.created_at, CURRENT_TIMESTAMP)
+FROM leads l
+WHERE NOT EXISTS (SELECT 1 FROM lead_status_history h WHERE h.lead_id = l.id);
@@ -0,0 +1,15 @@
+-- Create lead_merges table: audit trail for admin merges of duplicate leads.
+-- The merged lead is deleted, so its id has no foreign key and its data is kept as a snapshot.
+CREATE TABLE IF NOT EXISTS lead_merges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    survivor_lead_id UUID REFERENCES leads(id) ON DELETE SET NULL,
+    merged_lead_id UUID NOT NULL,
+    merged_lead_snapshot JSONB NOT NULL, -- lead fields of the removed lead at merge time
+    field_choices JSONB NOT NULL,        -- which side each field/section was taken from
+    moved_rows JSONB NOT NULL,           -- rows re-parented per table
+    merged_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    merged_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_lead_merges_survivor ON lead_merges(survivor_lead_id);
+CREATE INDEX IF NOT EXISTS idx_lead_merges_merged ON lead_merges(merged_lead_id);
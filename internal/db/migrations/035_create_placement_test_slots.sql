@@ -0,0 +1,19 @@
+-- Create placement_test_slots table: bookable placement test times per examiner.
+-- Leads book a slot (placement_tests.slot_id); the slot's examiner becomes run_by_user_id.
+CREATE TABLE IF NOT EXISTS placement_test_slots (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    slot_date DATE NOT NULL,
+    slot_time TIME NOT NULL,
+    test_type TEXT NOT NULL CHECK (test_type IN ('online', 'live')),
+    examiner_user_id UUID NOT NULL REFERENCES users(id),
+    capacity INTEGER NOT NULL DEFAULT 1 CHECK (capacity >= 1),
+    notes TEXT,
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (examiner_user_id, slot_date, slot_time) -- overlapping slots are also rejected in the app
+);
+
+CREATE INDEX IF NOT EXISTS idx_placement_test_slots_date ON placement_test_slots(slot_date, slot_time);
+
+ALTER TABLE placement_tests ADD COLUMN IF NOT EXISTS slot_id UUID REFERENCES placement_test_slots(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_placement_tests_slot_id ON placement_tests(slot_id);
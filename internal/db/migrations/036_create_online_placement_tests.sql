@@ -0,0 +1,40 @@
+-- Online placement test engine: question bank, tokenised test attempts, and the scored result
+-- stored against placement_tests until an admin approves the proposed level.
+CREATE TABLE IF NOT EXISTS placement_questions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    skill TEXT NOT NULL CHECK (skill IN ('grammar', 'vocabulary', 'reading')),
+    level INTEGER NOT NULL CHECK (level >= 1 AND level <= 8),
+    prompt TEXT NOT NULL,
+    options JSONB NOT NULL, -- array of answer strings, in display order
+    correct_option INTEGER NOT NULL CHECK (correct_option >= 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_placement_questions_level ON placement_questions(level, skill) WHERE active;
+
+CREATE TABLE IF NOT EXISTS online_placement_tests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    lead_id UUID NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'in_progress', 'submitted', 'approved', 'rejected', 'revoked')),
+    question_ids JSONB NOT NULL, -- questions drawn for this attempt, in display order
+    answers JSONB,               -- question id -> chosen option index
+    duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL, -- link must be opened before this
+    started_at TIMESTAMP WITH TIME ZONE,
+    submitted_at TIMESTAMP WITH TIME ZONE,
+    score_breakdown JSONB,
+    proposed_level INTEGER CHECK (proposed_level IS NULL OR (proposed_level >= 1 AND proposed_level <= 8)),
+    approved_level INTEGER CHECK (approved_level IS NULL OR (approved_level >= 1 AND approved_level <= 8)),
+    reviewed_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_online_placement_tests_lead_id ON online_placement_tests(lead_id, created_at DESC);
+
+ALTER TABLE placement_tests ADD COLUMN IF NOT EXISTS proposed_level INTEGER CHECK (proposed_level IS NULL OR (proposed_level >= 1 AND proposed_level <= 8));
+ALTER TABLE placement_tests ADD COLUMN IF NOT EXISTS score_breakdown JSONB;
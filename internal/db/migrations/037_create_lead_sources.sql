@@ -0,0 +1,32 @@
+-- Create lead_sources table: leads.source becomes a managed list instead of free text.
+-- Renaming a source renames it on every lead (ON UPDATE CASCADE); sources are retired, never deleted.
+CREATE TABLE IF NOT EXISTS lead_sources (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE CHECK (name = TRIM(name) AND name <> ''),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO lead_sources (name, sort_order) VALUES
+    ('Facebook', 10), ('WhatsApp', 20), ('Instagram', 30), ('Referral', 40),
+    ('Walk-in', 50), ('Admin', 60), ('Other', 1000)
+ON CONFLICT (name) DO NOTHING;
+
+-- Normalise existing free text onto the managed names (case-insensitive), blank -> NULL
+UPDATE leads SET source = NULLIF(TRIM(source), '') WHERE source IS DISTINCT FROM NULLIF(TRIM(source), '');
+UPDATE leads l SET source = s.name
+FROM lead_sources s
+WHERE LOWER(l.source) = LOWER(s.name) AND l.source <> s.name;
+
+-- Anything else is kept as a retired source so history is not lost
+INSERT INTO lead_sources (name, active, sort_order)
+SELECT DISTINCT l.source, FALSE, 900
+FROM leads l
+WHERE l.source IS NOT NULL
+ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE leads DROP CONSTRAINT IF EXISTS leads_source_fkey;
+ALTER TABLE leads ADD CONSTRAINT leads_source_fkey FOREIGN KEY (source) REFERENCES lead_sources(name) ON UPDATE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_leads_source_created_at ON leads(source, created_at);
@@ -0,0 +1,21 @@
+-- Create lead_tasks table: follow-up tasks for unpaid tested/offer-sent leads.
+-- Tasks are created automatically when a lead enters tested/offer_sent unpaid and closed
+-- automatically when it moves on; completed tasks with a contact outcome drive HotLevel.
+CREATE TABLE IF NOT EXISTS lead_tasks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    lead_id UUID NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    task_type TEXT NOT NULL CHECK (task_type IN ('call', 'whatsapp', 'offer_discount')),
+    due_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    assignee_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    notes TEXT,
+    outcome TEXT CHECK (outcome IN ('reached', 'callback', 'no_answer', 'not_interested', 'closed')),
+    completed_at TIMESTAMP WITH TIME ZONE,
+    completed_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    auto_created BOOLEAN NOT NULL DEFAULT false,
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((outcome IS NULL) = (completed_at IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_lead_tasks_lead_id ON lead_tasks(lead_id);
+CREATE INDEX IF NOT EXISTS idx_lead_tasks_open_due ON lead_tasks(assignee_user_id, due_at) WHERE completed_at IS NULL;
@@ -0,0 +1,22 @@
+-- Create lead_pauses table: one row per pause of a paid student (status 'paused').
+-- Pausing closes the active class enrolment and snapshots the remaining level credits;
+-- resuming ends the pause and puts the student back on the classes board for their level.
+CREATE TABLE IF NOT EXISTS lead_pauses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    lead_id UUID NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    previous_status TEXT NOT NULL,
+    class_key TEXT, -- class the student left, NULL when paused before a round started
+    reason TEXT NOT NULL,
+    expected_return_date DATE,
+    frozen_credits INTEGER NOT NULL DEFAULT 0, -- levels_purchased_total - levels_consumed at pause time
+    paused_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    paused_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    ended_at TIMESTAMP WITH TIME ZONE,
+    ended_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    outcome TEXT CHECK (outcome IN ('resumed', 'cancelled', 'merged')),
+    CHECK ((outcome IS NULL) = (ended_at IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_lead_pauses_lead_id ON lead_pauses(lead_id);
+-- At most one open pause per lead
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lead_pauses_open ON lead_pauses(lead_id) WHERE ended_at IS NULL;
@@ -0,0 +1,40 @@
+-- Create price_plans: published course price lists. The plan in effect is the latest one whose
+-- effective_from is on or before today; plans are never edited once published, a new list is
+-- published instead. Offers snapshot the plan they were priced from.
+CREATE TABLE IF NOT EXISTS price_plans (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CHECK (name = TRIM(name) AND name <> ''),
+    currency TEXT NOT NULL DEFAULT 'EGP' CHECK (currency ~ '^[A-Z]{3}$'),
+    effective_from DATE NOT NULL,
+    per_level_price INTEGER NOT NULL CHECK (per_level_price > 0), -- price of each level not covered by a bundle price
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_plans_effective_from ON price_plans(effective_from DESC, created_at DESC);
+
+-- Bundle prices of a plan (offers.bundle_levels 1..4); a missing row means levels * per_level_price
+CREATE TABLE IF NOT EXISTS price_plan_bundles (
+    plan_id UUID NOT NULL REFERENCES price_plans(id) ON DELETE CASCADE,
+    levels INTEGER NOT NULL CHECK (levels BETWEEN 1 AND 4),
+    price INTEGER NOT NULL CHECK (price > 0),
+    PRIMARY KEY (plan_id, levels)
+);
+
+-- Baseline: the prices that were hard-coded until now
+WITH baseline AS (
+    INSERT INTO price_plans (name, currency, effective_from, per_level_price)
+    SELECT 'Standard prices', 'EGP', DATE '2000-01-01', 1300
+    WHERE NOT EXISTS (SELECT 1 FROM price_plans)
+    RETURNING id
+)
+INSERT INTO price_plan_bundles (plan_id, levels, price)
+SELECT baseline.id, b.levels, b.price
+FROM baseline
+CROSS JOIN (VALUES (1, 1300), (2, 2400), (3, 3300), (4, 4000)) AS b(levels, price);
+
+ALTER TABLE offers ADD COLUMN IF NOT EXISTS price_plan_id UUID REFERENCES price_plans(id);
+
+-- Existing priced offers were priced from the baseline
+UPDATE offers SET price_plan_id = (SELECT id FROM price_plans ORDER BY effective_from, created_at LIMIT 1)
+WHERE price_plan_id IS NULL AND bundle_levels IS NOT NULL;
@@ -0,0 +1,40 @@
+-- Create promo_codes: pre-approved discounts admins can apply to offers. A code is redeemed when
+-- the offer is sent; max_uses caps redemptions. promo_code_bundles lists the bundle sizes a code
+-- applies to (no rows = every bundle).
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code TEXT NOT NULL UNIQUE CHECK (code = UPPER(TRIM(code)) AND code <> ''),
+    discount_type TEXT NOT NULL CHECK (discount_type IN ('amount', 'percent')),
+    discount_value INTEGER NOT NULL CHECK (discount_value > 0),
+    valid_from DATE NOT NULL,
+    valid_until DATE, -- inclusive, NULL = open-ended
+    max_uses INTEGER CHECK (max_uses > 0), -- NULL = unlimited
+    source TEXT REFERENCES lead_sources(name) ON UPDATE CASCADE, -- lead source the campaign runs on
+    campaign TEXT,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (discount_type <> 'percent' OR discount_value <= 100),
+    CHECK (valid_until IS NULL OR valid_until >= valid_from)
+);
+
+CREATE TABLE IF NOT EXISTS promo_code_bundles (
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    levels INTEGER NOT NULL CHECK (levels BETWEEN 1 AND 4),
+    PRIMARY KEY (promo_code_id, levels)
+);
+
+-- Redemption and discount approval on the offer. discount_set_by_user_id is whoever last changed
+-- the price or discount; a manual discount above the threshold needs approval from another admin,
+-- which is cleared whenever the price or discount changes again.
+ALTER TABLE offers ADD COLUMN IF NOT EXISTS promo_code_id UUID REFERENCES promo_codes(id);
+ALTER TABLE offers ADD COLUMN IF NOT EXISTS promo_redeemed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE offers ADD COLUMN IF NOT EXISTS discount_set_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE offers ADD COLUMN IF NOT EXISTS discount_approved_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE offers ADD COLUMN IF NOT EXISTS discount_approved_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_offers_promo_code_id ON offers(promo_code_id) WHERE promo_code_id IS NOT NULL;
+
+-- Manual discounts above this share of the base price need a second admin's approval
+INSERT INTO settings (key, value) VALUES ('discount_approval_threshold_percent', '20')
+ON CONFLICT (key) DO NOTHING;
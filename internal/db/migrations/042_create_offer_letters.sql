@@ -0,0 +1,29 @@
+-- Create offer_letters: every offer document issued to a lead, kept as sent (HTML and PDF).
+-- A letter is issued each time the offer is sent; the newest one sets offers.expires_on.
+CREATE TABLE IF NOT EXISTS offer_letters (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    lead_id UUID NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL CHECK (version > 0), -- 1, 2, ... per lead in issue order
+    bundle_levels INTEGER,
+    assigned_level INTEGER,
+    final_price INTEGER NOT NULL,
+    currency TEXT NOT NULL,
+    expires_on DATE NOT NULL, -- last day the offer can be taken up
+    html TEXT NOT NULL,
+    pdf BYTEA NOT NULL,
+    issued_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    issued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_offer_letters_lead_id ON offer_letters(lead_id, issued_at DESC);
+
+-- Expiry of the latest letter; the offer expiry job moves unpaid leads past it back to 'tested'
+ALTER TABLE offers ADD COLUMN IF NOT EXISTS expires_on DATE;
+
+CREATE INDEX IF NOT EXISTS idx_offers_expires_on ON offers(expires_on) WHERE expires_on IS NOT NULL;
+
+-- Days an offer stays open, and the payment instructions printed on the letter
+INSERT INTO settings (key, value) VALUES
+    ('offer_validity_days', '7'),
+    ('offer_payment_instructions', 'Vodafone Cash, bank transfer or PayPal. Send the payment receipt on WhatsApp with your full name.')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,21 @@
+-- Create lead_installments: the schedule a student pays their offer by. Installments add up to the
+-- offer's final price. Net course payments are matched to installments in due-date order, so
+-- paid_amount is recomputed after every payment and refund.
+CREATE TABLE IF NOT EXISTS lead_installments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    lead_id UUID NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    seq INTEGER NOT NULL CHECK (seq > 0),
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    due_date DATE NOT NULL,
+    paid_amount INTEGER NOT NULL DEFAULT 0 CHECK (paid_amount >= 0 AND paid_amount <= amount),
+    paid_at TIMESTAMP WITH TIME ZONE, -- set when paid_amount reaches amount
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (lead_id, seq)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lead_installments_unpaid_due ON lead_installments(due_date) WHERE paid_at IS NULL;
+
+-- When true, an overdue installment blocks marking the student ready and sending them to classes
+INSERT INTO settings (key, value) VALUES ('installment_overdue_blocks_classes', 'true')
+ON CONFLICT (key) DO NOTHING;
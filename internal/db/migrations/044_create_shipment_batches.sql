@@ -0,0 +1,31 @@
+-- Create shipment_batches: printed books packed together and handed to one courier. A batch is
+-- packed first (tracking numbers are filled in while packing), then sent as a whole; sending posts
+-- the shipping cost as an OUT transaction (ref_key shipment_batch:<id>).
+CREATE TABLE IF NOT EXISTS shipment_batches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    batch_number INTEGER NOT NULL UNIQUE CHECK (batch_number > 0),
+    courier TEXT NOT NULL CHECK (courier IN ('bosta', 'aramex', 'egypt_post', 'mylerz', 'other')),
+    status TEXT NOT NULL DEFAULT 'packing' CHECK (status IN ('packing', 'sent')),
+    shipping_cost INTEGER CHECK (shipping_cost >= 0), -- set when sent
+    payment_method TEXT CHECK (payment_method IN ('vodafone_cash', 'bank_transfer', 'paypal', 'other')),
+    sent_on DATE,
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (status <> 'sent' OR (shipping_cost IS NOT NULL AND sent_on IS NOT NULL))
+);
+
+-- A lead's shipment joins a batch when packed. ship_to_* snapshot the booking address at packing
+-- time so the manifest shows where the parcel actually went. shipment_date is the date sent.
+ALTER TABLE shipping ADD COLUMN IF NOT EXISTS batch_id UUID REFERENCES shipment_batches(id) ON DELETE SET NULL;
+ALTER TABLE shipping ADD COLUMN IF NOT EXISTS tracking_number TEXT;
+ALTER TABLE shipping ADD COLUMN IF NOT EXISTS ship_to_address TEXT;
+ALTER TABLE shipping ADD COLUMN IF NOT EXISTS ship_to_city TEXT;
+ALTER TABLE shipping ADD COLUMN IF NOT EXISTS packed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE shipping ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE shipping ADD COLUMN IF NOT EXISTS returned_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE shipping DROP CONSTRAINT IF EXISTS shipping_shipment_status_check;
+ALTER TABLE shipping ADD CONSTRAINT shipping_shipment_status_check
+    CHECK (shipment_status IN ('pending', 'packed', 'sent', 'delivered', 'returned'));
+
+CREATE INDEX IF NOT EXISTS idx_shipping_batch_id ON shipping(batch_id) WHERE batch_id IS NOT NULL;
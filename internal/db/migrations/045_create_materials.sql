@@ -0,0 +1,34 @@
+-- Create materials: the book catalogue, one item per level and format. Stock on hand is the sum of
+-- material_stock_movements.quantity; only printed books are stocked.
+CREATE TABLE IF NOT EXISTS materials (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    level INTEGER NOT NULL CHECK (level BETWEEN 1 AND 8),
+    format TEXT NOT NULL CHECK (format IN ('printed', 'pdf')),
+    name TEXT NOT NULL CHECK (name <> ''),
+    unit_cost INTEGER NOT NULL DEFAULT 0 CHECK (unit_cost >= 0),
+    low_stock_threshold INTEGER NOT NULL DEFAULT 5 CHECK (low_stock_threshold >= 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (level, format)
+);
+
+-- Stock movements: purchases and returns add stock, shipments take it out, adjustments correct a
+-- count. Shipment and return movements carry ref_key shipment:<leadID>:<batchID> /
+-- return:<leadID>:<batchID> so sending or returning a book never moves stock twice.
+CREATE TABLE IF NOT EXISTS material_stock_movements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    material_id UUID NOT NULL REFERENCES materials(id) ON DELETE CASCADE,
+    movement_type TEXT NOT NULL CHECK (movement_type IN ('purchase', 'shipment', 'return', 'adjustment')),
+    quantity INTEGER NOT NULL CHECK (quantity <> 0),
+    unit_cost INTEGER CHECK (unit_cost >= 0), -- purchases only
+    lead_id UUID REFERENCES leads(id) ON DELETE SET NULL,
+    ref_key TEXT UNIQUE,
+    notes TEXT,
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (movement_type NOT IN ('purchase', 'return') OR quantity > 0),
+    CHECK (movement_type <> 'shipment' OR quantity < 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_material_stock_movements_material ON material_stock_movements(material_id, created_at);
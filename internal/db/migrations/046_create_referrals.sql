@@ -0,0 +1,30 @@
+-- Referrals: who referred a lead, the discount the referred lead's offer got for it, and the
+-- rewards earned by referrers. Reward amounts live in settings (referral_discount_amount,
+-- referral_reward_type, referral_reward_amount).
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS referred_by_lead_id UUID REFERENCES leads(id) ON DELETE SET NULL;
+ALTER TABLE leads DROP CONSTRAINT IF EXISTS leads_referred_by_not_self;
+ALTER TABLE leads ADD CONSTRAINT leads_referred_by_not_self CHECK (referred_by_lead_id <> id);
+
+CREATE INDEX IF NOT EXISTS idx_leads_referred_by ON leads(referred_by_lead_id) WHERE referred_by_lead_id IS NOT NULL;
+
+-- referral_discount: taken off a referred lead's offer; referral_credit: the lead's own referral
+-- credit applied to their offer. Both are already deducted from final_price.
+ALTER TABLE offers ADD COLUMN IF NOT EXISTS referral_discount INTEGER CHECK (referral_discount > 0);
+ALTER TABLE offers ADD COLUMN IF NOT EXISTS referral_credit INTEGER CHECK (referral_credit > 0);
+
+-- One reward per referred lead, issued when it reaches paid_full. A credit is settled when it is
+-- applied to the referrer's offer; a cash-back when it is paid out (finance transaction
+-- category 'referral', ref_key referral:<rewardID>).
+CREATE TABLE IF NOT EXISTS referral_rewards (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    referrer_lead_id UUID NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    referred_lead_id UUID NOT NULL UNIQUE REFERENCES leads(id) ON DELETE CASCADE,
+    reward_type TEXT NOT NULL CHECK (reward_type IN ('credit', 'cashback')),
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    issued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    settled_at TIMESTAMP WITH TIME ZONE,
+    payment_method TEXT CHECK (payment_method IN ('vodafone_cash', 'bank_transfer', 'paypal', 'other')),
+    settled_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_referral_rewards_referrer ON referral_rewards(referrer_lead_id);
@@ -0,0 +1,34 @@
+-- Round outcomes: when a round closes, each student's enrolment records whether they move up a
+-- level or repeat it (more than 2 absences or an F at session 8), the level they continue at, and
+-- what happened next: replaced (back on the classes board, they had credits), renewal_offer (no
+-- credits left, a renewal offer was opened), completed (passed the last level) or skipped (the
+-- lead could not be moved, e.g. cancelled or missing a class schedule).
+ALTER TABLE class_enrolments ADD COLUMN IF NOT EXISTS outcome TEXT CHECK (outcome IN ('promote', 'repeat'));
+ALTER TABLE class_enrolments ADD COLUMN IF NOT EXISTS absences INTEGER CHECK (absences >= 0);
+ALTER TABLE class_enrolments ADD COLUMN IF NOT EXISTS grade TEXT;
+ALTER TABLE class_enrolments ADD COLUMN IF NOT EXISTS next_level INTEGER CHECK (next_level BETWEEN 1 AND 8);
+ALTER TABLE class_enrolments ADD COLUMN IF NOT EXISTS follow_on TEXT CHECK (follow_on IN ('replaced', 'renewal_offer', 'completed', 'skipped'));
+
+-- Renewal offers: pre-filled for students who finish a round without credits. Priced from the price
+-- list in effect when the round closed; accepting one records the payment (finance transaction
+-- category course_payment, ref_key renewal:<id>), adds the bundle's levels to the student's credits
+-- and puts them back on the classes board.
+CREATE TABLE IF NOT EXISTS renewal_offers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    lead_id UUID NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    class_key TEXT NOT NULL, -- the round it follows
+    level INTEGER NOT NULL CHECK (level BETWEEN 1 AND 8),
+    bundle_levels INTEGER NOT NULL CHECK (bundle_levels BETWEEN 1 AND 4),
+    price_plan_id UUID REFERENCES price_plans(id) ON DELETE SET NULL,
+    base_price INTEGER CHECK (base_price >= 0), -- NULL when no price list was in effect
+    final_price INTEGER CHECK (final_price > 0), -- set when accepted
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'accepted', 'declined', 'merged')),
+    payment_method TEXT CHECK (payment_method IN ('vodafone_cash', 'bank_transfer', 'paypal', 'other')),
+    payment_date DATE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP WITH TIME ZONE,
+    closed_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_renewal_offers_open_lead ON renewal_offers(lead_id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_renewal_offers_lead ON renewal_offers(lead_id);
@@ -0,0 +1,8 @@
+-- Archived leads: hidden from the lead list, the waiting list and the referrer picker, but every
+-- child row and finance transaction stays linked. Restoring clears all three columns. Leads are
+-- only removed for good by the admin retention purge (archived long enough, no finance history).
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS archived_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS archive_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_leads_archived_at ON leads(archived_at) WHERE archived_at IS NOT NULL;
@@ -0,0 +1,10 @@
+-- Lead list search: name, phone and notes are matched with ILIKE/LIKE '%term%', which trigram GIN
+-- indexes can serve. The list pages and sorts in SQL; its default order (newest first, archived
+-- leads left out) gets a partial index.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_leads_full_name_trgm ON leads USING gin (full_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_leads_phone_trgm ON leads USING gin (phone gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_leads_notes_trgm ON leads USING gin (notes gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_leads_active_created_at ON leads(created_at DESC) WHERE archived_at IS NULL;
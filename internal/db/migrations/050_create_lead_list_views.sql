@@ -0,0 +1,23 @@
+-- Saved views of the pre-enrolment list: a name, the list's filters and sort, and which optional
+-- columns to show. A view with no owner is shared with everyone; only shared views can be a
+-- role's default, which opens when that role visits the list without filters.
+CREATE TABLE IF NOT EXISTS lead_list_views (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    owner_user_id UUID REFERENCES users(id) ON DELETE CASCADE, -- NULL: shared
+    status TEXT NOT NULL DEFAULT '',
+    search TEXT NOT NULL DEFAULT '',
+    payment TEXT NOT NULL DEFAULT '',
+    hot TEXT NOT NULL DEFAULT '',
+    follow_up TEXT NOT NULL DEFAULT '',
+    include_cancelled BOOLEAN NOT NULL DEFAULT false,
+    sort TEXT NOT NULL DEFAULT '',
+    columns TEXT NOT NULL DEFAULT '', -- comma-separated column keys, in display order
+    default_for_role TEXT CHECK (default_for_role IN ('admin', 'moderator')),
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT lead_list_views_default_shared CHECK (default_for_role IS NULL OR owner_user_id IS NULL)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lead_list_views_role_default ON lead_list_views(default_for_role) WHERE default_for_role IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_lead_list_views_owner ON lead_list_views(owner_user_id);
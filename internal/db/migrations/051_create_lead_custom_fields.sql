@@ -0,0 +1,27 @@
+-- Custom lead fields: admin-defined extra data per campaign (age, occupation, goal, preferred
+-- contact time, ...) kept per lead instead of in notes. A field with required_from_stage must be
+-- filled before a lead moves forward into that stage or a later one. Fields are retired, never
+-- deleted, so existing values are kept; field_key and field_type are fixed once created.
+CREATE TABLE IF NOT EXISTS lead_custom_fields (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    field_key TEXT NOT NULL UNIQUE CHECK (field_key ~ '^[a-z][a-z0-9_]*$'), -- form and filter parameter cf_<key>
+    label TEXT NOT NULL CHECK (label = TRIM(label) AND label <> ''),
+    field_type TEXT NOT NULL CHECK (field_type IN ('text', 'number', 'date', 'select', 'boolean')),
+    options TEXT NOT NULL DEFAULT '', -- select fields: one option per line
+    required_from_stage TEXT CHECK (required_from_stage IN ('NEW_LEAD', 'TEST_BOOKED', 'TESTED', 'OFFER_SENT', 'BOOKING_CONFIRMED_DEPOSIT', 'BOOKING_CONFIRMED_PAID_FULL', 'SCHEDULE_SET', 'READY_TO_START')),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One value per lead and field, stored as text in the field type's canonical form
+-- (numbers as written by strconv, dates as YYYY-MM-DD, booleans as yes/no). Blank values are deleted.
+CREATE TABLE IF NOT EXISTS lead_custom_values (
+    lead_id UUID NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    field_id UUID NOT NULL REFERENCES lead_custom_fields(id) ON DELETE CASCADE,
+    value TEXT NOT NULL CHECK (value <> ''),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (lead_id, field_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lead_custom_values_field ON lead_custom_values(field_id, value);
@@ -0,0 +1,34 @@
+-- Payers: the parent or guardian who pays for one or more leads (siblings enrolled together).
+-- A lead has at most one payer; the payer's phone is the contact for absence follow-ups.
+CREATE TABLE IF NOT EXISTS payers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    full_name TEXT NOT NULL CHECK (full_name = TRIM(full_name) AND full_name <> ''),
+    phone TEXT NOT NULL UNIQUE CHECK (phone <> ''),
+    relationship TEXT NOT NULL DEFAULT '', -- e.g. mother, father, guardian
+    notes TEXT NOT NULL DEFAULT '',
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS payer_id UUID REFERENCES payers(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_leads_payer_id ON leads(payer_id) WHERE payer_id IS NOT NULL;
+
+-- One payment received from a payer. It is split across the children's balances as ordinary
+-- lead_payments (each with its own IN transaction), so per-lead balances, refunds and
+-- installments work unchanged.
+CREATE TABLE IF NOT EXISTS payer_payments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    payer_id UUID NOT NULL REFERENCES payers(id) ON DELETE CASCADE,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    payment_method TEXT NOT NULL CHECK (payment_method IN ('vodafone_cash', 'bank_transfer', 'paypal', 'other')),
+    payment_date DATE NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payer_payments_payer ON payer_payments(payer_id, payment_date DESC);
+
+ALTER TABLE lead_payments ADD COLUMN IF NOT EXISTS payer_payment_id UUID REFERENCES payer_payments(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_lead_payments_payer_payment ON lead_payments(payer_payment_id) WHERE payer_payment_id IS NOT NULL;
@@ -2082,3 +2082,32 @@ func (h *APIHandler) UpdateFeedbackStatus(w http.ResponseWriter, r *http.Request
 
 	jsonResponse(w, http.StatusOK, map[string]string{"status": "success"})
 }
+
+// GET /api/leads/{leadID}/status-history - returns the lead's status timeline
+func (h *APIHandler) GetLeadStatusHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	path := r.URL.Path
+	if !strings.HasPrefix(path, "/api/leads/") || !strings.HasSuffix(path, "/status-history") {
+		jsonError(w, http.StatusBadRequest, "Invalid path")
+		return
+	}
+	leadIDStr := strings.TrimSuffix(strings.TrimPrefix(path, "/api/leads/"), "/status-history")
+	leadID, err := uuid.Parse(leadIDStr)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid lead ID")
+		return
+	}
+
+	history, err := models.GetLeadStatusHistory(leadID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get status history for lead %s: %v", leadID, err)
+		jsonError(w, http.StatusInternalServerError, "Failed to load status history")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, history)
+}
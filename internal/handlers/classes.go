@@ -207,7 +207,7 @@ func (h *ClassesHandler) StartRound(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := models.StartRound()
+	err := models.StartRound(middleware.GetUserID(r))
 	if err != nil {
 		log.Printf("ERROR: Failed to start round: %v", err)
 		http.Error(w, fmt.Sprintf("Failed to start round: %v", err), http.StatusInternalServerError)
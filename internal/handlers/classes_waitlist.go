@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+)
+
+// Waitlist renders the waiting list grouped by level and preferred schedule, with the class groups
+// that waiting students could fill now (admin only)
+func (h *ClassesHandler) Waitlist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	students, err := models.GetWaitlist()
+	if err != nil {
+		log.Printf("ERROR: Failed to get waitlist: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load waitlist: %v", err), http.StatusInternalServerError)
+		return
+	}
+	suggestions := models.SuggestClassGroups(students, models.FullClassGroupSize)
+	groups := models.GroupWaitlist(students)
+
+	data := map[string]interface{}{
+		"Title":          "Waiting List - Eighty Twenty",
+		"Groups":         groups,
+		"Suggestions":    suggestions,
+		"StudentCount":   len(students),
+		"GroupSize":      models.FullClassGroupSize,
+		"UserRole":       middleware.GetUserRole(r),
+		"IsModerator":    IsModerator(r),
+		"Error":          r.URL.Query().Get("error"),
+		"SuccessMessage": r.URL.Query().Get("saved"),
+	}
+	renderTemplate(w, r, "classes_waitlist.html", data)
+}
+
+// AssignWaitlistGroup schedules a suggested group into its slot and puts it on the classes board (admin only)
+func (h *ClassesHandler) AssignWaitlistGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fail := func(msg string) {
+		http.Redirect(w, r, "/classes/waitlist?"+url.Values{"error": {msg}}.Encode(), http.StatusFound)
+	}
+
+	level, err := strconv.Atoi(r.FormValue("level"))
+	if err != nil || level < 1 || level > 8 {
+		http.Error(w, "Invalid level", http.StatusBadRequest)
+		return
+	}
+	classDays, classTime := r.FormValue("class_days"), r.FormValue("class_time")
+
+	assigned, err := models.AssignSuggestedGroup(int32(level), classDays, classTime, middleware.GetUserRole(r), middleware.GetUserID(r))
+	var waitlistErr *models.WaitlistError
+	if errors.As(err, &waitlistErr) {
+		fail(waitlistErr.Error())
+		return
+	}
+	var transitionErr *models.LeadTransitionError
+	if errors.As(err, &transitionErr) {
+		fail("A student could not be marked ready: " + transitionErr.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to assign waitlist group: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to assign group: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	h.cfg.Debugf("  ✅ Waitlist group assigned: level %d %s %s, %d students", level, classDays, classTime, len(assigned))
+	msg := fmt.Sprintf("%d students scheduled for Level %d %s %s and sent to the classes board.", len(assigned), level, classDays, classTime)
+	http.Redirect(w, r, "/classes/waitlist?"+url.Values{"saved": {msg}}.Encode(), http.StatusFound)
+}
@@ -129,10 +129,34 @@ func (h *FinanceHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Installments due before today with money outstanding
+	overdueInstallments, err := models.GetOverdueInstallments(time.Now())
+	if err != nil {
+		log.Printf("ERROR: Failed to get overdue installments: %v", err)
+		overdueInstallments = []*models.OverdueInstallment{}
+	}
+	var overdueInstallmentsTotal int32
+	for _, inst := range overdueInstallments {
+		overdueInstallmentsTotal += inst.Outstanding
+	}
+	installmentOverdueBlock, err := models.GetInstallmentOverdueBlock()
+	if err != nil {
+		log.Printf("ERROR: Failed to get installment overdue setting: %v", err)
+	}
+
+	// Printed books at or below their low-stock alert
+	lowStockMaterials, err := models.GetLowStockMaterials()
+	if err != nil {
+		log.Printf("ERROR: Failed to get low-stock materials: %v", err)
+		lowStockMaterials = []*models.Material{}
+	}
+
 	// Check for flash messages
 	flashMessage := ""
 	if r.URL.Query().Get("expense_created") == "1" {
 		flashMessage = "Expense created successfully"
+	} else if r.URL.Query().Get("installments_saved") == "1" {
+		flashMessage = "Installment settings saved"
 	} else if r.URL.Query().Get("error") == "future_date" {
 		flashMessage = "Payment date cannot be in the future"
 	}
@@ -149,6 +173,10 @@ func (h *FinanceHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
 		"CancelledBalancedCount": cancelledBalancedCount,
 		"CancelledErrorCount":    cancelledErrorCount,
 		"CancelledTotals":        cancelledTotals,
+		"OverdueInstallments":    overdueInstallments,
+		"OverdueTotal":           overdueInstallmentsTotal,
+		"InstallmentBlock":       installmentOverdueBlock,
+		"LowStockMaterials":      lowStockMaterials,
 		"DateFrom":               dateFrom,
 		"DateTo":                 dateTo,
 		"CategoryFilter":         categoryFilter,
@@ -176,18 +204,18 @@ func (h *FinanceHandler) NewExpenseForm(w http.ResponseWriter, r *http.Request)
 	}
 
 	today := time.Now().Format("2006-01-02")
-	
+
 	// Check for error messages
 	errorMsg := ""
 	if r.URL.Query().Get("error") == "future_date" {
 		errorMsg = "Payment date cannot be in the future"
 	}
-	
+
 	data := map[string]interface{}{
 		"Title":    "New Expense - Finance",
-		"Today":     today,
-		"UserRole":  userRole,
-		"Error":     errorMsg,
+		"Today":    today,
+		"UserRole": userRole,
+		"Error":    errorMsg,
 	}
 	renderTemplate(w, r, "finance_new_expense.html", data)
 }
@@ -332,3 +360,18 @@ func (h *FinanceHandler) CreateRefund(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?refund_created=1", leadID.String()), http.StatusFound)
 }
+
+// SaveInstallmentSettings turns the overdue-installment class block on or off (admin only)
+func (h *FinanceHandler) SaveInstallmentSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	block := r.FormValue("overdue_blocks_classes") == "on"
+	if err := models.SetInstallmentOverdueBlock(block); err != nil {
+		log.Printf("ERROR: Failed to save installment settings: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to save installment settings: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/finance?installments_saved=1#overdue-installments", http.StatusFound)
+}
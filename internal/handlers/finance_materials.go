@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// recentStockMovements is how many stock movements the materials page lists
+const recentStockMovements = 50
+
+// Materials renders the book catalogue with stock on hand and recent stock movements (admin only)
+func (h *FinanceHandler) Materials(w http.ResponseWriter, r *http.Request) {
+	materials, err := models.GetMaterials()
+	if err != nil {
+		log.Printf("ERROR: Failed to load materials: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load materials: %v", err), http.StatusInternalServerError)
+		return
+	}
+	movements, err := models.GetStockMovements(recentStockMovements)
+	if err != nil {
+		log.Printf("ERROR: Failed to load stock movements: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load materials: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var printed []*models.Material
+	for _, m := range materials {
+		if m.Format == models.MaterialPrinted {
+			printed = append(printed, m)
+		}
+	}
+	levels := make([]int32, 0, 8)
+	for l := int32(1); l <= 8; l++ {
+		levels = append(levels, l)
+	}
+	data := map[string]interface{}{
+		"Title":          "Book Stock - Eighty Twenty",
+		"UserRole":       middleware.GetUserRole(r),
+		"IsModerator":    IsModerator(r),
+		"Materials":      materials,
+		"Printed":        printed,
+		"Movements":      movements,
+		"Levels":         levels,
+		"Error":          r.URL.Query().Get("error"),
+		"SuccessMessage": r.URL.Query().Get("saved"),
+	}
+	renderTemplate(w, r, "finance_materials.html", data)
+}
+
+// parseMaterialForm reads the catalogue item fields shared by create and update
+func parseMaterialForm(r *http.Request) (*models.Material, string) {
+	m := &models.Material{
+		Name:   r.FormValue("name"),
+		Format: r.FormValue("format"),
+		Active: r.FormValue("active") == "on", // update only; new items start active
+	}
+	if v := strings.TrimSpace(r.FormValue("level")); v != "" {
+		level, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, "Level must be a number."
+		}
+		m.Level = int32(level)
+	}
+	cost, err := strconv.Atoi(strings.TrimSpace(r.FormValue("unit_cost")))
+	if err != nil {
+		return nil, "Unit cost must be a whole number."
+	}
+	threshold, err := strconv.Atoi(strings.TrimSpace(r.FormValue("low_stock_threshold")))
+	if err != nil {
+		return nil, "Low-stock threshold must be a whole number."
+	}
+	m.UnitCost, m.LowStockThreshold = int32(cost), int32(threshold)
+	return m, ""
+}
+
+// SaveMaterial creates or updates a catalogue item, or records a purchase or stock adjustment
+// (action=create|update|purchase|adjust, admin only)
+func (h *FinanceHandler) SaveMaterial(w http.ResponseWriter, r *http.Request) {
+	fail := func(msg string) {
+		http.Redirect(w, r, "/finance/materials?"+url.Values{"error": {msg}}.Encode(), http.StatusFound)
+	}
+	var err error
+	var done string
+	switch action := r.FormValue("action"); action {
+	case "create", "update":
+		m, msg := parseMaterialForm(r)
+		if msg != "" {
+			fail(msg)
+			return
+		}
+		if action == "create" {
+			err = models.CreateMaterial(m)
+			done = fmt.Sprintf("%s added to the catalogue.", m.Name)
+			break
+		}
+		id, parseErr := uuid.Parse(r.FormValue("material_id"))
+		if parseErr != nil {
+			http.Error(w, "Invalid material ID", http.StatusBadRequest)
+			return
+		}
+		m.ID = id
+		err = models.UpdateMaterial(m)
+		done = fmt.Sprintf("%s saved.", m.Name)
+	case "purchase", "adjust":
+		id, parseErr := uuid.Parse(r.FormValue("material_id"))
+		if parseErr != nil {
+			fail("Choose a book.")
+			return
+		}
+		quantity, parseErr := strconv.Atoi(strings.TrimSpace(r.FormValue("quantity")))
+		if parseErr != nil {
+			fail("Quantity must be a whole number.")
+			return
+		}
+		var unitCost sql.NullInt32
+		if v := strings.TrimSpace(r.FormValue("unit_cost")); v != "" {
+			cost, parseErr := strconv.Atoi(v)
+			if parseErr != nil {
+				fail("Unit cost must be a whole number.")
+				return
+			}
+			unitCost = sql.NullInt32{Int32: int32(cost), Valid: true}
+		}
+		movementType := models.StockPurchase
+		done = fmt.Sprintf("Purchase of %d book(s) recorded.", quantity)
+		if action == "adjust" {
+			movementType = models.StockAdjustment
+			done = "Stock adjusted."
+		}
+		err = models.RecordStockMovement(id, movementType, int32(quantity), unitCost, r.FormValue("notes"), middleware.GetUserID(r))
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	var materialErr *models.MaterialError
+	if errors.As(err, &materialErr) {
+		fail(materialErr.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to save material: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to save material: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/finance/materials?"+url.Values{"saved": {done}}.Encode(), http.StatusFound)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// customFieldStages are the stages a field can be required from, in pipeline order
+var customFieldStages = []string{
+	models.StageNewLead,
+	models.StageTestBooked,
+	models.StageTested,
+	models.StageOfferSent,
+	models.StageBookingConfirmedDeposit,
+	models.StageBookingConfirmedPaidFull,
+	models.StageScheduleSet,
+	models.StageReadyToStart,
+}
+
+// customFieldStageOption is a stage offered as "required from", with its display name
+type customFieldStageOption struct {
+	Stage string
+	Label string
+}
+
+// customFieldStageOptions returns customFieldStages with their display names
+func customFieldStageOptions() []customFieldStageOption {
+	options := make([]customFieldStageOption, len(customFieldStages))
+	for i, stage := range customFieldStages {
+		options[i] = customFieldStageOption{Stage: stage, Label: models.GetStatusDisplayInfo(models.StageToStatus(stage)).DisplayName}
+	}
+	return options
+}
+
+// customFieldInput is one custom field on a lead form with its value
+type customFieldInput struct {
+	Field     *models.LeadCustomField
+	Value     string
+	InputType string // text, number or date; select and boolean fields render a select
+	Required  bool   // at the lead's current stage
+}
+
+// leadFormCustomFields returns the active custom fields for a lead's form. A select field keeps the
+// lead's current value as an option after it was removed from the field, so saving the lead does
+// not clear it.
+func leadFormCustomFields(values map[string]string) []*models.LeadCustomField {
+	fields, err := models.GetLeadCustomFields(false)
+	if err != nil {
+		log.Printf("ERROR: Failed to load custom fields: %v", err)
+		return nil
+	}
+	for i, f := range fields {
+		if current := values[f.Key]; f.FieldType == models.CustomFieldSelect && current != "" && !f.HasOption(current) {
+			kept := *f
+			kept.Options = append(append([]string{}, f.Options...), current)
+			fields[i] = &kept
+		}
+	}
+	return fields
+}
+
+// customFieldInputs pairs fields with a lead's values for rendering
+func customFieldInputs(fields []*models.LeadCustomField, values map[string]string, stage string) []customFieldInput {
+	inputs := make([]customFieldInput, len(fields))
+	for i, f := range fields {
+		inputType := "text"
+		if f.FieldType == models.CustomFieldNumber || f.FieldType == models.CustomFieldDate {
+			inputType = f.FieldType
+		}
+		inputs[i] = customFieldInput{Field: f, Value: values[f.Key], InputType: inputType, Required: f.RequiredAt(stage)}
+	}
+	return inputs
+}
+
+// parseLeadCustomValues reads a lead form's custom field values and checks the fields required at
+// stage. ok is false when the form carries no custom fields (e.g. older forms), so nothing is saved.
+// Returns *models.CustomFieldError for invalid or missing values.
+func parseLeadCustomValues(r *http.Request, fields []*models.LeadCustomField, stage string) (values map[string]string, ok bool, err error) {
+	if r.FormValue("custom_fields_present") != "1" {
+		return nil, false, nil
+	}
+	values, err = models.ParseCustomFieldValues(fields, r.FormValue)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := models.CheckRequiredCustomFields(fields, values, stage); err != nil {
+		return nil, false, err
+	}
+	return values, true, nil
+}
+
+// customFieldFilterQuery returns the list's custom field filters and their query parameters
+func customFieldFilterQuery(r *http.Request, fields []*models.LeadCustomField) ([]models.CustomFieldFilter, url.Values) {
+	query := url.Values{}
+	for _, f := range fields {
+		if v := r.URL.Query().Get(models.CustomFieldParam + f.Key); v != "" {
+			query.Set(models.CustomFieldParam+f.Key, v)
+		}
+	}
+	return models.CustomFieldFilters(fields, r.URL.Query().Get), query
+}
+
+// CustomFields renders the custom lead field registry (admin only)
+func (h *PreEnrolmentHandler) CustomFields(w http.ResponseWriter, r *http.Request) {
+	fields, err := models.GetLeadCustomFields(true)
+	if err != nil {
+		log.Printf("ERROR: Failed to load custom fields: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load custom fields: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":          "Custom Fields - Eighty Twenty",
+		"UserRole":       middleware.GetUserRole(r),
+		"IsModerator":    IsModerator(r),
+		"Fields":         fields,
+		"FieldTypes":     models.CustomFieldTypes,
+		"Stages":         customFieldStageOptions(),
+		"Error":          r.URL.Query().Get("error"),
+		"SuccessMessage": r.URL.Query().Get("saved"),
+	}
+	renderTemplate(w, r, "pre_enrolment_custom_fields.html", data)
+}
+
+// SaveCustomField adds, edits, retires or restores a custom field (action=create|update|retire|restore)
+func (h *PreEnrolmentHandler) SaveCustomField(w http.ResponseWriter, r *http.Request) {
+	action := r.FormValue("action")
+	field := &models.LeadCustomField{
+		Label:             r.FormValue("label"),
+		FieldType:         r.FormValue("field_type"),
+		Options:           models.ParseCustomFieldOptions(r.FormValue("options")),
+		RequiredFromStage: r.FormValue("required_from_stage"),
+	}
+	var err error
+	var done string
+	if action == "create" {
+		err = models.CreateLeadCustomField(field)
+		done = fmt.Sprintf("Field %q added.", field.Label)
+	} else {
+		id, parseErr := uuid.Parse(r.FormValue("field_id"))
+		if parseErr != nil {
+			http.Error(w, "Invalid field ID", http.StatusBadRequest)
+			return
+		}
+		switch action {
+		case "update":
+			field.ID = id
+			field.SortOrder, _ = strconv.Atoi(r.FormValue("sort_order"))
+			err = models.UpdateLeadCustomField(field)
+			done = fmt.Sprintf("Field %q saved.", field.Label)
+		case "retire":
+			err = models.SetLeadCustomFieldActive(id, false)
+			done = "Field retired. Leads keep their values."
+		case "restore":
+			err = models.SetLeadCustomFieldActive(id, true)
+			done = "Field restored."
+		default:
+			http.Error(w, "Invalid action", http.StatusBadRequest)
+			return
+		}
+	}
+	if err != nil {
+		var fieldErr *models.CustomFieldError
+		if !errors.As(err, &fieldErr) {
+			log.Printf("ERROR: Failed to %s custom field: %v", action, err)
+			http.Error(w, fmt.Sprintf("Failed to update custom field: %v", err), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/pre-enrolment/custom-fields?"+url.Values{"error": {fieldErr.Error()}}.Encode(), http.StatusFound)
+		return
+	}
+	h.cfg.Debugf("  → Custom field %s done", action)
+	http.Redirect(w, r, "/pre-enrolment/custom-fields?"+url.Values{"saved": {done}}.Encode(), http.StatusFound)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// renderInstallmentError shows an installment plan rejection (bad split, overdue block) on the detail page.
+// Returns false when err is not a *models.InstallmentError.
+func (h *PreEnrolmentHandler) renderInstallmentError(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, err error) bool {
+	var installmentErr *models.InstallmentError
+	if !errors.As(err, &installmentErr) {
+		return false
+	}
+	h.renderDetailWithError(w, r, leadID, installmentErr.Error())
+	return true
+}
+
+// parseInstallmentPlan reads the plan form on the detail page: number of installments, optional first
+// amount (the deposit), first due date and months between installments
+func parseInstallmentPlan(r *http.Request, finalPrice int32) ([]*models.LeadInstallment, error) {
+	count, err := strconv.Atoi(strings.TrimSpace(r.FormValue("installment_count")))
+	if err != nil {
+		return nil, &models.InstallmentError{Message: "Number of installments must be a whole number."}
+	}
+	var firstAmount int64
+	if v := strings.TrimSpace(r.FormValue("first_amount")); v != "" {
+		firstAmount, err = strconv.ParseInt(v, 10, 32)
+		if err != nil || firstAmount < 0 {
+			return nil, &models.InstallmentError{Message: "Invalid first installment amount."}
+		}
+	}
+	firstDue, err := time.Parse("2006-01-02", r.FormValue("first_due_date"))
+	if err != nil {
+		return nil, &models.InstallmentError{Message: "First due date is required."}
+	}
+	interval, err := strconv.Atoi(strings.TrimSpace(r.FormValue("interval_months")))
+	if err != nil || interval < 1 || interval > 6 {
+		return nil, &models.InstallmentError{Message: "Months between installments must be between 1 and 6."}
+	}
+	return models.SplitInstallments(finalPrice, int32(firstAmount), count, firstDue, interval), nil
+}
+
+// saveInstallments handles the detail page's save_installments action (admin only)
+func (h *PreEnrolmentHandler) saveInstallments(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, actorUserID string) {
+	detail, err := models.GetLeadByID(leadID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load lead: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if detail.Offer == nil || !detail.Offer.FinalPrice.Valid || detail.Offer.FinalPrice.Int32 <= 0 {
+		h.renderDetailWithError(w, r, leadID, "The offer needs a final price before an installment plan can be set.")
+		return
+	}
+	plan, err := parseInstallmentPlan(r, detail.Offer.FinalPrice.Int32)
+	if err == nil {
+		err = models.SaveLeadInstallments(leadID, plan, actorUserID)
+	}
+	if h.renderInstallmentError(w, r, leadID, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to save installment plan: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to save installment plan: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  ✅ Installment plan saved (%d installments), redirecting to detail", len(plan))
+	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?installments=saved#installments", leadID.String()), http.StatusFound)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// parseReturnDate reads an expected return date (YYYY-MM-DD); zero when missing or invalid
+func parseReturnDate(value string) time.Time {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// renderPauseError shows a pause/resume rejection on the detail page.
+// Returns false when err is neither a *models.LeadPauseError nor a *models.LeadTransitionError.
+func (h *PreEnrolmentHandler) renderPauseError(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, err error) bool {
+	var pauseErr *models.LeadPauseError
+	if errors.As(err, &pauseErr) {
+		h.renderDetailWithError(w, r, leadID, pauseErr.Error())
+		return true
+	}
+	return h.renderTransitionError(w, r, leadID, err)
+}
+
+// pauseErrorStatus maps pause/resume errors to an API response; ok is false for unexpected errors
+func pauseErrorStatus(err error) (status int, message string, ok bool) {
+	var pauseErr *models.LeadPauseError
+	if errors.As(err, &pauseErr) {
+		return http.StatusBadRequest, pauseErr.Error(), true
+	}
+	var transitionErr *models.LeadTransitionError
+	if errors.As(err, &transitionErr) {
+		if transitionErr.Kind == models.TransitionForbidden {
+			return http.StatusForbidden, transitionErr.Error(), true
+		}
+		return http.StatusBadRequest, transitionErr.Error(), true
+	}
+	return 0, "", false
+}
+
+// GET /api/student-success/paused - paused students with their reason, expected return and frozen credits
+func (h *APIHandler) GetPausedStudents(w http.ResponseWriter, r *http.Request) {
+	pauses, err := models.GetPausedLeads()
+	if err != nil {
+		log.Printf("ERROR: Failed to get paused students: %v", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to load paused students")
+		return
+	}
+
+	type PausedResp struct {
+		LeadID             string `json:"lead_id"`
+		FullName           string `json:"full_name"`
+		Phone              string `json:"phone"`
+		Level              int32  `json:"level,omitempty"`
+		ClassKey           string `json:"class_key,omitempty"`
+		Reason             string `json:"reason"`
+		ExpectedReturnDate string `json:"expected_return_date,omitempty"`
+		ReturnDue          bool   `json:"return_due"`
+		FrozenCredits      int32  `json:"frozen_credits"`
+		PausedAt           string `json:"paused_at"`
+		PausedBy           string `json:"paused_by,omitempty"`
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	students := make([]PausedResp, 0, len(pauses))
+	for _, p := range pauses {
+		resp := PausedResp{
+			LeadID:        p.LeadID.String(),
+			FullName:      p.LeadName,
+			Phone:         p.LeadPhone,
+			Level:         p.AssignedLevel.Int32,
+			ClassKey:      p.ClassKey.String,
+			Reason:        p.Reason,
+			ReturnDue:     p.ReturnDue(today),
+			FrozenCredits: p.FrozenCredits,
+			PausedAt:      p.PausedAt.Format(time.RFC3339),
+			PausedBy:      p.PausedByEmail,
+		}
+		if p.ExpectedReturnDate.Valid {
+			resp.ExpectedReturnDate = p.ExpectedReturnDate.Time.Format("2006-01-02")
+		}
+		students = append(students, resp)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"students": students})
+}
+
+// POST /api/student-success/pause - pauses a student (body: { lead_id, reason, expected_return_date })
+func (h *APIHandler) PauseStudent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	var req struct {
+		LeadID             string `json:"lead_id"`
+		Reason             string `json:"reason"`
+		ExpectedReturnDate string `json:"expected_return_date"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	leadID, err := uuid.Parse(req.LeadID)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid lead_id")
+		return
+	}
+	if req.ExpectedReturnDate != "" && parseReturnDate(req.ExpectedReturnDate).IsZero() {
+		jsonError(w, http.StatusBadRequest, "expected_return_date must be YYYY-MM-DD")
+		return
+	}
+
+	err = models.PauseLead(leadID, req.Reason, parseReturnDate(req.ExpectedReturnDate), middleware.GetUserRole(r), middleware.GetUserID(r))
+	if status, message, ok := pauseErrorStatus(err); ok {
+		jsonError(w, status, message)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to pause student %s: %v", leadID, err)
+		jsonError(w, http.StatusInternalServerError, "Failed to pause student")
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// POST /api/student-success/resume - resumes a paused student (body: { lead_id })
+func (h *APIHandler) ResumeStudent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	var req struct {
+		LeadID string `json:"lead_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	leadID, err := uuid.Parse(req.LeadID)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid lead_id")
+		return
+	}
+
+	err = models.ResumeLead(leadID, middleware.GetUserRole(r), middleware.GetUserID(r))
+	if status, message, ok := pauseErrorStatus(err); ok {
+		jsonError(w, status, message)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to resume student %s: %v", leadID, err)
+		jsonError(w, http.StatusInternalServerError, "Failed to resume student")
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]bool{"ok": true})
+}
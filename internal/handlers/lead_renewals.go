@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// renderRenewalError shows a renewal rejection on the detail page.
+// Returns false when err is neither a *models.RenewalOfferError nor a *models.LeadTransitionError.
+func (h *PreEnrolmentHandler) renderRenewalError(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, err error) bool {
+	var renewalErr *models.RenewalOfferError
+	if errors.As(err, &renewalErr) {
+		h.renderDetailWithError(w, r, leadID, renewalErr.Error())
+		return true
+	}
+	return h.renderTransitionError(w, r, leadID, err)
+}
+
+// acceptRenewal handles the accept_renewal action (admin only): the renewal payment is posted to
+// finance, the bundle's levels are credited and the student goes back on the classes board
+func (h *PreEnrolmentHandler) acceptRenewal(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, actorUserID string) {
+	finalPrice, err := strconv.Atoi(strings.TrimSpace(r.FormValue("final_price")))
+	if err != nil {
+		h.renderDetailWithError(w, r, leadID, "Final price must be a whole number.")
+		return
+	}
+	paymentDate, err := time.Parse("2006-01-02", r.FormValue("payment_date"))
+	if err != nil {
+		h.renderDetailWithError(w, r, leadID, "Payment date is required.")
+		return
+	}
+	err = models.AcceptRenewalOffer(leadID, int32(finalPrice), r.FormValue("payment_method"), paymentDate, actorUserID)
+	if h.renderRenewalError(w, r, leadID, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to accept renewal: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to accept renewal: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  ✅ Renewal accepted at %d EGP, redirecting to detail", finalPrice)
+	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?renewal=accepted#renewal", leadID.String()), http.StatusFound)
+}
+
+// declineRenewal handles the decline_renewal action (admin only)
+func (h *PreEnrolmentHandler) declineRenewal(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, actorUserID string) {
+	err := models.DeclineRenewalOffer(leadID, actorUserID)
+	if h.renderRenewalError(w, r, leadID, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to decline renewal: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to decline renewal: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  ✅ Renewal declined, redirecting to detail")
+	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?renewal=declined#renewal", leadID.String()), http.StatusFound)
+}
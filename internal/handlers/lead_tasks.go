@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// parseTaskDue reads a datetime-local value (YYYY-MM-DDTHH:MM); zero when missing or invalid
+func parseTaskDue(value string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02T15:04", value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// taskAssigneeFromForm resolves the assignee select: "me" is the current user, "" is unassigned
+func taskAssigneeFromForm(r *http.Request, field string) string {
+	assignee := r.FormValue(field)
+	if assignee == "me" {
+		return middleware.GetUserID(r)
+	}
+	return assignee
+}
+
+// completeTaskFromForm logs the outcome of task_id and, when next_task_type is set, schedules
+// the next task for the same lead (assigned to the current user)
+func completeTaskFromForm(r *http.Request, leadID uuid.UUID) error {
+	taskID, err := uuid.Parse(r.FormValue("task_id"))
+	if err != nil {
+		return &models.LeadTaskError{Message: "Invalid task."}
+	}
+	userID := middleware.GetUserID(r)
+	if nextType := r.FormValue("next_task_type"); nextType != "" {
+		// Validate the follow-up before completing so a bad date doesn't lose the outcome
+		if parseTaskDue(r.FormValue("next_due_at")).IsZero() {
+			return &models.LeadTaskError{Message: "Set a due date and time for the next task."}
+		}
+	}
+	if err := models.CompleteLeadTask(taskID, r.FormValue("outcome"), r.FormValue("notes"), userID); err != nil {
+		return err
+	}
+	if nextType := r.FormValue("next_task_type"); nextType != "" {
+		return models.CreateLeadTask(leadID, nextType, parseTaskDue(r.FormValue("next_due_at")), userID, "", userID)
+	}
+	return nil
+}
+
+// renderTaskError shows a follow-up task rejection on the detail page.
+// Returns false when err is not a *models.LeadTaskError.
+func (h *PreEnrolmentHandler) renderTaskError(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, err error) bool {
+	var taskErr *models.LeadTaskError
+	if !errors.As(err, &taskErr) {
+		return false
+	}
+	h.renderDetailWithError(w, r, leadID, taskErr.Error())
+	return true
+}
+
+// Tasks renders the follow-up task queue (admin + moderator).
+// Query: scope=mine|unassigned|all (default mine).
+func (h *PreEnrolmentHandler) Tasks(w http.ResponseWriter, r *http.Request) {
+	scope := r.URL.Query().Get("scope")
+	if scope != models.LeadTaskScopeUnassigned && scope != models.LeadTaskScopeAll {
+		scope = models.LeadTaskScopeMine
+	}
+	tasks, err := models.GetLeadTaskQueue(scope, middleware.GetUserID(r))
+	if err != nil {
+		log.Printf("ERROR: Failed to load task queue: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load tasks: %v", err), http.StatusInternalServerError)
+		return
+	}
+	assignees, err := models.GetLeadTaskAssignees()
+	if err != nil {
+		log.Printf("ERROR: Failed to load task assignees: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load tasks: %v", err), http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	overdue := 0
+	for _, t := range tasks {
+		if t.Overdue(now) {
+			overdue++
+		}
+	}
+
+	h.cfg.Debugf("  → Task queue scope=%s: %d open, %d overdue", scope, len(tasks), overdue)
+	userRole := middleware.GetUserRole(r)
+	data := map[string]interface{}{
+		"Title":          "My Tasks - Eighty Twenty",
+		"UserRole":       userRole,
+		"IsModerator":    userRole == "moderator",
+		"IsAdmin":        userRole == "admin",
+		"Scope":          scope,
+		"Tasks":          tasks,
+		"Overdue":        overdue,
+		"Assignees":      assignees,
+		"TaskTypes":      models.LeadTaskTypes,
+		"TaskOutcomes":   models.LeadTaskOutcomes,
+		"Now":            now,
+		"Error":          r.URL.Query().Get("error"),
+		"SuccessMessage": r.URL.Query().Get("saved"),
+	}
+	renderTemplate(w, r, "lead_tasks.html", data)
+}
+
+// SaveTask completes (action=complete) or reassigns (action=assign, admin only) a task from the queue
+func (h *PreEnrolmentHandler) SaveTask(w http.ResponseWriter, r *http.Request) {
+	back := "/pre-enrolment/tasks?scope=" + url.QueryEscape(r.FormValue("scope"))
+	action := r.FormValue("action")
+	var err error
+	var done string
+	switch action {
+	case "complete":
+		leadID, parseErr := uuid.Parse(r.FormValue("lead_id"))
+		if parseErr != nil {
+			http.Error(w, "Invalid lead ID", http.StatusBadRequest)
+			return
+		}
+		err = completeTaskFromForm(r, leadID)
+		done = "Task completed."
+	case "assign":
+		if middleware.GetUserRole(r) != "admin" {
+			http.Error(w, "Forbidden: Only admins can reassign tasks", http.StatusForbidden)
+			return
+		}
+		taskID, parseErr := uuid.Parse(r.FormValue("task_id"))
+		if parseErr != nil {
+			http.Error(w, "Invalid task ID", http.StatusBadRequest)
+			return
+		}
+		err = models.AssignLeadTask(taskID, taskAssigneeFromForm(r, "assignee_user_id"))
+		done = "Task reassigned."
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		var taskErr *models.LeadTaskError
+		if !errors.As(err, &taskErr) {
+			log.Printf("ERROR: Failed to %s task: %v", action, err)
+			http.Error(w, fmt.Sprintf("Failed to update task: %v", err), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, back+"&"+url.Values{"error": {taskErr.Error()}}.Encode(), http.StatusFound)
+		return
+	}
+	h.cfg.Debugf("  → Task %s done", action)
+	http.Redirect(w, r, back+"&"+url.Values{"saved": {done}}.Encode(), http.StatusFound)
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Questions renders the online placement test question bank with the add-question form (admin only)
+func (h *PlacementTestsHandler) Questions(w http.ResponseWriter, r *http.Request) {
+	questions, err := models.GetPlacementQuestions()
+	if err != nil {
+		log.Printf("ERROR: Failed to load placement questions: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load questions: %v", err), http.StatusInternalServerError)
+		return
+	}
+	activePerLevel := make([]int, 9)
+	for _, q := range questions {
+		if q.Active {
+			activePerLevel[q.Level]++
+		}
+	}
+
+	h.cfg.Debugf("  → Placement question bank: %d questions", len(questions))
+	data := map[string]interface{}{
+		"Title":          "Placement Question Bank - Eighty Twenty",
+		"UserRole":       middleware.GetUserRole(r),
+		"IsModerator":    IsModerator(r),
+		"Questions":      questions,
+		"ActivePerLevel": activePerLevel[1:],
+		"PerLevel":       models.OnlineTestQuestionsPerLevel,
+		"Skills":         models.PlacementSkills,
+		"OptionSlots":    []int{0, 1, 2, 3},
+		"Error":          r.URL.Query().Get("error"),
+		"Created":        r.URL.Query().Get("created") == "1",
+	}
+	renderTemplate(w, r, "placement_questions.html", data)
+}
+
+// CreateQuestion adds a question to the bank from the question bank form
+func (h *PlacementTestsHandler) CreateQuestion(w http.ResponseWriter, r *http.Request) {
+	level, _ := strconv.Atoi(r.FormValue("level"))
+	correct, err := strconv.Atoi(r.FormValue("correct_option"))
+	if err != nil {
+		correct = -1
+	}
+	q := &models.PlacementQuestion{
+		Skill:         r.FormValue("skill"),
+		Level:         level,
+		Prompt:        r.FormValue("prompt"),
+		Options:       r.Form["option"],
+		CorrectOption: correct,
+	}
+	if err := models.CreatePlacementQuestion(q, middleware.GetUserID(r)); err != nil {
+		var testErr *models.OnlineTestError
+		if errors.As(err, &testErr) {
+			http.Redirect(w, r, "/placement-tests/questions?"+url.Values{"error": {testErr.Error()}}.Encode(), http.StatusFound)
+			return
+		}
+		log.Printf("ERROR: Failed to create placement question: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to create question: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  → Created placement question %s (level %d, %s)", q.ID, q.Level, q.Skill)
+	http.Redirect(w, r, "/placement-tests/questions?created=1", http.StatusFound)
+}
+
+// SetQuestionActive retires or restores a bank question
+func (h *PlacementTestsHandler) SetQuestionActive(w http.ResponseWriter, r *http.Request) {
+	questionID, err := uuid.Parse(r.FormValue("question_id"))
+	if err != nil {
+		http.Error(w, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+	active := r.FormValue("active") == "1"
+	if err := models.SetPlacementQuestionActive(questionID, active); err != nil {
+		log.Printf("ERROR: Failed to update placement question: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to update question: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/placement-tests/questions", http.StatusFound)
+}
+
+// TakeTest serves the public online placement test behind a tokenised link (no login).
+// GET shows the intro or the running test; POST with action=start starts the timer and
+// action=submit scores the answers.
+func (h *PlacementTestsHandler) TakeTest(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/online-test/")
+	if token == "" || strings.Contains(token, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	data := map[string]interface{}{
+		"Title": "Placement Test - Eighty Twenty",
+		"Token": token,
+	}
+	renderError := func(err error) {
+		var testErr *models.OnlineTestError
+		if !errors.As(err, &testErr) {
+			log.Printf("ERROR: Online placement test %s: %v", token, err)
+			http.Error(w, "Something went wrong. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		data["State"] = "closed"
+		data["Message"] = testErr.Error()
+		renderTemplate(w, r, "online_test.html", data)
+	}
+
+	if r.Method == http.MethodPost {
+		switch r.FormValue("action") {
+		case "start":
+			if _, err := models.StartOnlinePlacementTest(token); err != nil {
+				renderError(err)
+				return
+			}
+			http.Redirect(w, r, "/online-test/"+token, http.StatusFound)
+		case "submit":
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, "Invalid form", http.StatusBadRequest)
+				return
+			}
+			answers := make(map[string]int)
+			for key, values := range r.PostForm {
+				if !strings.HasPrefix(key, "q_") || len(values) == 0 {
+					continue
+				}
+				if chosen, err := strconv.Atoi(values[0]); err == nil {
+					answers[strings.TrimPrefix(key, "q_")] = chosen
+				}
+			}
+			test, err := models.SubmitOnlinePlacementTest(token, answers)
+			if err != nil {
+				renderError(err)
+				return
+			}
+			h.cfg.Debugf("  → Online test %s submitted: %d/%d, proposed level %d", test.ID, test.Score.Correct, test.Score.Total, test.Score.ProposedLevel)
+			data["State"] = "done"
+			renderTemplate(w, r, "online_test.html", data)
+		default:
+			http.Error(w, "Invalid action", http.StatusBadRequest)
+		}
+		return
+	}
+
+	test, err := models.GetOnlinePlacementTestByToken(token)
+	if err != nil {
+		renderError(err)
+		return
+	}
+	switch test.Status {
+	case "pending":
+		if time.Now().After(test.ExpiresAt) {
+			renderError(&models.OnlineTestError{Message: "This test link has expired. Please contact us for a new one."})
+			return
+		}
+		data["State"] = "intro"
+		data["DurationMinutes"] = test.DurationMinutes
+		data["QuestionCount"] = len(test.QuestionIDs)
+	case "in_progress":
+		questions, err := models.GetOnlinePlacementTestQuestions(test)
+		if err != nil {
+			renderError(err)
+			return
+		}
+		remaining := int(time.Until(test.Deadline()).Seconds())
+		if remaining < 0 {
+			remaining = 0
+		}
+		data["State"] = "running"
+		data["Questions"] = questions
+		data["RemainingSeconds"] = remaining
+	case "revoked":
+		renderError(&models.OnlineTestError{Message: "This test link has been replaced by a newer one."})
+		return
+	default:
+		data["State"] = "done"
+	}
+	renderTemplate(w, r, "online_test.html", data)
+}
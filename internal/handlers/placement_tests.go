@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"eighty-twenty-ops/internal/config"
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type PlacementTestsHandler struct {
+	cfg *config.Config
+}
+
+func NewPlacementTestsHandler(cfg *config.Config) *PlacementTestsHandler {
+	return &PlacementTestsHandler{cfg: cfg}
+}
+
+// placementCalendarDay is one column of the weekly slot calendar
+type placementCalendarDay struct {
+	Date  time.Time
+	Slots []*models.PlacementTestSlot
+}
+
+// Calendar renders a week of placement test slots with the add-slot form (admin only).
+// Query: start=YYYY-MM-DD (defaults to today).
+func (h *PlacementTestsHandler) Calendar(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if startStr := r.URL.Query().Get("start"); startStr != "" {
+		if t, err := time.Parse("2006-01-02", startStr); err == nil {
+			start = t
+		}
+	}
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+
+	slots, err := models.GetPlacementTestSlots(start, end)
+	if err != nil {
+		log.Printf("ERROR: Failed to load placement test slots: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load slots: %v", err), http.StatusInternalServerError)
+		return
+	}
+	examiners, err := models.GetPlacementExaminers()
+	if err != nil {
+		log.Printf("ERROR: Failed to load examiners: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load examiners: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	days := make([]placementCalendarDay, 7)
+	for i := range days {
+		days[i].Date = start.AddDate(0, 0, i)
+	}
+	for _, s := range slots {
+		i := int(s.SlotDate.Sub(start).Hours() / 24)
+		if i >= 0 && i < len(days) {
+			days[i].Slots = append(days[i].Slots, s)
+		}
+	}
+
+	h.cfg.Debugf("  → Placement calendar %s: %d slots", start.Format("2006-01-02"), len(slots))
+	data := map[string]interface{}{
+		"Title":       "Placement Tests - Eighty Twenty",
+		"UserRole":    middleware.GetUserRole(r),
+		"IsModerator": IsModerator(r),
+		"Days":        days,
+		"Start":       start,
+		"PrevStart":   start.AddDate(0, 0, -7).Format("2006-01-02"),
+		"NextStart":   end.Format("2006-01-02"),
+		"Examiners":   examiners,
+		"Error":       r.URL.Query().Get("error"),
+		"Created":     r.URL.Query().Get("created") == "1",
+		"Deleted":     r.URL.Query().Get("deleted") == "1",
+	}
+	renderTemplate(w, r, "placement_tests.html", data)
+}
+
+// CreateSlot adds a slot from the calendar form. Conflicts are shown back on the calendar.
+func (h *PlacementTestsHandler) CreateSlot(w http.ResponseWriter, r *http.Request) {
+	redirectWith := func(params url.Values) {
+		params.Set("start", r.FormValue("start"))
+		http.Redirect(w, r, "/placement-tests?"+params.Encode(), http.StatusFound)
+	}
+
+	slotDate, err := time.Parse("2006-01-02", r.FormValue("slot_date"))
+	if err != nil {
+		redirectWith(url.Values{"error": {"Slot date is required."}})
+		return
+	}
+	examinerID, err := uuid.Parse(r.FormValue("examiner_user_id"))
+	if err != nil {
+		redirectWith(url.Values{"error": {"Examiner is required."}})
+		return
+	}
+	capacity := 1
+	if c := r.FormValue("capacity"); c != "" {
+		capacity, err = strconv.Atoi(c)
+		if err != nil {
+			redirectWith(url.Values{"error": {"Capacity must be a number."}})
+			return
+		}
+	}
+	slot := &models.PlacementTestSlot{
+		SlotDate:       slotDate,
+		SlotTime:       r.FormValue("slot_time"),
+		TestType:       r.FormValue("test_type"),
+		ExaminerUserID: examinerID,
+		Capacity:       capacity,
+	}
+	if notes := r.FormValue("notes"); notes != "" {
+		slot.Notes = sql.NullString{String: notes, Valid: true}
+	}
+
+	if err := models.CreatePlacementTestSlot(slot, middleware.GetUserID(r)); err != nil {
+		var slotErr *models.PlacementSlotError
+		if errors.As(err, &slotErr) {
+			redirectWith(url.Values{"error": {slotErr.Error()}})
+			return
+		}
+		log.Printf("ERROR: Failed to create placement test slot: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to create slot: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  → Created placement test slot %s", slot.ID)
+	redirectWith(url.Values{"created": {"1"}})
+}
+
+// DeleteSlot removes an unbooked slot
+func (h *PlacementTestsHandler) DeleteSlot(w http.ResponseWriter, r *http.Request) {
+	slotID, err := uuid.Parse(r.FormValue("slot_id"))
+	if err != nil {
+		http.Error(w, "Invalid slot ID", http.StatusBadRequest)
+		return
+	}
+	params := url.Values{"start": {r.FormValue("start")}}
+	if err := models.DeletePlacementTestSlot(slotID); err != nil {
+		var slotErr *models.PlacementSlotError
+		if !errors.As(err, &slotErr) {
+			log.Printf("ERROR: Failed to delete placement test slot: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to delete slot: %v", err), http.StatusInternalServerError)
+			return
+		}
+		params.Set("error", slotErr.Error())
+	} else {
+		params.Set("deleted", "1")
+	}
+	http.Redirect(w, r, "/placement-tests?"+params.Encode(), http.StatusFound)
+}
+
+// Agenda renders one examiner's slots and booked leads for a day.
+// Admins can pick any examiner; other examiners only see their own agenda.
+func (h *PlacementTestsHandler) Agenda(w http.ResponseWriter, r *http.Request) {
+	userRole := middleware.GetUserRole(r)
+	day := time.Now()
+	if dayStr := r.URL.Query().Get("date"); dayStr != "" {
+		if t, err := time.Parse("2006-01-02", dayStr); err == nil {
+			day = t
+		}
+	}
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	examinerIDStr := middleware.GetUserID(r)
+	var examiners []*models.User
+	if userRole == "admin" {
+		var err error
+		examiners, err = models.GetPlacementExaminers()
+		if err != nil {
+			log.Printf("ERROR: Failed to load examiners: %v", err)
+		}
+		if e := r.URL.Query().Get("examiner"); e != "" {
+			examinerIDStr = e
+		}
+	}
+	examinerID, err := uuid.Parse(examinerIDStr)
+	if err != nil {
+		http.Error(w, "Invalid examiner ID", http.StatusBadRequest)
+		return
+	}
+
+	slots, err := models.GetExaminerAgenda(examinerID, day)
+	if err != nil {
+		log.Printf("ERROR: Failed to load examiner agenda: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load agenda: %v", err), http.StatusInternalServerError)
+		return
+	}
+	examinerEmail := ""
+	if examiner, err := models.GetUserByID(examinerID.String()); err == nil {
+		examinerEmail = examiner.Email
+	}
+
+	data := map[string]interface{}{
+		"Title":         "Examiner Agenda - Eighty Twenty",
+		"UserRole":      userRole,
+		"IsModerator":   IsModerator(r),
+		"IsAdmin":       userRole == "admin",
+		"Day":           day,
+		"PrevDay":       day.AddDate(0, 0, -1).Format("2006-01-02"),
+		"NextDay":       day.AddDate(0, 0, 1).Format("2006-01-02"),
+		"ExaminerID":    examinerID.String(),
+		"ExaminerEmail": examinerEmail,
+		"Examiners":     examiners,
+		"Slots":         slots,
+	}
+	renderTemplate(w, r, "placement_agenda.html", data)
+}
@@ -4,8 +4,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -31,6 +33,19 @@ func isValidAssignedLevel(level int) bool {
 }
 
 func (h *PreEnrolmentHandler) List(w http.ResponseWriter, r *http.Request) {
+	userRole := middleware.GetUserRole(r)
+
+	// The list without any parameters opens the role's default view (view=all skips it)
+	if r.URL.RawQuery == "" {
+		defaultView, err := models.GetDefaultLeadListView(userRole)
+		if err != nil {
+			log.Printf("ERROR: Failed to load default list view: %v", err)
+		} else if defaultView != nil {
+			http.Redirect(w, r, "/pre-enrolment?"+defaultView.URLQuery(), http.StatusFound)
+			return
+		}
+	}
+
 	// Read filter parameters from query string
 	statusFilter := r.URL.Query().Get("status")
 	searchFilter := r.URL.Query().Get("search")
@@ -46,18 +61,24 @@ func (h *PreEnrolmentHandler) List(w http.ResponseWriter, r *http.Request) {
 	// Check for flash messages in query params (separate from filter status)
 	flashMessage := ""
 	savedParam := r.URL.Query().Get("saved")
-	deletedParam := r.URL.Query().Get("deleted")
+	archivedParam := r.URL.Query().Get("archived")
 	statusFlashParam := r.URL.Query().Get("status_flash")
 	sentToClassesParam := r.URL.Query().Get("sentToClasses")
 
 	if sentToClassesParam == "1" {
 		flashMessage = "Lead sent to classes board successfully!"
-	} else if deletedParam == "1" {
-		flashMessage = "Lead cancelled successfully!"
+	} else if archivedParam == "1" {
+		flashMessage = "Lead archived. Admins can restore it from the Archived page."
 	} else if r.URL.Query().Get("cancelled") == "1" {
 		flashMessage = "Lead cancelled successfully!"
 	} else if savedParam == "1" {
 		flashMessage = "Lead saved successfully!"
+	} else if r.URL.Query().Get("view_saved") == "1" {
+		flashMessage = "View saved."
+	} else if r.URL.Query().Get("view_deleted") == "1" {
+		flashMessage = "View deleted."
+	} else if r.URL.Query().Get("view_default") == "1" {
+		flashMessage = "Default view updated."
 	} else if statusFlashParam != "" {
 		statusMessages := map[string]string{
 			"test_booked": "Placement test booked successfully!",
@@ -73,38 +94,90 @@ func (h *PreEnrolmentHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	h.cfg.Debugf("List: statusFilter=%q, searchFilter=%q, paymentFilter=%q, hotFilter=%q, followUpFilter=%q, includeCancelled=%v", statusFilter, searchFilter, paymentFilter, hotFilter, followUpFilter, includeCancelled)
 
-	// Get filtered leads
-	leads, err := models.GetAllLeads(statusFilter, searchFilter, paymentFilter, hotFilter, includeCancelled, followUpFilter)
+	customFields, err := models.GetLeadCustomFields(false)
+	if err != nil {
+		log.Printf("ERROR: Failed to load custom fields: %v", err)
+	}
+	customFilters, customQuery := customFieldFilterQuery(r, customFields)
+
+	sortKey, sortDesc := models.ParseLeadSort(r.URL.Query().Get("sort"))
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	listQuery := models.LeadListQuery{
+		Status:           statusFilter,
+		Search:           searchFilter,
+		Payment:          paymentFilter,
+		Hot:              hotFilter,
+		FollowUp:         followUpFilter,
+		IncludeCancelled: includeCancelled,
+		Custom:           customFilters,
+		Sort:             sortKey,
+		Desc:             sortDesc,
+		Page:             page,
+		PageSize:         models.DefaultLeadPageSize,
+	}
+
+	// One page of filtered leads
+	leadPage, err := models.QueryLeads(listQuery)
 	if err != nil {
 		log.Printf("ERROR: Failed to load leads: %v", err)
 		http.Error(w, fmt.Sprintf("Failed to load leads: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	h.cfg.Debugf("List: returned %d leads", len(leads))
+	h.cfg.Debugf("List: returned %d of %d leads (page %d)", len(leadPage.Leads), leadPage.Total, leadPage.Page)
 
-	// Count follow-ups due for banner
-	// Get total count of hot leads (need to fetch all leads without hot filter)
-	var followUpCount int
-	if hotFilter == "1" || hotFilter == "hot" {
-		// All leads in filtered list are hot leads
-		followUpCount = len(leads)
-	} else {
-		// Get all leads to count hot leads accurately (exclude cancelled)
-		allLeads, err := models.GetAllLeads("", "", "", "", false, "")
-		if err == nil {
-			for _, lead := range allLeads {
-				if lead.FollowUpDue {
-					followUpCount++
-				}
-			}
+	// Quick filter counts and the hot leads banner, counted in SQL under the current search and filters
+	tabCounts, err := models.CountLeadTabs(listQuery)
+	if err != nil {
+		log.Printf("ERROR: Failed to count leads: %v", err)
+		tabCounts = &models.LeadTabCounts{ByStage: map[string]int{}}
+	}
+	followUpCount := tabCounts.Hot
+
+	// Export links carry the active filters and sort; page links carry those and the view too
+	exportQuery := url.Values{}
+	for _, key := range listViewFilterKeys {
+		if v := r.URL.Query().Get(key); v != "" {
+			exportQuery.Set(key, v)
 		}
 	}
+	customValues := map[string]string{}
+	for key, v := range customQuery {
+		exportQuery[key] = v
+		customValues[strings.TrimPrefix(key, models.CustomFieldParam)] = v[0]
+	}
+	pageQuery := url.Values{}
+	for key, v := range exportQuery {
+		pageQuery[key] = v
+	}
+
+	// Saved views with their lead counts; the selected view sets the visible columns
+	views, err := models.GetLeadListViews(middleware.GetUserID(r))
+	if err != nil {
+		log.Printf("ERROR: Failed to load list views: %v", err)
+	} else if err := models.CountLeadListViews(views); err != nil {
+		log.Printf("ERROR: Failed to count list views: %v", err)
+	}
+	var activeView *models.LeadListView
+	viewLinks := make(map[string]template.URL, len(views))
+	for _, v := range views {
+		viewLinks[v.ID.String()] = template.URL(v.URLQuery())
+		if v.ID.String() == r.URL.Query().Get("view") {
+			activeView = v
+			pageQuery.Set("view", v.ID.String())
+		}
+	}
+	columns := models.DefaultLeadListColumns
+	if activeView != nil {
+		columns = activeView.Columns
+	}
 
-	userRole := middleware.GetUserRole(r)
 	data := map[string]interface{}{
 		"Title":            "Pre-Enrolment - Eighty Twenty",
-		"Leads":            leads,
+		"Leads":            leadPage.Leads,
+		"LeadPage":         leadPage,
+		"TabCounts":        tabCounts.ByStage,
+		"SortFilter":       r.URL.Query().Get("sort"),
 		"UserRole":         userRole,
 		"IsModerator":      IsModerator(r),
 		"IsAdmin":          IsAdmin(r),
@@ -116,6 +189,19 @@ func (h *PreEnrolmentHandler) List(w http.ResponseWriter, r *http.Request) {
 		"IncludeCancelled": includeCancelled,
 		"FollowUpCount":    followUpCount,
 		"FollowUpFilter":   followUpFilter,
+		"ExportQuery":      template.URL(exportQuery.Encode()),
+		"PageQuery":        template.URL(pageQuery.Encode()),
+		"ReturnQuery":      pageQuery.Encode(),
+		"Views":            views,
+		"ActiveView":       activeView,
+		"ViewLinks":        viewLinks,
+		"ViewError":        r.URL.Query().Get("view_error"),
+		"Columns":          models.LeadListColumnSet(columns),
+		"ColumnCount":      len(columns) + 2,
+		"ColumnOptions":    models.LeadListColumns,
+		"UserID":           middleware.GetUserID(r),
+		"CustomFilters":    customFieldInputs(customFields, customValues, ""),
+		"CustomFiltered":   len(customFilters) > 0,
 	}
 	renderTemplate(w, r, "pre_enrolment_list.html", data)
 }
@@ -123,14 +209,35 @@ func (h *PreEnrolmentHandler) List(w http.ResponseWriter, r *http.Request) {
 func (h *PreEnrolmentHandler) NewForm(w http.ResponseWriter, r *http.Request) {
 	h.cfg.Debugf("📝 NewForm() called - rendering pre_enrolment_new.html template")
 	data := map[string]interface{}{
-		"Title":       "New Lead - Eighty Twenty",
-		"UserRole":    middleware.GetUserRole(r),
-		"IsModerator": IsModerator(r),
+		"Title":        "New Lead - Eighty Twenty",
+		"UserRole":     middleware.GetUserRole(r),
+		"IsModerator":  IsModerator(r),
+		"Sources":      leadSourceOptions(sql.NullString{}),
+		"CustomFields": customFieldInputs(leadFormCustomFields(nil), nil, models.StageNewLead),
 	}
 	renderTemplate(w, r, "pre_enrolment_new.html", data)
 	h.cfg.Debugf("  → Template render complete")
 }
 
+// leadSourceOptions returns the active managed sources for lead forms. A lead's current source is
+// kept as an option even after it has been retired, so saving the lead does not change it.
+func leadSourceOptions(current sql.NullString) []string {
+	sources, err := models.GetActiveLeadSourceNames()
+	if err != nil {
+		log.Printf("ERROR: Failed to load lead sources: %v", err)
+		sources = []string{models.LeadSourceFallback}
+	}
+	if current.Valid && current.String != "" {
+		for _, s := range sources {
+			if s == current.String {
+				return sources
+			}
+		}
+		sources = append(sources, current.String)
+	}
+	return sources
+}
+
 func (h *PreEnrolmentHandler) Create(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -142,29 +249,48 @@ func (h *PreEnrolmentHandler) Create(w http.ResponseWriter, r *http.Request) {
 	source := r.FormValue("source")
 	notes := r.FormValue("notes")
 
+	// Custom fields as typed, so a rejected form keeps them
+	customFields := leadFormCustomFields(nil)
+	typedCustom := make(map[string]string, len(customFields))
+	for _, f := range customFields {
+		typedCustom[f.Key] = r.FormValue(models.CustomFieldParam + f.Key)
+	}
+	customInputs := customFieldInputs(customFields, typedCustom, models.StageNewLead)
+
 	if fullName == "" || phone == "" {
 		data := map[string]interface{}{
-			"Title":       "New Lead - Eighty Twenty",
-			"Error":       "Full name and phone are required",
-			"UserRole":    middleware.GetUserRole(r),
-			"IsModerator": IsModerator(r),
+			"Title":        "New Lead - Eighty Twenty",
+			"Error":        "Full name and phone are required",
+			"UserRole":     middleware.GetUserRole(r),
+			"IsModerator":  IsModerator(r),
+			"Sources":      leadSourceOptions(sql.NullString{}),
+			"CustomFields": customInputs,
 		}
 		renderTemplate(w, r, "pre_enrolment_new.html", data)
 		return
 	}
 
-	// Validate source is one of allowed options
-	allowedSources := map[string]bool{
-		"Facebook": true,
-		"WhatsApp": true,
-		"Admin":    true,
-		"Referral": true,
-		"Other":    true,
-	}
-	if source == "" || !allowedSources[source] {
-		source = "Other" // Default to Other if invalid
+	customValues, saveCustom, err := parseLeadCustomValues(r, customFields, models.StageNewLead)
+	if err != nil {
+		data := map[string]interface{}{
+			"Title":             "New Lead - Eighty Twenty",
+			"Error":             err.Error(),
+			"PreservedFullName": fullName,
+			"PreservedPhone":    phone,
+			"PreservedSource":   source,
+			"PreservedNotes":    notes,
+			"UserRole":          middleware.GetUserRole(r),
+			"IsModerator":       IsModerator(r),
+			"Sources":           leadSourceOptions(sql.NullString{}),
+			"CustomFields":      customInputs,
+		}
+		renderTemplate(w, r, "pre_enrolment_new.html", data)
+		return
 	}
 
+	// Validate source is on the managed list (defaults to Other)
+	source = models.NormalizeLeadSource(source, leadSourceOptions(sql.NullString{}))
+
 	userID := middleware.GetUserID(r)
 	lead, err := models.CreateLead(fullName, phone, source, notes, userID)
 	if err != nil {
@@ -200,6 +326,8 @@ func (h *PreEnrolmentHandler) Create(w http.ResponseWriter, r *http.Request) {
 				"PreservedNotes":    notes,
 				"UserRole":          middleware.GetUserRole(r),
 				"IsModerator":       IsModerator(r),
+				"Sources":           leadSourceOptions(sql.NullString{}),
+				"CustomFields":      customInputs,
 			}
 			renderTemplate(w, r, "pre_enrolment_new.html", data)
 			return
@@ -209,6 +337,17 @@ func (h *PreEnrolmentHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if referrerID, parseErr := uuid.Parse(r.FormValue("referred_by_lead_id")); parseErr == nil {
+		if err := models.SetLeadReferrer(lead.ID, referrerID); err != nil {
+			log.Printf("ERROR: Failed to set referrer on new lead %s: %v", lead.ID, err)
+		}
+	}
+	if saveCustom {
+		if err := models.SaveLeadCustomValues(lead.ID, customFields, customValues); err != nil {
+			log.Printf("ERROR: Failed to save custom fields on new lead %s: %v", lead.ID, err)
+		}
+	}
+
 	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s", lead.ID.String()), http.StatusFound)
 }
 
@@ -233,7 +372,7 @@ func (h *PreEnrolmentHandler) Detail(w http.ResponseWriter, r *http.Request) {
 
 	userRole := middleware.GetUserRole(r)
 
-	data, _ := h.buildDetailViewModel(detail, leadID, userRole)
+	data, _ := h.buildDetailViewModel(detail, leadID, userRole, middleware.GetUserID(r))
 
 	errorMsg := ""
 	phoneError := ""
@@ -281,6 +420,46 @@ func (h *PreEnrolmentHandler) Detail(w http.ResponseWriter, r *http.Request) {
 		successMsg = "Lead cancelled successfully."
 	} else if r.URL.Query().Get("saved") == "1" {
 		successMsg = "Lead saved successfully!"
+	} else if r.URL.Query().Get("merged") == "1" {
+		successMsg = "Duplicate lead merged into this lead."
+	} else if r.URL.Query().Get("online_test") == "created" {
+		successMsg = "Online test link created. Copy it from the Online Placement Test section and send it to the lead."
+	} else if r.URL.Query().Get("online_test") == "rejected" {
+		successMsg = "Online test result rejected. You can send the lead a new link."
+	} else if r.URL.Query().Get("task") == "added" {
+		successMsg = "Follow-up task added."
+	} else if r.URL.Query().Get("task") == "completed" {
+		successMsg = "Task completed."
+	} else if r.URL.Query().Get("paused") == "1" {
+		successMsg = "Student paused. Their class place was released and remaining credits are frozen."
+	} else if r.URL.Query().Get("resumed") == "1" {
+		successMsg = "Student resumed and back on the classes board."
+	} else if r.URL.Query().Get("discount_approved") == "1" {
+		successMsg = "Discount approved. The offer can now be sent."
+	} else if r.URL.Query().Get("installments") == "saved" {
+		successMsg = "Installment plan saved. Payments received so far were matched to it."
+	} else if r.URL.Query().Get("installments") == "cleared" {
+		successMsg = "Installment plan removed."
+	} else if r.URL.Query().Get("shipment") == "delivered" {
+		successMsg = "Book marked delivered."
+	} else if r.URL.Query().Get("shipment") == "returned" {
+		successMsg = "Book marked returned. It is back on the shipping page's to-pack list."
+	} else if r.URL.Query().Get("referral") == "saved" {
+		successMsg = "Referrer saved."
+	} else if r.URL.Query().Get("referral") == "credit_applied" {
+		successMsg = "Referral credit applied to the offer."
+	} else if r.URL.Query().Get("referral") == "cashback_paid" {
+		successMsg = "Referral cash-back recorded as paid and posted to finance."
+	} else if r.URL.Query().Get("payer") == "saved" {
+		successMsg = "Payer linked. Absence follow-ups for this student go to the payer."
+	} else if r.URL.Query().Get("payer") == "unlinked" {
+		successMsg = "Payer removed from this lead."
+	} else if r.URL.Query().Get("renewal") == "accepted" {
+		successMsg = "Renewal recorded. The payment is posted to finance and the student is back on the classes board."
+	} else if r.URL.Query().Get("renewal") == "declined" {
+		successMsg = "Renewal offer declined."
+	} else if r.URL.Query().Get("restored") == "1" {
+		successMsg = "Lead restored. It is back on the lead list."
 	}
 	data["SuccessMessage"] = successMsg
 
@@ -304,7 +483,7 @@ func (h *PreEnrolmentHandler) Detail(w http.ResponseWriter, r *http.Request) {
 
 // buildDetailViewModel returns the shared detail page data map used by both Detail() and renderDetailWithError.
 // Callers merge overrides (Error, SuccessMessage, ShowCancelModal, PhoneError, ExistingLeadID, PlacementTestPaid).
-func (h *PreEnrolmentHandler) buildDetailViewModel(detail *models.LeadDetail, leadID uuid.UUID, userRole string) (map[string]interface{}, error) {
+func (h *PreEnrolmentHandler) buildDetailViewModel(detail *models.LeadDetail, leadID uuid.UUID, userRole, currentUserID string) (map[string]interface{}, error) {
 	var placementTestRemaining int32 = 0
 	if detail.PlacementTest != nil {
 		if detail.PlacementTest.PlacementTestFee.Valid && detail.PlacementTest.PlacementTestFeePaid.Valid {
@@ -331,11 +510,26 @@ func (h *PreEnrolmentHandler) buildDetailViewModel(detail *models.LeadDetail, le
 	if detail.PlacementTest != nil {
 		testDate = detail.PlacementTest.TestDate
 	}
+	statusHistory, err := models.GetLeadStatusHistory(leadID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get status history: %v", err)
+		statusHistory = []*models.LeadStatusChange{}
+	}
+	var statusChangedAt sql.NullTime
+	if len(statusHistory) > 0 {
+		statusChangedAt = sql.NullTime{Time: statusHistory[len(statusHistory)-1].ChangedAt, Valid: true}
+	}
+	tasks, err := models.GetLeadTasks(leadID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get lead tasks: %v", err)
+	}
 	tempItem := &models.LeadListItem{
-		Lead:       detail.Lead,
-		TestDate:   testDate,
-		AmountPaid: amountPaid,
-		FinalPrice: finalPrice,
+		Lead:            detail.Lead,
+		TestDate:        testDate,
+		AmountPaid:      amountPaid,
+		FinalPrice:      finalPrice,
+		StatusChangedAt: statusChangedAt,
+		LastContactAt:   models.LastLeadContact(tasks),
 	}
 	models.ComputeLeadFlags(tempItem)
 
@@ -369,8 +563,167 @@ func (h *PreEnrolmentHandler) buildDetailViewModel(detail *models.LeadDetail, le
 	}
 	showFollowUpBanner := !isFullyPaid && tempItem.FollowUpDue && pipelineStatuses[detail.Lead.Status]
 
+	taskAssignees, err := models.GetLeadTaskAssignees()
+	if err != nil {
+		log.Printf("ERROR: Failed to get task assignees: %v", err)
+	}
+
+	pauses, err := models.GetLeadPauses(leadID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get lead pauses: %v", err)
+	}
+	levelsRemaining := detail.Lead.LevelsPurchasedTotal.Int32 - detail.Lead.LevelsConsumed.Int32
+	if levelsRemaining < 0 {
+		levelsRemaining = 0
+	}
+
+	// Bundle prices come from the price list in effect; an offer keeps the price of the list it was
+	// made from until its bundle changes (see Offer.ApplyPricePlan)
+	pricePlan, err := models.GetCurrentPricePlan(time.Now())
+	if err != nil {
+		log.Printf("ERROR: Failed to get price plan: %v", err)
+	}
+	bundlePrices := map[int32]int32{}
+	if pricePlan != nil {
+		for _, b := range pricePlan.BundleOptions() {
+			bundlePrices[b.Levels] = b.Price
+		}
+	}
+	var offerPricePlan *models.PricePlan
+	savedBundle, savedBasePrice := "", int32(0)
+	if o := detail.Offer; o != nil && o.PricePlanID.Valid {
+		if pricePlan == nil || o.PricePlanID.String != pricePlan.ID.String() {
+			offerPricePlan, err = models.GetPricePlan(o.PricePlanID.String)
+			if err != nil {
+				log.Printf("ERROR: Failed to get offer price plan: %v", err)
+			}
+		}
+		if o.BundleLevels.Valid && o.BasePrice.Valid {
+			savedBundle, savedBasePrice = strconv.Itoa(int(o.BundleLevels.Int32)), o.BasePrice.Int32
+		}
+	}
+
+	discountThreshold, err := models.GetDiscountApprovalThreshold()
+	if err != nil {
+		log.Printf("ERROR: Failed to get discount approval threshold: %v", err)
+	}
+	var manualDiscount float64
+	discountNeedsApproval, canApproveDiscount := false, false
+	if detail.Offer != nil {
+		manualDiscount = detail.Offer.ManualDiscountPercent()
+		discountNeedsApproval = manualDiscount > float64(discountThreshold) && !detail.Offer.DiscountApprovedByUserID.Valid
+		canApproveDiscount = discountNeedsApproval && userRole == "admin" &&
+			detail.Offer.DiscountSetByUserID.String != currentUserID
+	}
+
+	offerLetters, err := models.GetOfferLetters(leadID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get offer letters: %v", err)
+	}
+
+	installments, err := models.GetLeadInstallments(leadID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get installments: %v", err)
+	}
+	hasOverdueInstallment := false
+	for _, inst := range installments {
+		if inst.IsOverdue(time.Now()) {
+			hasOverdueInstallment = true
+		}
+	}
+
+	// Referral deduction the offer form's final price includes: what the offer already has, or
+	// the discount a referred lead's first priced offer will get
+	var referralDeduction int32
+	if detail.Offer != nil && detail.Offer.FinalPrice.Valid {
+		referralDeduction = detail.Offer.ReferralDeduction()
+	} else if discount, err := models.GetNewOfferReferralDiscount(leadID); err != nil {
+		log.Printf("ERROR: Failed to get referral discount: %v", err)
+	} else {
+		referralDeduction = discount.Int32
+	}
+	referrals, err := models.GetReferrals(leadID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get referrals: %v", err)
+	}
+	var referralCredit int32
+	for _, ref := range referrals {
+		if ref.Reward != nil && ref.Reward.RewardType == models.ReferralCredit && !ref.Reward.SettledAt.Valid {
+			referralCredit += ref.Reward.Amount
+		}
+	}
+
+	// Round outcomes (closed enrolments) and renewal offers; the open offer is listed first
+	var roundOutcomes []*models.ClassEnrolment
+	enrolments, err := models.GetLeadEnrolments(leadID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get lead enrolments: %v", err)
+	}
+	for _, e := range enrolments {
+		if e.Outcome.Valid {
+			roundOutcomes = append(roundOutcomes, e)
+		}
+	}
+	renewalOffers, err := models.GetLeadRenewalOffers(leadID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get renewal offers: %v", err)
+	}
+	var openRenewal *models.RenewalOffer
+	if len(renewalOffers) > 0 && renewalOffers[0].Status == models.RenewalOfferOpen {
+		openRenewal = renewalOffers[0]
+	}
+
+	customValues, err := models.GetLeadCustomValues(leadID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get custom field values: %v", err)
+	}
+	customFields := customFieldInputs(leadFormCustomFields(customValues), customValues, models.MapOldStatusToStage(detail.Lead.Status))
+
+	// The parent or guardian who pays; admins also see the family's other leads and balance
+	payer, err := models.GetLeadPayer(leadID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get lead payer: %v", err)
+	}
+	var family []*models.FamilyMember
+	var familyRemaining int32
+	if payer != nil && userRole == "admin" {
+		family, err = models.GetFamilyMembers(payer.ID)
+		if err != nil {
+			log.Printf("ERROR: Failed to get family members: %v", err)
+		}
+		_, _, familyRemaining = models.FamilyTotals(family)
+	}
+
+	var merges []*models.LeadMerge
+	if userRole == "admin" {
+		merges, err = models.GetLeadMerges(leadID)
+		if err != nil {
+			log.Printf("ERROR: Failed to get lead merges: %v", err)
+		}
+	}
+
+	var testSlots []*models.PlacementTestSlot
+	if userRole == "admin" {
+		currentSlot := ""
+		if detail.PlacementTest != nil && detail.PlacementTest.SlotID.Valid {
+			currentSlot = detail.PlacementTest.SlotID.String
+		}
+		testSlots, err = models.GetBookablePlacementTestSlots(currentSlot)
+		if err != nil {
+			log.Printf("ERROR: Failed to get placement test slots: %v", err)
+		}
+	}
+
+	var onlineTest *models.OnlinePlacementTest
+	if userRole == "admin" {
+		onlineTest, err = models.GetLatestOnlinePlacementTest(leadID)
+		if err != nil {
+			log.Printf("ERROR: Failed to get online placement test: %v", err)
+		}
+	}
+
 	statusInfo := models.GetStatusDisplayInfo(detail.Lead.Status)
-	if isFullyPaid {
+	if isFullyPaid && detail.Lead.Status != "paused" {
 		statusInfo = models.GetStatusDisplayInfo("paid_full")
 	}
 
@@ -379,6 +732,7 @@ func (h *PreEnrolmentHandler) buildDetailViewModel(detail *models.LeadDetail, le
 		"Detail":                 detail,
 		"UserRole":               userRole,
 		"IsModerator":            userRole == "moderator",
+		"LeadEvents":             models.LeadEventsFrom(detail.Lead.Status, userRole),
 		"IsAdmin":                userRole == "admin",
 		"PlacementTestRemaining": placementTestRemaining,
 		"FollowUpDue":            tempItem.FollowUpDue,
@@ -391,6 +745,7 @@ func (h *PreEnrolmentHandler) buildDetailViewModel(detail *models.LeadDetail, le
 		"TotalCoursePaid":        totalCoursePaid,
 		"RemainingBalance":       remainingBalance,
 		"IsFullyPaid":            isFullyPaid,
+		"CanSchedule":            isFullyPaid || len(installments) > 0,
 		"StatusDisplayName":      statusInfo.DisplayName,
 		"StatusBgColor":          statusInfo.BgColor,
 		"StatusTextColor":        statusInfo.TextColor,
@@ -400,6 +755,41 @@ func (h *PreEnrolmentHandler) buildDetailViewModel(detail *models.LeadDetail, le
 		"ExistingLeadID":         nil,
 		"SuccessMessage":         "",
 		"ShowCancelModal":        false,
+		"StatusHistory":          statusHistory,
+		"Merges":                 merges,
+		"TestSlots":              testSlots,
+		"OnlineTest":             onlineTest,
+		"Sources":                leadSourceOptions(detail.Lead.Source),
+		"Tasks":                  tasks,
+		"TaskAssignees":          taskAssignees,
+		"TaskTypes":              models.LeadTaskTypes,
+		"TaskOutcomes":           models.LeadTaskOutcomes,
+		"LastContactAt":          tempItem.LastContactAt,
+		"Now":                    time.Now(),
+		"Pauses":                 pauses,
+		"LevelsRemaining":        levelsRemaining,
+		"PricePlan":              pricePlan,
+		"OfferPricePlan":         offerPricePlan,
+		"BundlePrices":           bundlePrices,
+		"SavedBundle":            savedBundle,
+		"SavedBasePrice":         savedBasePrice,
+		"DiscountThreshold":      discountThreshold,
+		"ManualDiscountPercent":  int(manualDiscount + 0.5),
+		"DiscountNeedsApproval":  discountNeedsApproval,
+		"CanApproveDiscount":     canApproveDiscount,
+		"OfferLetters":           offerLetters,
+		"Installments":           installments,
+		"HasOverdueInstallment":  hasOverdueInstallment,
+		"ReferralDeduction":      referralDeduction,
+		"Referrals":              referrals,
+		"ReferralCredit":         referralCredit,
+		"RoundOutcomes":          roundOutcomes,
+		"RenewalOffers":          renewalOffers,
+		"OpenRenewal":            openRenewal,
+		"CustomFields":           customFields,
+		"Payer":                  payer,
+		"Family":                 family,
+		"FamilyRemaining":        familyRemaining,
 	}
 	return data, nil
 }
@@ -413,12 +803,50 @@ func (h *PreEnrolmentHandler) renderDetailWithError(w http.ResponseWriter, r *ht
 		return
 	}
 	userRole := middleware.GetUserRole(r)
-	data, _ := h.buildDetailViewModel(detail, leadID, userRole)
+	data, _ := h.buildDetailViewModel(detail, leadID, userRole, middleware.GetUserID(r))
 	data["Error"] = errMsg
 	data["SuccessMessage"] = ""
 	renderTemplate(w, r, "pre_enrolment_detail.html", data)
 }
 
+// renderTransitionError reports a pipeline rejection: 403 for role violations, otherwise the
+// detail page with the reason. Returns false when err is not a *models.LeadTransitionError.
+func (h *PreEnrolmentHandler) renderTransitionError(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, err error) bool {
+	var transitionErr *models.LeadTransitionError
+	if !errors.As(err, &transitionErr) {
+		return false
+	}
+	h.cfg.Debugf("  ⚠️  Transition rejected: %v", transitionErr)
+	if transitionErr.Kind == models.TransitionForbidden {
+		http.Error(w, "Forbidden: "+transitionErr.Error(), http.StatusForbidden)
+		return true
+	}
+	h.renderDetailWithError(w, r, leadID, transitionErr.Error())
+	return true
+}
+
+// renderSlotError shows a placement slot rejection (full, past, removed) on the detail page.
+// Returns false when err is not a *models.PlacementSlotError.
+func (h *PreEnrolmentHandler) renderSlotError(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, err error) bool {
+	var slotErr *models.PlacementSlotError
+	if !errors.As(err, &slotErr) {
+		return false
+	}
+	h.renderDetailWithError(w, r, leadID, slotErr.Error())
+	return true
+}
+
+// renderOnlineTestError shows an online placement test rejection (bank empty, awaiting review,
+// invalid level) on the detail page. Returns false when err is not a *models.OnlineTestError.
+func (h *PreEnrolmentHandler) renderOnlineTestError(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, err error) bool {
+	var testErr *models.OnlineTestError
+	if !errors.As(err, &testErr) {
+		return false
+	}
+	h.renderDetailWithError(w, r, leadID, testErr.Error())
+	return true
+}
+
 func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -446,35 +874,25 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 	switch action {
 	case "mark_test_booked":
 		h.cfg.Debugf("  → Action: mark_test_booked")
-		// Validate placement test fields
-		testDate := r.FormValue("test_date")
-		testTime := r.FormValue("test_time")
-		testType := r.FormValue("test_type")
-		if testDate == "" || testTime == "" || testType == "" {
-			log.Printf("ERROR: Validation failed for mark_test_booked: test_date=%q, test_time=%q, test_type=%q", testDate, testTime, testType)
-			http.Error(w, "Test date, time, and type are required to book placement test", http.StatusBadRequest)
+		if !models.LeadEventAllowedForRole(models.EventBookTest, userRole) {
+			http.Error(w, "Forbidden: Moderators cannot book placement tests", http.StatusForbidden)
 			return
 		}
-
-		// Parse and book test
-		var testDateVal sql.NullTime
-		if t, err := time.Parse("2006-01-02", testDate); err == nil {
-			testDateVal = sql.NullTime{Time: t, Valid: true}
-		}
-		var testTimeVal sql.NullString
-		if testTime != "" {
-			testTimeVal = sql.NullString{String: testTime, Valid: true}
-		}
-		var testTypeVal sql.NullString
-		if testType != "" {
-			testTypeVal = sql.NullString{String: testType, Valid: true}
+		// Tests are booked into a calendar slot; date, time, type and examiner come from the slot
+		slotID, err := uuid.Parse(r.FormValue("test_slot_id"))
+		if err != nil {
+			h.renderDetailWithError(w, r, leadID, "Select a test slot to book the placement test.")
+			return
 		}
 		var testNotes sql.NullString
 		if notes := r.FormValue("test_notes"); notes != "" {
 			testNotes = sql.NullString{String: notes, Valid: true}
 		}
 
-		err = models.BookPlacementTest(leadID, testDateVal, testTimeVal, testTypeVal, testNotes)
+		err = models.BookPlacementTest(leadID, slotID, testNotes, userRole, middleware.GetUserID(r))
+		if h.renderTransitionError(w, r, leadID, err) || h.renderSlotError(w, r, leadID, err) {
+			return
+		}
 		if err != nil {
 			log.Printf("ERROR: Failed to book placement test: %v", err)
 			http.Error(w, fmt.Sprintf("Failed to book placement test: %v", err), http.StatusInternalServerError)
@@ -487,8 +905,8 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	case "mark_tested":
 		h.cfg.Debugf("  → Action: mark_tested")
-		// Server-side check: moderators cannot update status
-		if userRole == "moderator" {
+		// Server-side check: the pipeline decides who may trigger the event
+		if !models.LeadEventAllowedForRole(models.EventMarkTested, userRole) {
 			http.Error(w, "Forbidden: Moderators cannot update lead status", http.StatusForbidden)
 			return
 		}
@@ -523,7 +941,10 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		err = models.UpdateLeadStatus(leadID, "tested")
+		err = models.ApplyLeadEvent(leadID, models.EventMarkTested, userRole, middleware.GetUserID(r), "")
+		if h.renderTransitionError(w, r, leadID, err) {
+			return
+		}
 		if err != nil {
 			log.Printf("ERROR: Failed to update status: %v", err)
 			http.Error(w, fmt.Sprintf("Failed to update status: %v", err), http.StatusInternalServerError)
@@ -534,10 +955,91 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/pre-enrolment?status_flash=tested", http.StatusFound)
 		return
 
+	case "generate_online_test":
+		h.cfg.Debugf("  → Action: generate_online_test")
+		if userRole == "moderator" {
+			http.Error(w, "Forbidden: Moderators cannot send placement tests", http.StatusForbidden)
+			return
+		}
+		test, err := models.CreateOnlinePlacementTest(leadID, middleware.GetUserID(r))
+		if h.renderOnlineTestError(w, r, leadID, err) {
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: Failed to create online placement test: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to create online test: %v", err), http.StatusInternalServerError)
+			return
+		}
+		h.cfg.Debugf("  ✅ Online test %s created with %d questions", test.ID, len(test.QuestionIDs))
+		http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?online_test=created#online-test", leadID.String()), http.StatusFound)
+		return
+
+	case "review_online_test":
+		h.cfg.Debugf("  → Action: review_online_test")
+		// Approving moves the lead to tested, so the same roles as mark_tested may review
+		if !models.LeadEventAllowedForRole(models.EventMarkTested, userRole) {
+			http.Error(w, "Forbidden: Moderators cannot review placement tests", http.StatusForbidden)
+			return
+		}
+		testID, err := uuid.Parse(r.FormValue("online_test_id"))
+		if err != nil {
+			http.Error(w, "Invalid online test ID", http.StatusBadRequest)
+			return
+		}
+		approve := r.FormValue("decision") == "approve"
+		level, _ := strconv.Atoi(r.FormValue("approved_level"))
+
+		err = models.ReviewOnlinePlacementTest(leadID, testID, approve, level, userRole, middleware.GetUserID(r))
+		if h.renderTransitionError(w, r, leadID, err) || h.renderOnlineTestError(w, r, leadID, err) {
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: Failed to review online placement test: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to review online test: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !approve {
+			h.cfg.Debugf("  ✅ Online test rejected, redirecting to detail")
+			http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?online_test=rejected#online-test", leadID.String()), http.StatusFound)
+			return
+		}
+		h.cfg.Debugf("  ✅ Online test approved at level %d, redirecting to list", level)
+		http.Redirect(w, r, "/pre-enrolment?status_flash=tested", http.StatusFound)
+		return
+
+	case "add_task":
+		h.cfg.Debugf("  → Action: add_task")
+		err = models.CreateLeadTask(leadID, r.FormValue("task_type"), parseTaskDue(r.FormValue("due_at")),
+			taskAssigneeFromForm(r, "assignee_user_id"), r.FormValue("task_notes"), middleware.GetUserID(r))
+		if h.renderTaskError(w, r, leadID, err) {
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: Failed to create lead task: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to create task: %v", err), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?task=added#tasks", leadID.String()), http.StatusFound)
+		return
+
+	case "complete_task":
+		h.cfg.Debugf("  → Action: complete_task")
+		err = completeTaskFromForm(r, leadID)
+		if h.renderTaskError(w, r, leadID, err) {
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: Failed to complete lead task: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to complete task: %v", err), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?task=completed#tasks", leadID.String()), http.StatusFound)
+		return
+
 	case "mark_offer_sent":
 		h.cfg.Debugf("  → Action: mark_offer_sent")
-		// Server-side check: moderators cannot update status
-		if userRole == "moderator" {
+		// Server-side check: the pipeline decides who may trigger the event
+		if !models.LeadEventAllowedForRole(models.EventSendOffer, userRole) {
 			http.Error(w, "Forbidden: Moderators cannot update lead status", http.StatusForbidden)
 			return
 		}
@@ -561,18 +1063,27 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 		if detail.Offer == nil {
 			detail.Offer = &models.Offer{LeadID: leadID}
 		}
+		if !detail.Offer.FinalPrice.Valid {
+			detail.Offer.ReferralDiscount, err = models.GetNewOfferReferralDiscount(leadID)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Failed to get referral discount: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
 
 		if b, err := strconv.Atoi(bundle); err == nil {
-			detail.Offer.BundleLevels = sql.NullInt32{Int32: int32(b), Valid: true}
+			plan, err := models.GetCurrentPricePlan(time.Now())
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Failed to load price plan: %v", err), http.StatusInternalServerError)
+				return
+			}
+			if err := detail.Offer.ApplyPricePlan(plan, int32(b)); h.renderPricePlanError(w, r, leadID, err) {
+				return
+			}
 		}
 		if fp, err := strconv.Atoi(finalPrice); err == nil {
 			detail.Offer.FinalPrice = sql.NullInt32{Int32: int32(fp), Valid: true}
 		}
-		if basePrice := r.FormValue("base_price"); basePrice != "" {
-			if bp, err := strconv.Atoi(basePrice); err == nil {
-				detail.Offer.BasePrice = sql.NullInt32{Int32: int32(bp), Valid: true}
-			}
-		}
 		if discount := r.FormValue("discount"); discount != "" {
 			if strings.HasSuffix(discount, "%") {
 				if pct, err := strconv.Atoi(strings.TrimSuffix(discount, "%")); err == nil {
@@ -587,15 +1098,24 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		if err := models.UpdateOffer(detail.Offer); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to update offer: %v", err), http.StatusInternalServerError)
+		err = applyOfferPromoCode(detail.Offer, r.FormValue("promo_code"))
+		if h.renderOfferError(w, r, leadID, err) {
 			return
 		}
+		if err != nil {
+			log.Printf("ERROR: Failed to apply promo code: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to apply promo code: %v", err), http.StatusInternalServerError)
+			return
+		}
+		detail.Offer.DiscountSetByUserID = sql.NullString{String: middleware.GetUserID(r), Valid: true}
 
-		err = models.UpdateLeadStatus(leadID, "offer_sent")
+		err = models.SendOffer(detail.Offer, userRole, middleware.GetUserID(r))
+		if h.renderOfferError(w, r, leadID, err) {
+			return
+		}
 		if err != nil {
-			log.Printf("ERROR: Failed to update status: %v", err)
-			http.Error(w, fmt.Sprintf("Failed to update status: %v", err), http.StatusInternalServerError)
+			log.Printf("ERROR: Failed to send offer: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to send offer: %v", err), http.StatusInternalServerError)
 			return
 		}
 
@@ -605,13 +1125,16 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	case "move_waiting":
 		h.cfg.Debugf("  → Action: move_waiting")
-		if userRole == "moderator" {
+		if !models.LeadEventAllowedForRole(models.EventMoveWaiting, userRole) {
 			http.Error(w, "Forbidden: Moderators cannot update lead status", http.StatusForbidden)
 			return
 		}
 
 		// WAITING allowed regardless of course payments. No refund; payments stay.
-		err = models.UpdateLeadStatus(leadID, "waiting_for_round")
+		err = models.ApplyLeadEvent(leadID, models.EventMoveWaiting, userRole, middleware.GetUserID(r), "")
+		if h.renderTransitionError(w, r, leadID, err) {
+			return
+		}
 		if err != nil {
 			log.Printf("ERROR: Failed to update status: %v", err)
 			http.Error(w, fmt.Sprintf("Failed to update status: %v", err), http.StatusInternalServerError)
@@ -624,43 +1147,12 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	case "mark_ready":
 		h.cfg.Debugf("  → Action: mark_ready")
-		// Server-side check: moderators cannot update status
-		if userRole == "moderator" {
+		// Server-side check: the pipeline decides who may trigger the event
+		if !models.LeadEventAllowedForRole(models.EventMarkReady, userRole) {
 			http.Error(w, "Forbidden: Moderators cannot update lead status", http.StatusForbidden)
 			return
 		}
 
-		// Get lead detail to validate prerequisites
-		detail, err := models.GetLeadByID(leadID)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to load lead: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		// Check if fully paid
-		var finalPriceValue int32 = 0
-		if detail.Offer != nil && detail.Offer.FinalPrice.Valid {
-			finalPriceValue = detail.Offer.FinalPrice.Int32
-		}
-		
-		totalCoursePaid, err := models.GetTotalCoursePaid(leadID)
-		if err != nil {
-			log.Printf("ERROR: Failed to get total course paid: %v", err)
-			totalCoursePaid = 0
-		}
-		
-		isFullyPaid := finalPriceValue > 0 && totalCoursePaid >= finalPriceValue
-		if !isFullyPaid {
-			h.renderDetailWithError(w, r, leadID, "Cannot mark READY_TO_START before full payment. Course must be fully paid first.")
-			return
-		}
-
-		// Check assigned level exists
-		if detail.PlacementTest == nil || !detail.PlacementTest.AssignedLevel.Valid {
-			h.renderDetailWithError(w, r, leadID, "Cannot mark READY_TO_START: Assigned level must be set first.")
-			return
-		}
-
 		// Schedule required: both Class Days and Class Time must be present
 		classDaysMR := r.FormValue("class_days")
 		classTimeMR := r.FormValue("class_time")
@@ -679,12 +1171,11 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := models.UpsertSchedulingClassDaysTime(leadID, classDaysMR, classTimeMR); err != nil {
-			log.Printf("ERROR: Failed to save schedule: %v", err)
-			http.Error(w, fmt.Sprintf("Failed to save schedule: %v", err), http.StatusInternalServerError)
+		// Payment and assigned level are checked by the pipeline guards; the schedule is only saved if they pass
+		err = models.MarkLeadReady(leadID, classDaysMR, classTimeMR, userRole, middleware.GetUserID(r))
+		if h.renderTransitionError(w, r, leadID, err) {
 			return
 		}
-		err = models.UpdateLeadStatus(leadID, "ready_to_start")
 		if err != nil {
 			log.Printf("ERROR: Failed to update status: %v", err)
 			http.Error(w, fmt.Sprintf("Failed to update status: %v", err), http.StatusInternalServerError)
@@ -726,6 +1217,15 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 		// Send to classes
 		err = models.SendLeadToClasses(leadID)
+		var installmentErr *models.InstallmentError
+		if errors.As(err, &installmentErr) {
+			if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
+				jsonResponse(w, http.StatusBadRequest, map[string]interface{}{"success": false, "error": installmentErr.Error()})
+				return
+			}
+			h.renderDetailWithError(w, r, leadID, installmentErr.Error())
+			return
+		}
 		if err != nil {
 			log.Printf("ERROR: Failed to send lead to classes: %v", err)
 			// Check if AJAX request
@@ -753,7 +1253,7 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 	case "cancel":
 		h.cfg.Debugf("  → Action: cancel")
 		// Server-side check: moderators cannot cancel
-		if userRole == "moderator" {
+		if !models.LeadEventAllowedForRole(models.EventCancel, userRole) {
 			http.Error(w, "Forbidden: Moderators cannot cancel leads", http.StatusForbidden)
 			return
 		}
@@ -854,7 +1354,14 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 
-			err = models.CancelLead(leadID)
+			cancelReason := strings.TrimSpace(r.FormValue("cancel_reason"))
+			if cancelReason == "" {
+				cancelReason = strings.TrimSpace(refundNotes)
+			}
+			err = models.ApplyLeadEvent(leadID, models.EventCancel, userRole, middleware.GetUserID(r), cancelReason)
+			if h.renderTransitionError(w, r, leadID, err) {
+				return
+			}
 			if err != nil {
 				log.Printf("ERROR: Failed to cancel lead: %v", err)
 				http.Error(w, fmt.Sprintf("Failed to cancel lead: %v", err), http.StatusInternalServerError)
@@ -912,6 +1419,8 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 			"Detail":                 detail,
 			"UserRole":               userRole,
 			"IsModerator":            false,
+			"LeadEvents":             models.LeadEventsFrom(detail.Lead.Status, userRole),
+			"Sources":                leadSourceOptions(detail.Lead.Source),
 			"ShowCancelModal":        true,
 			"PlacementTestPaid":      placementTestPaid,
 			"TotalCoursePaid":        totalCoursePaid,
@@ -926,13 +1435,16 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 	case "reopen":
 		h.cfg.Debugf("  → Action: reopen")
 		// Server-side check: moderators cannot reopen
-		if userRole == "moderator" {
+		if !models.LeadEventAllowedForRole(models.EventReopen, userRole) {
 			http.Error(w, "Forbidden: Moderators cannot reopen leads", http.StatusForbidden)
 			return
 		}
 		
 		// Reopen the cancelled lead
-		err = models.ReopenLead(leadID)
+		err = models.ApplyLeadEvent(leadID, models.EventReopen, userRole, middleware.GetUserID(r), "")
+		if h.renderTransitionError(w, r, leadID, err) {
+			return
+		}
 		if err != nil {
 			log.Printf("ERROR: Failed to reopen lead: %v", err)
 			http.Error(w, fmt.Sprintf("Failed to reopen lead: %v", err), http.StatusInternalServerError)
@@ -943,19 +1455,162 @@ func (h *PreEnrolmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?reopened=1", leadID.String()), http.StatusFound)
 		return
 
-	case "delete":
-		h.cfg.Debugf("  → Action: delete")
+	case "pause":
+		h.cfg.Debugf("  → Action: pause")
+		if !models.LeadEventAllowedForRole(models.EventPause, userRole) {
+			http.Error(w, "Forbidden: Moderators cannot pause students", http.StatusForbidden)
+			return
+		}
+		err = models.PauseLead(leadID, r.FormValue("pause_reason"), parseReturnDate(r.FormValue("expected_return_date")), userRole, middleware.GetUserID(r))
+		if h.renderPauseError(w, r, leadID, err) {
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: Failed to pause lead: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to pause student: %v", err), http.StatusInternalServerError)
+			return
+		}
+		h.cfg.Debugf("  ✅ Student paused, redirecting to detail")
+		http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?paused=1#pause", leadID.String()), http.StatusFound)
+		return
+
+	case "resume":
+		h.cfg.Debugf("  → Action: resume")
+		if !models.LeadEventAllowedForRole(models.EventResume, userRole) {
+			http.Error(w, "Forbidden: Moderators cannot resume students", http.StatusForbidden)
+			return
+		}
+		err = models.ResumeLead(leadID, userRole, middleware.GetUserID(r))
+		if h.renderPauseError(w, r, leadID, err) {
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: Failed to resume lead: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to resume student: %v", err), http.StatusInternalServerError)
+			return
+		}
+		h.cfg.Debugf("  ✅ Student resumed, redirecting to detail")
+		http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?resumed=1#pause", leadID.String()), http.StatusFound)
+		return
+
+	case "approve_discount":
+		h.cfg.Debugf("  → Action: approve_discount")
+		if userRole != "admin" {
+			http.Error(w, "Forbidden: Only admins can approve discounts", http.StatusForbidden)
+			return
+		}
+		err = models.ApproveOfferDiscount(leadID, middleware.GetUserID(r))
+		if h.renderOfferError(w, r, leadID, err) {
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: Failed to approve discount: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to approve discount: %v", err), http.StatusInternalServerError)
+			return
+		}
+		h.cfg.Debugf("  ✅ Discount approved, redirecting to detail")
+		http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?discount_approved=1#offer", leadID.String()), http.StatusFound)
+		return
+
+	case "save_installments":
+		h.cfg.Debugf("  → Action: save_installments")
+		if userRole != "admin" {
+			http.Error(w, "Forbidden: Only admins can set installment plans", http.StatusForbidden)
+			return
+		}
+		h.saveInstallments(w, r, leadID, middleware.GetUserID(r))
+		return
+
+	case "clear_installments":
+		h.cfg.Debugf("  → Action: clear_installments")
+		if userRole != "admin" {
+			http.Error(w, "Forbidden: Only admins can set installment plans", http.StatusForbidden)
+			return
+		}
+		if err := models.ClearLeadInstallments(leadID); err != nil {
+			log.Printf("ERROR: Failed to clear installment plan: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to clear installment plan: %v", err), http.StatusInternalServerError)
+			return
+		}
+		h.cfg.Debugf("  ✅ Installment plan cleared, redirecting to detail")
+		http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?installments=cleared#installments", leadID.String()), http.StatusFound)
+		return
+
+	case "set_payer":
+		h.cfg.Debugf("  → Action: set_payer")
+		h.setPayer(w, r, leadID, middleware.GetUserID(r))
+		return
+
+	case "set_referrer":
+		h.cfg.Debugf("  → Action: set_referrer")
+		if userRole != "admin" {
+			http.Error(w, "Forbidden: Only admins can set referrers", http.StatusForbidden)
+			return
+		}
+		h.setReferrer(w, r, leadID)
+		return
+
+	case "apply_referral_credit":
+		h.cfg.Debugf("  → Action: apply_referral_credit")
+		if userRole != "admin" {
+			http.Error(w, "Forbidden: Only admins can apply referral credit", http.StatusForbidden)
+			return
+		}
+		h.applyReferralCredit(w, r, leadID, middleware.GetUserID(r))
+		return
+
+	case "pay_referral_cashback":
+		h.cfg.Debugf("  → Action: pay_referral_cashback")
+		if userRole != "admin" {
+			http.Error(w, "Forbidden: Only admins can pay referral cash-back", http.StatusForbidden)
+			return
+		}
+		h.payReferralCashback(w, r, leadID, middleware.GetUserID(r))
+		return
+
+	case "accept_renewal":
+		h.cfg.Debugf("  → Action: accept_renewal")
+		if userRole != "admin" {
+			http.Error(w, "Forbidden: Only admins can record renewals", http.StatusForbidden)
+			return
+		}
+		h.acceptRenewal(w, r, leadID, middleware.GetUserID(r))
+		return
+
+	case "decline_renewal":
+		h.cfg.Debugf("  → Action: decline_renewal")
+		if userRole != "admin" {
+			http.Error(w, "Forbidden: Only admins can decline renewals", http.StatusForbidden)
+			return
+		}
+		h.declineRenewal(w, r, leadID, middleware.GetUserID(r))
+		return
+
+	case "set_shipment_status":
+		h.cfg.Debugf("  → Action: set_shipment_status")
+		if userRole != "admin" {
+			http.Error(w, "Forbidden: Only admins can update shipments", http.StatusForbidden)
+			return
+		}
+		h.setShipmentStatus(w, r, leadID)
+		return
+
+	case "archive":
+		h.cfg.Debugf("  → Action: archive")
 		if userRole == "moderator" {
-			http.Error(w, "Forbidden: Moderators cannot delete leads", http.StatusForbidden)
+			http.Error(w, "Forbidden: Moderators cannot archive leads", http.StatusForbidden)
 			return
 		}
-		if err := models.DeleteLead(leadID); err != nil {
-			log.Printf("ERROR: Failed to delete lead: %v", err)
-			http.Error(w, fmt.Sprintf("Failed to delete lead: %v", err), http.StatusInternalServerError)
+		h.archiveLead(w, r, leadID, middleware.GetUserID(r))
+		return
+
+	case "restore":
+		h.cfg.Debugf("  → Action: restore")
+		if userRole != "admin" {
+			http.Error(w, "Forbidden: Only admins can restore leads", http.StatusForbidden)
 			return
 		}
-		h.cfg.Debugf("  ✅ Lead deleted, redirecting to list")
-		http.Redirect(w, r, "/pre-enrolment?deleted=1", http.StatusFound)
+		h.restoreLead(w, r, leadID)
 		return
 
 	case "save", "":
@@ -979,13 +1634,7 @@ func (h *PreEnrolmentHandler) UpdateStatus(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Server-side check: moderators cannot update status
 	userRole := middleware.GetUserRole(r)
-	if userRole == "moderator" {
-		http.Error(w, "Forbidden: Moderators cannot update lead status", http.StatusForbidden)
-		return
-	}
-
 	pathParts := strings.Split(r.URL.Path, "/")
 	if len(pathParts) < 4 {
 		http.Error(w, "Invalid lead ID", http.StatusBadRequest)
@@ -1004,7 +1653,19 @@ func (h *PreEnrolmentHandler) UpdateStatus(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = models.UpdateLeadStatus(leadID, status)
+	// Resolve the pipeline event that leads to the requested status; the event re-checks role and guards
+	detail, err := models.GetLeadByID(leadID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load lead: %v", err), http.StatusInternalServerError)
+		return
+	}
+	event, err := models.FindLeadEvent(detail.Lead.Status, status, userRole)
+	if err == nil {
+		err = models.ApplyLeadEvent(leadID, event, userRole, middleware.GetUserID(r), r.FormValue("reason"))
+	}
+	if h.renderTransitionError(w, r, leadID, err) {
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update status: %v", err), http.StatusInternalServerError)
 		return
@@ -1017,6 +1678,7 @@ func (h *PreEnrolmentHandler) UpdateStatus(w http.ResponseWriter, r *http.Reques
 // IMPORTANT: This function now auto-classifies stage based on form completion.
 // Stage is computed from the furthest completed block and automatically upgraded.
 // Never downgrades stage - only upgrades based on what's filled.
+// The computed status goes through the lead pipeline (models.UpdateLeadDetail), so its guards and effects apply.
 // Validation: only validates basic lead fields (name, phone) + final_price if stage reaches OFFER_SENT
 // Does NOT require offer/pricing fields for test booking - can save test info without offer
 func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
@@ -1067,6 +1729,20 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Custom fields are checked against the lead's current stage here, and against the stage the
+	// save moves it to further down
+	existingCustom, err := models.GetLeadCustomValues(leadID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load custom fields: %v", err), http.StatusInternalServerError)
+		return
+	}
+	customFields := leadFormCustomFields(existingCustom)
+	customValues, saveCustom, err := parseLeadCustomValues(r, customFields, models.MapOldStatusToStage(existingDetail.Lead.Status))
+	if err != nil {
+		h.renderDetailWithError(w, r, leadID, err.Error())
+		return
+	}
+
 	// Parse form values
 	detail := &models.LeadDetail{
 		Lead: &models.Lead{
@@ -1079,14 +1755,9 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 	// Moderator restrictions: only allow editing Lead Info (name, phone, source, notes)
 	if userRole == "moderator" {
 		h.cfg.Debugf("  🔒 Moderator save: only updating Lead Info section")
-		source := r.FormValue("source")
-		allowedSources := map[string]bool{
-			"Facebook": true, "WhatsApp": true, "Instagram": true, "Referral": true, "Walk-in": true, "Other": true,
-		}
-		if source != "" && allowedSources[source] {
+		if source := r.FormValue("source"); source != "" {
+			source = models.NormalizeLeadSource(source, leadSourceOptions(existingDetail.Lead.Source))
 			detail.Lead.Source = sql.NullString{String: source, Valid: true}
-		} else if source != "" {
-			detail.Lead.Source = sql.NullString{String: "Other", Valid: true}
 		} else {
 			detail.Lead.Source = sql.NullString{Valid: false}
 		}
@@ -1111,6 +1782,13 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, fmt.Sprintf("Failed to update lead: %v", err), http.StatusInternalServerError)
 			return
 		}
+		if saveCustom {
+			if err := models.SaveLeadCustomValues(leadID, customFields, customValues); err != nil {
+				log.Printf("ERROR: Failed to save custom fields (moderator): %v", err)
+				http.Error(w, fmt.Sprintf("Failed to save custom fields: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
 		h.cfg.Debugf("  ✅ Moderator save successful")
 		http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?saved=1", leadID.String()), http.StatusFound)
 		return
@@ -1120,18 +1798,8 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 	h.cfg.Debugf("  👤 Admin save: updating all sections")
 
 	if source := r.FormValue("source"); source != "" {
-		// Validate source is one of allowed options
-		allowedSources := map[string]bool{
-			"Facebook":  true,
-			"WhatsApp":  true,
-			"Instagram": true,
-			"Referral":  true,
-			"Walk-in":   true,
-			"Other":     true,
-		}
-		if !allowedSources[source] {
-			source = "Other" // Default to Other if invalid
-		}
+		// Validate source is on the managed list (defaults to Other)
+		source = models.NormalizeLeadSource(source, leadSourceOptions(existingDetail.Lead.Source))
 		detail.Lead.Source = sql.NullString{String: source, Valid: true}
 	}
 	if notes := r.FormValue("notes"); notes != "" {
@@ -1145,19 +1813,8 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 	// This will be used after all sections are parsed
 
 	// Placement test
-	if r.FormValue("test_date") != "" || r.FormValue("assigned_level") != "" || r.FormValue("placement_test_fee") != "" {
+	if existingDetail.PlacementTest != nil || r.FormValue("assigned_level") != "" || r.FormValue("placement_test_fee") != "" {
 		pt := &models.PlacementTest{LeadID: leadID}
-		if testDate := r.FormValue("test_date"); testDate != "" {
-			if t, err := time.Parse("2006-01-02", testDate); err == nil {
-				pt.TestDate = sql.NullTime{Time: t, Valid: true}
-			}
-		}
-		if testTime := r.FormValue("test_time"); testTime != "" {
-			pt.TestTime = sql.NullString{String: testTime, Valid: true}
-		}
-		if testType := r.FormValue("test_type"); testType != "" {
-			pt.TestType = sql.NullString{String: testType, Valid: true}
-		}
 		if assignedLevel := r.FormValue("assigned_level"); assignedLevel != "" {
 			level, err := strconv.Atoi(assignedLevel)
 			if err != nil || !isValidAssignedLevel(level) {
@@ -1214,6 +1871,14 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 			pt.PlacementTestPaymentMethod = sql.NullString{Valid: false}
 		}
 
+		// Slot bookings own date/time/type and examiner; booking and rescheduling go through the slot picker
+		if existingDetail.PlacementTest != nil {
+			pt.SlotID = existingDetail.PlacementTest.SlotID
+			pt.RunByUserID = existingDetail.PlacementTest.RunByUserID
+			pt.TestDate = existingDetail.PlacementTest.TestDate
+			pt.TestTime = existingDetail.PlacementTest.TestTime
+			pt.TestType = existingDetail.PlacementTest.TestType
+		}
 		detail.PlacementTest = pt
 	}
 
@@ -1260,28 +1925,38 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 			offer.DiscountValue = existingOffer.DiscountValue
 			offer.DiscountType = existingOffer.DiscountType
 			offer.FinalPrice = existingOffer.FinalPrice
-		}
-		
-		// Hardcoded bundle prices
-		bundlePrices := map[int32]int32{
-			1: 1300,
-			2: 2400,
-			3: 3300,
-			4: 4000,
+			offer.PricePlanID = existingOffer.PricePlanID
+			offer.PromoCodeID = existingOffer.PromoCodeID
+			offer.PromoRedeemedAt = existingOffer.PromoRedeemedAt
+			offer.ReferralDiscount = existingOffer.ReferralDiscount
+			offer.ReferralCredit = existingOffer.ReferralCredit
+		}
+		// A referred lead's first priced offer gets the referral discount (the form's final price already includes it)
+		if existingOffer == nil || !existingOffer.FinalPrice.Valid {
+			offer.ReferralDiscount, err = models.GetNewOfferReferralDiscount(leadID)
+			if err != nil {
+				log.Printf("ERROR: Failed to get referral discount: %v", err)
+				http.Error(w, fmt.Sprintf("Failed to get referral discount: %v", err), http.StatusInternalServerError)
+				return
+			}
 		}
 		
 		// Update with form values
 		var basePrice int32 = 0
 		if bundleStr != "" {
-			if b, err := strconv.Atoi(bundleStr); err == nil && b >= 1 && b <= 4 {
-				bundleLevel := int32(b)
-				offer.BundleLevels = sql.NullInt32{Int32: bundleLevel, Valid: true}
-				// Auto-set base price from hardcoded bundle prices
-				if price, ok := bundlePrices[bundleLevel]; ok {
-					basePrice = price
-					offer.BasePrice = sql.NullInt32{Int32: basePrice, Valid: true}
-					h.cfg.Debugf("  💰 Bundle %d selected: auto-set base_price=%d, leadID=%s", bundleLevel, basePrice, leadID)
+			if b, err := strconv.Atoi(bundleStr); err == nil && b >= 1 && b <= models.MaxBundleLevels {
+				// Auto-set base price from the price plan in effect (an unchanged bundle keeps the offer's snapshot)
+				plan, err := models.GetCurrentPricePlan(time.Now())
+				if err != nil {
+					log.Printf("ERROR: Failed to load price plan: %v", err)
+					http.Error(w, fmt.Sprintf("Failed to load price plan: %v", err), http.StatusInternalServerError)
+					return
 				}
+				if err := offer.ApplyPricePlan(plan, int32(b)); h.renderPricePlanError(w, r, leadID, err) {
+					return
+				}
+				basePrice = offer.BasePrice.Int32
+				h.cfg.Debugf("  💰 Bundle %d selected: base_price=%d from plan %s, leadID=%s", b, basePrice, offer.PricePlanID.String, leadID)
 			}
 		}
 		
@@ -1331,7 +2006,7 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 			}
 		} else if basePrice > 0 {
 			// Auto-calculate final price from base - discount (only if final_price not explicitly provided)
-			calculatedFinalPrice := basePrice - discountAmount
+			calculatedFinalPrice := basePrice - discountAmount - offer.ReferralDeduction()
 			if calculatedFinalPrice < 0 {
 				calculatedFinalPrice = 0
 			}
@@ -1347,6 +2022,18 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 			h.cfg.Debugf("  ⚠️  Offer Final Price: NOT SET (new offer or no existing), leadID=%s", leadID)
 		}
 		
+		// A promo code replaces the manual discount and sets the final price
+		err = applyOfferPromoCode(offer, r.FormValue("promo_code"))
+		if h.renderOfferError(w, r, leadID, err) {
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: Failed to apply promo code: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to apply promo code: %v", err), http.StatusInternalServerError)
+			return
+		}
+		offer.DiscountSetByUserID = sql.NullString{String: middleware.GetUserID(r), Valid: true}
+		
 		detail.Offer = offer
 		finalPriceVal := int32(0)
 		if offer.FinalPrice.Valid {
@@ -1438,30 +2125,29 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 
 	// If user is setting schedule (either field provided), validate payment first
 	if classDays != "" || classTime != "" {
-		// Check if fully paid before allowing schedule updates
-		var finalPriceValue int32 = 0
-		if existingDetail.Offer != nil && existingDetail.Offer.FinalPrice.Valid {
-			finalPriceValue = existingDetail.Offer.FinalPrice.Int32
-		}
-		
-		totalCoursePaid, err := models.GetTotalCoursePaid(leadID)
-		if err != nil {
-			log.Printf("ERROR: Failed to get total course paid: %v", err)
-			totalCoursePaid = 0
-		}
-		
-		isFullyPaid := finalPriceValue > 0 && totalCoursePaid >= finalPriceValue
-		
-		if !isFullyPaid {
-			h.renderDetailWithError(w, r, leadID, "Cannot schedule before full payment. Course must be fully paid before setting class days and time.")
-			return
+		existingScheduling := existingDetail.Scheduling
+		hasExistingSchedule := existingScheduling != nil && existingScheduling.ClassDays.Valid && existingScheduling.ClassTime.Valid
+
+		// Same guards as Mark Ready: fully paid or on an installment plan, and no overdue installment
+		// while installment_overdue_blocks_classes is on. An unchanged schedule (posted back by the
+		// locked form) is not checked again.
+		scheduleChanged := !hasExistingSchedule ||
+			(classDays != "" && classDays != existingScheduling.ClassDays.String) ||
+			(classTime != "" && classTime != existingScheduling.ClassTime.String)
+		if scheduleChanged {
+			err := models.CheckClassScheduling(leadID)
+			if h.renderTransitionError(w, r, leadID, err) {
+				return
+			}
+			if err != nil {
+				log.Printf("ERROR: Failed to check class scheduling: %v", err)
+				http.Error(w, fmt.Sprintf("Failed to check class scheduling: %v", err), http.StatusInternalServerError)
+				return
+			}
 		}
-		
+
 		// Both fields must be present when setting NEW schedule
 		// But if one is already set, allow updating just the other one
-		existingScheduling := existingDetail.Scheduling
-		hasExistingSchedule := existingScheduling != nil && existingScheduling.ClassDays.Valid && existingScheduling.ClassTime.Valid
-		
 		if !hasExistingSchedule && (classDays == "" || classTime == "") {
 			h.renderDetailWithError(w, r, leadID, "Both Class Days and Class Time are required when setting schedule.")
 			return
@@ -1534,19 +2220,8 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 		detail.Scheduling = scheduling
 	}
 
-	// Shipping
-	if r.FormValue("shipment_status") != "" {
-		shipping := &models.Shipping{LeadID: leadID}
-		if shipmentStatus := r.FormValue("shipment_status"); shipmentStatus != "" {
-			shipping.ShipmentStatus = sql.NullString{String: shipmentStatus, Valid: true}
-		}
-		if shipmentDate := r.FormValue("shipment_date"); shipmentDate != "" {
-			if t, err := time.Parse("2006-01-02", shipmentDate); err == nil {
-				shipping.ShipmentDate = sql.NullTime{Time: t, Valid: true}
-			}
-		}
-		detail.Shipping = shipping
-	}
+	// Shipping is not saved from this form: books are packed and sent through shipment batches
+	// (/pre-enrolment/shipping) and marked delivered/returned with the set_shipment_status action
 
 	// CRITICAL: Preserve existing offer for display/computation, but track if it was explicitly changed
 	// This ensures that if user only updates other sections, offer final_price is not lost
@@ -1595,6 +2270,18 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Validation: custom fields required at the stage the lead is moving to
+	if dbStatus != currentStatus {
+		values := customValues
+		if !saveCustom {
+			values = existingCustom
+		}
+		if err := models.CheckRequiredCustomFields(customFields, values, newStage); err != nil {
+			h.renderDetailWithError(w, r, leadID, err.Error())
+			return
+		}
+	}
+
 	detail.Lead.Status = dbStatus
 	h.cfg.Debugf("  📊 Auto-stage: computed stage=%s, dbStatus=%s (was %s)", newStage, dbStatus, currentStatus)
 
@@ -1610,7 +2297,10 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 		h.cfg.Debugf("  💾 About to save: Offer is nil, leadID=%s", leadID)
 	}
 	
-	err = models.UpdateLeadDetail(detail)
+	err = models.UpdateLeadDetail(detail, userRole, middleware.GetUserID(r))
+	if h.renderOfferError(w, r, leadID, err) {
+		return
+	}
 	if err != nil {
 		// Check if it's a phone constraint error
 		var phoneErr *models.PhoneAlreadyExistsError
@@ -1646,6 +2336,14 @@ func (h *PreEnrolmentHandler) SaveFull(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	if saveCustom {
+		if err := models.SaveLeadCustomValues(leadID, customFields, customValues); err != nil {
+			log.Printf("ERROR: Failed to save custom fields: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to save custom fields: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Log after saving - reload to verify
 	reloadedDetail, err := models.GetLeadByID(leadID)
 	if err == nil && reloadedDetail.Offer != nil {
@@ -1781,9 +2479,9 @@ func (h *PreEnrolmentHandler) MarkTested(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Server-side check: moderators cannot update status
+	// Server-side check: the pipeline decides who may trigger the event
 	userRole := middleware.GetUserRole(r)
-	if userRole == "moderator" {
+	if !models.LeadEventAllowedForRole(models.EventMarkTested, userRole) {
 		http.Error(w, "Forbidden: Moderators cannot update lead status", http.StatusForbidden)
 		return
 	}
@@ -1832,7 +2530,10 @@ func (h *PreEnrolmentHandler) MarkTested(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Update status
-	err = models.UpdateLeadStatus(leadID, "tested")
+	err = models.ApplyLeadEvent(leadID, models.EventMarkTested, userRole, middleware.GetUserID(r), "")
+	if h.renderTransitionError(w, r, leadID, err) {
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update status: %v", err), http.StatusInternalServerError)
 		return
@@ -1848,9 +2549,9 @@ func (h *PreEnrolmentHandler) MarkOfferSent(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Server-side check: moderators cannot update status
+	// Server-side check: the pipeline decides who may trigger the event
 	userRole := middleware.GetUserRole(r)
-	if userRole == "moderator" {
+	if !models.LeadEventAllowedForRole(models.EventSendOffer, userRole) {
 		http.Error(w, "Forbidden: Moderators cannot update lead status", http.StatusForbidden)
 		return
 	}
@@ -1885,18 +2586,27 @@ func (h *PreEnrolmentHandler) MarkOfferSent(w http.ResponseWriter, r *http.Reque
 	if detail.Offer == nil {
 		detail.Offer = &models.Offer{LeadID: leadID}
 	}
+	if !detail.Offer.FinalPrice.Valid {
+		detail.Offer.ReferralDiscount, err = models.GetNewOfferReferralDiscount(leadID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get referral discount: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
 
 	if b, err := strconv.Atoi(bundle); err == nil {
-		detail.Offer.BundleLevels = sql.NullInt32{Int32: int32(b), Valid: true}
+		plan, err := models.GetCurrentPricePlan(time.Now())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to load price plan: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if err := detail.Offer.ApplyPricePlan(plan, int32(b)); h.renderPricePlanError(w, r, leadID, err) {
+			return
+		}
 	}
 	if fp, err := strconv.Atoi(finalPrice); err == nil {
 		detail.Offer.FinalPrice = sql.NullInt32{Int32: int32(fp), Valid: true}
 	}
-	if basePrice := r.FormValue("base_price"); basePrice != "" {
-		if bp, err := strconv.Atoi(basePrice); err == nil {
-			detail.Offer.BasePrice = sql.NullInt32{Int32: int32(bp), Valid: true}
-		}
-	}
 	if discount := r.FormValue("discount"); discount != "" {
 		// Parse discount (could be "500" or "10%")
 		if strings.HasSuffix(discount, "%") {
@@ -1912,16 +2622,24 @@ func (h *PreEnrolmentHandler) MarkOfferSent(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	// Update offer
-	if err := models.UpdateOffer(detail.Offer); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update offer: %v", err), http.StatusInternalServerError)
+	err = applyOfferPromoCode(detail.Offer, r.FormValue("promo_code"))
+	if h.renderOfferError(w, r, leadID, err) {
 		return
 	}
+	if err != nil {
+		log.Printf("ERROR: Failed to apply promo code: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to apply promo code: %v", err), http.StatusInternalServerError)
+		return
+	}
+	detail.Offer.DiscountSetByUserID = sql.NullString{String: middleware.GetUserID(r), Valid: true}
 
-	// Update status
-	err = models.UpdateLeadStatus(leadID, "offer_sent")
+	// Save the offer and send it (status, promo redemption and letter) together
+	err = models.SendOffer(detail.Offer, userRole, middleware.GetUserID(r))
+	if h.renderOfferError(w, r, leadID, err) {
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update status: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to send offer: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -1935,9 +2653,9 @@ func (h *PreEnrolmentHandler) MarkWaiting(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Server-side check: moderators cannot update status
+	// Server-side check: the pipeline decides who may trigger the event
 	userRole := middleware.GetUserRole(r)
-	if userRole == "moderator" {
+	if !models.LeadEventAllowedForRole(models.EventMoveWaiting, userRole) {
 		http.Error(w, "Forbidden: Moderators cannot update lead status", http.StatusForbidden)
 		return
 	}
@@ -1954,7 +2672,10 @@ func (h *PreEnrolmentHandler) MarkWaiting(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = models.UpdateLeadStatus(leadID, "waiting_for_round")
+	err = models.ApplyLeadEvent(leadID, models.EventMoveWaiting, userRole, middleware.GetUserID(r), "")
+	if h.renderTransitionError(w, r, leadID, err) {
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update status: %v", err), http.StatusInternalServerError)
 		return
@@ -1970,9 +2691,9 @@ func (h *PreEnrolmentHandler) MarkReady(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Server-side check: moderators cannot update status
+	// Server-side check: the pipeline decides who may trigger the event
 	userRole := middleware.GetUserRole(r)
-	if userRole == "moderator" {
+	if !models.LeadEventAllowedForRole(models.EventMarkReady, userRole) {
 		http.Error(w, "Forbidden: Moderators cannot update lead status", http.StatusForbidden)
 		return
 	}
@@ -1989,7 +2710,10 @@ func (h *PreEnrolmentHandler) MarkReady(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = models.UpdateLeadStatus(leadID, "ready_to_start")
+	err = models.ApplyLeadEvent(leadID, models.EventMarkReady, userRole, middleware.GetUserID(r), "")
+	if h.renderTransitionError(w, r, leadID, err) {
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update status: %v", err), http.StatusInternalServerError)
 		return
@@ -2006,7 +2730,7 @@ func (h *PreEnrolmentHandler) BookTest(w http.ResponseWriter, r *http.Request) {
 
 	// Server-side check: moderators cannot book tests
 	userRole := middleware.GetUserRole(r)
-	if userRole == "moderator" {
+	if !models.LeadEventAllowedForRole(models.EventBookTest, userRole) {
 		http.Error(w, "Forbidden: Moderators cannot book placement tests", http.StatusForbidden)
 		return
 	}
@@ -2023,22 +2747,10 @@ func (h *PreEnrolmentHandler) BookTest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse placement test fields
-	var testDate sql.NullTime
-	if dateStr := r.FormValue("test_date"); dateStr != "" {
-		if t, err := time.Parse("2006-01-02", dateStr); err == nil {
-			testDate = sql.NullTime{Time: t, Valid: true}
-		}
-	}
-
-	var testTime sql.NullString
-	if timeStr := r.FormValue("test_time"); timeStr != "" {
-		testTime = sql.NullString{String: timeStr, Valid: true}
-	}
-
-	var testType sql.NullString
-	if typeStr := r.FormValue("test_type"); typeStr != "" {
-		testType = sql.NullString{String: typeStr, Valid: true}
+	slotID, err := uuid.Parse(r.FormValue("test_slot_id"))
+	if err != nil {
+		http.Error(w, "Test slot is required to book placement test", http.StatusBadRequest)
+		return
 	}
 
 	var testNotes sql.NullString
@@ -2046,10 +2758,13 @@ func (h *PreEnrolmentHandler) BookTest(w http.ResponseWriter, r *http.Request) {
 		testNotes = sql.NullString{String: notesStr, Valid: true}
 	}
 
-	h.cfg.Debugf("📅 BookTest: leadID=%s, testDate=%v, testTime=%v, testType=%v", leadID, testDate, testTime, testType)
+	h.cfg.Debugf("📅 BookTest: leadID=%s, slotID=%s", leadID, slotID)
 
-	// Book the placement test (updates test fields and sets status to test_booked)
-	err = models.BookPlacementTest(leadID, testDate, testTime, testType, testNotes)
+	// Book the placement test (copies slot date/time/type/examiner and sets status to test_booked)
+	err = models.BookPlacementTest(leadID, slotID, testNotes, userRole, middleware.GetUserID(r))
+	if h.renderTransitionError(w, r, leadID, err) || h.renderSlotError(w, r, leadID, err) {
+		return
+	}
 	if err != nil {
 		log.Printf("ERROR: Failed to book placement test: %v", err)
 		http.Error(w, fmt.Sprintf("Failed to book placement test: %v", err), http.StatusInternalServerError)
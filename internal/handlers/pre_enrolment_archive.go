@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// renderArchiveError shows an archive/restore rejection on the detail page.
+// Returns false when err is not a *models.LeadArchiveError.
+func (h *PreEnrolmentHandler) renderArchiveError(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, err error) bool {
+	var archiveErr *models.LeadArchiveError
+	if !errors.As(err, &archiveErr) {
+		return false
+	}
+	h.renderDetailWithError(w, r, leadID, archiveErr.Error())
+	return true
+}
+
+// archiveLead handles the archive action (admin, not moderator): the lead leaves the lead list with
+// all its history intact
+func (h *PreEnrolmentHandler) archiveLead(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, actorUserID string) {
+	err := models.ArchiveLead(leadID, r.FormValue("archive_reason"), actorUserID)
+	if h.renderArchiveError(w, r, leadID, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to archive lead: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to archive lead: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  ✅ Lead archived, redirecting to list")
+	http.Redirect(w, r, "/pre-enrolment?archived=1", http.StatusFound)
+}
+
+// restoreLead handles the restore action (admin only)
+func (h *PreEnrolmentHandler) restoreLead(w http.ResponseWriter, r *http.Request, leadID uuid.UUID) {
+	err := models.RestoreLead(leadID)
+	if h.renderArchiveError(w, r, leadID, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to restore lead: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to restore lead: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  ✅ Lead restored, redirecting to detail")
+	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?restored=1", leadID.String()), http.StatusFound)
+}
+
+// Archived renders archived leads with restore buttons and the retention purge (admin only)
+func (h *PreEnrolmentHandler) Archived(w http.ResponseWriter, r *http.Request) {
+	leads, err := models.GetArchivedLeads()
+	if err != nil {
+		log.Printf("ERROR: Failed to load archived leads: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load archived leads: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":            "Archived Leads - Eighty Twenty",
+		"UserRole":         middleware.GetUserRole(r),
+		"IsModerator":      IsModerator(r),
+		"Leads":            leads,
+		"MinRetentionDays": models.MinArchiveRetentionDays,
+		"Error":            r.URL.Query().Get("error"),
+		"SuccessMessage":   r.URL.Query().Get("saved"),
+	}
+	renderTemplate(w, r, "pre_enrolment_archived.html", data)
+}
+
+// PurgeArchived runs the retention purge: POST /pre-enrolment/archived with older_than_days (admin only).
+// Leads with finance transactions are never purged.
+func (h *PreEnrolmentHandler) PurgeArchived(w http.ResponseWriter, r *http.Request) {
+	fail := func(msg string) {
+		http.Redirect(w, r, "/pre-enrolment/archived?"+url.Values{"error": {msg}}.Encode(), http.StatusFound)
+	}
+	if r.FormValue("confirm") != "PURGE" {
+		fail("Type PURGE to confirm permanent deletion.")
+		return
+	}
+	days, err := strconv.Atoi(strings.TrimSpace(r.FormValue("older_than_days")))
+	if err != nil {
+		fail("Days must be a whole number.")
+		return
+	}
+	purged, kept, err := models.PurgeArchivedLeads(days, time.Now())
+	var archiveErr *models.LeadArchiveError
+	if errors.As(err, &archiveErr) {
+		fail(archiveErr.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to purge archived leads: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to purge archived leads: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Archive retention purge by %s: %d lead(s) deleted, %d kept for finance history", middleware.GetUserID(r), purged, kept)
+	done := fmt.Sprintf("%d archived lead(s) permanently deleted.", purged)
+	if kept > 0 {
+		done += fmt.Sprintf(" %d kept because they have finance transactions.", kept)
+	}
+	http.Redirect(w, r, "/pre-enrolment/archived?"+url.Values{"saved": {done}}.Encode(), http.StatusFound)
+}
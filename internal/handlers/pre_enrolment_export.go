@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"eighty-twenty-ops/internal/models"
+	"eighty-twenty-ops/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// Export streams the filtered lead list as CSV (default) or XLSX (?format=xlsx).
+// Uses the same filters and sort as List, without paging, with a column per active custom field.
+// Moderators never receive pricing columns.
+func (h *PreEnrolmentHandler) Export(w http.ResponseWriter, r *http.Request) {
+	statusFilter := r.URL.Query().Get("status")
+	searchFilter := r.URL.Query().Get("search")
+	paymentFilter := r.URL.Query().Get("payment")
+	hotFilter := r.URL.Query().Get("hot")
+	followUpFilter := r.URL.Query().Get("follow_up")
+	includeCancelled := r.URL.Query().Get("include_cancelled") == "1" || r.URL.Query().Get("include_cancelled") == "true"
+	if statusFilter == "cancelled" {
+		includeCancelled = true
+	}
+	format := r.URL.Query().Get("format")
+	if format != "xlsx" {
+		format = "csv"
+	}
+
+	customFields, err := models.GetLeadCustomFields(false)
+	if err != nil {
+		log.Printf("ERROR: Failed to load custom fields for export: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load custom fields: %v", err), http.StatusInternalServerError)
+		return
+	}
+	customFilters, _ := customFieldFilterQuery(r, customFields)
+
+	// Every matching lead, in the list's sort order
+	sortKey, sortDesc := models.ParseLeadSort(r.URL.Query().Get("sort"))
+	leadPage, err := models.QueryLeads(models.LeadListQuery{
+		Status:           statusFilter,
+		Search:           searchFilter,
+		Payment:          paymentFilter,
+		Hot:              hotFilter,
+		FollowUp:         followUpFilter,
+		IncludeCancelled: includeCancelled,
+		Custom:           customFilters,
+		Sort:             sortKey,
+		Desc:             sortDesc,
+	})
+	if err != nil {
+		log.Printf("ERROR: Failed to load leads for export: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load leads: %v", err), http.StatusInternalServerError)
+		return
+	}
+	leads := leadPage.Leads
+
+	leadIDs := make([]uuid.UUID, len(leads))
+	for i, item := range leads {
+		leadIDs[i] = item.Lead.ID
+	}
+	customValues, err := models.GetLeadCustomValuesBatch(leadIDs)
+	if err != nil {
+		log.Printf("ERROR: Failed to load custom values for export: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load custom fields: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	includePricing := !IsModerator(r)
+	var coursePaid map[uuid.UUID]int32
+	if includePricing {
+		coursePaid, err = models.GetTotalCoursePaidBatch(leadIDs)
+		if err != nil {
+			log.Printf("ERROR: Failed to load course payments for export: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to load payments: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	header := []string{"Name", "Phone", "Source", "Status", "Assigned Level", "Payment State", "Hot Level", "Next Action", "Follow-Up Due", "Created At"}
+	for _, f := range customFields {
+		header = append(header, f.Label)
+	}
+	if includePricing {
+		header = append(header, "Offer Final Price", "Total Course Paid")
+	}
+
+	rows := make([][]interface{}, 0, len(leads))
+	for _, item := range leads {
+		var level interface{}
+		if item.AssignedLevel.Valid {
+			level = item.AssignedLevel.Int32
+		}
+		source := ""
+		if item.Lead.Source.Valid {
+			source = item.Lead.Source.String
+		}
+		followUp := "No"
+		if item.FollowUpDue {
+			followUp = "Yes"
+		}
+		row := []interface{}{
+			item.Lead.FullName,
+			item.Lead.Phone,
+			source,
+			models.GetStatusDisplayInfo(item.Lead.Status).DisplayName,
+			level,
+			item.PaymentState,
+			item.HotLevel,
+			item.NextAction,
+			followUp,
+			item.Lead.CreatedAt.Format("2006-01-02 15:04"),
+		}
+		for _, f := range customFields {
+			row = append(row, customValues[item.Lead.ID][f.Key])
+		}
+		if includePricing {
+			var finalPrice interface{}
+			if item.FinalPrice.Valid {
+				finalPrice = item.FinalPrice.Int32
+			}
+			row = append(row, finalPrice, coursePaid[item.Lead.ID])
+		}
+		rows = append(rows, row)
+	}
+
+	h.cfg.Debugf("  → Exporting %d leads as %s (pricing=%v)", len(rows), format, includePricing)
+	filename := fmt.Sprintf("leads-%s.%s", time.Now().Format("2006-01-02"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	if format == "xlsx" {
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		if err := util.WriteXLSX(w, "Leads", header, rows); err != nil {
+			log.Printf("ERROR: Failed to write XLSX export: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	record := make([]string, len(header))
+	for i, label := range header {
+		record[i] = util.CSVSafe(label)
+	}
+	cw.Write(record)
+	for _, row := range rows {
+		for i, cell := range row {
+			if cell == nil {
+				record[i] = ""
+			} else {
+				// Names, notes and custom values are user-entered; keep them from opening as formulas
+				record[i] = util.CSVSafe(fmt.Sprint(cell))
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("ERROR: Failed to write CSV export: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+)
+
+// maxLeadImportSize caps the uploaded CSV (2 MB is thousands of leads)
+const maxLeadImportSize = 2 << 20
+
+// ImportForm renders the bulk CSV import page (admin only)
+func (h *PreEnrolmentHandler) ImportForm(w http.ResponseWriter, r *http.Request) {
+	h.renderImport(w, r, map[string]interface{}{})
+}
+
+// Import handles the CSV upload. mode=preview (default) dry-runs and shows new/duplicate/invalid rows;
+// mode=commit re-checks the same CSV and creates all new rows in one transaction.
+func (h *PreEnrolmentHandler) Import(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLeadImportSize+1<<20)
+	if err := r.ParseMultipartForm(maxLeadImportSize); err != nil && err != http.ErrNotMultipart {
+		h.renderImport(w, r, map[string]interface{}{"Error": fmt.Sprintf("Failed to read upload: %v", err)})
+		return
+	}
+
+	// The preview page posts the CSV back as text so the commit imports exactly what was previewed
+	csvData := r.FormValue("csv_data")
+	if file, _, err := r.FormFile("csv_file"); err == nil {
+		defer file.Close()
+		b, err := io.ReadAll(io.LimitReader(file, maxLeadImportSize))
+		if err != nil {
+			h.renderImport(w, r, map[string]interface{}{"Error": fmt.Sprintf("Failed to read file: %v", err)})
+			return
+		}
+		csvData = string(b)
+	}
+	if strings.TrimSpace(csvData) == "" {
+		h.renderImport(w, r, map[string]interface{}{"Error": "Choose a CSV file to import"})
+		return
+	}
+
+	rows, err := models.ParseLeadImportCSV(strings.NewReader(csvData), leadSourceOptions(sql.NullString{}))
+	if err != nil {
+		h.renderImport(w, r, map[string]interface{}{"Error": err.Error()})
+		return
+	}
+	report, err := models.CheckLeadImportDuplicates(rows)
+	if err != nil {
+		log.Printf("ERROR: Failed to check import duplicates: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to check duplicates: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if r.FormValue("mode") != "commit" {
+		h.cfg.Debugf("  → Import preview: new=%d duplicate=%d invalid=%d", report.NewCount, report.Duplicates, report.Invalid)
+		h.renderImport(w, r, map[string]interface{}{"Report": report, "CSVData": csvData})
+		return
+	}
+
+	committed, err := models.CommitLeadImport(rows, middleware.GetUserID(r))
+	if err != nil {
+		log.Printf("ERROR: Lead import failed: %v", err)
+		h.renderImport(w, r, map[string]interface{}{
+			"Error":   fmt.Sprintf("Import failed, no leads were created: %v", err),
+			"Report":  report,
+			"CSVData": csvData,
+		})
+		return
+	}
+	h.cfg.Debugf("  → Imported %d leads", committed.Imported)
+	h.renderImport(w, r, map[string]interface{}{
+		"Report":         committed,
+		"SuccessMessage": fmt.Sprintf("Imported %d leads.", committed.Imported),
+	})
+}
+
+func (h *PreEnrolmentHandler) renderImport(w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	data["Title"] = "Import Leads - Eighty Twenty"
+	data["UserRole"] = middleware.GetUserRole(r)
+	data["IsModerator"] = IsModerator(r)
+	data["Sources"] = leadSourceOptions(sql.NullString{})
+	renderTemplate(w, r, "pre_enrolment_import.html", data)
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+	"eighty-twenty-ops/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// mergeFieldRow is one line of the side-by-side merge preview
+type mergeFieldRow struct {
+	Key       string // form field suffix: choice_<Key>
+	Label     string
+	Survivor  string
+	Merged    string
+	Default   string // survivor | merged | both
+	Choosable bool   // false when the merged side has nothing to offer
+	AllowBoth bool
+}
+
+// MergeForm previews merging a duplicate lead into the survivor (admin only).
+// Query: survivor=<id> and either merged=<id> or phone=<duplicate's phone>.
+func (h *PreEnrolmentHandler) MergeForm(w http.ResponseWriter, r *http.Request) {
+	survivorID, err := uuid.Parse(r.URL.Query().Get("survivor"))
+	if err != nil {
+		http.Error(w, "Invalid lead ID", http.StatusBadRequest)
+		return
+	}
+	survivor, err := models.GetLeadByID(survivorID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load lead: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{"Survivor": survivor}
+
+	mergedID, err := uuid.Parse(r.URL.Query().Get("merged"))
+	if err != nil {
+		phone := r.URL.Query().Get("phone")
+		if phone == "" {
+			data["Error"] = "Enter the phone number of the duplicate lead"
+			h.renderMerge(w, r, data)
+			return
+		}
+		found := h.findLeadByPhone(phone)
+		if found == nil {
+			data["Error"] = fmt.Sprintf("No lead found with phone %s", phone)
+			h.renderMerge(w, r, data)
+			return
+		}
+		mergedID = found.ID
+	}
+	if mergedID == survivorID {
+		data["Error"] = "That phone belongs to this lead. Enter the duplicate's phone number."
+		h.renderMerge(w, r, data)
+		return
+	}
+
+	merged, err := models.GetLeadByID(mergedID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load lead: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data["Merged"] = merged
+	data["Rows"] = buildMergeRows(survivor, merged)
+
+	survivorPaid, err := models.GetTotalCoursePaid(survivorID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get total course paid: %v", err)
+	}
+	mergedPaid, err := models.GetTotalCoursePaid(mergedID)
+	if err != nil {
+		log.Printf("ERROR: Failed to get total course paid: %v", err)
+	}
+	data["SurvivorPaid"] = survivorPaid
+	data["MergedPaid"] = mergedPaid
+	h.renderMerge(w, r, data)
+}
+
+// Merge performs the merge chosen on the preview page and redirects to the surviving lead
+func (h *PreEnrolmentHandler) Merge(w http.ResponseWriter, r *http.Request) {
+	survivorID, err := uuid.Parse(r.FormValue("survivor_id"))
+	if err != nil {
+		http.Error(w, "Invalid lead ID", http.StatusBadRequest)
+		return
+	}
+	mergedID, err := uuid.Parse(r.FormValue("merged_id"))
+	if err != nil {
+		http.Error(w, "Invalid lead ID", http.StatusBadRequest)
+		return
+	}
+
+	choices := models.LeadMergeChoices{
+		FullName:      r.FormValue("choice_full_name"),
+		Phone:         r.FormValue("choice_phone"),
+		Source:        r.FormValue("choice_source"),
+		Notes:         r.FormValue("choice_notes"),
+		PlacementTest: r.FormValue("choice_placement_test"),
+		Offer:         r.FormValue("choice_offer"),
+		Booking:       r.FormValue("choice_booking"),
+		Payment:       r.FormValue("choice_payment"),
+		Scheduling:    r.FormValue("choice_scheduling"),
+		Shipping:      r.FormValue("choice_shipping"),
+	}
+
+	h.cfg.Debugf("  → Merging lead %s into %s", mergedID, survivorID)
+	merge, err := models.MergeLeads(survivorID, mergedID, choices, middleware.GetUserID(r))
+	if err != nil {
+		log.Printf("ERROR: Failed to merge lead %s into %s: %v", mergedID, survivorID, err)
+		http.Error(w, fmt.Sprintf("Failed to merge leads: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  → Merge %s recorded, moved rows: %v", merge.ID, merge.MovedRows)
+	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?merged=1", survivorID), http.StatusSeeOther)
+}
+
+// findLeadByPhone looks up a lead by the phone as typed, then by its normalised form
+func (h *PreEnrolmentHandler) findLeadByPhone(phone string) *models.Lead {
+	if lead, err := models.GetLeadByPhone(phone); err == nil {
+		return lead
+	}
+	if normalized, err := util.NormalizeEgyptianPhone(phone); err == nil {
+		if lead, err := models.GetLeadByPhone(normalized); err == nil {
+			return lead
+		}
+	}
+	return nil
+}
+
+func (h *PreEnrolmentHandler) renderMerge(w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	data["Title"] = "Merge Leads - Eighty Twenty"
+	data["UserRole"] = middleware.GetUserRole(r)
+	data["IsModerator"] = IsModerator(r)
+	renderTemplate(w, r, "pre_enrolment_merge.html", data)
+}
+
+func buildMergeRows(survivor, merged *models.LeadDetail) []mergeFieldRow {
+	field := func(key, label, s, m string) mergeFieldRow {
+		row := mergeFieldRow{Key: key, Label: label, Survivor: s, Merged: m, Default: models.MergeKeepSurvivor, Choosable: m != "" && m != s}
+		if s == "" && m != "" {
+			row.Default = models.MergeKeepMerged
+		}
+		return row
+	}
+	// section rows: if only the merged lead has the section, take it by default so nothing is lost
+	section := func(key, label string, sHas, mHas bool, s, m string) mergeFieldRow {
+		row := mergeFieldRow{Key: key, Label: label, Survivor: s, Merged: m, Default: models.MergeKeepSurvivor, Choosable: sHas && mHas}
+		if mHas && !sHas {
+			row.Default = models.MergeKeepMerged
+		}
+		return row
+	}
+
+	notes := field("notes", "Notes", nullString(survivor.Lead.Notes), nullString(merged.Lead.Notes))
+	if notes.Choosable {
+		notes.AllowBoth = true
+		notes.Default = models.MergeKeepBoth
+	}
+	status := field("status", "Status", models.GetStatusDisplayInfo(survivor.Lead.Status).DisplayName, models.GetStatusDisplayInfo(merged.Lead.Status).DisplayName)
+	status.Choosable = false // derived from the merged sections
+
+	rows := []mergeFieldRow{
+		field("full_name", "Full Name", survivor.Lead.FullName, merged.Lead.FullName),
+		field("phone", "Phone", survivor.Lead.Phone, merged.Lead.Phone),
+		field("source", "Source", nullString(survivor.Lead.Source), nullString(merged.Lead.Source)),
+		notes,
+		status,
+	}
+	rows = append(rows,
+		section("placement_test", "Placement Test", survivor.PlacementTest != nil, merged.PlacementTest != nil,
+			describePlacementTest(survivor.PlacementTest), describePlacementTest(merged.PlacementTest)),
+		section("offer", "Offer", survivor.Offer != nil, merged.Offer != nil,
+			describeOffer(survivor.Offer), describeOffer(merged.Offer)),
+		section("booking", "Booking", survivor.Booking != nil, merged.Booking != nil,
+			describeBooking(survivor.Booking), describeBooking(merged.Booking)),
+		section("payment", "Payment Summary", survivor.Payment != nil, merged.Payment != nil,
+			describePayment(survivor.Payment), describePayment(merged.Payment)),
+		section("scheduling", "Schedule", survivor.Scheduling != nil, merged.Scheduling != nil,
+			describeScheduling(survivor.Scheduling), describeScheduling(merged.Scheduling)),
+		section("shipping", "Shipping", survivor.Shipping != nil, merged.Shipping != nil,
+			describeShipping(survivor.Shipping), describeShipping(merged.Shipping)),
+	)
+	return rows
+}
+
+func nullString(s sql.NullString) string {
+	if s.Valid {
+		return s.String
+	}
+	return ""
+}
+
+func describePlacementTest(pt *models.PlacementTest) string {
+	if pt == nil {
+		return ""
+	}
+	desc := "Test"
+	if pt.TestDate.Valid {
+		desc += " " + pt.TestDate.Time.Format("2006-01-02")
+	}
+	if pt.AssignedLevel.Valid {
+		desc += fmt.Sprintf(", Level %d", pt.AssignedLevel.Int32)
+	}
+	if pt.PlacementTestFeePaid.Valid {
+		desc += fmt.Sprintf(", fee paid %d EGP", pt.PlacementTestFeePaid.Int32)
+	}
+	return desc
+}
+
+func describeOffer(o *models.Offer) string {
+	if o == nil {
+		return ""
+	}
+	desc := "Offer"
+	if o.BundleLevels.Valid {
+		desc += fmt.Sprintf(" %d level(s)", o.BundleLevels.Int32)
+	}
+	if o.FinalPrice.Valid {
+		desc += fmt.Sprintf(", %d EGP", o.FinalPrice.Int32)
+	}
+	return desc
+}
+
+func describeBooking(b *models.Booking) string {
+	if b == nil {
+		return ""
+	}
+	desc := nullString(b.BookFormat)
+	if b.City.Valid {
+		desc += ", " + b.City.String
+	}
+	if desc == "" {
+		desc = "Booking"
+	}
+	return desc
+}
+
+func describePayment(p *models.Payment) string {
+	if p == nil {
+		return ""
+	}
+	desc := nullString(p.PaymentType)
+	if p.AmountPaid.Valid {
+		desc += fmt.Sprintf(" %d EGP paid", p.AmountPaid.Int32)
+	}
+	if desc == "" {
+		desc = "Payment"
+	}
+	return desc
+}
+
+func describeScheduling(s *models.Scheduling) string {
+	if s == nil {
+		return ""
+	}
+	desc := nullString(s.ClassDays)
+	if s.ClassTime.Valid {
+		desc += " " + s.ClassTime.String
+	}
+	if s.ExpectedRound.Valid {
+		desc += ", round " + s.ExpectedRound.String
+	}
+	if desc == "" {
+		desc = "Schedule"
+	}
+	return desc
+}
+
+func describeShipping(s *models.Shipping) string {
+	if s == nil {
+		return ""
+	}
+	desc := nullString(s.ShipmentStatus)
+	if desc == "" {
+		desc = "Shipping"
+	}
+	return desc
+}
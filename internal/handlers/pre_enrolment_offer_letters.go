@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"eighty-twenty-ops/internal/models"
+	"eighty-twenty-ops/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// offerLetterView is what an offer letter shows; the HTML template and the PDF are both built from it
+type offerLetterView struct {
+	LeadName            string
+	IssuedOn            time.Time
+	ExpiresOn           time.Time
+	AssignedLevel       sql.NullInt32
+	BundleLevels        sql.NullInt32
+	BasePrice           int32
+	DiscountAmount      int32
+	PromoCode           string
+	FinalPrice          int32
+	Currency            string
+	PaymentInstructions string
+}
+
+// Offers are sent inside the pipeline's transaction, which issues their letter with buildOfferLetter
+func init() {
+	models.SetOfferLetterBuilder(buildOfferLetter)
+}
+
+// buildOfferLetter renders the lead's current offer as a letter issued at now
+func buildOfferLetter(detail *models.LeadDetail, plan *models.PricePlan, settings *models.OfferLetterSettings, now time.Time) (*models.OfferLetter, error) {
+	offer := detail.Offer
+	if offer == nil || !offer.FinalPrice.Valid || offer.FinalPrice.Int32 <= 0 {
+		return nil, &models.OfferLetterError{Message: "Final price is required to issue an offer letter."}
+	}
+	v := offerLetterView{
+		LeadName:            detail.Lead.FullName,
+		IssuedOn:            now,
+		ExpiresOn:           models.OfferExpiryDate(now, settings.ValidityDays),
+		BundleLevels:        offer.BundleLevels,
+		BasePrice:           offer.FinalPrice.Int32,
+		PromoCode:           offer.PromoCode,
+		FinalPrice:          offer.FinalPrice.Int32,
+		Currency:            "EGP",
+		PaymentInstructions: settings.PaymentInstructions,
+	}
+	if detail.PlacementTest != nil {
+		v.AssignedLevel = detail.PlacementTest.AssignedLevel
+	}
+	if offer.BasePrice.Valid && offer.BasePrice.Int32 > v.FinalPrice {
+		v.BasePrice = offer.BasePrice.Int32
+		v.DiscountAmount = v.BasePrice - v.FinalPrice
+	}
+	if plan != nil {
+		v.Currency = plan.Currency
+	}
+
+	initTemplates()
+	var html bytes.Buffer
+	if err := templates.ExecuteTemplate(&html, "offer_letter_document", v); err != nil {
+		return nil, fmt.Errorf("failed to render offer letter: %w", err)
+	}
+	var pdf bytes.Buffer
+	if err := util.WritePDF(&pdf, "Eighty Twenty course offer - "+v.LeadName, offerLetterPDFLines(v)); err != nil {
+		return nil, fmt.Errorf("failed to write offer letter PDF: %w", err)
+	}
+	return &models.OfferLetter{
+		LeadID:        detail.Lead.ID,
+		BundleLevels:  v.BundleLevels,
+		AssignedLevel: v.AssignedLevel,
+		FinalPrice:    v.FinalPrice,
+		Currency:      v.Currency,
+		ExpiresOn:     v.ExpiresOn,
+		HTML:          html.String(),
+		PDF:           pdf.Bytes(),
+	}, nil
+}
+
+// offerLetterPDFLines lays out the same content as offer_letter.html
+func offerLetterPDFLines(v offerLetterView) []util.PDFLine {
+	intro := "Thank you for taking the Eighty Twenty placement test."
+	if v.AssignedLevel.Valid {
+		intro += fmt.Sprintf(" Based on your result you will start at Level %d.", v.AssignedLevel.Int32)
+	}
+	intro += " Here is your course offer:"
+	item := "Course"
+	if v.BundleLevels.Valid {
+		item = fmt.Sprintf("Bundle of %d level", v.BundleLevels.Int32)
+		if v.BundleLevels.Int32 > 1 {
+			item += "s"
+		}
+	}
+
+	lines := []util.PDFLine{
+		{Text: "Eighty Twenty", Size: 20, Bold: true},
+		{Text: "Your English course offer", Size: 14},
+		{Text: "Issued " + v.IssuedOn.Format("02 Jan 2006"), Size: 9, Gap: 6},
+		{Text: "Dear " + v.LeadName + ",", Gap: 12},
+		{Text: intro, Gap: 4},
+		{Text: fmt.Sprintf("%s: %d %s", item, v.BasePrice, v.Currency), Gap: 10},
+	}
+	if v.DiscountAmount > 0 {
+		discount := "Discount"
+		if v.PromoCode != "" {
+			discount += " (code " + v.PromoCode + ")"
+		}
+		lines = append(lines, util.PDFLine{Text: fmt.Sprintf("%s: -%d %s", discount, v.DiscountAmount, v.Currency)})
+	}
+	return append(lines,
+		util.PDFLine{Text: fmt.Sprintf("Total: %d %s", v.FinalPrice, v.Currency), Size: 13, Bold: true, Gap: 4},
+		util.PDFLine{Text: "This offer is valid until " + v.ExpiresOn.Format("02 Jan 2006") + ". Your place is reserved once a payment is received.", Gap: 10},
+		util.PDFLine{Text: "How to pay", Size: 13, Bold: true, Gap: 10},
+		util.PDFLine{Text: v.PaymentInstructions},
+		util.PDFLine{Text: "We look forward to seeing you in class.", Gap: 12},
+		util.PDFLine{Text: "The Eighty Twenty team"},
+	)
+}
+
+// OfferLetter serves a stored offer letter: /pre-enrolment/{id}/offer-letters/{letterID} as HTML,
+// or with a .pdf suffix as PDF
+func (h *PreEnrolmentHandler) OfferLetter(w http.ResponseWriter, r *http.Request) {
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) != 5 || pathParts[3] != "offer-letters" {
+		http.NotFound(w, r)
+		return
+	}
+	leadID, err := uuid.Parse(pathParts[2])
+	if err != nil {
+		http.Error(w, "Invalid lead ID", http.StatusBadRequest)
+		return
+	}
+	asPDF := strings.HasSuffix(pathParts[4], ".pdf")
+	letterID, err := uuid.Parse(strings.TrimSuffix(pathParts[4], ".pdf"))
+	if err != nil {
+		http.Error(w, "Invalid offer letter ID", http.StatusBadRequest)
+		return
+	}
+
+	letter, err := models.GetOfferLetter(leadID, letterID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load offer letter: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load offer letter: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if letter == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if asPDF {
+		filename := fmt.Sprintf("offer-%s-v%d.pdf", letter.IssuedAt.Format("2006-01-02"), letter.Version)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, filename))
+		w.Write(letter.PDF)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(letter.HTML))
+}
+
+// SaveOfferLetterSettings saves the offer validity and payment instructions from the prices page (admin only)
+func (h *PreEnrolmentHandler) SaveOfferLetterSettings(w http.ResponseWriter, r *http.Request) {
+	fail := func(msg string) {
+		http.Redirect(w, r, "/pre-enrolment/prices?"+url.Values{"error": {msg}}.Encode(), http.StatusFound)
+	}
+	days, err := strconv.Atoi(strings.TrimSpace(r.FormValue("validity_days")))
+	if err != nil {
+		fail("Offer validity must be a whole number of days.")
+		return
+	}
+	err = models.SetOfferLetterSettings(models.OfferLetterSettings{
+		ValidityDays:        days,
+		PaymentInstructions: r.FormValue("payment_instructions"),
+	})
+	var letterErr *models.OfferLetterError
+	if errors.As(err, &letterErr) {
+		fail(letterErr.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to save offer letter settings: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to save offer letter settings: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  → Offer letter settings saved: validity %d days", days)
+	http.Redirect(w, r, "/pre-enrolment/prices?"+url.Values{"saved": {"Offer letter settings saved."}}.Encode(), http.StatusFound)
+}
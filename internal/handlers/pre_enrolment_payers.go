@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+	"eighty-twenty-ops/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// parsePayerPath returns the payer ID of /pre-enrolment/payers/{payerID}
+func parsePayerPath(path string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(strings.TrimPrefix(path, "/pre-enrolment/payers/"))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// Payers lists parents and guardians with what each family still owes (admin only)
+func (h *PreEnrolmentHandler) Payers(w http.ResponseWriter, r *http.Request) {
+	search := r.URL.Query().Get("search")
+	payers, err := models.GetPayers(search)
+	if err != nil {
+		log.Printf("ERROR: Failed to load payers: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load payers: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":       "Families - Eighty Twenty",
+		"UserRole":    middleware.GetUserRole(r),
+		"IsModerator": IsModerator(r),
+		"Payers":      payers,
+		"Search":      search,
+	}
+	renderTemplate(w, r, "pre_enrolment_payers.html", data)
+}
+
+// Payer renders a family: the payer's contact, each lead's balance, the family balance and the
+// payer's payments (admin only)
+func (h *PreEnrolmentHandler) Payer(w http.ResponseWriter, r *http.Request) {
+	payerID, ok := parsePayerPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	payer, err := models.GetPayer(payerID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load payer: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load payer: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if payer == nil {
+		http.NotFound(w, r)
+		return
+	}
+	members, err := models.GetFamilyMembers(payerID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load family members: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load family: %v", err), http.StatusInternalServerError)
+		return
+	}
+	payments, err := models.GetPayerPayments(payerID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load payer payments: %v", err)
+	}
+	totalPrice, totalPaid, remaining := models.FamilyTotals(members)
+
+	data := map[string]interface{}{
+		"Title":          payer.FullName + " - Families - Eighty Twenty",
+		"UserRole":       middleware.GetUserRole(r),
+		"IsModerator":    IsModerator(r),
+		"Payer":          payer,
+		"Members":        members,
+		"TotalPrice":     totalPrice,
+		"TotalPaid":      totalPaid,
+		"Remaining":      remaining,
+		"Payments":       payments,
+		"Today":          time.Now().Format("2006-01-02"),
+		"Error":          r.URL.Query().Get("error"),
+		"SuccessMessage": r.URL.Query().Get("saved"),
+	}
+	renderTemplate(w, r, "pre_enrolment_payer.html", data)
+}
+
+// SavePayer edits a payer's contact or records a family payment (action=update|record_payment,
+// admin only). A payment's alloc_{leadID} amounts split it across the family; with none entered it
+// pays off the oldest lead's balance first.
+func (h *PreEnrolmentHandler) SavePayer(w http.ResponseWriter, r *http.Request) {
+	payerID, ok := parsePayerPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	payerURL := fmt.Sprintf("/pre-enrolment/payers/%s", payerID)
+	fail := func(msg string) {
+		http.Redirect(w, r, payerURL+"?"+url.Values{"error": {msg}}.Encode(), http.StatusFound)
+	}
+
+	var err error
+	var done string
+	switch r.FormValue("action") {
+	case "update":
+		err = models.UpdatePayer(&models.Payer{
+			ID:           payerID,
+			FullName:     r.FormValue("full_name"),
+			Phone:        r.FormValue("phone"),
+			Relationship: r.FormValue("relationship"),
+			Notes:        r.FormValue("notes"),
+		})
+		done = "Payer details saved."
+	case "record_payment":
+		amount, parseErr := strconv.Atoi(strings.TrimSpace(r.FormValue("amount")))
+		if parseErr != nil || amount <= 0 {
+			fail("Amount must be a whole number above 0.")
+			return
+		}
+		paymentDate, parseErr := util.ParseDateLocal(r.FormValue("payment_date"))
+		if parseErr != nil {
+			fail("Payment date is required.")
+			return
+		}
+		requested := make(map[uuid.UUID]int32)
+		for key, values := range r.PostForm {
+			leadIDStr, found := strings.CutPrefix(key, "alloc_")
+			if !found || len(values) == 0 || strings.TrimSpace(values[0]) == "" {
+				continue
+			}
+			leadID, parseErr := uuid.Parse(leadIDStr)
+			if parseErr != nil {
+				http.Error(w, "Invalid lead ID", http.StatusBadRequest)
+				return
+			}
+			share, parseErr := strconv.Atoi(strings.TrimSpace(values[0]))
+			if parseErr != nil {
+				fail("Split amounts must be whole numbers.")
+				return
+			}
+			requested[leadID] = int32(share)
+		}
+		var payment *models.PayerPayment
+		payment, err = models.RecordPayerPayment(payerID, int32(amount), r.FormValue("payment_method"), paymentDate,
+			r.FormValue("notes"), requested, middleware.GetUserID(r))
+		if err == nil {
+			shares := make([]string, len(payment.Allocations))
+			for i, a := range payment.Allocations {
+				shares[i] = fmt.Sprintf("%s %d EGP", a.FullName, a.Amount)
+			}
+			done = fmt.Sprintf("Payment of %d EGP recorded: %s.", amount, strings.Join(shares, ", "))
+		}
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	var payerErr *models.PayerError
+	if errors.As(err, &payerErr) {
+		fail(payerErr.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to update payer: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to update payer: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  → Payer %s saved", payerID)
+	http.Redirect(w, r, payerURL+"?"+url.Values{"saved": {done}}.Encode(), http.StatusFound)
+}
+
+// setPayer handles the detail page's set_payer action: link the lead to the payer with the given
+// phone (created from the form when new), or unlink it with unlink=1 (admin and moderator)
+func (h *PreEnrolmentHandler) setPayer(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, actorUserID string) {
+	var err error
+	result := "saved"
+	if r.FormValue("unlink") == "1" {
+		err = models.UnlinkLeadPayer(leadID)
+		result = "unlinked"
+	} else {
+		err = models.LinkLeadPayer(leadID, &models.Payer{
+			FullName:     r.FormValue("payer_name"),
+			Phone:        r.FormValue("payer_phone"),
+			Relationship: r.FormValue("payer_relationship"),
+		}, actorUserID)
+	}
+	var payerErr *models.PayerError
+	if errors.As(err, &payerErr) {
+		h.renderDetailWithError(w, r, leadID, payerErr.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to set payer: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to set payer: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  ✅ Payer %s, redirecting to detail", result)
+	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?payer=%s#payer", leadID.String(), result), http.StatusFound)
+}
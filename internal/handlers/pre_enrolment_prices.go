@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// renderPricePlanError shows a pricing rejection (no plan in effect, bad bundle) on the detail page.
+// Returns false when err is not a *models.PricePlanError.
+func (h *PreEnrolmentHandler) renderPricePlanError(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, err error) bool {
+	var planErr *models.PricePlanError
+	if !errors.As(err, &planErr) {
+		return false
+	}
+	h.renderDetailWithError(w, r, leadID, planErr.Error())
+	return true
+}
+
+// Prices renders the published price lists, the form to publish a new one and the offer letter settings (admin only)
+func (h *PreEnrolmentHandler) Prices(w http.ResponseWriter, r *http.Request) {
+	plans, err := models.GetPricePlans()
+	if err != nil {
+		log.Printf("ERROR: Failed to load price plans: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load price plans: %v", err), http.StatusInternalServerError)
+		return
+	}
+	current, err := models.GetCurrentPricePlan(time.Now())
+	if err != nil {
+		log.Printf("ERROR: Failed to load current price plan: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load price plans: %v", err), http.StatusInternalServerError)
+		return
+	}
+	letterSettings, err := models.GetOfferLetterSettings()
+	if err != nil {
+		log.Printf("ERROR: Failed to load offer letter settings: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load price plans: %v", err), http.StatusInternalServerError)
+		return
+	}
+	levels := make([]int32, 0, models.MaxBundleLevels)
+	for l := int32(1); l <= models.MaxBundleLevels; l++ {
+		levels = append(levels, l)
+	}
+	data := map[string]interface{}{
+		"Title":          "Prices - Eighty Twenty",
+		"UserRole":       middleware.GetUserRole(r),
+		"IsModerator":    IsModerator(r),
+		"Plans":          plans,
+		"Current":        current,
+		"BundleLevels":   levels,
+		"OfferLetter":    letterSettings,
+		"Today":          time.Now().Format("2006-01-02"),
+		"Error":          r.URL.Query().Get("error"),
+		"SuccessMessage": r.URL.Query().Get("saved"),
+	}
+	renderTemplate(w, r, "pre_enrolment_prices.html", data)
+}
+
+// PublishPrices publishes a new price list. Blank bundle prices fall back to the per-level price.
+func (h *PreEnrolmentHandler) PublishPrices(w http.ResponseWriter, r *http.Request) {
+	fail := func(msg string) {
+		http.Redirect(w, r, "/pre-enrolment/prices?"+url.Values{"error": {msg}}.Encode(), http.StatusFound)
+	}
+	plan := &models.PricePlan{
+		Name:     r.FormValue("name"),
+		Currency: r.FormValue("currency"),
+		Bundles:  make(map[int32]int32),
+	}
+	if v := r.FormValue("effective_from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			fail("Effective-from date must be YYYY-MM-DD.")
+			return
+		}
+		plan.EffectiveFrom = t
+	}
+	perLevel, err := strconv.Atoi(strings.TrimSpace(r.FormValue("per_level_price")))
+	if err != nil {
+		fail("Per-level price must be a whole number.")
+		return
+	}
+	plan.PerLevelPrice = int32(perLevel)
+	for l := int32(1); l <= models.MaxBundleLevels; l++ {
+		v := strings.TrimSpace(r.FormValue(fmt.Sprintf("bundle_%d", l)))
+		if v == "" {
+			continue
+		}
+		price, err := strconv.Atoi(v)
+		if err != nil {
+			fail(fmt.Sprintf("Bundle %d price must be a whole number.", l))
+			return
+		}
+		plan.Bundles[l] = int32(price)
+	}
+
+	err = models.PublishPricePlan(plan, middleware.GetUserID(r))
+	var planErr *models.PricePlanError
+	if errors.As(err, &planErr) {
+		fail(planErr.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to publish price plan: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to publish price plan: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  → Price plan %s published, effective %s", plan.ID, plan.EffectiveFrom.Format("2006-01-02"))
+	done := fmt.Sprintf("%s published, effective %s.", plan.Name, plan.EffectiveFrom.Format("02 Jan 2006"))
+	http.Redirect(w, r, "/pre-enrolment/prices?"+url.Values{"saved": {done}}.Encode(), http.StatusFound)
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// applyOfferPromoCode applies the promo code typed on the offer form, replacing the manual discount.
+// A blank code removes the promo code and keeps the discount as typed.
+func applyOfferPromoCode(offer *models.Offer, code string) error {
+	if models.NormalizePromoCode(code) == "" {
+		offer.PromoCodeID = sql.NullString{}
+		offer.PromoCode = ""
+		return nil
+	}
+	promo, err := models.GetPromoCodeByCode(code)
+	if err != nil {
+		return err
+	}
+	return offer.ApplyPromoCode(promo, time.Now())
+}
+
+// renderOfferError shows a promo code or send-offer rejection on the detail page.
+// Returns false when err is not a *models.PromoCodeError, *models.OfferLetterError or *models.LeadTransitionError.
+func (h *PreEnrolmentHandler) renderOfferError(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, err error) bool {
+	var promoErr *models.PromoCodeError
+	if errors.As(err, &promoErr) {
+		h.renderDetailWithError(w, r, leadID, promoErr.Error())
+		return true
+	}
+	var letterErr *models.OfferLetterError
+	if errors.As(err, &letterErr) {
+		h.renderDetailWithError(w, r, leadID, letterErr.Error())
+		return true
+	}
+	return h.renderTransitionError(w, r, leadID, err)
+}
+
+// PromoCodes renders the promo code list, the create form and the discount approval threshold (admin only)
+func (h *PreEnrolmentHandler) PromoCodes(w http.ResponseWriter, r *http.Request) {
+	codes, err := models.GetPromoCodes()
+	if err != nil {
+		log.Printf("ERROR: Failed to load promo codes: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load promo codes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	threshold, err := models.GetDiscountApprovalThreshold()
+	if err != nil {
+		log.Printf("ERROR: Failed to load discount approval threshold: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load promo codes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	referralSettings, err := models.GetReferralSettings()
+	if err != nil {
+		log.Printf("ERROR: Failed to load referral settings: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load promo codes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	sources, err := models.GetActiveLeadSourceNames()
+	if err != nil {
+		log.Printf("ERROR: Failed to load lead sources: %v", err)
+	}
+	levels := make([]int32, 0, models.MaxBundleLevels)
+	for l := int32(1); l <= models.MaxBundleLevels; l++ {
+		levels = append(levels, l)
+	}
+	data := map[string]interface{}{
+		"Title":            "Promo Codes - Eighty Twenty",
+		"UserRole":         middleware.GetUserRole(r),
+		"IsModerator":      IsModerator(r),
+		"Codes":            codes,
+		"Threshold":        threshold,
+		"ReferralSettings": referralSettings,
+		"Sources":          sources,
+		"BundleLevels":     levels,
+		"Today":            time.Now().Format("2006-01-02"),
+		"Error":            r.URL.Query().Get("error"),
+		"SuccessMessage":   r.URL.Query().Get("saved"),
+	}
+	renderTemplate(w, r, "pre_enrolment_promo_codes.html", data)
+}
+
+// SavePromoCode creates, deactivates or reactivates a promo code, or changes the discount
+// approval threshold or the referral rewards (action=create|deactivate|activate|threshold|referrals)
+func (h *PreEnrolmentHandler) SavePromoCode(w http.ResponseWriter, r *http.Request) {
+	fail := func(msg string) {
+		http.Redirect(w, r, "/pre-enrolment/promo-codes?"+url.Values{"error": {msg}}.Encode(), http.StatusFound)
+	}
+	action := r.FormValue("action")
+	var err error
+	var done string
+	switch action {
+	case "create":
+		promo, msg := parsePromoCodeForm(r)
+		if msg != "" {
+			fail(msg)
+			return
+		}
+		err = models.CreatePromoCode(promo, middleware.GetUserID(r))
+		done = fmt.Sprintf("Promo code %s created.", promo.Code)
+	case "deactivate", "activate":
+		id, parseErr := uuid.Parse(r.FormValue("promo_code_id"))
+		if parseErr != nil {
+			http.Error(w, "Invalid promo code ID", http.StatusBadRequest)
+			return
+		}
+		err = models.SetPromoCodeActive(id, action == "activate")
+		done = "Promo code deactivated."
+		if action == "activate" {
+			done = "Promo code reactivated."
+		}
+	case "threshold":
+		percent, parseErr := strconv.Atoi(strings.TrimSpace(r.FormValue("threshold")))
+		if parseErr != nil {
+			fail("Approval threshold must be a whole number.")
+			return
+		}
+		err = models.SetDiscountApprovalThreshold(percent)
+		done = fmt.Sprintf("Manual discounts above %d%% now need a second admin's approval.", percent)
+	case "referrals":
+		settings, msg := parseReferralSettingsForm(r)
+		if msg != "" {
+			fail(msg)
+			return
+		}
+		err = models.SetReferralSettings(settings)
+		done = "Referral rewards saved."
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		var promoErr *models.PromoCodeError
+		var referralErr *models.ReferralError
+		if errors.As(err, &promoErr) {
+			fail(promoErr.Error())
+			return
+		}
+		if errors.As(err, &referralErr) {
+			fail(referralErr.Error())
+			return
+		}
+		log.Printf("ERROR: Failed to %s promo code: %v", action, err)
+		http.Error(w, fmt.Sprintf("Failed to update promo code: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  → Promo code %s done", action)
+	http.Redirect(w, r, "/pre-enrolment/promo-codes?"+url.Values{"saved": {done}}.Encode(), http.StatusFound)
+}
+
+// parsePromoCodeForm reads the create form; msg is set when a field cannot be parsed
+func parsePromoCodeForm(r *http.Request) (promo *models.PromoCode, msg string) {
+	promo = &models.PromoCode{
+		Code:         r.FormValue("code"),
+		DiscountType: r.FormValue("discount_type"),
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(r.FormValue("discount_value")))
+	if err != nil {
+		return nil, "Discount must be a whole number."
+	}
+	promo.DiscountValue = int32(value)
+	if promo.ValidFrom, err = time.Parse("2006-01-02", r.FormValue("valid_from")); err != nil {
+		return nil, "Valid-from date must be YYYY-MM-DD."
+	}
+	if v := r.FormValue("valid_until"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return nil, "Valid-until date must be YYYY-MM-DD."
+		}
+		promo.ValidUntil = sql.NullTime{Time: t, Valid: true}
+	}
+	if v := strings.TrimSpace(r.FormValue("max_uses")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, "Max uses must be a whole number."
+		}
+		promo.MaxUses = sql.NullInt32{Int32: int32(n), Valid: true}
+	}
+	for _, v := range r.Form["bundles"] {
+		if levels, err := strconv.Atoi(v); err == nil {
+			promo.EligibleBundles = append(promo.EligibleBundles, int32(levels))
+		}
+	}
+	if v := r.FormValue("source"); v != "" {
+		promo.Source = sql.NullString{String: v, Valid: true}
+	}
+	if v := strings.TrimSpace(r.FormValue("campaign")); v != "" {
+		promo.Campaign = sql.NullString{String: v, Valid: true}
+	}
+	return promo, ""
+}
+
+// parseReferralSettingsForm reads the referral rewards form; msg is set when an amount cannot be parsed
+func parseReferralSettingsForm(r *http.Request) (settings models.ReferralSettings, msg string) {
+	discount, err := strconv.Atoi(strings.TrimSpace(r.FormValue("referral_discount_amount")))
+	if err != nil {
+		return settings, "Referral discount must be a whole number."
+	}
+	reward, err := strconv.Atoi(strings.TrimSpace(r.FormValue("referral_reward_amount")))
+	if err != nil {
+		return settings, "Referral reward must be a whole number."
+	}
+	settings.DiscountAmount = int32(discount)
+	settings.RewardType = r.FormValue("referral_reward_type")
+	settings.RewardAmount = int32(reward)
+	return settings, ""
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// renderReferralError shows a referral rejection (loop, reward already issued, nothing to apply) on the detail page.
+// Returns false when err is not a *models.ReferralError.
+func (h *PreEnrolmentHandler) renderReferralError(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, err error) bool {
+	var referralErr *models.ReferralError
+	if !errors.As(err, &referralErr) {
+		return false
+	}
+	h.renderDetailWithError(w, r, leadID, referralErr.Error())
+	return true
+}
+
+// ReferrerSearch backs the referrer picker: GET /pre-enrolment/referrers?q=&exclude=<leadID>
+// returns up to 10 leads whose name or phone matches q (admin and moderator)
+func (h *PreEnrolmentHandler) ReferrerSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	exclude, _ := uuid.Parse(r.URL.Query().Get("exclude"))
+	results, err := models.SearchLeadsForReferrer(r.URL.Query().Get("q"), exclude, 10)
+	if err != nil {
+		log.Printf("ERROR: Failed to search referrers: %v", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to search leads")
+		return
+	}
+	jsonResponse(w, http.StatusOK, results)
+}
+
+// setReferrer handles the detail page's set_referrer action (admin only); a blank referrer clears it
+func (h *PreEnrolmentHandler) setReferrer(w http.ResponseWriter, r *http.Request, leadID uuid.UUID) {
+	referrerID := uuid.Nil
+	if v := strings.TrimSpace(r.FormValue("referred_by_lead_id")); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			h.renderDetailWithError(w, r, leadID, "Pick the referrer from the search results.")
+			return
+		}
+		referrerID = id
+	}
+	err := models.SetLeadReferrer(leadID, referrerID)
+	if h.renderReferralError(w, r, leadID, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to set referrer: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to set referrer: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  ✅ Referrer set to %s, redirecting to detail", referrerID)
+	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?referral=saved#referral", leadID.String()), http.StatusFound)
+}
+
+// applyReferralCredit handles the apply_referral_credit action (admin only): unsettled referral
+// credit comes off the lead's own offer, which may leave it paid in full
+func (h *PreEnrolmentHandler) applyReferralCredit(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, actorUserID string) {
+	applied, err := models.ApplyReferralCredit(leadID, actorUserID)
+	if h.renderReferralError(w, r, leadID, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to apply referral credit: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to apply referral credit: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := models.UpdateLeadStatusFromPayment(leadID); err != nil {
+		log.Printf("ERROR: Failed to update lead status after referral credit: %v", err)
+	}
+	h.cfg.Debugf("  ✅ Referral credit of %d EGP applied, redirecting to detail", applied)
+	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?referral=credit_applied#referral", leadID.String()), http.StatusFound)
+}
+
+// payReferralCashback handles the pay_referral_cashback action (admin only) and posts the payout to finance
+func (h *PreEnrolmentHandler) payReferralCashback(w http.ResponseWriter, r *http.Request, leadID uuid.UUID, actorUserID string) {
+	rewardID, err := uuid.Parse(r.FormValue("reward_id"))
+	if err != nil {
+		http.Error(w, "Invalid reward ID", http.StatusBadRequest)
+		return
+	}
+	err = models.PayReferralCashback(rewardID, r.FormValue("payment_method"), actorUserID)
+	if h.renderReferralError(w, r, leadID, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to pay referral cash-back: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to pay referral cash-back: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  ✅ Referral cash-back %s paid, redirecting to detail", rewardID)
+	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?referral=cashback_paid#referral", leadID.String()), http.StatusFound)
+}
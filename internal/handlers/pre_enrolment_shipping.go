@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// recentShipmentBatches is how many batches the shipping page lists
+const recentShipmentBatches = 30
+
+// Shipping renders the printed books waiting to be packed and the recent shipment batches (admin only)
+func (h *PreEnrolmentHandler) Shipping(w http.ResponseWriter, r *http.Request) {
+	toPack, err := models.GetShipmentsToPack()
+	if err != nil {
+		log.Printf("ERROR: Failed to load shipments to pack: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load shipping: %v", err), http.StatusInternalServerError)
+		return
+	}
+	batches, err := models.GetShipmentBatches(recentShipmentBatches)
+	if err != nil {
+		log.Printf("ERROR: Failed to load shipment batches: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load shipping: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":          "Shipping - Eighty Twenty",
+		"UserRole":       middleware.GetUserRole(r),
+		"IsModerator":    IsModerator(r),
+		"ToPack":         toPack,
+		"Batches":        batches,
+		"Couriers":       models.ShippingCouriers,
+		"Error":          r.URL.Query().Get("error"),
+		"SuccessMessage": r.URL.Query().Get("saved"),
+	}
+	renderTemplate(w, r, "pre_enrolment_shipping.html", data)
+}
+
+// CreateShipmentBatch packs the selected books into a new batch and opens it (admin only)
+func (h *PreEnrolmentHandler) CreateShipmentBatch(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	var leadIDs []uuid.UUID
+	for _, v := range r.Form["lead_id"] {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "Invalid lead ID", http.StatusBadRequest)
+			return
+		}
+		leadIDs = append(leadIDs, id)
+	}
+
+	batch, err := models.CreateShipmentBatch(r.FormValue("courier"), leadIDs, middleware.GetUserID(r))
+	var shippingErr *models.ShippingError
+	if errors.As(err, &shippingErr) {
+		http.Redirect(w, r, "/pre-enrolment/shipping?"+url.Values{"error": {shippingErr.Error()}}.Encode(), http.StatusFound)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to create shipment batch: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to create shipment batch: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  ✅ Shipment batch %d created with %d books", batch.BatchNumber, len(leadIDs))
+	msg := fmt.Sprintf("%s created with %d book(s). Add the tracking numbers, then mark it sent.", batch.Label(), len(leadIDs))
+	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/shipping/batches/%s?", batch.ID)+url.Values{"saved": {msg}}.Encode(), http.StatusFound)
+}
+
+// parseShipmentBatchPath reads /pre-enrolment/shipping/batches/{id}[/manifest]
+func parseShipmentBatchPath(path string) (batchID uuid.UUID, manifest bool, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/pre-enrolment/shipping/batches/"), "/")
+	if len(parts) > 2 || (len(parts) == 2 && parts[1] != "manifest") {
+		return uuid.Nil, false, false
+	}
+	id, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, false, false
+	}
+	return id, len(parts) == 2, true
+}
+
+// ShipmentBatch renders a batch with its tracking numbers and send form, or with a /manifest suffix
+// the printable packing manifest (admin only)
+func (h *PreEnrolmentHandler) ShipmentBatch(w http.ResponseWriter, r *http.Request) {
+	batchID, manifest, ok := parseShipmentBatchPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	batch, err := models.GetShipmentBatch(batchID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to load shipment batch: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load shipment batch: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if manifest {
+		initTemplates()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := templates.ExecuteTemplate(w, "shipment_manifest_document", map[string]interface{}{
+			"Batch":     batch,
+			"PrintedAt": time.Now(),
+		}); err != nil {
+			log.Printf("ERROR: Failed to render packing manifest: %v", err)
+		}
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":          batch.Label() + " - Shipping - Eighty Twenty",
+		"UserRole":       middleware.GetUserRole(r),
+		"IsModerator":    IsModerator(r),
+		"Batch":          batch,
+		"Today":          time.Now().Format("2006-01-02"),
+		"Error":          r.URL.Query().Get("error"),
+		"SuccessMessage": r.URL.Query().Get("saved"),
+	}
+	renderTemplate(w, r, "pre_enrolment_shipment_batch.html", data)
+}
+
+// SaveShipmentBatch saves tracking numbers, takes a book out of the batch, or marks the batch sent
+// (action=save_tracking|remove|mark_sent, admin only)
+func (h *PreEnrolmentHandler) SaveShipmentBatch(w http.ResponseWriter, r *http.Request) {
+	batchID, manifest, ok := parseShipmentBatchPath(r.URL.Path)
+	if !ok || manifest {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	batchURL := fmt.Sprintf("/pre-enrolment/shipping/batches/%s", batchID)
+	fail := func(msg string) {
+		http.Redirect(w, r, batchURL+"?"+url.Values{"error": {msg}}.Encode(), http.StatusFound)
+	}
+
+	var err error
+	var done string
+	switch r.FormValue("action") {
+	case "save_tracking":
+		tracking := make(map[uuid.UUID]string)
+		for key, values := range r.PostForm {
+			leadIDStr, found := strings.CutPrefix(key, "tracking_")
+			if !found || len(values) == 0 {
+				continue
+			}
+			leadID, parseErr := uuid.Parse(leadIDStr)
+			if parseErr != nil {
+				http.Error(w, "Invalid lead ID", http.StatusBadRequest)
+				return
+			}
+			tracking[leadID] = values[0]
+		}
+		err = models.SaveBatchTrackingNumbers(batchID, tracking)
+		done = "Tracking numbers saved."
+	case "remove":
+		leadID, parseErr := uuid.Parse(r.FormValue("lead_id"))
+		if parseErr != nil {
+			http.Error(w, "Invalid lead ID", http.StatusBadRequest)
+			return
+		}
+		err = models.RemoveFromShipmentBatch(batchID, leadID)
+		done = "Book taken out of the batch; it is back on the to-pack list."
+	case "mark_sent":
+		cost, parseErr := strconv.Atoi(strings.TrimSpace(r.FormValue("shipping_cost")))
+		if parseErr != nil {
+			fail("Shipping cost must be a whole number.")
+			return
+		}
+		sentOn, parseErr := time.Parse("2006-01-02", r.FormValue("sent_on"))
+		if parseErr != nil {
+			fail("Sent date is required.")
+			return
+		}
+		err = models.MarkShipmentBatchSent(batchID, int32(cost), r.FormValue("payment_method"), sentOn)
+		done = "Batch marked sent."
+		if cost > 0 {
+			done = fmt.Sprintf("Batch marked sent; shipping cost of %d EGP posted to finance.", cost)
+		}
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	var shippingErr *models.ShippingError
+	if errors.As(err, &shippingErr) {
+		fail(shippingErr.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to update shipment batch: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to update shipment batch: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, batchURL+"?"+url.Values{"saved": {done}}.Encode(), http.StatusFound)
+}
+
+// setShipmentStatus handles the detail page's set_shipment_status action: a sent book was delivered
+// or came back (admin only)
+func (h *PreEnrolmentHandler) setShipmentStatus(w http.ResponseWriter, r *http.Request, leadID uuid.UUID) {
+	status := r.FormValue("shipment_status")
+	err := models.SetShipmentStatus(leadID, status)
+	var shippingErr *models.ShippingError
+	if errors.As(err, &shippingErr) {
+		h.renderDetailWithError(w, r, leadID, shippingErr.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to update shipment status: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to update shipment status: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.cfg.Debugf("  ✅ Shipment marked %s, redirecting to detail", status)
+	http.Redirect(w, r, fmt.Sprintf("/pre-enrolment/%s?shipment=%s#shipping-section", leadID.String(), status), http.StatusFound)
+}
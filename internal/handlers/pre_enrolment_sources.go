@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Sources renders the managed lead source list (admin only)
+func (h *PreEnrolmentHandler) Sources(w http.ResponseWriter, r *http.Request) {
+	sources, err := models.GetLeadSources(true)
+	if err != nil {
+		log.Printf("ERROR: Failed to load lead sources: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load lead sources: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":          "Lead Sources - Eighty Twenty",
+		"UserRole":       middleware.GetUserRole(r),
+		"IsModerator":    IsModerator(r),
+		"Sources":        sources,
+		"Fallback":       models.LeadSourceFallback,
+		"Error":          r.URL.Query().Get("error"),
+		"SuccessMessage": r.URL.Query().Get("saved"),
+	}
+	renderTemplate(w, r, "pre_enrolment_sources.html", data)
+}
+
+// SaveSource adds, renames, retires or restores a lead source (action=create|rename|retire|restore)
+func (h *PreEnrolmentHandler) SaveSource(w http.ResponseWriter, r *http.Request) {
+	action := r.FormValue("action")
+	var err error
+	var done string
+	if action == "create" {
+		err = models.CreateLeadSource(r.FormValue("name"))
+		done = "Source added."
+	} else {
+		id, parseErr := uuid.Parse(r.FormValue("source_id"))
+		if parseErr != nil {
+			http.Error(w, "Invalid source ID", http.StatusBadRequest)
+			return
+		}
+		switch action {
+		case "rename":
+			err = models.RenameLeadSource(id, r.FormValue("name"))
+			done = "Source renamed on all leads."
+		case "retire":
+			err = models.SetLeadSourceActive(id, false)
+			done = "Source retired."
+		case "restore":
+			err = models.SetLeadSourceActive(id, true)
+			done = "Source restored."
+		default:
+			http.Error(w, "Invalid action", http.StatusBadRequest)
+			return
+		}
+	}
+	if err != nil {
+		var sourceErr *models.LeadSourceError
+		if !errors.As(err, &sourceErr) {
+			log.Printf("ERROR: Failed to %s lead source: %v", action, err)
+			http.Error(w, fmt.Sprintf("Failed to update lead source: %v", err), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/pre-enrolment/sources?"+url.Values{"error": {sourceErr.Error()}}.Encode(), http.StatusFound)
+		return
+	}
+	h.cfg.Debugf("  → Lead source %s done", action)
+	http.Redirect(w, r, "/pre-enrolment/sources?"+url.Values{"saved": {done}}.Encode(), http.StatusFound)
+}
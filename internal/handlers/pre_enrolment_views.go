@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// listViewFilterKeys are the list query parameters a saved view stores
+var listViewFilterKeys = []string{"status", "search", "payment", "hot", "follow_up", "include_cancelled", "sort"}
+
+// SaveListView creates, deletes or sets the role default of a saved list view
+// (action=create|delete|set_default). Anyone on the list can save and delete their own views;
+// shared views and role defaults are admin only.
+func (h *PreEnrolmentHandler) SaveListView(w http.ResponseWriter, r *http.Request) {
+	// Back to the list as it was, with only the list's own parameters
+	returnQuery := url.Values{}
+	if q, err := url.ParseQuery(r.FormValue("return_query")); err == nil {
+		for _, key := range append([]string{"view"}, listViewFilterKeys...) {
+			if v := q.Get(key); v != "" {
+				returnQuery.Set(key, v)
+			}
+		}
+	}
+	fail := func(msg string) {
+		returnQuery.Set("view_error", msg)
+		http.Redirect(w, r, "/pre-enrolment?"+returnQuery.Encode(), http.StatusFound)
+	}
+
+	userID := middleware.GetUserID(r)
+	action := r.FormValue("action")
+	var err error
+	var target string
+	switch action {
+	case "create":
+		shared := r.FormValue("shared") == "1"
+		defaultRole := r.FormValue("default_role")
+		if (shared || defaultRole != "") && !IsAdmin(r) {
+			fail("Only admins can share views or set a role's default view.")
+			return
+		}
+		view := &models.LeadListView{
+			Name:             r.FormValue("name"),
+			Status:           r.FormValue("status"),
+			Search:           r.FormValue("search"),
+			Payment:          r.FormValue("payment"),
+			Hot:              r.FormValue("hot"),
+			FollowUp:         r.FormValue("follow_up"),
+			IncludeCancelled: r.FormValue("include_cancelled") == "1",
+			Sort:             r.FormValue("sort"),
+			Columns:          r.Form["columns"],
+			DefaultForRole:   sql.NullString{String: defaultRole, Valid: defaultRole != ""},
+		}
+		if !shared {
+			view.OwnerUserID = sql.NullString{String: userID, Valid: true}
+		}
+		err = models.CreateLeadListView(view, userID)
+		if err == nil {
+			target = "/pre-enrolment?" + view.URLQuery() + "&view_saved=1"
+		}
+	case "delete", "set_default":
+		id, parseErr := uuid.Parse(r.FormValue("view_id"))
+		if parseErr != nil {
+			http.Error(w, "Invalid view ID", http.StatusBadRequest)
+			return
+		}
+		if action == "delete" {
+			err = models.DeleteLeadListView(id, userID, IsAdmin(r))
+			target = "/pre-enrolment?view=all&view_deleted=1"
+		} else {
+			if !IsAdmin(r) {
+				fail("Only admins can set a role's default view.")
+				return
+			}
+			err = models.SetLeadListViewDefault(id, r.FormValue("default_role"))
+			returnQuery.Set("view_default", "1")
+			target = "/pre-enrolment?" + returnQuery.Encode()
+		}
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		var viewErr *models.LeadListViewError
+		if !errors.As(err, &viewErr) {
+			log.Printf("ERROR: Failed to %s list view: %v", action, err)
+			http.Error(w, fmt.Sprintf("Failed to update list view: %v", err), http.StatusInternalServerError)
+			return
+		}
+		returnQuery.Del("view_default")
+		fail(viewErr.Error())
+		return
+	}
+	h.cfg.Debugf("  → List view %s done", action)
+	http.Redirect(w, r, target, http.StatusFound)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"eighty-twenty-ops/internal/config"
+	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
+)
+
+type ReportsHandler struct {
+	cfg *config.Config
+}
+
+func NewReportsHandler(cfg *config.Config) *ReportsHandler {
+	return &ReportsHandler{cfg: cfg}
+}
+
+// funnelFilters reads from/to (YYYY-MM-DD, inclusive) and source from the query.
+// The default range is the current month and the five before it.
+func funnelFilters(r *http.Request) (time.Time, time.Time, string) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from := time.Date(now.Year(), now.Month()-5, 1, 0, 0, 0, 0, time.UTC)
+	to := today
+	q := r.URL.Query()
+	if t, err := time.Parse("2006-01-02", q.Get("from")); err == nil {
+		from = t
+	}
+	if t, err := time.Parse("2006-01-02", q.Get("to")); err == nil {
+		to = t
+	}
+	if to.Before(from) {
+		from, to = to, from
+	}
+	return from, to, q.Get("source")
+}
+
+// Funnel renders the funnel and source-attribution report (admin only).
+// Query: from, to (YYYY-MM-DD), source.
+func (h *ReportsHandler) Funnel(w http.ResponseWriter, r *http.Request) {
+	from, to, source := funnelFilters(r)
+	report, err := models.GetFunnelReport(from, to, source)
+	if err != nil {
+		log.Printf("ERROR: Failed to build funnel report: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to build funnel report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	sources, err := models.GetLeadSources(true)
+	if err != nil {
+		log.Printf("ERROR: Failed to load lead sources: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load lead sources: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	h.cfg.Debugf("  → Funnel report %s..%s source=%q: %d leads", report.From, report.To, source, report.Total.Leads)
+	data := map[string]interface{}{
+		"Title":       "Funnel Report - Eighty Twenty",
+		"UserRole":    middleware.GetUserRole(r),
+		"IsModerator": IsModerator(r),
+		"Report":      report,
+		"StageLabels": []string{"Leads", "Test booked", "Tested", "Offer sent", "Paid", "In classes"},
+		"Sources":     sources,
+		"Query":       r.URL.RawQuery,
+	}
+	renderTemplate(w, r, "reports_funnel.html", data)
+}
+
+// FunnelJSON returns the same report as JSON (GET /api/reports/funnel, admin only)
+func (h *ReportsHandler) FunnelJSON(w http.ResponseWriter, r *http.Request) {
+	from, to, source := funnelFilters(r)
+	report, err := models.GetFunnelReport(from, to, source)
+	if err != nil {
+		log.Printf("ERROR: Failed to build funnel report: %v", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to build funnel report")
+		return
+	}
+	jsonResponse(w, http.StatusOK, report)
+}
+
+// Referrals renders the referral leaderboard: referrers ranked by referred leads that paid in full,
+// with the credit and cash-back still outstanding (admin only)
+func (h *ReportsHandler) Referrals(w http.ResponseWriter, r *http.Request) {
+	board, err := models.GetReferralLeaderboard()
+	if err != nil {
+		log.Printf("ERROR: Failed to build referral leaderboard: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to build referral leaderboard: %v", err), http.StatusInternalServerError)
+		return
+	}
+	settings, err := models.GetReferralSettings()
+	if err != nil {
+		log.Printf("ERROR: Failed to load referral settings: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to load referral settings: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var totals models.ReferralLeaderboardRow
+	for _, row := range board {
+		totals.Referred += row.Referred
+		totals.Converted += row.Converted
+		totals.Rewarded += row.Rewarded
+		totals.CreditAvailable += row.CreditAvailable
+		totals.CashbackOwed += row.CashbackOwed
+	}
+
+	h.cfg.Debugf("  → Referral leaderboard: %d referrers", len(board))
+	data := map[string]interface{}{
+		"Title":       "Referral Report - Eighty Twenty",
+		"UserRole":    middleware.GetUserRole(r),
+		"IsModerator": IsModerator(r),
+		"Board":       board,
+		"Totals":      &totals,
+		"Settings":    settings,
+	}
+	renderTemplate(w, r, "reports_referrals.html", data)
+}
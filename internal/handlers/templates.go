@@ -13,6 +13,7 @@ import (
 
 	"eighty-twenty-ops/internal/config"
 	"eighty-twenty-ops/internal/middleware"
+	"eighty-twenty-ops/internal/models"
 	"eighty-twenty-ops/internal/views"
 )
 
@@ -86,6 +87,14 @@ func initTemplates() {
 			"sub": func(a, b int) int {
 				return a - b
 			},
+			"add": func(a, b int) int {
+				return a + b
+			},
+			"statusName": func(status string) string {
+				return models.GetStatusDisplayInfo(status).DisplayName
+			},
+			"taskTypeName":    models.LeadTaskTypeLabel,
+			"taskOutcomeName": models.LeadTaskOutcomeLabel,
 		}
 		tmpl := template.New("").Funcs(funcMap)
 		var err2 error
@@ -134,20 +143,41 @@ func renderTemplate(w http.ResponseWriter, r *http.Request, name string, data in
 		"pre_enrolment_new.html":    "pre_enrolment_new_content",
 		"pre_enrolment_list.html":   "pre_enrolment_list_content",
 		"pre_enrolment_detail.html": "pre_enrolment_detail_content",
+		"pre_enrolment_import.html": "pre_enrolment_import_content",
+		"pre_enrolment_merge.html":  "pre_enrolment_merge_content",
+		"pre_enrolment_sources.html": "pre_enrolment_sources_content",
+		"pre_enrolment_custom_fields.html": "pre_enrolment_custom_fields_content",
+		"pre_enrolment_payers.html": "pre_enrolment_payers_content",
+		"pre_enrolment_payer.html": "pre_enrolment_payer_content",
+		"pre_enrolment_prices.html": "pre_enrolment_prices_content",
+		"pre_enrolment_promo_codes.html": "pre_enrolment_promo_codes_content",
+		"pre_enrolment_shipping.html": "pre_enrolment_shipping_content",
+		"pre_enrolment_shipment_batch.html": "pre_enrolment_shipment_batch_content",
+		"pre_enrolment_archived.html": "pre_enrolment_archived_content",
+		"lead_tasks.html":            "lead_tasks_content",
 		"classes.html":              "classes_content",
+		"classes_waitlist.html":     "classes_waitlist_content",
 		"finance.html":              "finance_content",
 		"finance_new_expense.html":  "finance_new_expense_content",
+		"finance_materials.html":    "finance_materials_content",
 		"access_restricted.html":    "access_restricted_content",
 		"mentor_head.html":          "mentor_head_content",
 		"mentor.html":               "mentor_content",
 		"mentor_class_detail.html":  "mentor_class_detail_content",
 		"community_officer.html":   "community_officer_content",
 		"hr_mentors.html":          "hr_mentors_content",
+		"placement_tests.html":     "placement_tests_content",
+		"placement_agenda.html":    "placement_agenda_content",
+		"placement_questions.html": "placement_questions_content",
+		"online_test.html":         "online_test_content",
+		"reports_funnel.html":      "reports_funnel_content",
+		"reports_referrals.html":   "reports_referrals_content",
 	}
 	
 	// Templates that use auth_layout instead of main layout
 	authLayoutTemplates := map[string]bool{
-		"login.html": true,
+		"login.html":       true,
+		"online_test.html": true, // public placement test link, no sidebar
 	}
 
 	// Get the content template name for this file
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reasons recorded in class_enrolments.left_reason
+const (
+	EnrolmentLeftMoved       = "moved"
+	EnrolmentLeftRoundClosed = "round_closed"
+	EnrolmentLeftRemoved     = "removed"
+	EnrolmentLeftPaused      = "paused"
+)
+
+// enrolClassFromSchedulingTx snapshots the classes board assignment (scheduling + placement level)
+// into class_enrolments when a round starts. Students already actively enrolled elsewhere are skipped.
+func enrolClassFromSchedulingTx(tx *sql.Tx, classKey string, now time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO class_enrolments (lead_id, class_key, round, joined_at, created_at, updated_at)
+		SELECT s.lead_id, cg.class_key,
+			(SELECT CAST(value AS INTEGER) FROM settings WHERE key = 'current_round'),
+			$2, $2, $2
+		FROM scheduling s
+		INNER JOIN placement_tests pt ON pt.lead_id = s.lead_id
+		INNER JOIN class_groups cg ON (
+			cg.level = pt.assigned_level
+			AND cg.class_days = s.class_days
+			AND cg.class_time = s.class_time::text
+			AND COALESCE(cg.class_number, 1) = COALESCE(s.class_group_index, 1)
+		)
+		WHERE cg.class_key = $1
+		AND NOT EXISTS (
+			SELECT 1 FROM class_enrolments ce WHERE ce.lead_id = s.lead_id AND ce.left_at IS NULL
+		)
+	`, classKey, now)
+	if err != nil {
+		return fmt.Errorf("failed to enrol students: %w", err)
+	}
+	return nil
+}
+
+// leaveActiveEnrolmentTx closes the lead's active enrolment (if any) with the given reason
+func leaveActiveEnrolmentTx(q sqlExecer, leadID uuid.UUID, reason string, now time.Time) error {
+	_, err := q.Exec(`
+		UPDATE class_enrolments
+		SET left_at = $1, left_reason = $2, updated_at = $1
+		WHERE lead_id = $3 AND left_at IS NULL
+	`, now, reason, leadID)
+	if err != nil {
+		return fmt.Errorf("failed to close enrolment: %w", err)
+	}
+	return nil
+}
+
+// GetActiveEnrolment returns the lead's current class enrolment, or nil if not enrolled
+func GetActiveEnrolment(leadID uuid.UUID) (*ClassEnrolment, error) {
+	e := &ClassEnrolment{}
+	err := db.DB.QueryRow(`
+		SELECT id, lead_id, class_key, round, joined_at, left_at, left_reason,
+			outcome, absences, grade, next_level, follow_on, created_at, updated_at
+		FROM class_enrolments
+		WHERE lead_id = $1 AND left_at IS NULL
+	`, leadID).Scan(&e.ID, &e.LeadID, &e.ClassKey, &e.Round, &e.JoinedAt, &e.LeftAt, &e.LeftReason,
+		&e.Outcome, &e.Absences, &e.Grade, &e.NextLevel, &e.FollowOn, &e.CreatedAt, &e.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active enrolment: %w", err)
+	}
+	return e, nil
+}
+
+// GetLeadEnrolments returns the lead's enrolment history, newest first
+func GetLeadEnrolments(leadID uuid.UUID) ([]*ClassEnrolment, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, lead_id, class_key, round, joined_at, left_at, left_reason,
+			outcome, absences, grade, next_level, follow_on, created_at, updated_at
+		FROM class_enrolments
+		WHERE lead_id = $1
+		ORDER BY joined_at DESC
+	`, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query enrolments: %w", err)
+	}
+	defer rows.Close()
+
+	var enrolments []*ClassEnrolment
+	for rows.Next() {
+		e := &ClassEnrolment{}
+		if err := rows.Scan(&e.ID, &e.LeadID, &e.ClassKey, &e.Round, &e.JoinedAt, &e.LeftAt, &e.LeftReason,
+			&e.Outcome, &e.Absences, &e.Grade, &e.NextLevel, &e.FollowOn, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan enrolment: %w", err)
+		}
+		enrolments = append(enrolments, e)
+	}
+	return enrolments, rows.Err()
+}
+
+// currentRoundEnrolment limits class_enrolments ce, joined to its class_groups cg, to the class's
+// current round: the round has started and the student joined at or after its start. Enrolments
+// from earlier rounds of the same class are left out.
+const currentRoundEnrolment = `cg.round_status IN ('active', 'closed')
+	AND (cg.round_started_at IS NULL OR ce.joined_at >= cg.round_started_at)`
+
+// HasClassEnrolments reports whether a class has enrolments in its current round (i.e. the round has been started)
+func HasClassEnrolments(classKey string) (bool, error) {
+	var exists bool
+	err := db.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM class_enrolments ce
+			INNER JOIN class_groups cg ON cg.class_key = ce.class_key
+			WHERE ce.class_key = $1 AND `+currentRoundEnrolment+`
+		)
+	`, classKey).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check enrolments: %w", err)
+	}
+	return exists, nil
+}
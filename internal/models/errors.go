@@ -25,6 +25,174 @@ func (e *PhoneAlreadyExistsError) Error() string {
 	return fmt.Sprintf("Phone number %s already exists", e.Phone)
 }
 
+// PlacementSlotError is returned when a placement test slot cannot be created or booked
+// (examiner already busy, slot full, slot in the past)
+type PlacementSlotError struct {
+	Message string
+}
+
+func (e *PlacementSlotError) Error() string {
+	return e.Message
+}
+
+// OnlineTestError is returned when an online placement test or bank question is rejected
+// (invalid link, expired, already submitted, incomplete question)
+type OnlineTestError struct {
+	Message string
+}
+
+func (e *OnlineTestError) Error() string {
+	return e.Message
+}
+
+// LeadSourceError is returned when a managed lead source cannot be added, renamed or retired
+type LeadSourceError struct {
+	Message string
+}
+
+func (e *LeadSourceError) Error() string {
+	return e.Message
+}
+
+// LeadTaskError is returned when a follow-up task cannot be created, completed or reassigned
+type LeadTaskError struct {
+	Message string
+}
+
+func (e *LeadTaskError) Error() string {
+	return e.Message
+}
+
+// LeadPauseError is returned when a pause request is incomplete (reason, return date)
+type LeadPauseError struct {
+	Message string
+}
+
+func (e *LeadPauseError) Error() string {
+	return e.Message
+}
+
+// LeadArchiveError is returned when a lead cannot be archived, restored or purged
+type LeadArchiveError struct {
+	Message string
+}
+
+func (e *LeadArchiveError) Error() string {
+	return e.Message
+}
+
+// LeadListViewError is returned when a saved list view cannot be saved or deleted
+type LeadListViewError struct {
+	Message string
+}
+
+func (e *LeadListViewError) Error() string {
+	return e.Message
+}
+
+// CustomFieldError is returned when a custom field definition or a lead's custom value is invalid,
+// or required custom fields are missing
+type CustomFieldError struct {
+	Message string
+}
+
+func (e *CustomFieldError) Error() string {
+	return e.Message
+}
+
+// PayerError is returned when a payer cannot be saved or linked, or a family payment cannot be
+// allocated across the payer's leads
+type PayerError struct {
+	Message string
+}
+
+func (e *PayerError) Error() string {
+	return e.Message
+}
+
+// PricePlanError is returned when a price list cannot be published or used
+type PricePlanError struct {
+	Message string
+}
+
+func (e *PricePlanError) Error() string {
+	return e.Message
+}
+
+// PromoCodeError is returned when a promo code is invalid, not applicable or used up,
+// or a discount approval is not allowed
+type PromoCodeError struct {
+	Message string
+}
+
+func (e *PromoCodeError) Error() string {
+	return e.Message
+}
+
+// OfferLetterError is returned when an offer letter cannot be issued or its settings are invalid
+type OfferLetterError struct {
+	Message string
+}
+
+func (e *OfferLetterError) Error() string {
+	return e.Message
+}
+
+// InstallmentError is returned when an installment plan is invalid, or an overdue installment blocks an action
+type InstallmentError struct {
+	Message string
+}
+
+func (e *InstallmentError) Error() string {
+	return e.Message
+}
+
+// WaitlistError is returned when a suggested class group can no longer be formed
+type WaitlistError struct {
+	Message string
+}
+
+func (e *WaitlistError) Error() string {
+	return e.Message
+}
+
+// ShippingError is returned when a shipment or batch change is not allowed (bad address, missing
+// tracking number, invalid status transition)
+type ShippingError struct {
+	Message string
+}
+
+func (e *ShippingError) Error() string {
+	return e.Message
+}
+
+// MaterialError is returned when a catalogue item or stock movement is invalid
+type MaterialError struct {
+	Message string
+}
+
+func (e *MaterialError) Error() string {
+	return e.Message
+}
+
+// ReferralError is returned when a referrer, referral setting or reward change is not allowed
+type ReferralError struct {
+	Message string
+}
+
+func (e *ReferralError) Error() string {
+	return e.Message
+}
+
+// RenewalOfferError is returned when a renewal offer cannot be accepted or declined
+type RenewalOfferError struct {
+	Message string
+}
+
+func (e *RenewalOfferError) Error() string {
+	return e.Message
+}
+
 // IsPhoneConstraintError checks if an error is a PostgreSQL unique constraint violation on phone
 // Returns the error wrapped as PhoneAlreadyExistsError if it matches, or nil otherwise
 func IsPhoneConstraintError(err error) *PhoneAlreadyExistsError {
@@ -0,0 +1,286 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FunnelStages are the funnel steps reported, in order. "paid" covers every paid status.
+var FunnelStages = []string{"lead_created", "test_booked", "tested", "offer_sent", "paid", "in_classes"}
+
+const funnelStagePaid = 4
+
+// funnelStageOfStatus maps a lead status to the furthest funnel stage it implies.
+// cancelled and paused are not stages; the stages reached before them still count.
+var funnelStageOfStatus = map[string]int{
+	"lead_created":      0,
+	"test_booked":       1,
+	"tested":            2,
+	"offer_sent":        3,
+	"booking_confirmed": 3,
+	"deposit_paid":      4,
+	"paid_full":         4,
+	"waiting_for_round": 4,
+	"schedule_assigned": 4,
+	"ready_to_start":    4,
+	"in_classes":        5,
+}
+
+// FunnelNoSource labels leads without a source
+const FunnelNoSource = "(none)"
+
+// funnelStatusChange is one lead_status_history row used to date the stages
+type funnelStatusChange struct {
+	Status string
+	At     time.Time
+}
+
+// funnelLead is one lead's path through the funnel
+type funnelLead struct {
+	Source    string
+	Cohort    string       // creation month, YYYY-MM
+	MaxStage  int          // furthest stage reached
+	StageAt   [6]time.Time // first time each stage was reached (zero if unknown)
+	Cancelled bool
+	Revenue   int64 // course payments minus refunds
+}
+
+// buildFunnelLead works out how far a lead got and when. Reaching a stage implies every earlier
+// stage was reached too, even when the history has no date for it (e.g. leads older than the history).
+func buildFunnelLead(source string, createdAt time.Time, status string, history []funnelStatusChange, firstPayment sql.NullTime, revenue int64) *funnelLead {
+	if source == "" {
+		source = FunnelNoSource
+	}
+	fl := &funnelLead{
+		Source:    source,
+		Cohort:    createdAt.Format("2006-01"),
+		Cancelled: status == "cancelled",
+		Revenue:   revenue,
+	}
+	fl.StageAt[0] = createdAt
+	reach := func(stage int, at time.Time) {
+		if stage > fl.MaxStage {
+			fl.MaxStage = stage
+		}
+		if !at.IsZero() && (fl.StageAt[stage].IsZero() || at.Before(fl.StageAt[stage])) {
+			fl.StageAt[stage] = at
+		}
+	}
+	if stage, ok := funnelStageOfStatus[status]; ok {
+		reach(stage, time.Time{})
+	}
+	for _, h := range history {
+		if stage, ok := funnelStageOfStatus[h.Status]; ok && stage > 0 {
+			reach(stage, h.At)
+		}
+	}
+	if firstPayment.Valid {
+		reach(funnelStagePaid, firstPayment.Time)
+	}
+	return fl
+}
+
+// FunnelRow is the funnel for one source (and cohort month, when grouped by cohort)
+type FunnelRow struct {
+	Source          string     `json:"source"`
+	Cohort          string     `json:"cohort,omitempty"`
+	Leads           int        `json:"leads"`
+	Reached         []int      `json:"reached"`     // leads reaching each of FunnelStages
+	ReachedPct      []float64  `json:"reached_pct"` // share of the row's leads reaching each stage
+	StepPct         []float64  `json:"step_pct"`    // share of the previous stage reaching each stage
+	MedianDays      []*float64 `json:"median_days"` // median days from stage i to i+1; nil when unknown
+	Cancelled       int        `json:"cancelled"`
+	CancellationPct float64    `json:"cancellation_pct"`
+	Revenue         int64      `json:"revenue"`
+}
+
+// FunnelReport groups the funnel by source, by source and creation month, and overall
+type FunnelReport struct {
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Source   string       `json:"source,omitempty"`
+	Stages   []string     `json:"stages"`
+	Total    *FunnelRow   `json:"total"`
+	BySource []*FunnelRow `json:"by_source"`
+	ByCohort []*FunnelRow `json:"by_cohort"`
+}
+
+func pct(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part*1000/whole) / 10
+}
+
+func medianDays(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Float64s(values)
+	m := values[len(values)/2]
+	if len(values)%2 == 0 {
+		m = (values[len(values)/2-1] + m) / 2
+	}
+	m = float64(int(m*10+0.5)) / 10
+	return &m
+}
+
+// summariseFunnel aggregates leads into one row
+func summariseFunnel(source, cohort string, leads []*funnelLead) *FunnelRow {
+	n := len(FunnelStages)
+	row := &FunnelRow{
+		Source:     source,
+		Cohort:     cohort,
+		Leads:      len(leads),
+		Reached:    make([]int, n),
+		ReachedPct: make([]float64, n),
+		StepPct:    make([]float64, n),
+		MedianDays: make([]*float64, n-1),
+	}
+	gaps := make([][]float64, n-1)
+	for _, fl := range leads {
+		for stage := 0; stage <= fl.MaxStage; stage++ {
+			row.Reached[stage]++
+		}
+		for stage := 0; stage < n-1; stage++ {
+			from, to := fl.StageAt[stage], fl.StageAt[stage+1]
+			if !from.IsZero() && !to.IsZero() && !to.Before(from) {
+				gaps[stage] = append(gaps[stage], to.Sub(from).Hours()/24)
+			}
+		}
+		if fl.Cancelled {
+			row.Cancelled++
+		}
+		row.Revenue += fl.Revenue
+	}
+	for stage := 0; stage < n; stage++ {
+		row.ReachedPct[stage] = pct(row.Reached[stage], row.Leads)
+		if stage == 0 {
+			row.StepPct[stage] = row.ReachedPct[stage]
+		} else {
+			row.StepPct[stage] = pct(row.Reached[stage], row.Reached[stage-1])
+		}
+	}
+	for stage := range gaps {
+		row.MedianDays[stage] = medianDays(gaps[stage])
+	}
+	row.CancellationPct = pct(row.Cancelled, row.Leads)
+	return row
+}
+
+// buildFunnelReport groups leads by source (by lead count, largest first) and by source × cohort month
+func buildFunnelReport(leads []*funnelLead) *FunnelReport {
+	bySource := map[string][]*funnelLead{}
+	byCohort := map[[2]string][]*funnelLead{}
+	for _, fl := range leads {
+		bySource[fl.Source] = append(bySource[fl.Source], fl)
+		key := [2]string{fl.Source, fl.Cohort}
+		byCohort[key] = append(byCohort[key], fl)
+	}
+	report := &FunnelReport{
+		Stages: FunnelStages,
+		Total:  summariseFunnel("All sources", "", leads),
+	}
+	for source, group := range bySource {
+		report.BySource = append(report.BySource, summariseFunnel(source, "", group))
+	}
+	sort.Slice(report.BySource, func(i, j int) bool {
+		a, b := report.BySource[i], report.BySource[j]
+		if a.Leads != b.Leads {
+			return a.Leads > b.Leads
+		}
+		return a.Source < b.Source
+	})
+	for key, group := range byCohort {
+		report.ByCohort = append(report.ByCohort, summariseFunnel(key[0], key[1], group))
+	}
+	sort.Slice(report.ByCohort, func(i, j int) bool {
+		a, b := report.ByCohort[i], report.ByCohort[j]
+		if a.Cohort != b.Cohort {
+			return a.Cohort > b.Cohort
+		}
+		return a.Source < b.Source
+	})
+	return report
+}
+
+// GetFunnelReport builds the funnel for leads created in [from, to] (dates, inclusive),
+// optionally limited to one source
+func GetFunnelReport(from, to time.Time, source string) (*FunnelReport, error) {
+	end := to.AddDate(0, 0, 1)
+	rows, err := db.DB.Query(`
+		SELECT l.id, COALESCE(l.source, ''), l.created_at, l.status,
+			(SELECT MIN(p.payment_date) FROM lead_payments p WHERE p.lead_id = l.id),
+			COALESCE((SELECT SUM(p.amount) FROM lead_payments p WHERE p.lead_id = l.id), 0)
+			- COALESCE((SELECT SUM(t.amount) FROM transactions t
+				WHERE t.lead_id = l.id AND t.transaction_type = 'OUT' AND t.category = 'refund'), 0)
+		FROM leads l
+		WHERE l.created_at >= $1 AND l.created_at < $2 AND ($3 = '' OR l.source = $3)
+	`, from, end, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query funnel leads: %w", err)
+	}
+	type leadRow struct {
+		source, status string
+		createdAt      time.Time
+		firstPayment   sql.NullTime
+		revenue        int64
+	}
+	var ids []string
+	leadRows := map[uuid.UUID]*leadRow{}
+	for rows.Next() {
+		var id uuid.UUID
+		lr := &leadRow{}
+		if err := rows.Scan(&id, &lr.source, &lr.createdAt, &lr.status, &lr.firstPayment, &lr.revenue); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan funnel lead: %w", err)
+		}
+		leadRows[id] = lr
+		ids = append(ids, id.String())
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read funnel leads: %w", err)
+	}
+
+	history := map[uuid.UUID][]funnelStatusChange{}
+	if len(ids) > 0 {
+		hrows, err := db.DB.Query(`
+			SELECT lead_id, new_status, changed_at
+			FROM lead_status_history
+			WHERE lead_id::text = ANY($1)
+			ORDER BY changed_at
+		`, ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query status history: %w", err)
+		}
+		for hrows.Next() {
+			var id uuid.UUID
+			var h funnelStatusChange
+			if err := hrows.Scan(&id, &h.Status, &h.At); err != nil {
+				hrows.Close()
+				return nil, fmt.Errorf("failed to scan status history: %w", err)
+			}
+			history[id] = append(history[id], h)
+		}
+		hrows.Close()
+		if err := hrows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read status history: %w", err)
+		}
+	}
+
+	leads := make([]*funnelLead, 0, len(leadRows))
+	for id, lr := range leadRows {
+		leads = append(leads, buildFunnelLead(lr.source, lr.createdAt, lr.status, history[id], lr.firstPayment, lr.revenue))
+	}
+	report := buildFunnelReport(leads)
+	report.From = from.Format("2006-01-02")
+	report.To = to.Format("2006-01-02")
+	report.Source = source
+	return report, nil
+}
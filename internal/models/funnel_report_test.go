@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestBuildFunnelLead(t *testing.T) {
+	created := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	day := func(n int) time.Time { return created.AddDate(0, 0, n) }
+
+	// Cancelled after testing: still counts as tested, history dates the stages
+	fl := buildFunnelLead("", created, "cancelled", []funnelStatusChange{
+		{Status: "test_booked", At: day(2)},
+		{Status: "tested", At: day(5)},
+		{Status: "cancelled", At: day(9)},
+	}, sql.NullTime{}, 0)
+	if fl.Source != FunnelNoSource || fl.Cohort != "2026-03" || !fl.Cancelled {
+		t.Fatalf("unexpected lead %+v", fl)
+	}
+	if fl.MaxStage != 2 || !fl.StageAt[1].Equal(day(2)) || !fl.StageAt[2].Equal(day(5)) {
+		t.Errorf("expected tested on day 5, got max %d at %v", fl.MaxStage, fl.StageAt)
+	}
+
+	// A payment implies the paid stage even without history; earlier stages are reached but undated
+	fl = buildFunnelLead("Facebook", created, "offer_sent", nil, sql.NullTime{Time: day(4), Valid: true}, 500)
+	if fl.MaxStage != funnelStagePaid || !fl.StageAt[funnelStagePaid].Equal(day(4)) || !fl.StageAt[3].IsZero() {
+		t.Errorf("expected paid on day 4 from payment, got max %d at %v", fl.MaxStage, fl.StageAt)
+	}
+}
+
+func TestBuildFunnelReport(t *testing.T) {
+	created := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	booked := func(days int) []funnelStatusChange {
+		return []funnelStatusChange{{Status: "test_booked", At: created.AddDate(0, 0, days)}}
+	}
+	leads := []*funnelLead{
+		buildFunnelLead("Facebook", created, "test_booked", booked(1), sql.NullTime{}, 0),
+		buildFunnelLead("Facebook", created, "test_booked", booked(3), sql.NullTime{}, 0),
+		buildFunnelLead("Facebook", created.AddDate(0, 1, 0), "lead_created", nil, sql.NullTime{}, 0),
+		buildFunnelLead("Facebook", created, "cancelled", nil, sql.NullTime{}, 0),
+		buildFunnelLead("Referral", created, "in_classes", nil, sql.NullTime{Time: created, Valid: true}, 1200),
+	}
+	report := buildFunnelReport(leads)
+
+	if report.Total.Leads != 5 || report.Total.Revenue != 1200 {
+		t.Fatalf("unexpected total %+v", report.Total)
+	}
+	fb := report.BySource[0]
+	if fb.Source != "Facebook" || fb.Leads != 4 {
+		t.Fatalf("expected Facebook first with 4 leads, got %+v", fb)
+	}
+	if fb.Reached[1] != 2 || fb.ReachedPct[1] != 50 || fb.StepPct[2] != 0 {
+		t.Errorf("unexpected reach %v %v %v", fb.Reached, fb.ReachedPct, fb.StepPct)
+	}
+	if fb.MedianDays[0] == nil || *fb.MedianDays[0] != 2 {
+		t.Errorf("expected median 2 days to booking, got %v", fb.MedianDays[0])
+	}
+	if fb.MedianDays[1] != nil {
+		t.Errorf("expected no median without data, got %v", *fb.MedianDays[1])
+	}
+	if fb.Cancelled != 1 || fb.CancellationPct != 25 {
+		t.Errorf("expected 1 cancelled (25%%), got %d (%v%%)", fb.Cancelled, fb.CancellationPct)
+	}
+	if rf := report.BySource[1]; rf.Reached[5] != 1 || rf.Reached[4] != 1 {
+		t.Errorf("expected Referral in classes to count every stage, got %v", rf.Reached)
+	}
+	if len(report.ByCohort) != 3 || report.ByCohort[0].Cohort != "2026-04" {
+		t.Errorf("expected 3 cohorts newest first, got %d starting %q", len(report.ByCohort), report.ByCohort[0].Cohort)
+	}
+}
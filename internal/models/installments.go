@@ -0,0 +1,283 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InstallmentOverdueBlockKey is the settings key that decides whether an overdue installment
+// blocks marking a student ready and sending them to classes ("true"/"false")
+const InstallmentOverdueBlockKey = "installment_overdue_blocks_classes"
+
+const maxInstallments = 12
+
+// Outstanding is the part of the installment not yet covered by payments
+func (i *LeadInstallment) Outstanding() int32 {
+	return i.Amount - i.PaidAmount
+}
+
+// IsOverdue reports whether the installment was due before today and is not fully paid
+func (i *LeadInstallment) IsOverdue(today time.Time) bool {
+	return i.Outstanding() > 0 && i.DueDate.Format("2006-01-02") < today.Format("2006-01-02")
+}
+
+// SplitInstallments builds a plan for total: an optional first installment of firstAmount (e.g. the
+// deposit), then the rest split evenly over the remaining installments, intervalMonths apart from firstDue.
+// Rounding remainders go on the last installment.
+func SplitInstallments(total, firstAmount int32, count int, firstDue time.Time, intervalMonths int) []*LeadInstallment {
+	if count < 1 {
+		return nil
+	}
+	plan := make([]*LeadInstallment, 0, count)
+	rest, restCount := total, count
+	if firstAmount > 0 && count > 1 {
+		plan = append(plan, &LeadInstallment{Amount: firstAmount, DueDate: firstDue})
+		rest -= firstAmount
+		restCount--
+	}
+	share := rest / int32(restCount)
+	for i := 0; i < restCount; i++ {
+		amount := share
+		if i == restCount-1 {
+			amount = rest - share*int32(restCount-1)
+		}
+		plan = append(plan, &LeadInstallment{Amount: amount, DueDate: addMonthsClamped(firstDue, len(plan)*intervalMonths)})
+	}
+	for i, inst := range plan {
+		inst.Seq = i + 1
+	}
+	return plan
+}
+
+// addMonthsClamped moves t by months, keeping the day of month but clamping it to the month's last day
+// (Jan 31 + 1 month = Feb 28, not Mar 3)
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	day := t.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// checkInstallmentPlan validates a plan against the offer's final price
+func checkInstallmentPlan(plan []*LeadInstallment, finalPrice int32) error {
+	if finalPrice <= 0 {
+		return &InstallmentError{Message: "The offer needs a final price before an installment plan can be set."}
+	}
+	if len(plan) < 2 || len(plan) > maxInstallments {
+		return &InstallmentError{Message: fmt.Sprintf("An installment plan needs between 2 and %d installments.", maxInstallments)}
+	}
+	var sum int32
+	for i, inst := range plan {
+		if inst.Amount <= 0 {
+			return &InstallmentError{Message: "Every installment must be a positive amount."}
+		}
+		if i > 0 && inst.DueDate.Before(plan[i-1].DueDate) {
+			return &InstallmentError{Message: "Installments must be in due-date order."}
+		}
+		sum += inst.Amount
+	}
+	if sum != finalPrice {
+		return &InstallmentError{Message: fmt.Sprintf("Installments add up to %d but the offer's final price is %d.", sum, finalPrice)}
+	}
+	return nil
+}
+
+// AllocateInstallmentPayments matches totalPaid (net course payments) to the plan in due-date order.
+// Installments that become fully paid get PaidAt = now; ones no longer fully paid (after a refund) lose it.
+func AllocateInstallmentPayments(plan []*LeadInstallment, totalPaid int32, now time.Time) {
+	remaining := totalPaid
+	for _, inst := range plan {
+		paid := inst.Amount
+		if remaining < paid {
+			paid = remaining
+		}
+		if paid < 0 {
+			paid = 0
+		}
+		inst.PaidAmount = paid
+		remaining -= paid
+		if paid == inst.Amount {
+			if !inst.PaidAt.Valid {
+				inst.PaidAt = sql.NullTime{Time: now, Valid: true}
+			}
+		} else {
+			inst.PaidAt = sql.NullTime{}
+		}
+	}
+}
+
+func getLeadInstallments(q sqlExecer, leadID uuid.UUID) ([]*LeadInstallment, error) {
+	rows, err := q.Query(`
+		SELECT id, lead_id, seq, amount, due_date, paid_amount, paid_at, created_at
+		FROM lead_installments
+		WHERE lead_id = $1
+		ORDER BY due_date, seq
+	`, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installments: %w", err)
+	}
+	defer rows.Close()
+
+	var plan []*LeadInstallment
+	for rows.Next() {
+		inst := &LeadInstallment{}
+		if err := rows.Scan(&inst.ID, &inst.LeadID, &inst.Seq, &inst.Amount, &inst.DueDate, &inst.PaidAmount, &inst.PaidAt, &inst.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan installment: %w", err)
+		}
+		plan = append(plan, inst)
+	}
+	return plan, rows.Err()
+}
+
+// GetLeadInstallments returns the lead's installment plan in due-date order (empty when there is none)
+func GetLeadInstallments(leadID uuid.UUID) ([]*LeadInstallment, error) {
+	return getLeadInstallments(db.DB, leadID)
+}
+
+// syncLeadInstallmentsTx re-matches the lead's net course payments to its installments.
+// Runs after every payment and refund (see UpdateLeadStatusFromPayment).
+func syncLeadInstallmentsTx(tx *sql.Tx, leadID uuid.UUID, totalPaid int32, now time.Time) error {
+	plan, err := getLeadInstallments(tx, leadID)
+	if err != nil {
+		return err
+	}
+	before := make([]LeadInstallment, len(plan))
+	for i, inst := range plan {
+		before[i] = *inst
+	}
+	AllocateInstallmentPayments(plan, totalPaid, now)
+	for i, inst := range plan {
+		if inst.PaidAmount == before[i].PaidAmount && inst.PaidAt.Valid == before[i].PaidAt.Valid {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE lead_installments SET paid_amount = $1, paid_at = $2 WHERE id = $3`,
+			inst.PaidAmount, inst.PaidAt, inst.ID); err != nil {
+			return fmt.Errorf("failed to update installment: %w", err)
+		}
+	}
+	return nil
+}
+
+// SaveLeadInstallments replaces the lead's installment plan and matches existing payments to it.
+// Returns *InstallmentError when the plan does not add up to the offer's final price.
+func SaveLeadInstallments(leadID uuid.UUID, plan []*LeadInstallment, actorUserID string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := getLeadStatusForUpdate(tx, leadID); err != nil {
+		return err
+	}
+	facts, err := loadLeadFactsTx(tx, leadID)
+	if err != nil {
+		return err
+	}
+	if err := checkInstallmentPlan(plan, facts.OfferFinalPrice); err != nil {
+		return err
+	}
+
+	var createdBy sql.NullString
+	if actorUserID != "" {
+		createdBy = sql.NullString{String: actorUserID, Valid: true}
+	}
+	if _, err := tx.Exec(`DELETE FROM lead_installments WHERE lead_id = $1`, leadID); err != nil {
+		return fmt.Errorf("failed to clear installments: %w", err)
+	}
+	now := time.Now()
+	for i, inst := range plan {
+		inst.Seq = i + 1
+		_, err := tx.Exec(`
+			INSERT INTO lead_installments (lead_id, seq, amount, due_date, created_by_user_id, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, leadID, inst.Seq, inst.Amount, inst.DueDate, createdBy, now)
+		if err != nil {
+			return fmt.Errorf("failed to insert installment: %w", err)
+		}
+	}
+	if err := syncLeadInstallmentsTx(tx, leadID, facts.TotalCoursePaid, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ClearLeadInstallments removes the lead's installment plan
+func ClearLeadInstallments(leadID uuid.UUID) error {
+	if _, err := db.DB.Exec(`DELETE FROM lead_installments WHERE lead_id = $1`, leadID); err != nil {
+		return fmt.Errorf("failed to clear installments: %w", err)
+	}
+	return nil
+}
+
+// GetOverdueInstallments lists installments due before today with money outstanding, oldest first.
+// Cancelled leads are left out; their money is settled through refunds. Archived leads are left out too.
+func GetOverdueInstallments(today time.Time) ([]*OverdueInstallment, error) {
+	// DATE columns scan as UTC midnight
+	todayDate := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	rows, err := db.DB.Query(`
+		SELECT i.id, i.lead_id, i.seq, i.amount, i.due_date, i.paid_amount, i.paid_at, i.created_at,
+		       l.full_name, l.phone, l.status
+		FROM lead_installments i
+		JOIN leads l ON l.id = i.lead_id
+		WHERE i.paid_amount < i.amount AND i.due_date < $1 AND l.status <> 'cancelled' AND l.archived_at IS NULL
+		ORDER BY i.due_date, l.full_name
+	`, today.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overdue installments: %w", err)
+	}
+	defer rows.Close()
+
+	var overdue []*OverdueInstallment
+	for rows.Next() {
+		o := &OverdueInstallment{}
+		if err := rows.Scan(&o.ID, &o.LeadID, &o.Seq, &o.Amount, &o.DueDate, &o.PaidAmount, &o.PaidAt, &o.CreatedAt,
+			&o.FullName, &o.Phone, &o.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan overdue installment: %w", err)
+		}
+		o.Outstanding = o.LeadInstallment.Outstanding()
+		o.DaysOverdue = int(todayDate.Sub(o.DueDate).Hours() / 24)
+		overdue = append(overdue, o)
+	}
+	return overdue, rows.Err()
+}
+
+// GetInstallmentOverdueBlock reports whether overdue installments block class start (default true)
+func GetInstallmentOverdueBlock() (bool, error) {
+	return getInstallmentOverdueBlock(db.DB)
+}
+
+func getInstallmentOverdueBlock(q sqlExecer) (bool, error) {
+	var value string
+	err := q.QueryRow(`SELECT value FROM settings WHERE key = $1`, InstallmentOverdueBlockKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get installment overdue setting: %w", err)
+	}
+	block, err := strconv.ParseBool(value)
+	if err != nil {
+		return true, nil
+	}
+	return block, nil
+}
+
+// SetInstallmentOverdueBlock turns the overdue-installment hard stop on or off
+func SetInstallmentOverdueBlock(block bool) error {
+	_, err := db.DB.Exec(`
+		INSERT INTO settings (key, value, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
+	`, InstallmentOverdueBlockKey, strconv.FormatBool(block), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to set installment overdue setting: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestSplitInstallments(t *testing.T) {
+	first := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		total       int32
+		firstAmount int32
+		count       int
+		wantAmounts []int32
+		wantDue     []string
+	}{
+		{"even split", 6000, 0, 3, []int32{2000, 2000, 2000}, []string{"2026-01-31", "2026-02-28", "2026-03-31"}},
+		{"remainder on last", 1000, 0, 3, []int32{333, 333, 334}, nil},
+		{"deposit first", 6000, 1500, 4, []int32{1500, 1500, 1500, 1500}, nil},
+		{"deposit then rest", 6000, 2000, 3, []int32{2000, 2000, 2000}, nil},
+		{"uneven deposit", 5000, 1000, 3, []int32{1000, 2000, 2000}, nil},
+	}
+	for _, tt := range tests {
+		plan := SplitInstallments(tt.total, tt.firstAmount, tt.count, first, 1)
+		if len(plan) != len(tt.wantAmounts) {
+			t.Fatalf("%s: got %d installments, want %d", tt.name, len(plan), len(tt.wantAmounts))
+		}
+		var sum int32
+		for i, inst := range plan {
+			if inst.Amount != tt.wantAmounts[i] || inst.Seq != i+1 {
+				t.Errorf("%s: installment %d = #%d %d, want #%d %d", tt.name, i, inst.Seq, inst.Amount, i+1, tt.wantAmounts[i])
+			}
+			if tt.wantDue != nil && inst.DueDate.Format("2006-01-02") != tt.wantDue[i] {
+				t.Errorf("%s: installment %d due %s, want %s", tt.name, i, inst.DueDate.Format("2006-01-02"), tt.wantDue[i])
+			}
+			sum += inst.Amount
+		}
+		if sum != tt.total {
+			t.Errorf("%s: installments add up to %d, want %d", tt.name, sum, tt.total)
+		}
+	}
+}
+
+func TestCheckInstallmentPlan(t *testing.T) {
+	due := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		plan       []*LeadInstallment
+		finalPrice int32
+		wantErr    bool
+	}{
+		{"valid", SplitInstallments(6000, 0, 3, due, 1), 6000, false},
+		{"no final price", SplitInstallments(6000, 0, 3, due, 1), 0, true},
+		{"single installment", SplitInstallments(6000, 0, 1, due, 1), 6000, true},
+		{"too many", SplitInstallments(6000, 0, 13, due, 1), 6000, true},
+		{"deposit covers everything", SplitInstallments(6000, 6000, 3, due, 1), 6000, true},
+		{"wrong total", SplitInstallments(5000, 0, 2, due, 1), 6000, true},
+		{"out of order", []*LeadInstallment{{Amount: 3000, DueDate: due}, {Amount: 3000, DueDate: due.AddDate(0, 0, -1)}}, 6000, true},
+	}
+	for _, tt := range tests {
+		err := checkInstallmentPlan(tt.plan, tt.finalPrice)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkInstallmentPlan() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAllocateInstallmentPayments(t *testing.T) {
+	now := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	earlier := sql.NullTime{Time: now.AddDate(0, -1, 0), Valid: true}
+	newPlan := func() []*LeadInstallment {
+		return []*LeadInstallment{
+			{Amount: 2000, PaidAmount: 2000, PaidAt: earlier},
+			{Amount: 2000},
+			{Amount: 2000},
+		}
+	}
+	tests := []struct {
+		name     string
+		paid     int32
+		wantPaid []int32
+		wantAt   []bool
+	}{
+		{"nothing paid", 0, []int32{0, 0, 0}, []bool{false, false, false}},
+		{"partial second", 3500, []int32{2000, 1500, 0}, []bool{true, false, false}},
+		{"all paid", 6000, []int32{2000, 2000, 2000}, []bool{true, true, true}},
+		{"refund below first", 1000, []int32{1000, 0, 0}, []bool{false, false, false}},
+	}
+	for _, tt := range tests {
+		plan := newPlan()
+		AllocateInstallmentPayments(plan, tt.paid, now)
+		for i, inst := range plan {
+			if inst.PaidAmount != tt.wantPaid[i] || inst.PaidAt.Valid != tt.wantAt[i] {
+				t.Errorf("%s: installment %d paid %d (at %v), want %d (at %v)", tt.name, i, inst.PaidAmount, inst.PaidAt.Valid, tt.wantPaid[i], tt.wantAt[i])
+			}
+		}
+		if plan[0].PaidAt.Valid && !plan[0].PaidAt.Time.Equal(earlier.Time) {
+			t.Errorf("%s: first installment paid date changed to %s", tt.name, plan[0].PaidAt.Time)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"eighty-twenty-ops/internal/db"
+
+	"github.com/google/uuid"
+)
+
+// MinArchiveRetentionDays is the shortest time a lead must stay archived before it can be purged
+const MinArchiveRetentionDays = 90
+
+// checkLeadArchive validates an archive request. Students in classes, paused or on the classes board
+// hold a class place or credits and must be paused or cancelled out of it first.
+func checkLeadArchive(status string, sentToClasses, archived bool, reason string) (string, error) {
+	if archived {
+		return "", &LeadArchiveError{Message: "This lead is already archived."}
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", &LeadArchiveError{Message: "An archive reason is required."}
+	}
+	if status == "in_classes" || status == "paused" || sentToClasses {
+		return "", &LeadArchiveError{Message: "Students in classes, paused or on the classes board cannot be archived. Cancel the lead first."}
+	}
+	return reason, nil
+}
+
+// checkArchiveRetention validates the purge's "archived for at least" days
+func checkArchiveRetention(days int) error {
+	if days < MinArchiveRetentionDays {
+		return &LeadArchiveError{Message: fmt.Sprintf("Leads must stay archived for at least %d days before they are purged.", MinArchiveRetentionDays)}
+	}
+	return nil
+}
+
+// ArchiveLead hides a lead from the lead list. Nothing is deleted: placement tests, offers, payments
+// and finance transactions stay linked, and RestoreLead brings it back unchanged. Its open follow-up
+// tasks are closed, and the task queue, overdue installments and offer expiry skip it while archived.
+// Returns *LeadArchiveError when the lead cannot be archived.
+func ArchiveLead(leadID uuid.UUID, reason, actorUserID string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	var sentToClasses sql.NullBool
+	var archivedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT status, sent_to_classes, archived_at FROM leads WHERE id = $1 FOR UPDATE
+	`, leadID).Scan(&status, &sentToClasses, &archivedAt)
+	if err != nil {
+		return fmt.Errorf("failed to get lead: %w", err)
+	}
+	reason, err = checkLeadArchive(status, sentToClasses.Bool, archivedAt.Valid, reason)
+	if err != nil {
+		return err
+	}
+
+	var archivedBy sql.NullString
+	if actorUserID != "" {
+		archivedBy = sql.NullString{String: actorUserID, Valid: true}
+	}
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE leads SET archived_at = $1, archived_by_user_id = $2, archive_reason = $3, updated_at = $1 WHERE id = $4
+	`, now, archivedBy, reason, leadID)
+	if err != nil {
+		return fmt.Errorf("failed to archive lead: %w", err)
+	}
+	// Nobody follows up an archived lead; restoring it opens a new task when one is due
+	_, err = tx.Exec(`
+		UPDATE lead_tasks SET outcome = $1, completed_at = $2
+		WHERE lead_id = $3 AND completed_at IS NULL
+	`, LeadTaskOutcomeClosed, now, leadID)
+	if err != nil {
+		return fmt.Errorf("failed to close lead tasks: %w", err)
+	}
+	return tx.Commit()
+}
+
+// RestoreLead puts an archived lead back on the lead list as it was, with a follow-up task when its
+// status calls for one
+func RestoreLead(leadID uuid.UUID) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var status string
+	err = tx.QueryRow(`
+		UPDATE leads SET archived_at = NULL, archived_by_user_id = NULL, archive_reason = NULL, updated_at = $1
+		WHERE id = $2 AND archived_at IS NOT NULL
+		RETURNING status
+	`, now, leadID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return &LeadArchiveError{Message: "This lead is not archived."}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore lead: %w", err)
+	}
+	facts, err := loadLeadFactsTx(tx, leadID)
+	if err != nil {
+		return err
+	}
+	if err := syncLeadTasksTx(tx, leadID, status, facts.TotalCoursePaid, "", now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetArchivedLeads returns archived leads, most recently archived first, with whether each has
+// finance transactions (those are never purged)
+func GetArchivedLeads() ([]*ArchivedLead, error) {
+	rows, err := db.DB.Query(`
+		SELECT l.id, l.full_name, l.phone, l.status, l.archived_at, COALESCE(u.email, ''), COALESCE(l.archive_reason, ''),
+		       EXISTS (SELECT 1 FROM transactions t WHERE t.lead_id = l.id)
+		FROM leads l
+		LEFT JOIN users u ON u.id = l.archived_by_user_id
+		WHERE l.archived_at IS NOT NULL
+		ORDER BY l.archived_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archived leads: %w", err)
+	}
+	defer rows.Close()
+
+	var leads []*ArchivedLead
+	for rows.Next() {
+		a := &ArchivedLead{}
+		if err := rows.Scan(&a.ID, &a.FullName, &a.Phone, &a.Status, &a.ArchivedAt, &a.ArchivedByEmail, &a.Reason, &a.HasFinance); err != nil {
+			return nil, fmt.Errorf("failed to scan archived lead: %w", err)
+		}
+		leads = append(leads, a)
+	}
+	return leads, rows.Err()
+}
+
+// PurgeArchivedLeads permanently deletes leads archived for at least olderThanDays, with all their
+// child rows. Leads with finance transactions are kept so the ledger never loses its link; kept
+// counts them. Returns *LeadArchiveError when olderThanDays is below MinArchiveRetentionDays.
+func PurgeArchivedLeads(olderThanDays int, now time.Time) (purged, kept int, err error) {
+	if err := checkArchiveRetention(olderThanDays); err != nil {
+		return 0, 0, err
+	}
+	cutoff := now.AddDate(0, 0, -olderThanDays)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		DELETE FROM leads l
+		WHERE l.archived_at IS NOT NULL AND l.archived_at <= $1
+		  AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.lead_id = l.id)
+	`, cutoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to purge archived leads: %w", err)
+	}
+	n, _ := res.RowsAffected()
+
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM leads WHERE archived_at IS NOT NULL AND archived_at <= $1
+	`, cutoff).Scan(&kept)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count kept leads: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return int(n), kept, nil
+}
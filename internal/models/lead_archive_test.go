@@ -0,0 +1,49 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckLeadArchive(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        string
+		sentToClasses bool
+		archived      bool
+		reason        string
+		wantReason    string
+		wantErr       bool
+	}{
+		{"duplicate lead", "lead_created", false, false, "  Duplicate of Sara  ", "Duplicate of Sara", false},
+		{"cancelled lead", "cancelled", false, false, "Test entry", "Test entry", false},
+		{"reason required", "tested", false, false, "   ", "", true},
+		{"already archived", "tested", false, true, "Spam", "", true},
+		{"in classes", "in_classes", false, false, "Left", "", true},
+		{"paused", "paused", false, false, "Left", "", true},
+		{"on the classes board", "ready_to_start", true, false, "Left", "", true},
+	}
+	for _, tt := range tests {
+		got, err := checkLeadArchive(tt.status, tt.sentToClasses, tt.archived, tt.reason)
+		var archiveErr *LeadArchiveError
+		if tt.wantErr {
+			if !errors.As(err, &archiveErr) {
+				t.Errorf("%s: want *LeadArchiveError, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.wantReason {
+			t.Errorf("%s: got (%q, %v), want %q", tt.name, got, err, tt.wantReason)
+		}
+	}
+}
+
+func TestCheckArchiveRetention(t *testing.T) {
+	if err := checkArchiveRetention(MinArchiveRetentionDays); err != nil {
+		t.Errorf("minimum retention rejected: %v", err)
+	}
+	var archiveErr *LeadArchiveError
+	if err := checkArchiveRetention(MinArchiveRetentionDays - 1); !errors.As(err, &archiveErr) {
+		t.Errorf("want *LeadArchiveError below the minimum, got %v", err)
+	}
+}
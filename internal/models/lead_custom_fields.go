@@ -0,0 +1,421 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"eighty-twenty-ops/internal/db"
+
+	"github.com/google/uuid"
+)
+
+// Custom field types
+const (
+	CustomFieldText    = "text"
+	CustomFieldNumber  = "number"
+	CustomFieldDate    = "date"
+	CustomFieldSelect  = "select"
+	CustomFieldBoolean = "boolean" // stored as yes/no
+)
+
+// CustomFieldTypes lists the field types in the order the registry offers them
+var CustomFieldTypes = []string{CustomFieldText, CustomFieldNumber, CustomFieldDate, CustomFieldSelect, CustomFieldBoolean}
+
+// CustomFieldParam is the form and list filter parameter prefix of custom fields (cf_<key>)
+const CustomFieldParam = "cf_"
+
+const maxCustomTextLength = 500
+
+// CustomFieldKey derives a field's key from its label: lower case letters, digits and underscores
+func CustomFieldKey(label string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(label)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	key := strings.TrimRight(b.String(), "_")
+	if key != "" && key[0] >= '0' && key[0] <= '9' {
+		key = "f_" + key
+	}
+	return key
+}
+
+// ParseCustomFieldOptions reads select options, one per line, dropping blanks and duplicates
+func ParseCustomFieldOptions(raw string) []string {
+	var options []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(raw, "\n") {
+		option := strings.TrimSpace(line)
+		if option == "" || seen[strings.ToLower(option)] {
+			continue
+		}
+		seen[strings.ToLower(option)] = true
+		options = append(options, option)
+	}
+	return options
+}
+
+// checkLeadCustomField validates a field definition and normalises its label and options
+func checkLeadCustomField(f *LeadCustomField) error {
+	f.Label = strings.TrimSpace(f.Label)
+	if f.Label == "" {
+		return &CustomFieldError{Message: "A field label is required."}
+	}
+	if len(f.Label) > 60 {
+		return &CustomFieldError{Message: "Field labels can be at most 60 characters."}
+	}
+	if !containsString(CustomFieldTypes, f.FieldType) {
+		return &CustomFieldError{Message: "Choose a field type."}
+	}
+	if f.FieldType == CustomFieldSelect {
+		if len(f.Options) == 0 {
+			return &CustomFieldError{Message: "Select fields need at least one option."}
+		}
+	} else {
+		f.Options = nil
+	}
+	if f.RequiredFromStage != "" && stageRank(f.RequiredFromStage) < 0 {
+		return &CustomFieldError{Message: "Unknown stage for a required field."}
+	}
+	return nil
+}
+
+// NormalizeCustomFieldValue checks a submitted value against the field's type and returns it in
+// its stored form. Blank values return "".
+func NormalizeCustomFieldValue(f *LeadCustomField, raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return "", nil
+	}
+	switch f.FieldType {
+	case CustomFieldNumber:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", &CustomFieldError{Message: fmt.Sprintf("%s must be a number.", f.Label)}
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case CustomFieldDate:
+		d, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return "", &CustomFieldError{Message: fmt.Sprintf("%s must be a date (YYYY-MM-DD).", f.Label)}
+		}
+		return d.Format("2006-01-02"), nil
+	case CustomFieldSelect:
+		for _, option := range f.Options {
+			if strings.EqualFold(option, value) {
+				return option, nil
+			}
+		}
+		return "", &CustomFieldError{Message: fmt.Sprintf("%s must be one of: %s.", f.Label, strings.Join(f.Options, ", "))}
+	case CustomFieldBoolean:
+		switch strings.ToLower(value) {
+		case "yes", "true", "1", "on":
+			return "yes", nil
+		case "no", "false", "0", "off":
+			return "no", nil
+		}
+		return "", &CustomFieldError{Message: fmt.Sprintf("%s must be yes or no.", f.Label)}
+	}
+	if len(value) > maxCustomTextLength {
+		return "", &CustomFieldError{Message: fmt.Sprintf("%s can be at most %d characters.", f.Label, maxCustomTextLength)}
+	}
+	return value, nil
+}
+
+// ParseCustomFieldValues reads the fields' cf_<key> values with get, keyed by field key.
+// Returns *CustomFieldError for the first invalid value.
+func ParseCustomFieldValues(fields []*LeadCustomField, get func(string) string) (map[string]string, error) {
+	values := make(map[string]string, len(fields))
+	for _, f := range fields {
+		v, err := NormalizeCustomFieldValue(f, get(CustomFieldParam+f.Key))
+		if err != nil {
+			return nil, err
+		}
+		values[f.Key] = v
+	}
+	return values, nil
+}
+
+// RequiredAt reports whether a lead at stage needs a value for the field
+func (f *LeadCustomField) RequiredAt(stage string) bool {
+	return f.Active && requiredAtStage(f.RequiredFromStage, stage)
+}
+
+// HasOption reports whether value is one of a select field's options
+func (f *LeadCustomField) HasOption(value string) bool {
+	for _, option := range f.Options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+// OptionsText returns a select field's options one per line, for editing
+func (f *LeadCustomField) OptionsText() string {
+	return strings.Join(f.Options, "\n")
+}
+
+// CheckRequiredCustomFields returns *CustomFieldError naming the active fields required at stage
+// that have no value
+func CheckRequiredCustomFields(fields []*LeadCustomField, values map[string]string, stage string) error {
+	var missing []string
+	for _, f := range fields {
+		if f.RequiredAt(stage) && values[f.Key] == "" {
+			missing = append(missing, f.Label)
+		}
+	}
+	if len(missing) > 0 {
+		return &CustomFieldError{Message: requiredCustomFieldsMessage(missing)}
+	}
+	return nil
+}
+
+func requiredAtStage(fromStage, stage string) bool {
+	return fromStage != "" && stageRank(fromStage) >= 0 && stageRank(fromStage) <= stageRank(stage)
+}
+
+func requiredCustomFieldsMessage(labels []string) string {
+	return fmt.Sprintf("Required fields missing: %s.", strings.Join(labels, ", "))
+}
+
+// RequiredCustomField is a required custom field as the pipeline guards see it
+type RequiredCustomField struct {
+	Label     string
+	FromStage string
+	Filled    bool
+}
+
+// MissingCustomFields returns the labels of required fields without a value that a move from
+// status from to status to would need. Only forward moves between pipeline stages are checked.
+func (f LeadFacts) MissingCustomFields(from, to string) []string {
+	fromState, okFrom := findLeadState(from)
+	toState, okTo := findLeadState(to)
+	if !okFrom || !okTo || fromState.Stage == "" || toState.Stage == "" || stageRank(toState.Stage) <= stageRank(fromState.Stage) {
+		return nil
+	}
+	var missing []string
+	for _, rf := range f.RequiredCustomFields {
+		if !rf.Filled && requiredAtStage(rf.FromStage, toState.Stage) {
+			missing = append(missing, rf.Label)
+		}
+	}
+	return missing
+}
+
+// CustomFieldFilter narrows the lead list to leads whose custom field Key has Value
+// (contained in it for text fields)
+type CustomFieldFilter struct {
+	Key      string
+	Value    string
+	Contains bool
+}
+
+// CustomFieldFilters reads the list's cf_<key> filter values with get. Values are normalised
+// like form input; an invalid value is matched as typed.
+func CustomFieldFilters(fields []*LeadCustomField, get func(string) string) []CustomFieldFilter {
+	var filters []CustomFieldFilter
+	for _, f := range fields {
+		raw := strings.TrimSpace(get(CustomFieldParam + f.Key))
+		if raw == "" {
+			continue
+		}
+		value, err := NormalizeCustomFieldValue(f, raw)
+		if err != nil {
+			value = raw
+		}
+		filters = append(filters, CustomFieldFilter{Key: f.Key, Value: value, Contains: f.FieldType == CustomFieldText})
+	}
+	return filters
+}
+
+// GetLeadCustomFields returns the custom fields in display order with how many leads have a value.
+// Retired fields are included only when includeRetired is set.
+func GetLeadCustomFields(includeRetired bool) ([]*LeadCustomField, error) {
+	rows, err := db.DB.Query(`
+		SELECT f.id, f.field_key, f.label, f.field_type, f.options, COALESCE(f.required_from_stage, ''),
+		       f.active, f.sort_order, (SELECT COUNT(*) FROM lead_custom_values v WHERE v.field_id = f.id)
+		FROM lead_custom_fields f
+		WHERE f.active OR $1
+		ORDER BY f.active DESC, f.sort_order, f.label
+	`, includeRetired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query custom fields: %w", err)
+	}
+	defer rows.Close()
+
+	var fields []*LeadCustomField
+	for rows.Next() {
+		f := &LeadCustomField{}
+		var options string
+		if err := rows.Scan(&f.ID, &f.Key, &f.Label, &f.FieldType, &options, &f.RequiredFromStage, &f.Active, &f.SortOrder, &f.LeadCount); err != nil {
+			return nil, fmt.Errorf("failed to scan custom field: %w", err)
+		}
+		f.Options = ParseCustomFieldOptions(options)
+		fields = append(fields, f)
+	}
+	return fields, rows.Err()
+}
+
+// CreateLeadCustomField adds a field to the registry; its key is derived from the label.
+// Returns *CustomFieldError when the field is invalid or the key is taken.
+func CreateLeadCustomField(f *LeadCustomField) error {
+	if err := checkLeadCustomField(f); err != nil {
+		return err
+	}
+	f.Key = CustomFieldKey(f.Label)
+	if f.Key == "" {
+		return &CustomFieldError{Message: "Field labels need at least one letter or digit."}
+	}
+
+	var clash int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM lead_custom_fields WHERE field_key = $1`, f.Key).Scan(&clash); err != nil {
+		return fmt.Errorf("failed to check custom field: %w", err)
+	}
+	if clash > 0 {
+		return &CustomFieldError{Message: fmt.Sprintf("A field like %q already exists; restore or rename it instead.", f.Label)}
+	}
+	err := db.DB.QueryRow(`
+		INSERT INTO lead_custom_fields (field_key, label, field_type, options, required_from_stage, sort_order)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), (SELECT COALESCE(MAX(sort_order), 0) + 10 FROM lead_custom_fields))
+		RETURNING id, sort_order
+	`, f.Key, f.Label, f.FieldType, strings.Join(f.Options, "\n"), f.RequiredFromStage).Scan(&f.ID, &f.SortOrder)
+	if err != nil {
+		return fmt.Errorf("failed to create custom field: %w", err)
+	}
+	f.Active = true
+	return nil
+}
+
+// UpdateLeadCustomField changes a field's label, options, required stage and order. The key and
+// type stay fixed so stored values keep their meaning.
+func UpdateLeadCustomField(f *LeadCustomField) error {
+	var fieldType string
+	if err := db.DB.QueryRow(`SELECT field_type FROM lead_custom_fields WHERE id = $1`, f.ID).Scan(&fieldType); err != nil {
+		return fmt.Errorf("failed to load custom field: %w", err)
+	}
+	f.FieldType = fieldType
+	if err := checkLeadCustomField(f); err != nil {
+		return err
+	}
+	_, err := db.DB.Exec(`
+		UPDATE lead_custom_fields SET label = $1, options = $2, required_from_stage = NULLIF($3, ''), sort_order = $4
+		WHERE id = $5
+	`, f.Label, strings.Join(f.Options, "\n"), f.RequiredFromStage, f.SortOrder, f.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update custom field: %w", err)
+	}
+	return nil
+}
+
+// SetLeadCustomFieldActive retires or restores a field. Retired fields keep their values but are
+// no longer shown on forms, filtered on, exported or required.
+func SetLeadCustomFieldActive(id uuid.UUID, active bool) error {
+	if _, err := db.DB.Exec(`UPDATE lead_custom_fields SET active = $1 WHERE id = $2`, active, id); err != nil {
+		return fmt.Errorf("failed to update custom field: %w", err)
+	}
+	return nil
+}
+
+// GetLeadCustomValues returns a lead's custom values keyed by field key
+func GetLeadCustomValues(leadID uuid.UUID) (map[string]string, error) {
+	values, err := GetLeadCustomValuesBatch([]uuid.UUID{leadID})
+	if err != nil {
+		return nil, err
+	}
+	if values[leadID] == nil {
+		return map[string]string{}, nil
+	}
+	return values[leadID], nil
+}
+
+// GetLeadCustomValuesBatch returns custom values for several leads, keyed by lead and field key
+func GetLeadCustomValuesBatch(leadIDs []uuid.UUID) (map[uuid.UUID]map[string]string, error) {
+	result := make(map[uuid.UUID]map[string]string, len(leadIDs))
+	if len(leadIDs) == 0 {
+		return result, nil
+	}
+	ids := make([]string, len(leadIDs))
+	for i, id := range leadIDs {
+		ids[i] = id.String()
+	}
+	rows, err := db.DB.Query(`
+		SELECT v.lead_id, f.field_key, v.value
+		FROM lead_custom_values v
+		JOIN lead_custom_fields f ON f.id = v.field_id
+		WHERE v.lead_id::text = ANY($1)
+	`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query custom values: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var leadID uuid.UUID
+		var key, value string
+		if err := rows.Scan(&leadID, &key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan custom value: %w", err)
+		}
+		if result[leadID] == nil {
+			result[leadID] = map[string]string{}
+		}
+		result[leadID][key] = value
+	}
+	return result, rows.Err()
+}
+
+// SaveLeadCustomValues stores a lead's values for fields; a blank value clears the field.
+// Values of fields not in fields (e.g. retired ones) are left alone.
+func SaveLeadCustomValues(leadID uuid.UUID, fields []*LeadCustomField, values map[string]string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, f := range fields {
+		if v := values[f.Key]; v != "" {
+			_, err = tx.Exec(`
+				INSERT INTO lead_custom_values (lead_id, field_id, value, updated_at) VALUES ($1, $2, $3, $4)
+				ON CONFLICT (lead_id, field_id) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
+				WHERE lead_custom_values.value <> EXCLUDED.value
+			`, leadID, f.ID, v, now)
+		} else {
+			_, err = tx.Exec(`DELETE FROM lead_custom_values WHERE lead_id = $1 AND field_id = $2`, leadID, f.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save custom value %s: %w", f.Key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// loadRequiredCustomFieldsTx reads the active required fields and whether the lead has a value for each
+func loadRequiredCustomFieldsTx(q sqlExecer, leadID uuid.UUID) ([]RequiredCustomField, error) {
+	rows, err := q.Query(`
+		SELECT f.label, f.required_from_stage,
+		       EXISTS (SELECT 1 FROM lead_custom_values v WHERE v.lead_id = $1 AND v.field_id = f.id)
+		FROM lead_custom_fields f
+		WHERE f.active AND f.required_from_stage IS NOT NULL
+		ORDER BY f.sort_order, f.label
+	`, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load required custom fields: %w", err)
+	}
+	defer rows.Close()
+	var required []RequiredCustomField
+	for rows.Next() {
+		var rf RequiredCustomField
+		if err := rows.Scan(&rf.Label, &rf.FromStage, &rf.Filled); err != nil {
+			return nil, fmt.Errorf("failed to scan required custom field: %w", err)
+		}
+		required = append(required, rf)
+	}
+	return required, rows.Err()
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCustomFieldKey(t *testing.T) {
+	tests := []struct {
+		label, want string
+	}{
+		{"School", "school"},
+		{"  Preferred Time ", "preferred_time"},
+		{"Parent's e-mail", "parent_s_e_mail"},
+		{"2nd phone", "f_2nd_phone"},
+		{"Ölçü", "l"},
+		{"!!!", ""},
+	}
+	for _, tt := range tests {
+		if got := CustomFieldKey(tt.label); got != tt.want {
+			t.Errorf("CustomFieldKey(%q) = %q, want %q", tt.label, got, tt.want)
+		}
+	}
+}
+
+func TestParseCustomFieldOptions(t *testing.T) {
+	got := ParseCustomFieldOptions("Morning\n\n  Evening \r\nmorning\nWeekend")
+	want := []string{"Morning", "Evening", "Weekend"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCustomFieldOptions = %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeCustomFieldValue(t *testing.T) {
+	text := &LeadCustomField{Label: "School", FieldType: CustomFieldText}
+	number := &LeadCustomField{Label: "Age", FieldType: CustomFieldNumber}
+	date := &LeadCustomField{Label: "Birthday", FieldType: CustomFieldDate}
+	sel := &LeadCustomField{Label: "Time", FieldType: CustomFieldSelect, Options: []string{"Morning", "Evening"}}
+	boolean := &LeadCustomField{Label: "Has laptop", FieldType: CustomFieldBoolean}
+	tests := []struct {
+		name    string
+		field   *LeadCustomField
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{"blank", number, "   ", "", false},
+		{"text trimmed", text, " Cairo American College ", "Cairo American College", false},
+		{"number", number, "17.50", "17.5", false},
+		{"not a number", number, "seventeen", "", true},
+		{"date", date, "2010-03-09", "2010-03-09", false},
+		{"bad date", date, "09/03/2010", "", true},
+		{"select any case", sel, "evening", "Evening", false},
+		{"unknown option", sel, "Night", "", true},
+		{"boolean yes", boolean, "on", "yes", false},
+		{"boolean no", boolean, "False", "no", false},
+		{"boolean other", boolean, "maybe", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeCustomFieldValue(tt.field, tt.raw)
+		if tt.wantErr {
+			var fieldErr *CustomFieldError
+			if !errors.As(err, &fieldErr) {
+				t.Errorf("%s: got (%q, %v), want CustomFieldError", tt.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got (%q, %v), want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckRequiredCustomFields(t *testing.T) {
+	fields := []*LeadCustomField{
+		{Key: "school", Label: "School", Active: true, RequiredFromStage: StageNewLead},
+		{Key: "time", Label: "Time", Active: true, RequiredFromStage: StageOfferSent},
+		{Key: "old", Label: "Old", Active: false, RequiredFromStage: StageNewLead},
+		{Key: "notes", Label: "Notes", Active: true},
+	}
+	tests := []struct {
+		name    string
+		values  map[string]string
+		stage   string
+		wantErr string
+	}{
+		{"new lead missing school", map[string]string{}, StageNewLead, "Required fields missing: School."},
+		{"new lead with school", map[string]string{"school": "CAC"}, StageNewLead, ""},
+		{"offer needs both", map[string]string{}, StageOfferSent, "Required fields missing: School, Time."},
+		{"later stage still needs time", map[string]string{"school": "CAC"}, StageReadyToStart, "Required fields missing: Time."},
+		{"all filled", map[string]string{"school": "CAC", "time": "Morning"}, StageReadyToStart, ""},
+	}
+	for _, tt := range tests {
+		err := CheckRequiredCustomFields(fields, tt.values, tt.stage)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.wantErr {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.wantErr)
+		}
+	}
+}
+
+func TestMissingCustomFields(t *testing.T) {
+	facts := LeadFacts{RequiredCustomFields: []RequiredCustomField{
+		{Label: "School", FromStage: StageTestBooked},
+		{Label: "Time", FromStage: StageOfferSent, Filled: true},
+		{Label: "Laptop", FromStage: StageReadyToStart},
+	}}
+	tests := []struct {
+		name, from, to string
+		want           []string
+	}{
+		{"book test", "lead_created", "test_booked", []string{"School"}},
+		{"send offer", "tested", "offer_sent", []string{"School"}},
+		{"ready", "paid_full", "ready_to_start", []string{"School", "Laptop"}},
+		{"same stage", "schedule_assigned", "waiting_for_round", nil},
+		{"backward", "offer_sent", "tested", nil},
+		{"out of pipeline", "paused", "ready_to_start", nil},
+	}
+	for _, tt := range tests {
+		if got := facts.MissingCustomFields(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCustomFieldFilters(t *testing.T) {
+	fields := []*LeadCustomField{
+		{Key: "school", Label: "School", FieldType: CustomFieldText},
+		{Key: "age", Label: "Age", FieldType: CustomFieldNumber},
+		{Key: "time", Label: "Time", FieldType: CustomFieldSelect, Options: []string{"Morning"}},
+		{Key: "laptop", Label: "Laptop", FieldType: CustomFieldBoolean},
+	}
+	query := map[string]string{"cf_school": " college ", "cf_age": "12.0", "cf_time": "morning", "cf_laptop": "", "cf_other": "x"}
+	got := CustomFieldFilters(fields, func(key string) string { return query[key] })
+	want := []CustomFieldFilter{
+		{Key: "school", Value: "college", Contains: true},
+		{Key: "age", Value: "12"},
+		{Key: "time", Value: "Morning"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CustomFieldFilters = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,242 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"eighty-twenty-ops/internal/util"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Import row outcomes
+const (
+	ImportRowNew       = "new"
+	ImportRowDuplicate = "duplicate"
+	ImportRowInvalid   = "invalid"
+)
+
+// LeadImportRow is one parsed CSV row with its dry-run outcome
+type LeadImportRow struct {
+	Line           int // 1-based line number in the CSV (header is line 1)
+	FullName       string
+	RawPhone       string
+	Phone          string // normalised (01XXXXXXXXX) when valid
+	Source         string
+	Notes          string
+	AssignedLevel  sql.NullInt32
+	Result         string // new | duplicate | invalid
+	Message        string
+	ExistingLeadID *uuid.UUID
+}
+
+// LeadImportReport summarises a dry-run or committed import
+type LeadImportReport struct {
+	Rows       []*LeadImportRow
+	NewCount   int
+	Duplicates int
+	Invalid    int
+	Imported   int
+}
+
+// leadImportColumns maps accepted header names to canonical column keys
+var leadImportColumns = map[string]string{
+	"name":           "name",
+	"full_name":      "name",
+	"full name":      "name",
+	"phone":          "phone",
+	"phone_number":   "phone",
+	"mobile":         "phone",
+	"source":         "source",
+	"notes":          "notes",
+	"level":          "level",
+	"assigned_level": "level",
+}
+
+// ParseLeadImportCSV reads a CSV with a header row (name, phone, source, notes, optional level)
+// and validates each row. Sources are matched against the managed list (see NormalizeLeadSource).
+// Duplicates are not checked here; see CheckLeadImportDuplicates.
+func ParseLeadImportCSV(r io.Reader, sources []string) ([]*LeadImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("CSV is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	colIndex := map[string]int{}
+	for i, h := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if canonical, ok := leadImportColumns[key]; ok {
+			if _, seen := colIndex[canonical]; !seen {
+				colIndex[canonical] = i
+			}
+		}
+	}
+	if _, ok := colIndex["name"]; !ok {
+		return nil, fmt.Errorf("CSV header must include a name column")
+	}
+	if _, ok := colIndex["phone"]; !ok {
+		return nil, fmt.Errorf("CSV header must include a phone column")
+	}
+
+	field := func(record []string, key string) string {
+		i, ok := colIndex[key]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []*LeadImportRow
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, &LeadImportRow{Line: line, Result: ImportRowInvalid, Message: parseErr.Err.Error()})
+				continue
+			}
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		row := &LeadImportRow{
+			Line:     line,
+			FullName: field(record, "name"),
+			RawPhone: field(record, "phone"),
+			Notes:    field(record, "notes"),
+			Source:   NormalizeLeadSource(field(record, "source"), sources),
+			Result:   ImportRowNew,
+		}
+		if row.FullName == "" && row.RawPhone == "" {
+			continue // blank line
+		}
+		if row.FullName == "" {
+			row.Result, row.Message = ImportRowInvalid, "Name is required"
+		} else if phone, err := util.NormalizeEgyptianPhone(row.RawPhone); err != nil {
+			row.Result, row.Message = ImportRowInvalid, "Invalid phone number"
+		} else {
+			row.Phone = phone
+		}
+		if levelStr := field(record, "level"); levelStr != "" && row.Result != ImportRowInvalid {
+			level, err := strconv.Atoi(levelStr)
+			if err != nil || level < 1 || level > 8 {
+				row.Result, row.Message = ImportRowInvalid, "Level must be a number from 1 to 8"
+			} else {
+				row.AssignedLevel = sql.NullInt32{Int32: int32(level), Valid: true}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// CheckLeadImportDuplicates marks rows whose phone already exists (in the database or earlier in the file)
+// and returns the dry-run report.
+func CheckLeadImportDuplicates(rows []*LeadImportRow) (*LeadImportReport, error) {
+	seen := map[string]int{}
+	for _, row := range rows {
+		if row.Result != ImportRowNew {
+			continue
+		}
+		if firstLine, ok := seen[row.Phone]; ok {
+			row.Result = ImportRowDuplicate
+			row.Message = fmt.Sprintf("Same phone as line %d", firstLine)
+			continue
+		}
+		seen[row.Phone] = row.Line
+
+		// Existing leads may be stored with the raw (un-normalised) phone, so check both
+		phones := []string{row.Phone}
+		if row.RawPhone != row.Phone {
+			phones = append(phones, row.RawPhone)
+		}
+		for _, phone := range phones {
+			existing, err := GetLeadByPhone(phone)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to check phone %s: %w", phone, err)
+			}
+			row.Result = ImportRowDuplicate
+			row.Message = fmt.Sprintf("Phone already exists (%s)", existing.FullName)
+			row.ExistingLeadID = &existing.ID
+			break
+		}
+	}
+	return buildLeadImportReport(rows), nil
+}
+
+// CommitLeadImport creates all rows marked new in a single transaction. Nothing is imported if any insert fails.
+func CommitLeadImport(rows []*LeadImportRow, createdByUserID string) (*LeadImportReport, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	imported := 0
+	for _, row := range rows {
+		if row.Result != ImportRowNew {
+			continue
+		}
+		lead, err := createLead(tx, row.FullName, row.Phone, row.Source, row.Notes, createdByUserID)
+		if err != nil {
+			if phoneErr := IsPhoneConstraintError(err); phoneErr != nil {
+				return nil, fmt.Errorf("line %d: phone %s already exists", row.Line, row.Phone)
+			}
+			return nil, fmt.Errorf("line %d: %w", row.Line, err)
+		}
+		if row.AssignedLevel.Valid {
+			_, err = tx.Exec(`
+				INSERT INTO placement_tests (id, lead_id, assigned_level, updated_at)
+				VALUES (gen_random_uuid(), $1, $2, $3)
+			`, lead.ID, row.AssignedLevel, now)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: failed to set level: %w", row.Line, err)
+			}
+		}
+		imported++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+
+	report := buildLeadImportReport(rows)
+	report.Imported = imported
+	return report, nil
+}
+
+func buildLeadImportReport(rows []*LeadImportRow) *LeadImportReport {
+	report := &LeadImportReport{Rows: rows}
+	for _, row := range rows {
+		switch row.Result {
+		case ImportRowNew:
+			report.NewCount++
+		case ImportRowDuplicate:
+			report.Duplicates++
+		case ImportRowInvalid:
+			report.Invalid++
+		}
+	}
+	return report
+}
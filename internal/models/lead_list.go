@@ -0,0 +1,407 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"eighty-twenty-ops/internal/db"
+)
+
+// Lead list sort keys (?sort=<key>_asc or <key>_desc)
+const (
+	LeadSortCreated  = "created"
+	LeadSortStatus   = "status"   // pipeline order
+	LeadSortProgress = "progress" // days since last progress
+	LeadSortBalance  = "balance"  // offer final price minus amount paid
+)
+
+// DefaultLeadPageSize is the lead list's page size
+const DefaultLeadPageSize = 50
+
+// LeadListQuery is the lead list's filters, sort and page. PageSize 0 returns every matching lead.
+type LeadListQuery struct {
+	Status           string
+	Search           string
+	Payment          string
+	Hot              string
+	FollowUp         string
+	IncludeCancelled bool
+	Custom           []CustomFieldFilter
+	Sort             string // one of the LeadSort keys; empty sorts newest first (hot leads by urgency)
+	Desc             bool
+	Page             int // 1-based
+	PageSize         int
+}
+
+// LeadListPage is one page of the lead list
+type LeadListPage struct {
+	Leads    []*LeadListItem
+	Total    int
+	Page     int
+	PageSize int
+}
+
+// TotalPages returns the number of pages (at least 1)
+func (p *LeadListPage) TotalPages() int {
+	if p.PageSize <= 0 || p.Total <= p.PageSize {
+		return 1
+	}
+	return (p.Total + p.PageSize - 1) / p.PageSize
+}
+
+// HasPrev reports whether there is a page before this one
+func (p *LeadListPage) HasPrev() bool { return p.Page > 1 }
+
+// HasNext reports whether there is a page after this one
+func (p *LeadListPage) HasNext() bool { return p.Page < p.TotalPages() }
+
+// PrevPage and NextPage return the neighbouring page numbers
+func (p *LeadListPage) PrevPage() int { return p.Page - 1 }
+func (p *LeadListPage) NextPage() int { return p.Page + 1 }
+
+// FirstRow and LastRow return the 1-based range shown on this page (0, 0 when empty)
+func (p *LeadListPage) FirstRow() int {
+	if p.Total == 0 {
+		return 0
+	}
+	return (p.Page-1)*p.PageSize + 1
+}
+
+func (p *LeadListPage) LastRow() int {
+	if p.PageSize <= 0 {
+		return p.Total
+	}
+	last := p.Page * p.PageSize
+	if last > p.Total {
+		last = p.Total
+	}
+	return last
+}
+
+// LeadTabCounts are the lead counts behind the list's quick filters, under the current search and
+// filters other than status and hot
+type LeadTabCounts struct {
+	Hot     int
+	ByStage map[string]int // keyed by stage constant (NEW_LEAD, TESTED, ...)
+}
+
+// ParseLeadSort reads a ?sort= value such as "progress_desc". Unknown keys return "" (default order).
+func ParseLeadSort(v string) (key string, desc bool) {
+	key, dir, _ := strings.Cut(v, "_")
+	switch key {
+	case LeadSortCreated, LeadSortStatus, LeadSortProgress, LeadSortBalance:
+		return key, dir != "asc"
+	}
+	return "", true
+}
+
+// likePattern turns a search term into a contains-pattern for LIKE/ILIKE, escaping wildcards
+func likePattern(term string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(strings.TrimSpace(term)) + "%"
+}
+
+// hotLeadStatuses are the statuses in the TESTED and OFFER_SENT stages; unpaid leads in them are hot
+func hotLeadStatuses() []string {
+	var statuses []string
+	for _, s := range leadStates {
+		if s.Stage == StageTested || s.Stage == StageOfferSent {
+			statuses = append(statuses, s.Status)
+		}
+	}
+	return statuses
+}
+
+// pipelineStatuses lists statuses in pipeline order, for sorting by status
+func pipelineStatuses() []string {
+	statuses := make([]string, 0, len(leadStates))
+	for _, s := range leadStates {
+		statuses = append(statuses, s.Status)
+	}
+	return statuses
+}
+
+// leadListOrderBy returns the ORDER BY for the list. Hot leads default to most urgent first
+// (HOT, WARM, COOL, then longest without progress). created_at and id break ties so pages are stable.
+func leadListOrderBy(key string, desc, hot bool) string {
+	dir := "DESC"
+	if !desc {
+		dir = "ASC"
+	}
+	var order string
+	switch key {
+	case LeadSortCreated:
+		order = "d.created_at " + dir
+	case LeadSortStatus:
+		order = "d.status_rank " + dir
+	case LeadSortProgress:
+		// More days since progress means an older progress time
+		if desc {
+			order = "d.progress_at ASC"
+		} else {
+			order = "d.progress_at DESC"
+		}
+	case LeadSortBalance:
+		order = "d.balance " + dir
+	default:
+		if hot {
+			order = "CASE WHEN d.days_since_progress <= 6 THEN 3 WHEN d.days_since_progress <= 13 THEN 2 ELSE 1 END DESC, d.progress_at ASC"
+		}
+	}
+	if order == "" {
+		return "ORDER BY d.created_at DESC, d.id"
+	}
+	return "ORDER BY " + order + ", d.created_at DESC, d.id"
+}
+
+// leadListFilter collects a query's WHERE clauses and arguments
+type leadListFilter struct {
+	inner []string // on the joined lead row (aliases l, pt, p, o)
+	outer []string // on the derived columns (alias d)
+	args  []interface{}
+}
+
+func (f *leadListFilter) arg(v interface{}) string {
+	f.args = append(f.args, v)
+	return fmt.Sprintf("$%d", len(f.args))
+}
+
+// buildLeadListFilter turns the list filters into SQL. Status and hot are left out when
+// withTabs is false, for the per-tab counts.
+func buildLeadListFilter(q LeadListQuery, withTabs bool) *leadListFilter {
+	f := &leadListFilter{}
+	f.arg(leadContactOutcomes) // $1, used by the last-contact subquery
+	f.inner = append(f.inner, "l.archived_at IS NULL")
+
+	// Books follow students into classes, so the shipping filter also covers students already in classes;
+	// renewal offers only exist for students whose round has closed
+	switch q.Payment {
+	case PaymentFilterBookNotShipped:
+		f.inner = append(f.inner, PaidBookNotShippedCond)
+	case PaymentFilterRenewalDue:
+		f.inner = append(f.inner, RenewalDueCond)
+	default:
+		f.inner = append(f.inner, "l.status != 'in_classes' AND (l.sent_to_classes IS NULL OR l.sent_to_classes = false)")
+		if q.Payment != "" {
+			f.outer = append(f.outer, "d.payment_state = "+f.arg(q.Payment))
+		}
+	}
+
+	if q.FollowUp == "high_priority" {
+		f.inner = append(f.inner, "l.high_priority_follow_up = true")
+	}
+
+	// Exclude cancelled by default. Include if IncludeCancelled OR explicitly filtering by status=cancelled.
+	statusFilter := ""
+	if withTabs {
+		statusFilter = q.Status
+	}
+	if !q.IncludeCancelled && statusFilter != "cancelled" {
+		f.inner = append(f.inner, "l.status != 'cancelled'")
+	}
+
+	if statusFilter != "" {
+		// Stage constants map to the stored status; anything else is used as-is (backward compat)
+		dbStatus := statusFilter
+		if mapped := StageToStatus(statusFilter); mapped != "" {
+			dbStatus = mapped
+		}
+		f.inner = append(f.inner, "l.status = "+f.arg(dbStatus))
+		// Booking confirmed stages also need the matching payment state
+		if statusFilter == StageBookingConfirmedPaidFull {
+			f.outer = append(f.outer, "d.payment_state = '"+PaymentStatePaidFull+"'")
+		} else if statusFilter == StageBookingConfirmedDeposit {
+			f.outer = append(f.outer, "d.payment_state = '"+PaymentStateDeposit+"'")
+		}
+	}
+
+	// Name, phone or notes; trigram indexes back the ILIKEs
+	if search := strings.TrimSpace(q.Search); search != "" {
+		p := f.arg(likePattern(search))
+		f.inner = append(f.inner, fmt.Sprintf("(l.full_name ILIKE %s OR l.phone LIKE %s OR l.notes ILIKE %s)", p, p, p))
+	}
+
+	// Custom fields: exact match, or contains for text fields
+	for _, cf := range q.Custom {
+		cond := "v.value = " + f.arg(cf.Value)
+		if cf.Contains {
+			cond = "v.value ILIKE " + f.arg(likePattern(cf.Value))
+		}
+		f.inner = append(f.inner, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM lead_custom_values v JOIN lead_custom_fields cf ON cf.id = v.field_id
+			WHERE v.lead_id = l.id AND cf.field_key = %s AND %s)`, f.arg(cf.Key), cond))
+	}
+
+	if withTabs && (q.Hot == "hot" || q.Hot == "1") {
+		f.outer = append(f.outer, "d.is_hot")
+	}
+	return f
+}
+
+// leadListSQL wraps the filtered leads in the derived columns the list filters and sorts on:
+// payment state, hot, last progress, days since progress, balance and pipeline rank
+func leadListSQL(f *leadListFilter) string {
+	hot := f.arg(hotLeadStatuses())
+	rank := f.arg(pipelineStatuses())
+	outer := "true"
+	if len(f.outer) > 0 {
+		outer = strings.Join(f.outer, " AND ")
+	}
+	return `
+		WITH b AS (
+			SELECT
+				l.id, l.full_name, l.phone, l.source, l.notes, l.status, l.sent_to_classes,
+				l.created_by_user_id, l.created_at, l.updated_at,
+				pt.assigned_level, pt.test_date,
+				p.remaining_balance, p.amount_paid,
+				o.final_price,
+				(SELECT MAX(h.changed_at) FROM lead_status_history h WHERE h.lead_id = l.id) AS status_changed_at,
+				(SELECT MAX(t.completed_at) FROM lead_tasks t WHERE t.lead_id = l.id AND t.outcome = ANY($1)) AS last_contact_at
+			FROM leads l
+			LEFT JOIN placement_tests pt ON l.id = pt.lead_id
+			LEFT JOIN payments p ON l.id = p.lead_id
+			LEFT JOIN offers o ON l.id = o.lead_id
+			WHERE ` + strings.Join(f.inner, " AND ") + `
+		), c AS (
+			SELECT b.*,
+				CASE
+					WHEN COALESCE(b.amount_paid, 0) = 0 THEN '` + PaymentStateUnpaid + `'
+					WHEN b.final_price > 0 AND b.amount_paid >= b.final_price THEN '` + PaymentStatePaidFull + `'
+					ELSE '` + PaymentStateDeposit + `'
+				END AS payment_state,
+				GREATEST(b.created_at, b.last_contact_at, b.status_changed_at, b.test_date::timestamp with time zone) AS progress_at,
+				COALESCE(b.final_price, 0) - COALESCE(b.amount_paid, 0) AS balance,
+				COALESCE(array_position(` + rank + `::text[], b.status), 0) AS status_rank
+			FROM b
+		), d AS (
+			SELECT c.*,
+				c.status = ANY(` + hot + `::text[]) AND c.payment_state = '` + PaymentStateUnpaid + `' AS is_hot,
+				FLOOR(EXTRACT(EPOCH FROM (NOW() - c.progress_at)) / 86400)::integer AS days_since_progress
+			FROM c
+		)
+		SELECT * FROM d WHERE ` + outer
+}
+
+// QueryLeads returns one page of the lead list. Filtering, sorting and the total all happen in SQL;
+// only the page's rows are loaded.
+func QueryLeads(q LeadListQuery) (*LeadListPage, error) {
+	page := &LeadListPage{Page: q.Page, PageSize: q.PageSize}
+	if page.Page < 1 {
+		page.Page = 1
+	}
+
+	f := buildLeadListFilter(q, true)
+	listSQL := leadListSQL(f)
+	query := `
+		SELECT d.id, d.full_name, d.phone, d.source, d.notes, d.status, d.sent_to_classes,
+		       d.created_by_user_id, d.created_at, d.updated_at,
+		       d.assigned_level, d.test_date, d.remaining_balance, d.amount_paid, d.final_price,
+		       d.status_changed_at, d.last_contact_at,
+		       COUNT(*) OVER ()
+		FROM (` + listSQL + `) d
+		` + leadListOrderBy(q.Sort, q.Desc, q.Hot == "hot" || q.Hot == "1")
+	if page.PageSize > 0 {
+		query += fmt.Sprintf(" LIMIT %s OFFSET %s", f.arg(page.PageSize), f.arg((page.Page-1)*page.PageSize))
+	}
+
+	rows, err := db.DB.Query(query, f.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leads: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		lead := &Lead{}
+		item := &LeadListItem{Lead: lead}
+		err := rows.Scan(
+			&lead.ID, &lead.FullName, &lead.Phone, &lead.Source, &lead.Notes, &lead.Status, &lead.SentToClasses,
+			&lead.CreatedByUserID, &lead.CreatedAt, &lead.UpdatedAt,
+			&item.AssignedLevel, &item.TestDate, &item.RemainingBalance, &item.AmountPaid, &item.FinalPrice,
+			&item.StatusChangedAt, &item.LastContactAt,
+			&page.Total,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lead: %w", err)
+		}
+		item.PaymentStatus = GetPaymentStatus(item.RemainingBalance, item.AmountPaid)
+		item.PaymentState = GetPaymentState(item.AmountPaid, item.FinalPrice)
+		item.NextAction = GetNextAction(lead.Status)
+		ComputeLeadFlags(item)
+		page.Leads = append(page.Leads, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// A page past the end (e.g. after filtering) still reports the total
+	if len(page.Leads) == 0 && page.Page > 1 {
+		if page.Total, err = CountLeads(q); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// CountLeads returns how many leads match q's filters
+func CountLeads(q LeadListQuery) (int, error) {
+	f := buildLeadListFilter(q, true)
+	countSQL := `SELECT COUNT(*) FROM (` + leadListSQL(f) + `) d`
+	var n int
+	if err := db.DB.QueryRow(countSQL, f.args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count leads: %w", err)
+	}
+	return n, nil
+}
+
+// CountLeadTabs counts leads per quick-filter tab (and hot leads) in one query, under q's search and
+// filters other than status and hot
+func CountLeadTabs(q LeadListQuery) (*LeadTabCounts, error) {
+	f := buildLeadListFilter(q, false)
+	listSQL := leadListSQL(f)
+	rows, err := db.DB.Query(`
+		SELECT d.status, d.payment_state, COUNT(*), COUNT(*) FILTER (WHERE d.is_hot)
+		FROM (`+listSQL+`) d
+		GROUP BY d.status, d.payment_state
+	`, f.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count leads: %w", err)
+	}
+	defer rows.Close()
+
+	counts := &LeadTabCounts{ByStage: make(map[string]int)}
+	for rows.Next() {
+		var status, paymentState string
+		var n, hot int
+		if err := rows.Scan(&status, &paymentState, &n, &hot); err != nil {
+			return nil, fmt.Errorf("failed to scan lead count: %w", err)
+		}
+		counts.Hot += hot
+		addLeadTabCount(counts.ByStage, status, paymentState, n)
+	}
+	return counts, rows.Err()
+}
+
+// addLeadTabCount adds n leads of status/paymentState to the stage tabs whose filter would list them.
+// A stage tab filters on its stored status (StageToStatus); the booking confirmed stages also need
+// the matching payment state.
+func addLeadTabCount(byStage map[string]int, status, paymentState string, n int) {
+	for _, stage := range stageOrder {
+		if StageToStatus(stage) != status {
+			continue
+		}
+		switch stage {
+		case StageBookingConfirmedPaidFull:
+			if paymentState != PaymentStatePaidFull {
+				continue
+			}
+		case StageBookingConfirmedDeposit:
+			if paymentState != PaymentStateDeposit {
+				continue
+			}
+		}
+		byStage[stage] += n
+	}
+	if status == "cancelled" {
+		byStage["cancelled"] += n
+	}
+}
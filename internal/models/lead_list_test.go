@@ -0,0 +1,126 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLeadSort(t *testing.T) {
+	tests := []struct {
+		in       string
+		wantKey  string
+		wantDesc bool
+	}{
+		{"", "", true},
+		{"created_asc", LeadSortCreated, false},
+		{"created_desc", LeadSortCreated, true},
+		{"status_asc", LeadSortStatus, false},
+		{"progress_desc", LeadSortProgress, true},
+		{"balance", LeadSortBalance, true},
+		{"name_asc", "", true},
+		{"balance_asc; DROP TABLE leads", LeadSortBalance, true},
+	}
+	for _, tt := range tests {
+		key, desc := ParseLeadSort(tt.in)
+		if key != tt.wantKey || desc != tt.wantDesc {
+			t.Errorf("ParseLeadSort(%q) = (%q, %v), want (%q, %v)", tt.in, key, desc, tt.wantKey, tt.wantDesc)
+		}
+	}
+}
+
+func TestLikePattern(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"sara", "%sara%"},
+		{"  010 ", "%010%"},
+		{"50%", `%50\%%`},
+		{"a_b", `%a\_b%`},
+		{`c:\x`, `%c:\\x%`},
+	}
+	for _, tt := range tests {
+		if got := likePattern(tt.in); got != tt.want {
+			t.Errorf("likePattern(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLeadListOrderBy(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		desc, hot bool
+		wantStart string
+	}{
+		{"default", "", true, false, "ORDER BY d.created_at DESC, d.id"},
+		{"hot default", "", true, true, "ORDER BY CASE WHEN d.days_since_progress"},
+		{"oldest first", LeadSortCreated, false, false, "ORDER BY d.created_at ASC"},
+		{"status", LeadSortStatus, false, false, "ORDER BY d.status_rank ASC"},
+		{"longest without progress", LeadSortProgress, true, false, "ORDER BY d.progress_at ASC"},
+		{"explicit sort wins on hot", LeadSortBalance, true, true, "ORDER BY d.balance DESC"},
+	}
+	for _, tt := range tests {
+		got := leadListOrderBy(tt.key, tt.desc, tt.hot)
+		if !strings.HasPrefix(got, tt.wantStart) {
+			t.Errorf("%s: got %q, want prefix %q", tt.name, got, tt.wantStart)
+		}
+		if !strings.HasSuffix(got, "d.id") {
+			t.Errorf("%s: %q has no stable tie-break", tt.name, got)
+		}
+	}
+}
+
+func TestLeadListPage(t *testing.T) {
+	tests := []struct {
+		name                           string
+		total, page, pageSize          int
+		wantPages, wantFirst, wantLast int
+		wantPrev, wantNext             bool
+	}{
+		{"empty", 0, 1, 50, 1, 0, 0, false, false},
+		{"single page", 12, 1, 50, 1, 1, 12, false, false},
+		{"first of three", 120, 1, 50, 3, 1, 50, false, true},
+		{"middle", 120, 2, 50, 3, 51, 100, true, true},
+		{"last partial", 120, 3, 50, 3, 101, 120, true, false},
+		{"unpaged", 120, 1, 0, 1, 1, 120, false, false},
+	}
+	for _, tt := range tests {
+		p := &LeadListPage{Total: tt.total, Page: tt.page, PageSize: tt.pageSize}
+		if got := p.TotalPages(); got != tt.wantPages {
+			t.Errorf("%s: TotalPages = %d, want %d", tt.name, got, tt.wantPages)
+		}
+		if p.FirstRow() != tt.wantFirst || p.LastRow() != tt.wantLast {
+			t.Errorf("%s: rows %d–%d, want %d–%d", tt.name, p.FirstRow(), p.LastRow(), tt.wantFirst, tt.wantLast)
+		}
+		if p.HasPrev() != tt.wantPrev || p.HasNext() != tt.wantNext {
+			t.Errorf("%s: prev/next = %v/%v, want %v/%v", tt.name, p.HasPrev(), p.HasNext(), tt.wantPrev, tt.wantNext)
+		}
+	}
+}
+
+func TestAddLeadTabCount(t *testing.T) {
+	byStage := map[string]int{}
+	addLeadTabCount(byStage, "lead_created", "", 3)
+	addLeadTabCount(byStage, "offer_sent", "", 2)
+	addLeadTabCount(byStage, "booking_confirmed", "", 5) // legacy, not a tab's stored status
+	addLeadTabCount(byStage, "deposit_paid", PaymentStateDeposit, 4)
+	addLeadTabCount(byStage, "deposit_paid", PaymentStatePaidFull, 1)
+	addLeadTabCount(byStage, "paid_full", PaymentStatePaidFull, 6)
+	addLeadTabCount(byStage, "cancelled", "", 7)
+
+	want := map[string]int{
+		StageNewLead:                  3,
+		StageOfferSent:                2,
+		StageBookingConfirmedDeposit:  4,
+		StageBookingConfirmedPaidFull: 6,
+		"cancelled":                   7,
+	}
+	for stage, n := range want {
+		if byStage[stage] != n {
+			t.Errorf("%s = %d, want %d", stage, byStage[stage], n)
+		}
+	}
+	if len(byStage) != len(want) {
+		t.Errorf("unexpected tabs counted: %v", byStage)
+	}
+}
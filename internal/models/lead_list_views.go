@@ -0,0 +1,306 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"eighty-twenty-ops/internal/db"
+
+	"github.com/google/uuid"
+)
+
+// LeadListColumn is an optional column of the pre-enrolment list. Name and the action buttons are
+// always shown.
+type LeadListColumn struct {
+	Key   string
+	Label string
+}
+
+// LeadListColumns are the optional list columns in display order
+var LeadListColumns = []LeadListColumn{
+	{"phone", "Phone"},
+	{"status", "Status"},
+	{"level", "Assigned Level"},
+	{"payment", "Payment"},
+	{"next_action", "Next Action"},
+	{"source", "Source"},
+	{"created", "Created"},
+	{"progress", "Days Since Progress"},
+}
+
+// DefaultLeadListColumns are shown when no view (or a view without columns) is selected
+var DefaultLeadListColumns = []string{"phone", "status", "level", "payment", "next_action"}
+
+// leadListViewRoles are the roles that can have a default view
+var leadListViewRoles = []string{"admin", "moderator"}
+
+// ParseLeadListColumns keeps the known column keys in display order, dropping unknown keys and
+// duplicates. No known keys means the default columns.
+func ParseLeadListColumns(keys []string) []string {
+	want := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		want[strings.TrimSpace(k)] = true
+	}
+	var columns []string
+	for _, c := range LeadListColumns {
+		if want[c.Key] {
+			columns = append(columns, c.Key)
+		}
+	}
+	if len(columns) == 0 {
+		return append([]string(nil), DefaultLeadListColumns...)
+	}
+	return columns
+}
+
+// LeadListColumnSet returns columns as a lookup for templates
+func LeadListColumnSet(columns []string) map[string]bool {
+	set := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		set[c] = true
+	}
+	return set
+}
+
+// Shared reports whether the view is visible to everyone
+func (v *LeadListView) Shared() bool {
+	return !v.OwnerUserID.Valid
+}
+
+// ListQuery returns the view's filters and sort as a list query (first page, unpaged)
+func (v *LeadListView) ListQuery() LeadListQuery {
+	key, desc := ParseLeadSort(v.Sort)
+	return LeadListQuery{
+		Status:           v.Status,
+		Search:           v.Search,
+		Payment:          v.Payment,
+		Hot:              v.Hot,
+		FollowUp:         v.FollowUp,
+		IncludeCancelled: v.IncludeCancelled || v.Status == "cancelled",
+		Sort:             key,
+		Desc:             desc,
+	}
+}
+
+// URLQuery returns the list page query string that opens the view
+func (v *LeadListView) URLQuery() string {
+	q := url.Values{"view": {v.ID.String()}}
+	for key, value := range map[string]string{
+		"status": v.Status, "search": v.Search, "payment": v.Payment,
+		"hot": v.Hot, "follow_up": v.FollowUp, "sort": v.Sort,
+	} {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	if v.IncludeCancelled {
+		q.Set("include_cancelled", "1")
+	}
+	return q.Encode()
+}
+
+// checkLeadListView validates a view before it is saved and normalises it: the name is trimmed,
+// the sort and columns are reduced to known values, and only shared views keep a default role
+func checkLeadListView(v *LeadListView) error {
+	v.Name = strings.TrimSpace(v.Name)
+	if v.Name == "" {
+		return &LeadListViewError{Message: "A view name is required."}
+	}
+	if len(v.Name) > 60 {
+		return &LeadListViewError{Message: "View names can be at most 60 characters."}
+	}
+	v.Search = strings.TrimSpace(v.Search)
+	if key, desc := ParseLeadSort(v.Sort); key == "" {
+		v.Sort = ""
+	} else if desc {
+		v.Sort = key + "_desc"
+	} else {
+		v.Sort = key + "_asc"
+	}
+	v.Columns = ParseLeadListColumns(v.Columns)
+
+	if v.DefaultForRole.Valid {
+		if !v.Shared() {
+			return &LeadListViewError{Message: "Only shared views can be a role's default."}
+		}
+		known := false
+		for _, role := range leadListViewRoles {
+			known = known || v.DefaultForRole.String == role
+		}
+		if !known {
+			return &LeadListViewError{Message: "Default views can only be set for admins and moderators."}
+		}
+	}
+	return nil
+}
+
+const leadListViewColumns = `
+	id, name, owner_user_id, status, search, payment, hot, follow_up, include_cancelled, sort,
+	columns, default_for_role, created_at`
+
+func scanLeadListView(row interface{ Scan(...interface{}) error }) (*LeadListView, error) {
+	v := &LeadListView{}
+	var columns string
+	err := row.Scan(&v.ID, &v.Name, &v.OwnerUserID, &v.Status, &v.Search, &v.Payment, &v.Hot, &v.FollowUp,
+		&v.IncludeCancelled, &v.Sort, &columns, &v.DefaultForRole, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	v.Columns = ParseLeadListColumns(strings.Split(columns, ","))
+	return v, nil
+}
+
+// GetLeadListViews returns the shared views and the user's own views, shared first, by name
+func GetLeadListViews(userID string) ([]*LeadListView, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+leadListViewColumns+`
+		FROM lead_list_views
+		WHERE owner_user_id IS NULL OR owner_user_id::text = $1
+		ORDER BY owner_user_id IS NOT NULL, LOWER(name)
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query list views: %w", err)
+	}
+	defer rows.Close()
+
+	var views []*LeadListView
+	for rows.Next() {
+		v, err := scanLeadListView(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan list view: %w", err)
+		}
+		views = append(views, v)
+	}
+	return views, rows.Err()
+}
+
+// GetDefaultLeadListView returns the role's default view, or nil when it has none
+func GetDefaultLeadListView(role string) (*LeadListView, error) {
+	v, err := scanLeadListView(db.DB.QueryRow(`
+		SELECT `+leadListViewColumns+` FROM lead_list_views WHERE default_for_role = $1
+	`, role))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get default list view: %w", err)
+	}
+	return v, nil
+}
+
+// CountLeadListViews fills in each view's lead count under its filters
+func CountLeadListViews(views []*LeadListView) error {
+	for _, v := range views {
+		n, err := CountLeads(v.ListQuery())
+		if err != nil {
+			return err
+		}
+		v.Count = n
+	}
+	return nil
+}
+
+// CreateLeadListView saves a view. A shared view that is a role's default replaces that role's
+// previous default. Returns *LeadListViewError when the view is invalid or its name is already
+// used by a shared view or another of the owner's views.
+func CreateLeadListView(v *LeadListView, actorUserID string) error {
+	if err := checkLeadListView(v); err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var taken bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM lead_list_views
+			WHERE LOWER(name) = LOWER($1)
+			  AND (owner_user_id IS NULL OR owner_user_id = $2::uuid)
+		)
+	`, v.Name, v.OwnerUserID).Scan(&taken)
+	if err != nil {
+		return fmt.Errorf("failed to check view name: %w", err)
+	}
+	if taken {
+		return &LeadListViewError{Message: fmt.Sprintf("A view called %q already exists.", v.Name)}
+	}
+
+	if v.DefaultForRole.Valid {
+		_, err = tx.Exec(`UPDATE lead_list_views SET default_for_role = NULL WHERE default_for_role = $1`, v.DefaultForRole.String)
+		if err != nil {
+			return fmt.Errorf("failed to clear previous default view: %w", err)
+		}
+	}
+
+	var createdBy sql.NullString
+	if actorUserID != "" {
+		createdBy = sql.NullString{String: actorUserID, Valid: true}
+	}
+	err = tx.QueryRow(`
+		INSERT INTO lead_list_views
+			(name, owner_user_id, status, search, payment, hot, follow_up, include_cancelled, sort,
+			 columns, default_for_role, created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at
+	`, v.Name, v.OwnerUserID, v.Status, v.Search, v.Payment, v.Hot, v.FollowUp, v.IncludeCancelled, v.Sort,
+		strings.Join(v.Columns, ","), v.DefaultForRole, createdBy).Scan(&v.ID, &v.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save list view: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteLeadListView deletes a view. Users delete their own views; shared views need an admin.
+// Returns *LeadListViewError when the view is missing or not the user's to delete.
+func DeleteLeadListView(id uuid.UUID, userID string, isAdmin bool) error {
+	res, err := db.DB.Exec(`
+		DELETE FROM lead_list_views
+		WHERE id = $1 AND (owner_user_id::text = $2 OR (owner_user_id IS NULL AND $3))
+	`, id, userID, isAdmin)
+	if err != nil {
+		return fmt.Errorf("failed to delete list view: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &LeadListViewError{Message: "This view does not exist or is not yours to delete."}
+	}
+	return nil
+}
+
+// SetLeadListViewDefault makes a shared view the default for role, replacing that role's previous
+// default. An empty role clears the view's default. Returns *LeadListViewError for a personal or
+// missing view or an unknown role.
+func SetLeadListViewDefault(id uuid.UUID, role string) error {
+	check := &LeadListView{Name: "default", DefaultForRole: sql.NullString{String: role, Valid: role != ""}}
+	if err := checkLeadListView(check); err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if role != "" {
+		_, err = tx.Exec(`UPDATE lead_list_views SET default_for_role = NULL WHERE default_for_role = $1 AND id <> $2`, role, id)
+		if err != nil {
+			return fmt.Errorf("failed to clear previous default view: %w", err)
+		}
+	}
+	res, err := tx.Exec(`
+		UPDATE lead_list_views SET default_for_role = $1 WHERE id = $2 AND owner_user_id IS NULL
+	`, check.DefaultForRole, id)
+	if err != nil {
+		return fmt.Errorf("failed to set default view: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &LeadListViewError{Message: "Only shared views can be a role's default."}
+	}
+	return tx.Commit()
+}
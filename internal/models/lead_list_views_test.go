@@ -0,0 +1,104 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseLeadListColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{"display order", []string{"source", "phone"}, []string{"phone", "source"}},
+		{"unknown and duplicate keys dropped", []string{"phone", "salary", " phone "}, []string{"phone"}},
+		{"none means default", nil, DefaultLeadListColumns},
+		{"stored empty string", []string{""}, DefaultLeadListColumns},
+	}
+	for _, tt := range tests {
+		if got := ParseLeadListColumns(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckLeadListView(t *testing.T) {
+	owner := sql.NullString{String: "u1", Valid: true}
+	role := func(r string) sql.NullString { return sql.NullString{String: r, Valid: true} }
+	tests := []struct {
+		name     string
+		view     LeadListView
+		wantSort string
+		wantErr  bool
+	}{
+		{"personal view", LeadListView{Name: "  Unpaid offers ", OwnerUserID: owner, Sort: "balance_desc"}, "balance_desc", false},
+		{"sort defaults to desc", LeadListView{Name: "Progress", OwnerUserID: owner, Sort: "progress"}, "progress_desc", false},
+		{"unknown sort dropped", LeadListView{Name: "Names", OwnerUserID: owner, Sort: "name_asc"}, "", false},
+		{"shared role default", LeadListView{Name: "Morning", DefaultForRole: role("moderator")}, "", false},
+		{"name required", LeadListView{Name: "   ", OwnerUserID: owner}, "", true},
+		{"name too long", LeadListView{Name: string(make([]byte, 61)), OwnerUserID: owner}, "", true},
+		{"personal view cannot be a default", LeadListView{Name: "Mine", OwnerUserID: owner, DefaultForRole: role("admin")}, "", true},
+		{"unknown role", LeadListView{Name: "Mentors", DefaultForRole: role("mentor")}, "", true},
+	}
+	for _, tt := range tests {
+		v := tt.view
+		err := checkLeadListView(&v)
+		var viewErr *LeadListViewError
+		if tt.wantErr {
+			if !errors.As(err, &viewErr) {
+				t.Errorf("%s: want *LeadListViewError, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if v.Sort != tt.wantSort {
+			t.Errorf("%s: sort %q, want %q", tt.name, v.Sort, tt.wantSort)
+		}
+		if v.Name != strings.TrimSpace(tt.view.Name) {
+			t.Errorf("%s: name %q not trimmed", tt.name, v.Name)
+		}
+		if len(v.Columns) == 0 {
+			t.Errorf("%s: columns not defaulted", tt.name)
+		}
+	}
+}
+
+func TestLeadListViewQuery(t *testing.T) {
+	v := &LeadListView{
+		ID:       uuid.MustParse("6f1c2d3e-4a5b-4c6d-8e7f-901234567890"),
+		Status:   "cancelled",
+		Search:   "sara",
+		Hot:      "",
+		FollowUp: "high_priority",
+		Sort:     "created_asc",
+	}
+	q, err := url.ParseQuery(v.URLQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{
+		"view":      {v.ID.String()},
+		"status":    {"cancelled"},
+		"search":    {"sara"},
+		"follow_up": {"high_priority"},
+		"sort":      {"created_asc"},
+	}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("URLQuery = %v, want %v", q, want)
+	}
+
+	lq := v.ListQuery()
+	if lq.Sort != LeadSortCreated || lq.Desc || !lq.IncludeCancelled || lq.PageSize != 0 {
+		t.Errorf("ListQuery = %+v", lq)
+	}
+}
@@ -0,0 +1,464 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sides an admin can pick when merging two leads
+const (
+	MergeKeepSurvivor = "survivor"
+	MergeKeepMerged   = "merged"
+	MergeKeepBoth     = "both" // notes only: survivor notes followed by merged notes
+)
+
+// LeadMergeChoices says which lead each field or one-per-lead section is taken from.
+// Empty values mean MergeKeepSurvivor. The status is not chosen: see mergedLeadStatus.
+type LeadMergeChoices struct {
+	FullName      string `json:"full_name"`
+	Phone         string `json:"phone"`
+	Source        string `json:"source"`
+	Notes         string `json:"notes"`
+	PlacementTest string `json:"placement_test"`
+	Offer         string `json:"offer"`
+	Booking       string `json:"booking"`
+	Payment       string `json:"payment"`
+	Scheduling    string `json:"scheduling"`
+	Shipping      string `json:"shipping"`
+}
+
+// leadMergeSections are the one-row-per-lead tables, keyed by the choice they follow
+func (c LeadMergeChoices) sections() []struct{ table, choice string } {
+	return []struct{ table, choice string }{
+		{"placement_tests", c.PlacementTest},
+		{"offers", c.Offer},
+		{"bookings", c.Booking},
+		{"payments", c.Payment},
+		{"scheduling", c.Scheduling},
+		{"shipping", c.Shipping},
+	}
+}
+
+// leadMergeChildTables are the many-rows-per-lead tables re-parented onto the survivor.
+// conflictCols lists the columns that, together with lead_id, are unique; the merged lead's
+// conflicting rows are dropped in favour of the survivor's.
+var leadMergeChildTables = []struct {
+	table        string
+	conflictCols []string
+}{
+	{"lead_payments", nil},
+	{"attendance", []string{"session_id"}},
+	{"grades", []string{"class_key", "session_number"}},
+	{"student_notes", nil},
+	{"community_officer_feedback", []string{"class_key", "session_number"}},
+	{"absence_follow_up_logs", nil},
+	{"followups", []string{"class_key", "session_number"}},
+	{"lead_status_history", nil},
+	{"online_placement_tests", nil},
+	{"lead_tasks", nil},
+	{"offer_letters", nil},
+	{"lead_installments", []string{"seq"}},
+	{"material_stock_movements", nil},
+}
+
+// MergeLeads folds mergedID into survivorID in a single transaction: child rows are re-parented
+// (finance transactions get their ref_key/ref_id rewritten), one-per-lead sections and lead fields
+// follow choices, credits are summed, the merged lead is deleted, and the merge is recorded in lead_merges.
+func MergeLeads(survivorID, mergedID uuid.UUID, choices LeadMergeChoices, actorUserID string) (*LeadMerge, error) {
+	if survivorID == mergedID {
+		return nil, fmt.Errorf("cannot merge a lead into itself")
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock both leads (in a stable order to avoid deadlocks with a concurrent reverse merge)
+	type mergeLead struct {
+		FullName      string         `json:"full_name"`
+		Phone         string         `json:"phone"`
+		Source        sql.NullString `json:"-"`
+		Notes         sql.NullString `json:"-"`
+		Status        string         `json:"status"`
+		SourceText    string         `json:"source,omitempty"`
+		NotesText     string         `json:"notes,omitempty"`
+		CreatedAt     time.Time      `json:"created_at"`
+		LevelsBought  int32          `json:"levels_purchased_total"`
+		LevelsUsed    int32          `json:"levels_consumed"`
+		BundleType    string         `json:"bundle_type,omitempty"`
+		HighPriority  bool           `json:"high_priority_follow_up"`
+		SentToClasses bool           `json:"sent_to_classes"`
+	}
+	leads := map[uuid.UUID]*mergeLead{}
+	rows, err := tx.Query(`
+		SELECT id, full_name, phone, source, notes, status, created_at,
+		       COALESCE(levels_purchased_total, 0), COALESCE(levels_consumed, 0), COALESCE(bundle_type, ''),
+		       COALESCE(high_priority_follow_up, false), COALESCE(sent_to_classes, false)
+		FROM leads
+		WHERE id IN ($1, $2)
+		ORDER BY id
+		FOR UPDATE
+	`, survivorID, mergedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock leads: %w", err)
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		l := &mergeLead{}
+		if err := rows.Scan(&id, &l.FullName, &l.Phone, &l.Source, &l.Notes, &l.Status, &l.CreatedAt,
+			&l.LevelsBought, &l.LevelsUsed, &l.BundleType, &l.HighPriority, &l.SentToClasses); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan lead: %w", err)
+		}
+		l.SourceText, l.NotesText = l.Source.String, l.Notes.String
+		leads[id] = l
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read leads: %w", err)
+	}
+	survivor, merged := leads[survivorID], leads[mergedID]
+	if survivor == nil || merged == nil {
+		return nil, fmt.Errorf("lead not found")
+	}
+
+	now := time.Now()
+	moved := map[string]int64{}
+
+	// 1. One-per-lead sections: taking the merged side replaces the survivor's row
+	for _, s := range choices.sections() {
+		if s.choice != MergeKeepMerged {
+			continue
+		}
+		var mergedHasRow bool
+		if err := tx.QueryRow(fmt.Sprintf(`SELECT EXISTS(SELECT 1 FROM %s WHERE lead_id = $1)`, s.table), mergedID).Scan(&mergedHasRow); err != nil {
+			return nil, fmt.Errorf("failed to check %s: %w", s.table, err)
+		}
+		if !mergedHasRow {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE lead_id = $1`, s.table), survivorID); err != nil {
+			return nil, fmt.Errorf("failed to replace %s: %w", s.table, err)
+		}
+		res, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET lead_id = $1, updated_at = $3 WHERE lead_id = $2`, s.table), survivorID, mergedID, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move %s: %w", s.table, err)
+		}
+		moved[s.table], _ = res.RowsAffected()
+	}
+
+	// 2. Many-per-lead tables
+	for _, c := range leadMergeChildTables {
+		if len(c.conflictCols) > 0 {
+			match := ""
+			for _, col := range c.conflictCols {
+				match += fmt.Sprintf(" AND s.%s = m.%s", col, col)
+			}
+			_, err := tx.Exec(fmt.Sprintf(`
+				DELETE FROM %s m
+				WHERE m.lead_id = $2
+				AND EXISTS (SELECT 1 FROM %s s WHERE s.lead_id = $1%s)
+			`, c.table, c.table, match), survivorID, mergedID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to drop duplicate %s: %w", c.table, err)
+			}
+		}
+		res, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET lead_id = $1 WHERE lead_id = $2`, c.table), survivorID, mergedID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move %s: %w", c.table, err)
+		}
+		moved[c.table], _ = res.RowsAffected()
+	}
+
+	// Referrals follow the merged lead: its referees and rewards move to the survivor, and the survivor
+	// takes its referrer when it has none. A referral between the two leads is dropped, and so is the
+	// merged lead's reward when the survivor already earned one for being referred.
+	_, err = tx.Exec(`
+		DELETE FROM referral_rewards
+		WHERE (referred_lead_id = $2 AND (referrer_lead_id = $1 OR EXISTS (SELECT 1 FROM referral_rewards WHERE referred_lead_id = $1)))
+		OR (referrer_lead_id = $2 AND referred_lead_id = $1)
+	`, survivorID, mergedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to drop duplicate referral_rewards: %w", err)
+	}
+	rewardsMoved, err := tx.Exec(`
+		UPDATE referral_rewards
+		SET referrer_lead_id = CASE WHEN referrer_lead_id = $2 THEN $1 ELSE referrer_lead_id END,
+			referred_lead_id = CASE WHEN referred_lead_id = $2 THEN $1 ELSE referred_lead_id END
+		WHERE referrer_lead_id = $2 OR referred_lead_id = $2
+	`, survivorID, mergedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to move referral_rewards: %w", err)
+	}
+	moved["referral_rewards"], _ = rewardsMoved.RowsAffected()
+	if _, err := tx.Exec(`UPDATE leads SET referred_by_lead_id = $1 WHERE referred_by_lead_id = $2 AND id <> $1`, survivorID, mergedID); err != nil {
+		return nil, fmt.Errorf("failed to move referred leads: %w", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE leads s SET referred_by_lead_id = m.referred_by_lead_id
+		FROM leads m
+		WHERE s.id = $1 AND m.id = $2 AND s.referred_by_lead_id IS NULL AND m.referred_by_lead_id <> $1
+	`, survivorID, mergedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to move referrer: %w", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE leads s SET payer_id = m.payer_id
+		FROM leads m
+		WHERE s.id = $1 AND m.id = $2 AND s.payer_id IS NULL AND m.payer_id IS NOT NULL
+	`, survivorID, mergedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to move payer: %w", err)
+	}
+
+	// 3. Class enrolments: only one may be active, the survivor's wins
+	active, err := activeEnrolmentExistsTx(tx, survivorID)
+	if err != nil {
+		return nil, err
+	}
+	if active {
+		if err := leaveActiveEnrolmentTx(tx, mergedID, EnrolmentLeftRemoved, now); err != nil {
+			return nil, err
+		}
+	}
+	res, err := tx.Exec(`UPDATE class_enrolments SET lead_id = $1, updated_at = $3 WHERE lead_id = $2`, survivorID, mergedID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to move class_enrolments: %w", err)
+	}
+	moved["class_enrolments"], _ = res.RowsAffected()
+
+	// Pauses follow the same rule: only one may be open, the survivor's wins
+	_, err = tx.Exec(`
+		UPDATE lead_pauses SET ended_at = $3, outcome = $4
+		WHERE lead_id = $2 AND ended_at IS NULL
+		AND EXISTS (SELECT 1 FROM lead_pauses WHERE lead_id = $1 AND ended_at IS NULL)
+	`, survivorID, mergedID, now, LeadPauseMerged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close merged pause: %w", err)
+	}
+	res, err = tx.Exec(`UPDATE lead_pauses SET lead_id = $1 WHERE lead_id = $2`, survivorID, mergedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to move lead_pauses: %w", err)
+	}
+	moved["lead_pauses"], _ = res.RowsAffected()
+
+	// Renewal offers too: only one may be open, the survivor's wins
+	_, err = tx.Exec(`
+		UPDATE renewal_offers SET status = $3, closed_at = $4
+		WHERE lead_id = $2 AND status = 'open'
+		AND EXISTS (SELECT 1 FROM renewal_offers WHERE lead_id = $1 AND status = 'open')
+	`, survivorID, mergedID, RenewalOfferMerged, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close merged renewal offer: %w", err)
+	}
+	res, err = tx.Exec(`UPDATE renewal_offers SET lead_id = $1 WHERE lead_id = $2`, survivorID, mergedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to move renewal_offers: %w", err)
+	}
+	moved["renewal_offers"], _ = res.RowsAffected()
+
+	// Custom field values: the survivor keeps its own, the merged lead fills the gaps
+	_, err = tx.Exec(`
+		DELETE FROM lead_custom_values v
+		WHERE v.lead_id = $2 AND EXISTS (SELECT 1 FROM lead_custom_values s WHERE s.lead_id = $1 AND s.field_id = v.field_id)
+	`, survivorID, mergedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to drop duplicate custom values: %w", err)
+	}
+	res, err = tx.Exec(`UPDATE lead_custom_values SET lead_id = $1 WHERE lead_id = $2`, survivorID, mergedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to move lead_custom_values: %w", err)
+	}
+	moved["lead_custom_values"], _ = res.RowsAffected()
+
+	// 4. Finance transactions: ref_key/ref_id embed the lead id, rewrite them so later
+	// idempotent upserts keyed on the survivor still line up. A key that would collide
+	// with one the survivor already has is suffixed instead.
+	res, err = tx.Exec(`
+		UPDATE transactions t
+		SET lead_id = $1,
+			ref_id = CASE WHEN t.ref_id = $2::text THEN $1::text ELSE t.ref_id END,
+			ref_key = CASE
+				WHEN t.ref_key IS NULL THEN NULL
+				WHEN EXISTS (SELECT 1 FROM transactions x WHERE x.ref_key = replace(t.ref_key, $2::text, $1::text))
+					THEN replace(t.ref_key, $2::text, $1::text) || ':merged:' || $2::text
+				ELSE replace(t.ref_key, $2::text, $1::text)
+			END,
+			updated_at = $3
+		WHERE t.lead_id = $2
+	`, survivorID, mergedID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to move transactions: %w", err)
+	}
+	moved["transactions"], _ = res.RowsAffected()
+
+	// 5. Delete the merged lead (frees its phone for the survivor if chosen)
+	if _, err := tx.Exec(`DELETE FROM leads WHERE id = $1`, mergedID); err != nil {
+		return nil, fmt.Errorf("failed to delete merged lead: %w", err)
+	}
+
+	// 6. Apply field choices and combine credits
+	pick := func(choice, survivorVal, mergedVal string) string {
+		if choice == MergeKeepMerged {
+			return mergedVal
+		}
+		return survivorVal
+	}
+	notes := pick(choices.Notes, survivor.NotesText, merged.NotesText)
+	if choices.Notes == MergeKeepBoth && merged.NotesText != "" {
+		if notes != "" {
+			notes += "\n\n"
+		}
+		notes += merged.NotesText
+	}
+	source := pick(choices.Source, survivor.SourceText, merged.SourceText)
+	facts, err := loadLeadFactsTx(tx, survivorID)
+	if err != nil {
+		return nil, err
+	}
+	status := mergedLeadStatus(survivor.Status, facts)
+	bundleType := survivor.BundleType
+	if bundleType == "" || bundleType == "none" {
+		bundleType = merged.BundleType
+	}
+
+	_, err = tx.Exec(`
+		UPDATE leads
+		SET full_name = $1, phone = $2, source = NULLIF($3, ''), notes = NULLIF($4, ''),
+			levels_purchased_total = $5, levels_consumed = $6, bundle_type = NULLIF($7, ''),
+			high_priority_follow_up = $8, sent_to_classes = $9, updated_at = $10
+		WHERE id = $11
+	`, pick(choices.FullName, survivor.FullName, merged.FullName), pick(choices.Phone, survivor.Phone, merged.Phone),
+		source, notes,
+		survivor.LevelsBought+merged.LevelsBought, survivor.LevelsUsed+merged.LevelsUsed, bundleType,
+		survivor.HighPriority || merged.HighPriority, survivor.SentToClasses || merged.SentToClasses, now, survivorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update surviving lead: %w", err)
+	}
+	if status != survivor.Status {
+		if _, err := tx.Exec(`UPDATE leads SET status = $1 WHERE id = $2`, status, survivorID); err != nil {
+			return nil, fmt.Errorf("failed to update lead status: %w", err)
+		}
+		if err := syncLeadTasksTx(tx, survivorID, status, facts.TotalCoursePaid, actorUserID, now); err != nil {
+			return nil, err
+		}
+		reason := fmt.Sprintf("Merged with duplicate lead %s (%s)", merged.FullName, merged.Phone)
+		if err := recordLeadStatusChange(tx, survivorID, survivor.Status, status, StatusSourceAuto, reason, actorUserID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	// 7. Audit
+	snapshotJSON, _ := json.Marshal(merged)
+	choicesJSON, _ := json.Marshal(choices)
+	movedJSON, _ := json.Marshal(moved)
+	var mergedBy sql.NullString
+	if actorUserID != "" {
+		mergedBy = sql.NullString{String: actorUserID, Valid: true}
+	}
+	m := &LeadMerge{
+		SurvivorLeadID: survivorID,
+		MergedLeadID:   mergedID,
+		MergedName:     merged.FullName,
+		MergedPhone:    merged.Phone,
+		MovedRows:      moved,
+		MergedAt:       now,
+	}
+	err = tx.QueryRow(`
+		INSERT INTO lead_merges (survivor_lead_id, merged_lead_id, merged_lead_snapshot, field_choices, moved_rows, merged_by_user_id, merged_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, survivorID, mergedID, string(snapshotJSON), string(choicesJSON), string(movedJSON), mergedBy, now).Scan(&m.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record merge: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit merge: %w", err)
+	}
+	return m, nil
+}
+
+// mergedLeadStatus is the status a merge survivor ends in: its own status, moved forward to the
+// furthest stage the merged sections support (as form completion would) and back to offer_sent
+// when a taken-over offer is no longer fully paid. Statuses outside the sales pipeline are kept.
+func mergedLeadStatus(survivorStatus string, facts LeadFacts) string {
+	if s, ok := findLeadState(survivorStatus); ok && s.Stage == "" {
+		return survivorStatus
+	}
+	if survivorStatus == "paid_full" && !facts.FullyPaid() {
+		return "offer_sent"
+	}
+
+	current := MapOldStatusToStage(survivorStatus)
+	stage := current
+	raise := func(target string) {
+		if stageRank(stage) < stageRank(target) {
+			stage = target
+		}
+	}
+	if facts.HasTestSlot {
+		raise(StageTestBooked)
+	}
+	if facts.HasAssignedLevel {
+		raise(StageTested)
+	}
+	if facts.OfferFinalPrice > 0 {
+		raise(StageOfferSent)
+		if facts.FullyPaid() {
+			raise(StageBookingConfirmedPaidFull)
+		} else if facts.TotalCoursePaid > 0 {
+			raise(StageBookingConfirmedDeposit)
+		}
+	}
+	if stage == current {
+		return survivorStatus
+	}
+	return StageToStatus(stage)
+}
+
+// GetLeadMerges returns the merges into a lead, newest first
+func GetLeadMerges(survivorID uuid.UUID) ([]*LeadMerge, error) {
+	rows, err := db.DB.Query(`
+		SELECT m.id, m.survivor_lead_id, m.merged_lead_id,
+			COALESCE(m.merged_lead_snapshot->>'full_name', ''), COALESCE(m.merged_lead_snapshot->>'phone', ''),
+			m.moved_rows::text, COALESCE(u.email, ''), m.merged_at
+		FROM lead_merges m
+		LEFT JOIN users u ON u.id = m.merged_by_user_id
+		WHERE m.survivor_lead_id = $1
+		ORDER BY m.merged_at DESC
+	`, survivorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lead merges: %w", err)
+	}
+	defer rows.Close()
+
+	var merges []*LeadMerge
+	for rows.Next() {
+		m := &LeadMerge{}
+		var movedJSON string
+		if err := rows.Scan(&m.ID, &m.SurvivorLeadID, &m.MergedLeadID, &m.MergedName, &m.MergedPhone, &movedJSON, &m.MergedByEmail, &m.MergedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan lead merge: %w", err)
+		}
+		_ = json.Unmarshal([]byte(movedJSON), &m.MovedRows)
+		merges = append(merges, m)
+	}
+	return merges, rows.Err()
+}
+
+// activeEnrolmentExistsTx reports whether the lead is currently enrolled in a class
+func activeEnrolmentExistsTx(tx *sql.Tx, leadID uuid.UUID) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM class_enrolments WHERE lead_id = $1 AND left_at IS NULL)`, leadID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check active enrolment: %w", err)
+	}
+	return exists, nil
+}
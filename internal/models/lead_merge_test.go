@@ -0,0 +1,34 @@
+package models
+
+import "testing"
+
+func TestMergedLeadStatus(t *testing.T) {
+	offer := LeadFacts{HasTestSlot: true, HasAssignedLevel: true, OfferFinalPrice: 6000}
+	deposit := offer
+	deposit.TotalCoursePaid = 2000
+	paid := offer
+	paid.TotalCoursePaid = 6000
+
+	tests := []struct {
+		name     string
+		survivor string
+		facts    LeadFacts
+		want     string
+	}{
+		{"nothing new keeps status", "lead_created", LeadFacts{}, "lead_created"},
+		{"merged test booking", "lead_created", LeadFacts{HasTestSlot: true}, "test_booked"},
+		{"merged offer", "test_booked", offer, "offer_sent"},
+		{"merged deposit", "tested", deposit, "deposit_paid"},
+		{"merged full payment", "offer_sent", paid, "paid_full"},
+		{"never moves back", "ready_to_start", offer, "ready_to_start"},
+		{"same stage keeps legacy status", "booking_confirmed", offer, "booking_confirmed"},
+		{"taken-over offer no longer paid", "paid_full", deposit, "offer_sent"},
+		{"outside the pipeline", "in_classes", LeadFacts{}, "in_classes"},
+		{"cancelled stays cancelled", "cancelled", paid, "cancelled"},
+	}
+	for _, tt := range tests {
+		if got := mergedLeadStatus(tt.survivor, tt.facts); got != tt.want {
+			t.Errorf("%s: mergedLeadStatus(%s) = %s, want %s", tt.name, tt.survivor, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How a pause ended (lead_pauses.outcome)
+const (
+	LeadPauseResumed   = "resumed"
+	LeadPauseCancelled = "cancelled"
+	LeadPauseMerged    = "merged"
+)
+
+// ReturnDue reports whether an open pause has reached its expected return date
+func (p *LeadPause) ReturnDue(today time.Time) bool {
+	return !p.EndedAt.Valid && p.ExpectedReturnDate.Valid && !p.ExpectedReturnDate.Time.After(today)
+}
+
+// checkLeadPause validates a pause request. expectedReturn may be zero (unknown) but not before today.
+func checkLeadPause(reason string, expectedReturn, today time.Time) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", &LeadPauseError{Message: "A pause reason is required."}
+	}
+	if !expectedReturn.IsZero() && expectedReturn.Before(today) {
+		return "", &LeadPauseError{Message: "Expected return date cannot be in the past."}
+	}
+	return reason, nil
+}
+
+// PauseLead pauses a ready or in-class student: the pipeline closes their active class enrolment
+// and takes them off the classes board, and the pause is recorded with the remaining level credits
+// (levels_purchased_total - levels_consumed) frozen until they resume.
+// Returns *LeadPauseError for an incomplete request and *LeadTransitionError when the lead cannot be paused.
+func PauseLead(leadID uuid.UUID, reason string, expectedReturn time.Time, role, actorUserID string) error {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	reason, err := checkLeadPause(reason, expectedReturn, today)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Snapshot before the transition closes the enrolment
+	from, err := getLeadStatusForUpdate(tx, leadID)
+	if err != nil {
+		return err
+	}
+	var credits int32
+	err = tx.QueryRow(`
+		SELECT GREATEST(COALESCE(levels_purchased_total, 0) - COALESCE(levels_consumed, 0), 0)
+		FROM leads WHERE id = $1
+	`, leadID).Scan(&credits)
+	if err != nil {
+		return fmt.Errorf("failed to read level credits: %w", err)
+	}
+	var classKey sql.NullString
+	err = tx.QueryRow(`SELECT class_key FROM class_enrolments WHERE lead_id = $1 AND left_at IS NULL`, leadID).Scan(&classKey)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get active enrolment: %w", err)
+	}
+
+	if err := applyLeadEventTx(tx, leadID, EventPause, role, actorUserID, "Paused: "+reason, now); err != nil {
+		return err
+	}
+
+	var expected sql.NullTime
+	if !expectedReturn.IsZero() {
+		expected = sql.NullTime{Time: expectedReturn, Valid: true}
+	}
+	var actor sql.NullString
+	if actorUserID != "" {
+		actor = sql.NullString{String: actorUserID, Valid: true}
+	}
+	_, err = tx.Exec(`
+		INSERT INTO lead_pauses (lead_id, previous_status, class_key, reason, expected_return_date, frozen_credits, paused_at, paused_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, leadID, from, classKey, reason, expected, credits, now, actor)
+	if err != nil {
+		return fmt.Errorf("failed to record pause: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ResumeLead ends a student's pause: the lead returns to ready_to_start and is put back on the
+// classes board eligible list for its assigned level, to be grouped into the next round.
+// Returns *LeadTransitionError when the lead is not paused or lacks a level or class schedule.
+func ResumeLead(leadID uuid.UUID, role, actorUserID string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if err := applyLeadEventTx(tx, leadID, EventResume, role, actorUserID, "", now); err != nil {
+		return err
+	}
+	var actor sql.NullString
+	if actorUserID != "" {
+		actor = sql.NullString{String: actorUserID, Valid: true}
+	}
+	_, err = tx.Exec(`
+		UPDATE lead_pauses SET ended_at = $1, ended_by_user_id = $2, outcome = $3
+		WHERE lead_id = $4 AND ended_at IS NULL
+	`, now, actor, LeadPauseResumed, leadID)
+	if err != nil {
+		return fmt.Errorf("failed to end pause: %w", err)
+	}
+	return tx.Commit()
+}
+
+const leadPauseColumns = `
+	p.id, p.lead_id, l.full_name, l.phone, pt.assigned_level, p.previous_status, p.class_key,
+	p.reason, p.expected_return_date, p.frozen_credits, p.paused_at, COALESCE(pb.email, ''),
+	p.ended_at, COALESCE(eb.email, ''), p.outcome
+	FROM lead_pauses p
+	JOIN leads l ON l.id = p.lead_id
+	LEFT JOIN placement_tests pt ON pt.lead_id = p.lead_id
+	LEFT JOIN users pb ON pb.id = p.paused_by_user_id
+	LEFT JOIN users eb ON eb.id = p.ended_by_user_id`
+
+func scanLeadPauses(rows *sql.Rows) ([]*LeadPause, error) {
+	defer rows.Close()
+	var pauses []*LeadPause
+	for rows.Next() {
+		p := &LeadPause{}
+		err := rows.Scan(&p.ID, &p.LeadID, &p.LeadName, &p.LeadPhone, &p.AssignedLevel, &p.PreviousStatus, &p.ClassKey,
+			&p.Reason, &p.ExpectedReturnDate, &p.FrozenCredits, &p.PausedAt, &p.PausedByEmail,
+			&p.EndedAt, &p.EndedByEmail, &p.Outcome)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lead pause: %w", err)
+		}
+		pauses = append(pauses, p)
+	}
+	return pauses, rows.Err()
+}
+
+// GetPausedLeads returns the open pauses of currently paused students, soonest expected return first
+// (unknown return dates last)
+func GetPausedLeads() ([]*LeadPause, error) {
+	rows, err := db.DB.Query(`SELECT ` + leadPauseColumns + `
+		WHERE p.ended_at IS NULL AND l.status = 'paused'
+		ORDER BY p.expected_return_date NULLS LAST, p.paused_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query paused leads: %w", err)
+	}
+	return scanLeadPauses(rows)
+}
+
+// GetLeadPauses returns a lead's pauses, newest first
+func GetLeadPauses(leadID uuid.UUID) ([]*LeadPause, error) {
+	rows, err := db.DB.Query(`SELECT `+leadPauseColumns+`
+		WHERE p.lead_id = $1
+		ORDER BY p.paused_at DESC
+	`, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lead pauses: %w", err)
+	}
+	return scanLeadPauses(rows)
+}
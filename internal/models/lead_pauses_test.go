@@ -0,0 +1,57 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckLeadPause(t *testing.T) {
+	today := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		reason     string
+		returnDate time.Time
+		wantErr    bool
+	}{
+		{"reason and date", " Travelling ", today.AddDate(0, 1, 0), false},
+		{"no return date", "Exams", time.Time{}, false},
+		{"returning today", "Exams", today, false},
+		{"missing reason", "  ", today.AddDate(0, 1, 0), true},
+		{"return date in the past", "Exams", today.AddDate(0, 0, -1), true},
+	}
+	for _, tt := range tests {
+		reason, err := checkLeadPause(tt.reason, tt.returnDate, today)
+		var pauseErr *LeadPauseError
+		if tt.wantErr {
+			if !errors.As(err, &pauseErr) {
+				t.Errorf("%s: got %v, want *LeadPauseError", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if reason == "" || reason[0] == ' ' {
+			t.Errorf("%s: reason %q not trimmed", tt.name, reason)
+		}
+	}
+}
+
+func TestLeadPauseReturnDue(t *testing.T) {
+	today := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	p := &LeadPause{ExpectedReturnDate: sql.NullTime{Time: today, Valid: true}}
+	if !p.ReturnDue(today) {
+		t.Errorf("pause expected back today should be due")
+	}
+	p.ExpectedReturnDate.Time = today.AddDate(0, 0, 1)
+	if p.ReturnDue(today) {
+		t.Errorf("pause expected back tomorrow should not be due")
+	}
+	p.ExpectedReturnDate.Time = today
+	p.EndedAt = sql.NullTime{Time: today, Valid: true}
+	if p.ReturnDue(today) {
+		t.Errorf("ended pause should not be due")
+	}
+}
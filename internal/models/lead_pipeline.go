@@ -0,0 +1,650 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The pre-enrolment pipeline is defined once here: every leads.status value, its workflow stage
+// and next action, and the events that move a lead between statuses (who may trigger them,
+// the guards that must hold and the side effects applied in the same transaction).
+// Handlers go through ApplyLeadEvent instead of writing leads.status directly.
+
+// LeadEvent names a pipeline transition
+type LeadEvent string
+
+const (
+	EventBookTest        LeadEvent = "book_test"
+	EventMarkTested      LeadEvent = "mark_tested"
+	EventSendOffer       LeadEvent = "send_offer"
+	EventMoveWaiting     LeadEvent = "move_waiting"
+	EventMarkReady       LeadEvent = "mark_ready"
+	EventPaymentComplete LeadEvent = "payment_complete"
+	EventPaymentReversed LeadEvent = "payment_reversed"
+	EventStartClasses    LeadEvent = "start_classes"
+	EventCancel          LeadEvent = "cancel"
+	EventReopen          LeadEvent = "reopen"
+	EventPause           LeadEvent = "pause"
+	EventResume          LeadEvent = "resume"
+	EventExpireOffer     LeadEvent = "expire_offer"
+	EventNextRound       LeadEvent = "next_round"
+)
+
+// RoleSystem is the role passed for automatic transitions (payments, round start and close)
+const RoleSystem = "system"
+
+// leadState is one leads.status value. Stage is empty for statuses outside the sales pipeline,
+// which form completion never moves.
+type leadState struct {
+	Status     string
+	Stage      string
+	NextAction string
+}
+
+// leadStates lists statuses in pipeline order. The first status of each stage is the one stored
+// when a lead reaches that stage through form completion.
+var leadStates = []leadState{
+	{"lead_created", StageNewLead, "Book placement test"},
+	{"test_booked", StageTestBooked, "Run placement test"},
+	{"tested", StageTested, "Send offer"},
+	{"offer_sent", StageOfferSent, "Wait for booking"},
+	{"booking_confirmed", StageOfferSent, "Wait for booking"}, // legacy
+	{"deposit_paid", StageBookingConfirmedDeposit, "Collect remaining"},
+	{"paid_full", StageBookingConfirmedPaidFull, "Assign schedule"},
+	{"schedule_assigned", StageScheduleSet, "Mark ready to start"},
+	{"waiting_for_round", StageScheduleSet, "Mark ready to start"},
+	{"ready_to_start", StageReadyToStart, "Ready for activation"},
+	{"in_classes", "", "In classes"},
+	{"paused", "", "Resume when the student returns"},
+	{"cancelled", "", "Reopen to continue"},
+}
+
+// stageOrder ranks stages; form completion only ever moves a lead to a later stage
+var stageOrder = []string{
+	StageNewLead,
+	StageTestBooked,
+	StageTested,
+	StageOfferSent,
+	StageBookingConfirmedDeposit,
+	StageBookingConfirmedPaidFull,
+	StageScheduleSet,
+	StageReadyToStart,
+}
+
+// LeadFacts is the lead data transition guards look at
+type LeadFacts struct {
+	HasTestSlot      bool // booked into a placement test slot
+	HasAssignedLevel bool
+	OfferFinalPrice  int32
+	TotalCoursePaid  int32
+	HasClassSchedule bool // class days and time set
+	// ManualDiscountPercent is the offer's discount beyond its promo code, as a percent of the base price
+	ManualDiscountPercent     float64
+	DiscountApproved          bool
+	DiscountApprovalThreshold int // percent; see DiscountApprovalThresholdKey
+	HasInstallmentPlan        bool
+	OverdueInstallments       int
+	OverdueBlocksClasses      bool // see InstallmentOverdueBlockKey
+	RequiredCustomFields      []RequiredCustomField
+}
+
+// FullyPaid reports whether course payments cover the offer's final price
+func (f LeadFacts) FullyPaid() bool {
+	return f.OfferFinalPrice > 0 && f.TotalCoursePaid >= f.OfferFinalPrice
+}
+
+// DiscountNeedsApproval reports whether the offer's manual discount is above the threshold and not yet approved
+func (f LeadFacts) DiscountNeedsApproval() bool {
+	return f.ManualDiscountPercent > float64(f.DiscountApprovalThreshold) && !f.DiscountApproved
+}
+
+// PaidOrOnPlan reports whether the course is fully paid or the student pays it by installment plan
+func (f LeadFacts) PaidOrOnPlan() bool {
+	return f.FullyPaid() || f.HasInstallmentPlan
+}
+
+// InstallmentOverdueBlocked reports whether an overdue installment stops the student starting classes
+func (f LeadFacts) InstallmentOverdueBlocked() bool {
+	return f.OverdueBlocksClasses && f.OverdueInstallments > 0
+}
+
+type leadGuard struct {
+	check   func(LeadFacts) bool
+	message string
+}
+
+var (
+	guardTestBooked    = leadGuard{func(f LeadFacts) bool { return f.HasTestSlot }, "Book a placement test slot first."}
+	guardAssignedLevel = leadGuard{func(f LeadFacts) bool { return f.HasAssignedLevel }, "Assigned level must be set first."}
+	guardOfferRequired = leadGuard{func(f LeadFacts) bool { return f.OfferFinalPrice > 0 }, "Final price is required when sending an offer."}
+	guardFullyPaid     = leadGuard{func(f LeadFacts) bool { return f.FullyPaid() }, "Course must be fully paid first."}
+	guardNotFullyPaid  = leadGuard{func(f LeadFacts) bool { return !f.FullyPaid() }, "Course is still fully paid."}
+	guardClassSchedule = leadGuard{func(f LeadFacts) bool { return f.HasClassSchedule }, "Both Class Days and Class Time are required."}
+	guardDiscountOK    = leadGuard{func(f LeadFacts) bool { return !f.DiscountNeedsApproval() }, "Discount is above the approval threshold and needs a second admin's approval."}
+	guardPaidOrOnPlan  = leadGuard{func(f LeadFacts) bool { return f.PaidOrOnPlan() }, "Course must be fully paid or on an installment plan first."}
+	guardNoOverdue     = leadGuard{func(f LeadFacts) bool { return !f.InstallmentOverdueBlocked() }, "An installment is overdue; collect it before the student starts classes."}
+	guardNothingPaid   = leadGuard{func(f LeadFacts) bool { return f.TotalCoursePaid <= 0 }, "Course payments have been received."}
+)
+
+// leadEffect runs inside the transition's transaction, after the status update
+type leadEffect func(q sqlExecer, leadID uuid.UUID, actorUserID string, now time.Time) error
+
+func effectSetCancelledAt(q sqlExecer, leadID uuid.UUID, actorUserID string, now time.Time) error {
+	if _, err := q.Exec(`UPDATE leads SET cancelled_at = $1 WHERE id = $2`, now, leadID); err != nil {
+		return fmt.Errorf("failed to set cancelled_at: %w", err)
+	}
+	return nil
+}
+
+// effectLeaveClasses takes a pausing student off the classes board and out of their class;
+// sessions completed while paused do not consume their credits.
+func effectLeaveClasses(q sqlExecer, leadID uuid.UUID, actorUserID string, now time.Time) error {
+	if _, err := q.Exec(`UPDATE leads SET sent_to_classes = false WHERE id = $1`, leadID); err != nil {
+		return fmt.Errorf("failed to clear sent_to_classes: %w", err)
+	}
+	return leaveActiveEnrolmentTx(q, leadID, EnrolmentLeftPaused, now)
+}
+
+// effectReturnToClassesBoard puts a resumed or continuing student back on the classes board eligible list.
+// The group index is cleared so the student is regrouped with the current round.
+func effectReturnToClassesBoard(q sqlExecer, leadID uuid.UUID, actorUserID string, now time.Time) error {
+	if _, err := q.Exec(`UPDATE leads SET sent_to_classes = true WHERE id = $1`, leadID); err != nil {
+		return fmt.Errorf("failed to set sent_to_classes: %w", err)
+	}
+	if _, err := q.Exec(`UPDATE scheduling SET class_group_index = NULL, updated_at = $1 WHERE lead_id = $2`, now, leadID); err != nil {
+		return fmt.Errorf("failed to reset class group: %w", err)
+	}
+	return nil
+}
+
+// effectEndOpenPause ends a paused student's open pause when the lead is cancelled instead of resumed
+func effectEndOpenPause(q sqlExecer, leadID uuid.UUID, actorUserID string, now time.Time) error {
+	if _, err := q.Exec(`
+		UPDATE lead_pauses SET ended_at = $1, outcome = $2
+		WHERE lead_id = $3 AND ended_at IS NULL
+	`, now, LeadPauseCancelled, leadID); err != nil {
+		return fmt.Errorf("failed to end pause: %w", err)
+	}
+	return nil
+}
+
+func effectClearCancelledAt(q sqlExecer, leadID uuid.UUID, actorUserID string, now time.Time) error {
+	if _, err := q.Exec(`UPDATE leads SET cancelled_at = NULL WHERE id = $1`, leadID); err != nil {
+		return fmt.Errorf("failed to clear cancelled_at: %w", err)
+	}
+	return nil
+}
+
+type leadTransition struct {
+	Label   string // used in error messages: "Cannot <Label>: ..."
+	From    []string
+	To      string
+	Roles   []string
+	Guards  []leadGuard
+	Effects []leadEffect
+	Source  string // lead_status_history.source
+	Reason  string // default history reason
+}
+
+var (
+	preTestStatuses  = []string{"lead_created", "test_booked", "tested"}
+	preClassStatuses = []string{"lead_created", "test_booked", "tested", "offer_sent", "booking_confirmed", "deposit_paid", "paid_full", "schedule_assigned", "waiting_for_round", "ready_to_start"}
+	unpaidStatuses   = []string{"lead_created", "test_booked", "tested", "offer_sent", "booking_confirmed", "deposit_paid"}
+	pausableStatuses = []string{"ready_to_start", "in_classes"}
+	pauseRoles       = []string{"admin", "mentor_head", "student_success"}
+)
+
+var leadTransitions = map[LeadEvent]leadTransition{
+	EventBookTest: {
+		Label:  "book placement test",
+		From:   []string{"lead_created", "test_booked"},
+		To:     "test_booked",
+		Roles:  []string{"admin"},
+		Guards: []leadGuard{guardTestBooked},
+		Source: StatusSourceManual,
+		Reason: "Placement test booked",
+	},
+	EventMarkTested: {
+		Label:  "mark TESTED",
+		From:   preTestStatuses,
+		To:     "tested",
+		Roles:  []string{"admin"},
+		Source: StatusSourceManual,
+		Reason: "Marked tested",
+	},
+	EventSendOffer: {
+		Label:   "mark OFFER_SENT",
+		From:    append(append([]string{}, preTestStatuses...), "offer_sent", "booking_confirmed"),
+		To:      "offer_sent",
+		Roles:   []string{"admin"},
+		Guards:  []leadGuard{guardOfferRequired, guardDiscountOK},
+		Effects: []leadEffect{effectRedeemPromoCode, effectIssueOfferLetter},
+		Source:  StatusSourceManual,
+		Reason:  "Offer sent",
+	},
+	EventMoveWaiting: {
+		Label:  "move to waiting list",
+		From:   preClassStatuses,
+		To:     "waiting_for_round",
+		Roles:  []string{"admin"},
+		Source: StatusSourceManual,
+		Reason: "Moved to waiting list",
+	},
+	EventMarkReady: {
+		Label:  "mark READY_TO_START",
+		From:   preClassStatuses,
+		To:     "ready_to_start",
+		Roles:  []string{"admin"},
+		Guards: []leadGuard{guardPaidOrOnPlan, guardNoOverdue, guardAssignedLevel, guardClassSchedule},
+		Source: StatusSourceManual,
+		Reason: "Marked ready to start",
+	},
+	EventPaymentComplete: {
+		Label:   "mark PAID_FULL",
+		From:    unpaidStatuses,
+		To:      "paid_full",
+		Roles:   []string{RoleSystem},
+		Guards:  []leadGuard{guardFullyPaid},
+		Effects: []leadEffect{effectIssueReferralReward},
+		Source:  StatusSourceAuto,
+		Reason:  "Course fully paid",
+	},
+	EventPaymentReversed: {
+		Label:  "revert to OFFER_SENT",
+		From:   []string{"paid_full"},
+		To:     "offer_sent",
+		Roles:  []string{RoleSystem},
+		Guards: []leadGuard{guardNotFullyPaid},
+		Source: StatusSourceAuto,
+		Reason: "Course paid dropped below final price (refund)",
+	},
+	EventStartClasses: {
+		Label:  "start classes",
+		From:   []string{"ready_to_start"},
+		To:     "in_classes",
+		Roles:  []string{RoleSystem},
+		Source: StatusSourceAuto,
+		Reason: "Round started",
+	},
+	EventCancel: {
+		Label:   "cancel lead",
+		From:    append(append([]string{}, preClassStatuses...), "in_classes", "paused"),
+		To:      "cancelled",
+		Roles:   []string{"admin"},
+		Effects: []leadEffect{effectSetCancelledAt, effectEndOpenPause},
+		Source:  StatusSourceManual,
+	},
+	EventReopen: {
+		Label:   "reopen lead",
+		From:    []string{"cancelled"},
+		To:      "lead_created",
+		Roles:   []string{"admin"},
+		Effects: []leadEffect{effectClearCancelledAt},
+		Source:  StatusSourceManual,
+		Reason:  "Lead reopened",
+	},
+	EventPause: {
+		Label:   "pause student",
+		From:    pausableStatuses,
+		To:      "paused",
+		Roles:   pauseRoles,
+		Effects: []leadEffect{effectLeaveClasses},
+		Source:  StatusSourceManual,
+		Reason:  "Student paused",
+	},
+	EventResume: {
+		Label:   "resume student",
+		From:    []string{"paused"},
+		To:      "ready_to_start",
+		Roles:   pauseRoles,
+		Guards:  []leadGuard{guardAssignedLevel, guardClassSchedule},
+		Effects: []leadEffect{effectReturnToClassesBoard},
+		Source:  StatusSourceManual,
+		Reason:  "Student resumed",
+	},
+	EventExpireOffer: {
+		Label:  "expire offer",
+		From:   []string{"offer_sent", "booking_confirmed"},
+		To:     "tested",
+		Roles:  []string{RoleSystem},
+		Guards: []leadGuard{guardNothingPaid},
+		Source: StatusSourceAuto,
+		Reason: "Offer expired unpaid",
+	},
+	EventNextRound: {
+		Label:   "place for the next round",
+		From:    []string{"in_classes"},
+		To:      "ready_to_start",
+		Roles:   []string{RoleSystem},
+		Guards:  []leadGuard{guardAssignedLevel, guardClassSchedule},
+		Effects: []leadEffect{effectReturnToClassesBoard},
+		Source:  StatusSourceAuto,
+		Reason:  "Round closed: back on the classes board",
+	},
+}
+
+// Kinds of LeadTransitionError
+const (
+	TransitionNotAllowed  = "not_allowed"  // event cannot fire from the current status
+	TransitionGuardFailed = "guard_failed" // a precondition such as "offer required" does not hold
+	TransitionForbidden   = "forbidden"    // the role may not trigger the event
+)
+
+// LeadTransitionError is returned when the pipeline rejects an event
+type LeadTransitionError struct {
+	Event  LeadEvent
+	From   string
+	To     string
+	Kind   string
+	Reason string
+}
+
+func (e *LeadTransitionError) Error() string {
+	label := string(e.Event)
+	if t, ok := leadTransitions[e.Event]; ok {
+		label = t.Label
+	}
+	switch e.Kind {
+	case TransitionForbidden:
+		return fmt.Sprintf("Not allowed to %s", label)
+	case TransitionGuardFailed:
+		return fmt.Sprintf("Cannot %s: %s", label, e.Reason)
+	}
+	return fmt.Sprintf("Cannot %s from status %s", label, GetStatusDisplayInfo(e.From).DisplayName)
+}
+
+// MapOldStatusToStage maps a leads.status value to its workflow stage.
+// Statuses outside the sales pipeline (and unknown ones) map to NEW_LEAD for backward compatibility.
+func MapOldStatusToStage(oldStatus string) string {
+	if s, ok := findLeadState(oldStatus); ok && s.Stage != "" {
+		return s.Stage
+	}
+	return StageNewLead
+}
+
+// StageToStatus returns the status stored for a stage, or "" if stage is not a stage constant
+func StageToStatus(stage string) string {
+	for _, s := range leadStates {
+		if s.Stage == stage {
+			return s.Status
+		}
+	}
+	return ""
+}
+
+// GetNextAction returns the suggested next step for a lead in the given status
+func GetNextAction(status string) string {
+	if s, ok := findLeadState(status); ok {
+		return s.NextAction
+	}
+	return "Review"
+}
+
+func findLeadState(status string) (leadState, bool) {
+	for _, s := range leadStates {
+		if s.Status == status {
+			return s, true
+		}
+	}
+	return leadState{}, false
+}
+
+func stageRank(stage string) int {
+	for i, s := range stageOrder {
+		if s == stage {
+			return i
+		}
+	}
+	return -1
+}
+
+// ComputeStageFromFormCompletion computes the appropriate workflow stage based on form completion
+// Rules: Compute stage from the furthest completed block, never downgrade
+// Returns the new stage and the status to store. Statuses outside the sales pipeline
+// (in_classes, cancelled) are returned unchanged, as is the current status when the stage does not move.
+func ComputeStageFromFormCompletion(detail *LeadDetail, currentStatus string) (newStage string, dbStatus string) {
+	currentStage := MapOldStatusToStage(currentStatus)
+	if s, ok := findLeadState(currentStatus); ok && s.Stage == "" {
+		return currentStage, currentStatus
+	}
+
+	stage := currentStage
+	raise := func(target string) {
+		if stageRank(stage) < stageRank(target) {
+			stage = target
+		}
+	}
+
+	// 1. Booked test slot -> at least TEST_BOOKED
+	if detail.PlacementTest != nil && detail.PlacementTest.SlotID.Valid {
+		raise(StageTestBooked)
+	}
+	// 2. Assigned level -> at least TESTED
+	if detail.PlacementTest != nil && detail.PlacementTest.AssignedLevel.Valid {
+		raise(StageTested)
+	}
+	// 3. Offer final price -> at least OFFER_SENT
+	var finalPrice int32
+	if detail.Offer != nil && detail.Offer.FinalPrice.Valid {
+		finalPrice = detail.Offer.FinalPrice.Int32
+	}
+	if finalPrice > 0 {
+		raise(StageOfferSent)
+	}
+	// 4. Payment: amountPaid >= finalPrice -> PAID_FULL, else any amount -> DEPOSIT
+	if detail.Payment != nil && detail.Payment.AmountPaid.Valid && detail.Payment.AmountPaid.Int32 > 0 {
+		if finalPrice > 0 && detail.Payment.AmountPaid.Int32 >= finalPrice {
+			raise(StageBookingConfirmedPaidFull)
+		} else {
+			raise(StageBookingConfirmedDeposit)
+		}
+	}
+	// 5. Class days + class time -> READY_TO_START
+	if detail.Scheduling != nil && detail.Scheduling.ClassDays.Valid && detail.Scheduling.ClassTime.Valid {
+		raise(StageReadyToStart)
+	}
+
+	if stage == currentStage {
+		return stage, currentStatus
+	}
+	return stage, StageToStatus(stage)
+}
+
+// formCompletionEvents are the events that move a lead to the status computed from form completion.
+// Deposit and paid statuses have none: course payments move leads there (UpdateLeadStatusFromPayment).
+var formCompletionEvents = map[string]LeadEvent{
+	"test_booked":    EventBookTest,
+	"tested":         EventMarkTested,
+	"offer_sent":     EventSendOffer,
+	"ready_to_start": EventMarkReady,
+}
+
+// formCompletionEvent returns the event that moves a lead to status after a detail form save
+func formCompletionEvent(status string) (LeadEvent, bool) {
+	event, ok := formCompletionEvents[status]
+	return event, ok
+}
+
+// LeadEventAllowedForRole reports whether role may trigger event at all (regardless of status)
+func LeadEventAllowedForRole(event LeadEvent, role string) bool {
+	t, ok := leadTransitions[event]
+	return ok && containsString(t.Roles, role)
+}
+
+// LeadEventsFrom returns the events role may trigger for a lead in status, keyed by event name.
+// Guards are not evaluated; views use this to decide which actions to offer.
+func LeadEventsFrom(status, role string) map[string]bool {
+	events := map[string]bool{}
+	for event, t := range leadTransitions {
+		if containsString(t.Roles, role) && containsString(t.From, status) {
+			events[string(event)] = true
+		}
+	}
+	return events
+}
+
+// CheckLeadTransition validates event for a lead in status from and returns the target status.
+// Returns *LeadTransitionError when the role, current status or a guard rejects the event.
+func CheckLeadTransition(from string, event LeadEvent, role string, facts LeadFacts) (string, error) {
+	t, ok := leadTransitions[event]
+	if !ok {
+		return "", fmt.Errorf("unknown lead event %q", event)
+	}
+	if !containsString(t.Roles, role) {
+		return "", &LeadTransitionError{Event: event, From: from, To: t.To, Kind: TransitionForbidden}
+	}
+	if !containsString(t.From, from) {
+		return "", &LeadTransitionError{Event: event, From: from, To: t.To, Kind: TransitionNotAllowed}
+	}
+	for _, g := range t.Guards {
+		if !g.check(facts) {
+			return "", &LeadTransitionError{Event: event, From: from, To: t.To, Kind: TransitionGuardFailed, Reason: g.message}
+		}
+	}
+	// Required custom fields hold back manual forward moves; automatic ones (payments) always go through
+	if role != RoleSystem {
+		if missing := facts.MissingCustomFields(from, t.To); len(missing) > 0 {
+			return "", &LeadTransitionError{Event: event, From: from, To: t.To, Kind: TransitionGuardFailed, Reason: requiredCustomFieldsMessage(missing)}
+		}
+	}
+	return t.To, nil
+}
+
+// FindLeadEvent returns the event that moves a lead from status from to status to for role.
+// Returns *LeadTransitionError (not_allowed) when no such event exists.
+func FindLeadEvent(from, to, role string) (LeadEvent, error) {
+	for event, t := range leadTransitions {
+		if t.To == to && containsString(t.From, from) && containsString(t.Roles, role) {
+			return event, nil
+		}
+	}
+	return "", &LeadTransitionError{From: from, To: to, Kind: TransitionNotAllowed}
+}
+
+// ApplyLeadEvent runs event for a lead in its own transaction: checks the transition,
+// updates the status, applies side effects, opens or closes follow-up tasks and records lead_status_history.
+// reason overrides the transition's default history reason when not empty.
+func ApplyLeadEvent(leadID uuid.UUID, event LeadEvent, role, actorUserID, reason string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := applyLeadEventTx(tx, leadID, event, role, actorUserID, reason, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func applyLeadEventTx(tx *sql.Tx, leadID uuid.UUID, event LeadEvent, role, actorUserID, reason string, now time.Time) error {
+	from, err := getLeadStatusForUpdate(tx, leadID)
+	if err != nil {
+		return err
+	}
+	facts, err := loadLeadFactsTx(tx, leadID)
+	if err != nil {
+		return err
+	}
+	to, err := CheckLeadTransition(from, event, role, facts)
+	if err != nil {
+		return err
+	}
+	t := leadTransitions[event]
+
+	_, err = tx.Exec(`UPDATE leads SET status = $1, updated_at = $2 WHERE id = $3`, to, now, leadID)
+	if err != nil {
+		return fmt.Errorf("failed to update lead status: %w", err)
+	}
+	for _, effect := range t.Effects {
+		if err := effect(tx, leadID, actorUserID, now); err != nil {
+			return err
+		}
+	}
+	if err := syncLeadTasksTx(tx, leadID, to, facts.TotalCoursePaid, actorUserID, now); err != nil {
+		return err
+	}
+	if reason == "" {
+		reason = t.Reason
+	}
+	return recordLeadStatusChange(tx, leadID, from, to, t.Source, reason, actorUserID, now)
+}
+
+// CheckClassScheduling checks that a lead's class days and time may be set: the Mark Ready guards
+// that do not depend on the schedule itself (paid or on an installment plan, no blocking overdue
+// installment). Returns *LeadTransitionError naming the failed guard.
+func CheckClassScheduling(leadID uuid.UUID) error {
+	facts, err := loadLeadFactsTx(db.DB, leadID)
+	if err != nil {
+		return err
+	}
+	return checkClassScheduling(facts)
+}
+
+func checkClassScheduling(facts LeadFacts) error {
+	for _, g := range []leadGuard{guardPaidOrOnPlan, guardNoOverdue} {
+		if !g.check(facts) {
+			return &LeadTransitionError{Event: EventMarkReady, To: "ready_to_start", Kind: TransitionGuardFailed, Reason: g.message}
+		}
+	}
+	return nil
+}
+
+// loadLeadFactsTx reads the guard inputs for a lead
+func loadLeadFactsTx(q sqlExecer, leadID uuid.UUID) (LeadFacts, error) {
+	var facts LeadFacts
+	offer := &Offer{}
+	err := q.QueryRow(`
+		SELECT
+			COALESCE(pt.slot_id IS NOT NULL, false),
+			COALESCE(pt.assigned_level IS NOT NULL, false),
+			COALESCE(o.final_price, 0),
+			COALESCE(s.class_days IS NOT NULL AND s.class_time IS NOT NULL, false),
+			GREATEST(
+				COALESCE((SELECT SUM(amount) FROM lead_payments WHERE lead_id = l.id), 0) -
+				COALESCE((SELECT SUM(amount) FROM transactions WHERE lead_id = l.id AND category = 'refund' AND transaction_type = 'OUT'), 0),
+				0),
+			o.base_price, o.final_price, o.discount_type, o.discount_value, o.promo_code_id, o.referral_discount, o.referral_credit,
+			COALESCE(o.discount_approved_by_user_id IS NOT NULL, false),
+			EXISTS(SELECT 1 FROM lead_installments WHERE lead_id = l.id),
+			(SELECT COUNT(*) FROM lead_installments WHERE lead_id = l.id AND paid_amount < amount AND due_date < CURRENT_DATE)
+		FROM leads l
+		LEFT JOIN placement_tests pt ON pt.lead_id = l.id
+		LEFT JOIN offers o ON o.lead_id = l.id
+		LEFT JOIN scheduling s ON s.lead_id = l.id
+		WHERE l.id = $1
+	`, leadID).Scan(&facts.HasTestSlot, &facts.HasAssignedLevel, &facts.OfferFinalPrice, &facts.HasClassSchedule, &facts.TotalCoursePaid,
+		&offer.BasePrice, &offer.FinalPrice, &offer.DiscountType, &offer.DiscountValue, &offer.PromoCodeID,
+		&offer.ReferralDiscount, &offer.ReferralCredit, &facts.DiscountApproved,
+		&facts.HasInstallmentPlan, &facts.OverdueInstallments)
+	if err != nil {
+		return facts, fmt.Errorf("failed to load lead facts: %w", err)
+	}
+	facts.ManualDiscountPercent = offer.ManualDiscountPercent()
+	facts.DiscountApprovalThreshold, err = getDiscountApprovalThreshold(q)
+	if err != nil {
+		return facts, err
+	}
+	facts.OverdueBlocksClasses, err = getInstallmentOverdueBlock(q)
+	if err != nil {
+		return facts, err
+	}
+	facts.RequiredCustomFields, err = loadRequiredCustomFieldsTx(q, leadID)
+	if err != nil {
+		return facts, err
+	}
+	return facts, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
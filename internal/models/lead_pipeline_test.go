@@ -0,0 +1,180 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLeadTransitionsReferenceKnownStatuses(t *testing.T) {
+	for event, tr := range leadTransitions {
+		if _, ok := findLeadState(tr.To); !ok {
+			t.Errorf("%s: unknown target status %q", event, tr.To)
+		}
+		for _, from := range tr.From {
+			if _, ok := findLeadState(from); !ok {
+				t.Errorf("%s: unknown source status %q", event, from)
+			}
+		}
+		if len(tr.Roles) == 0 {
+			t.Errorf("%s: no roles may trigger it", event)
+		}
+	}
+	for _, stage := range stageOrder {
+		if StageToStatus(stage) == "" {
+			t.Errorf("stage %s has no status", stage)
+		}
+		if got := MapOldStatusToStage(StageToStatus(stage)); got != stage {
+			t.Errorf("MapOldStatusToStage(StageToStatus(%s)) = %s", stage, got)
+		}
+	}
+	for status, event := range formCompletionEvents {
+		if tr, ok := leadTransitions[event]; !ok || tr.To != status {
+			t.Errorf("form completion to %s fires %s, which does not lead there", status, event)
+		}
+	}
+}
+
+func TestCheckLeadTransition(t *testing.T) {
+	paidReady := LeadFacts{HasAssignedLevel: true, OfferFinalPrice: 6000, TotalCoursePaid: 6000, HasClassSchedule: true}
+	schoolMissing := LeadFacts{OfferFinalPrice: 6000, RequiredCustomFields: []RequiredCustomField{{Label: "School", FromStage: StageOfferSent}}}
+	schoolFilled := LeadFacts{OfferFinalPrice: 6000, RequiredCustomFields: []RequiredCustomField{{Label: "School", FromStage: StageOfferSent, Filled: true}}}
+	tests := []struct {
+		name     string
+		from     string
+		event    LeadEvent
+		role     string
+		facts    LeadFacts
+		wantTo   string
+		wantKind string
+	}{
+		{"book test", "lead_created", EventBookTest, "admin", LeadFacts{HasTestSlot: true}, "test_booked", ""},
+		{"book test without a slot", "lead_created", EventBookTest, "admin", LeadFacts{}, "", TransitionGuardFailed},
+		{"moderator cannot mark tested", "test_booked", EventMarkTested, "moderator", LeadFacts{}, "", TransitionForbidden},
+		{"offer requires final price", "tested", EventSendOffer, "admin", LeadFacts{}, "", TransitionGuardFailed},
+		{"offer sent", "tested", EventSendOffer, "admin", LeadFacts{OfferFinalPrice: 6000}, "offer_sent", ""},
+		{"large discount needs approval", "tested", EventSendOffer, "admin", LeadFacts{OfferFinalPrice: 3000, ManualDiscountPercent: 25, DiscountApprovalThreshold: 20}, "", TransitionGuardFailed},
+		{"approved large discount", "tested", EventSendOffer, "admin", LeadFacts{OfferFinalPrice: 3000, ManualDiscountPercent: 25, DiscountApprovalThreshold: 20, DiscountApproved: true}, "offer_sent", ""},
+		{"discount at threshold", "tested", EventSendOffer, "admin", LeadFacts{OfferFinalPrice: 3000, ManualDiscountPercent: 20, DiscountApprovalThreshold: 20}, "offer_sent", ""},
+		{"offer needs required custom field", "tested", EventSendOffer, "admin", schoolMissing, "", TransitionGuardFailed},
+		{"offer with required custom field", "tested", EventSendOffer, "admin", schoolFilled, "offer_sent", ""},
+		{"system moves skip custom fields", "deposit_paid", EventPaymentComplete, RoleSystem, LeadFacts{OfferFinalPrice: 6000, TotalCoursePaid: 6000, RequiredCustomFields: []RequiredCustomField{{Label: "School", FromStage: StageOfferSent}}}, "paid_full", ""},
+		{"backward move skips custom fields", "cancelled", EventReopen, "admin", schoolMissing, "lead_created", ""},
+		{"unpaid offer expires", "offer_sent", EventExpireOffer, RoleSystem, LeadFacts{OfferFinalPrice: 6000}, "tested", ""},
+		{"offer with payments does not expire", "offer_sent", EventExpireOffer, RoleSystem, LeadFacts{OfferFinalPrice: 6000, TotalCoursePaid: 500}, "", TransitionGuardFailed},
+		{"admin cannot expire offer", "offer_sent", EventExpireOffer, "admin", LeadFacts{OfferFinalPrice: 6000}, "", TransitionForbidden},
+		{"offer cannot downgrade deposit", "deposit_paid", EventSendOffer, "admin", LeadFacts{OfferFinalPrice: 6000}, "", TransitionNotAllowed},
+		{"ready needs full payment", "deposit_paid", EventMarkReady, "admin", LeadFacts{HasAssignedLevel: true, OfferFinalPrice: 6000, TotalCoursePaid: 3000, HasClassSchedule: true}, "", TransitionGuardFailed},
+		{"ready on installment plan", "deposit_paid", EventMarkReady, "admin", LeadFacts{HasAssignedLevel: true, OfferFinalPrice: 6000, TotalCoursePaid: 2000, HasClassSchedule: true, HasInstallmentPlan: true}, "ready_to_start", ""},
+		{"overdue installment blocks ready", "deposit_paid", EventMarkReady, "admin", LeadFacts{HasAssignedLevel: true, OfferFinalPrice: 6000, TotalCoursePaid: 2000, HasClassSchedule: true, HasInstallmentPlan: true, OverdueInstallments: 1, OverdueBlocksClasses: true}, "", TransitionGuardFailed},
+		{"overdue installment with block off", "deposit_paid", EventMarkReady, "admin", LeadFacts{HasAssignedLevel: true, OfferFinalPrice: 6000, TotalCoursePaid: 2000, HasClassSchedule: true, HasInstallmentPlan: true, OverdueInstallments: 1}, "ready_to_start", ""},
+		{"ready needs level", "paid_full", EventMarkReady, "admin", LeadFacts{OfferFinalPrice: 6000, TotalCoursePaid: 6000, HasClassSchedule: true}, "", TransitionGuardFailed},
+		{"ready", "paid_full", EventMarkReady, "admin", paidReady, "ready_to_start", ""},
+		{"cancelled lead cannot be made ready", "cancelled", EventMarkReady, "admin", paidReady, "", TransitionNotAllowed},
+		{"payment completes", "deposit_paid", EventPaymentComplete, RoleSystem, paidReady, "paid_full", ""},
+		{"payment does not downgrade ready", "ready_to_start", EventPaymentComplete, RoleSystem, paidReady, "", TransitionNotAllowed},
+		{"admin cannot fire payment event", "deposit_paid", EventPaymentComplete, "admin", paidReady, "", TransitionForbidden},
+		{"refund reverts paid_full", "paid_full", EventPaymentReversed, RoleSystem, LeadFacts{OfferFinalPrice: 6000, TotalCoursePaid: 1000}, "offer_sent", ""},
+		{"reopen", "cancelled", EventReopen, "admin", LeadFacts{}, "lead_created", ""},
+		{"reopen active lead", "tested", EventReopen, "admin", LeadFacts{}, "", TransitionNotAllowed},
+		{"cancel twice", "cancelled", EventCancel, "admin", LeadFacts{}, "", TransitionNotAllowed},
+		{"pause in class", "in_classes", EventPause, "student_success", LeadFacts{}, "paused", ""},
+		{"pause before payment", "offer_sent", EventPause, "admin", LeadFacts{}, "", TransitionNotAllowed},
+		{"moderator cannot pause", "in_classes", EventPause, "moderator", LeadFacts{}, "", TransitionForbidden},
+		{"resume", "paused", EventResume, "mentor_head", paidReady, "ready_to_start", ""},
+		{"resume needs schedule", "paused", EventResume, "admin", LeadFacts{HasAssignedLevel: true}, "", TransitionGuardFailed},
+		{"cancel paused", "paused", EventCancel, "admin", LeadFacts{}, "cancelled", ""},
+		{"next round", "in_classes", EventNextRound, RoleSystem, paidReady, "ready_to_start", ""},
+		{"next round needs schedule", "in_classes", EventNextRound, RoleSystem, LeadFacts{HasAssignedLevel: true}, "", TransitionGuardFailed},
+		{"admin cannot place for next round", "in_classes", EventNextRound, "admin", paidReady, "", TransitionForbidden},
+	}
+	for _, tt := range tests {
+		to, err := CheckLeadTransition(tt.from, tt.event, tt.role, tt.facts)
+		if tt.wantKind == "" {
+			if err != nil || to != tt.wantTo {
+				t.Errorf("%s: got (%q, %v), want %q", tt.name, to, err, tt.wantTo)
+			}
+			continue
+		}
+		var transitionErr *LeadTransitionError
+		if !errors.As(err, &transitionErr) || transitionErr.Kind != tt.wantKind {
+			t.Errorf("%s: got (%q, %v), want %s error", tt.name, to, err, tt.wantKind)
+		}
+	}
+}
+
+func TestCheckClassScheduling(t *testing.T) {
+	tests := []struct {
+		name    string
+		facts   LeadFacts
+		wantErr bool
+	}{
+		{"fully paid", LeadFacts{OfferFinalPrice: 6000, TotalCoursePaid: 6000}, false},
+		{"deposit only", LeadFacts{OfferFinalPrice: 6000, TotalCoursePaid: 2000}, true},
+		{"on installment plan", LeadFacts{OfferFinalPrice: 6000, TotalCoursePaid: 2000, HasInstallmentPlan: true}, false},
+		{"overdue installment", LeadFacts{OfferFinalPrice: 6000, TotalCoursePaid: 2000, HasInstallmentPlan: true, OverdueInstallments: 1, OverdueBlocksClasses: true}, true},
+		{"overdue installment with block off", LeadFacts{OfferFinalPrice: 6000, TotalCoursePaid: 2000, HasInstallmentPlan: true, OverdueInstallments: 1}, false},
+	}
+	for _, tt := range tests {
+		err := checkClassScheduling(tt.facts)
+		var transitionErr *LeadTransitionError
+		if tt.wantErr != errors.As(err, &transitionErr) {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFindLeadEvent(t *testing.T) {
+	if event, err := FindLeadEvent("cancelled", "lead_created", "admin"); err != nil || event != EventReopen {
+		t.Errorf("cancelled -> lead_created = (%q, %v), want reopen", event, err)
+	}
+	if _, err := FindLeadEvent("in_classes", "tested", "admin"); err == nil {
+		t.Errorf("in_classes -> tested should not be allowed")
+	}
+	if _, err := FindLeadEvent("test_booked", "tested", "moderator"); err == nil {
+		t.Errorf("moderator should not find an event")
+	}
+}
+
+func TestComputeStageFromFormCompletion(t *testing.T) {
+	withLevel := &LeadDetail{PlacementTest: &PlacementTest{AssignedLevel: sql.NullInt32{Int32: 2, Valid: true}}}
+	withDeposit := &LeadDetail{
+		Offer:   &Offer{FinalPrice: sql.NullInt32{Int32: 6000, Valid: true}},
+		Payment: &Payment{AmountPaid: sql.NullInt32{Int32: 2000, Valid: true}},
+	}
+	withPaidFull := &LeadDetail{
+		Offer:   &Offer{FinalPrice: sql.NullInt32{Int32: 6000, Valid: true}},
+		Payment: &Payment{AmountPaid: sql.NullInt32{Int32: 6000, Valid: true}},
+	}
+	withSchedule := &LeadDetail{Scheduling: &Scheduling{ClassDays: sql.NullString{String: "Sun/Wed", Valid: true}, ClassTime: sql.NullString{String: "07:30", Valid: true}}}
+	withTest := &LeadDetail{PlacementTest: &PlacementTest{SlotID: sql.NullString{String: "9f1c2d3e-0000-4000-8000-000000000001", Valid: true}}}
+	withUnbookedDate := &LeadDetail{PlacementTest: &PlacementTest{TestDate: sql.NullTime{Time: time.Now(), Valid: true}, TestTime: sql.NullString{String: "10:00", Valid: true}}}
+
+	tests := []struct {
+		name       string
+		detail     *LeadDetail
+		status     string
+		wantStage  string
+		wantStatus string
+	}{
+		{"empty form keeps new lead", &LeadDetail{}, "lead_created", StageNewLead, "lead_created"},
+		{"test booked", withTest, "lead_created", StageTestBooked, "test_booked"},
+		{"date without a slot books nothing", withUnbookedDate, "lead_created", StageNewLead, "lead_created"},
+		{"level upgrades to tested", withLevel, "test_booked", StageTested, "tested"},
+		{"level never downgrades", withLevel, "offer_sent", StageOfferSent, "offer_sent"},
+		{"deposit", withDeposit, "tested", StageBookingConfirmedDeposit, "deposit_paid"},
+		{"paid in full", withPaidFull, "deposit_paid", StageBookingConfirmedPaidFull, "paid_full"},
+		{"deposit never downgrades paid_full", withDeposit, "paid_full", StageBookingConfirmedPaidFull, "paid_full"},
+		{"schedule makes ready", withSchedule, "paid_full", StageReadyToStart, "ready_to_start"},
+		{"waiting list kept without schedule", &LeadDetail{}, "waiting_for_round", StageScheduleSet, "waiting_for_round"},
+		{"in classes untouched", withSchedule, "in_classes", StageNewLead, "in_classes"},
+		{"cancelled untouched", withLevel, "cancelled", StageNewLead, "cancelled"},
+	}
+	for _, tt := range tests {
+		stage, status := ComputeStageFromFormCompletion(tt.detail, tt.status)
+		if stage != tt.wantStage || status != tt.wantStatus {
+			t.Errorf("%s: got (%s, %s), want (%s, %s)", tt.name, stage, status, tt.wantStage, tt.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// LeadSourceFallback is used when a lead's source is missing or not on the managed list.
+// It cannot be retired or renamed.
+const LeadSourceFallback = "Other"
+
+// NormalizeLeadSource matches a source case-insensitively against the managed source names,
+// defaulting to LeadSourceFallback
+func NormalizeLeadSource(source string, sources []string) string {
+	source = strings.TrimSpace(source)
+	for _, s := range sources {
+		if strings.EqualFold(s, source) {
+			return s
+		}
+	}
+	return LeadSourceFallback
+}
+
+// GetLeadSources returns the managed sources in display order with how many leads use each.
+// Retired sources are included only when includeRetired is set.
+func GetLeadSources(includeRetired bool) ([]*LeadSource, error) {
+	rows, err := db.DB.Query(`
+		SELECT s.id, s.name, s.active, s.sort_order, COUNT(l.id)
+		FROM lead_sources s
+		LEFT JOIN leads l ON l.source = s.name
+		WHERE s.active OR $1
+		GROUP BY s.id
+		ORDER BY s.active DESC, s.sort_order, s.name
+	`, includeRetired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lead sources: %w", err)
+	}
+	defer rows.Close()
+	var sources []*LeadSource
+	for rows.Next() {
+		s := &LeadSource{}
+		if err := rows.Scan(&s.ID, &s.Name, &s.Active, &s.SortOrder, &s.LeadCount); err != nil {
+			return nil, fmt.Errorf("failed to scan lead source: %w", err)
+		}
+		sources = append(sources, s)
+	}
+	return sources, rows.Err()
+}
+
+// GetActiveLeadSourceNames returns the names offered on lead forms, in display order
+func GetActiveLeadSourceNames() ([]string, error) {
+	sources, err := GetLeadSources(false)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(sources))
+	for i, s := range sources {
+		names[i] = s.Name
+	}
+	return names, nil
+}
+
+// CreateLeadSource adds a source to the managed list (or reactivates a retired one with the same name)
+func CreateLeadSource(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return &LeadSourceError{Message: "Source name is required."}
+	}
+	var existing string
+	err := db.DB.QueryRow(`SELECT name FROM lead_sources WHERE LOWER(name) = LOWER($1)`, name).Scan(&existing)
+	if err == nil {
+		if _, err := db.DB.Exec(`UPDATE lead_sources SET active = TRUE WHERE name = $1`, existing); err != nil {
+			return fmt.Errorf("failed to reactivate lead source: %w", err)
+		}
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return fmt.Errorf("failed to check lead source: %w", err)
+	}
+	_, err = db.DB.Exec(`
+		INSERT INTO lead_sources (name, sort_order)
+		VALUES ($1, (SELECT COALESCE(MAX(sort_order), 0) + 10 FROM lead_sources WHERE name <> $2))
+	`, name, LeadSourceFallback)
+	if err != nil {
+		return fmt.Errorf("failed to create lead source: %w", err)
+	}
+	return nil
+}
+
+// RenameLeadSource renames a source; leads using it follow through the foreign key
+func RenameLeadSource(id uuid.UUID, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return &LeadSourceError{Message: "Source name is required."}
+	}
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current string
+	if err := tx.QueryRow(`SELECT name FROM lead_sources WHERE id = $1 FOR UPDATE`, id).Scan(&current); err != nil {
+		return fmt.Errorf("failed to load lead source: %w", err)
+	}
+	if current == LeadSourceFallback {
+		return &LeadSourceError{Message: fmt.Sprintf("%q is the fallback source and cannot be renamed.", LeadSourceFallback)}
+	}
+	var clash int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM lead_sources WHERE LOWER(name) = LOWER($1) AND id <> $2`, name, id).Scan(&clash); err != nil {
+		return fmt.Errorf("failed to check lead source: %w", err)
+	}
+	if clash > 0 {
+		return &LeadSourceError{Message: fmt.Sprintf("A source named %q already exists.", name)}
+	}
+	if _, err := tx.Exec(`UPDATE lead_sources SET name = $1 WHERE id = $2`, name, id); err != nil {
+		return fmt.Errorf("failed to rename lead source: %w", err)
+	}
+	return tx.Commit()
+}
+
+// SetLeadSourceActive retires or restores a source. Retired sources stay on existing leads
+// but are no longer offered on forms.
+func SetLeadSourceActive(id uuid.UUID, active bool) error {
+	res, err := db.DB.Exec(`UPDATE lead_sources SET active = $1 WHERE id = $2 AND name <> $3`, active, id, LeadSourceFallback)
+	if err != nil {
+		return fmt.Errorf("failed to update lead source: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &LeadSourceError{Message: fmt.Sprintf("%q is the fallback source and cannot be retired.", LeadSourceFallback)}
+	}
+	return nil
+}
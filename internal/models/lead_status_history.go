@@ -0,0 +1,90 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sources recorded in lead_status_history.source
+const (
+	StatusSourceManual = "manual"
+	StatusSourceAuto   = "auto"
+)
+
+// sqlExecer is satisfied by both *sql.DB and *sql.Tx
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// recordLeadStatusChange inserts a lead_status_history row. No-op when the status did not change.
+// actorUserID may be empty for system-triggered changes.
+func recordLeadStatusChange(q sqlExecer, leadID uuid.UUID, previousStatus, newStatus, source, reason, actorUserID string, changedAt time.Time) error {
+	if previousStatus == newStatus {
+		return nil
+	}
+	var prev, reasonVal, actor sql.NullString
+	if previousStatus != "" {
+		prev = sql.NullString{String: previousStatus, Valid: true}
+	}
+	if reason != "" {
+		reasonVal = sql.NullString{String: reason, Valid: true}
+	}
+	if actorUserID != "" {
+		if u, err := uuid.Parse(actorUserID); err == nil {
+			actor = sql.NullString{String: u.String(), Valid: true}
+		}
+	}
+	_, err := q.Exec(`
+		INSERT INTO lead_status_history (lead_id, previous_status, new_status, source, reason, changed_by_user_id, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, leadID, prev, newStatus, source, reasonVal, actor, changedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record status change: %w", err)
+	}
+	return nil
+}
+
+// getLeadStatusForUpdate reads a lead's current status, locking the row when called inside a transaction
+func getLeadStatusForUpdate(q sqlExecer, leadID uuid.UUID) (string, error) {
+	var status string
+	err := q.QueryRow(`SELECT status FROM leads WHERE id = $1 FOR UPDATE`, leadID).Scan(&status)
+	if err != nil {
+		return "", fmt.Errorf("failed to get lead status: %w", err)
+	}
+	return status, nil
+}
+
+// GetLeadStatusHistory returns a lead's status changes, oldest first
+func GetLeadStatusHistory(leadID uuid.UUID) ([]*LeadStatusChange, error) {
+	rows, err := db.DB.Query(`
+		SELECT h.id, h.lead_id, h.previous_status, h.new_status, h.source, COALESCE(h.reason, ''),
+		       h.changed_by_user_id::text, COALESCE(u.email, ''), h.changed_at
+		FROM lead_status_history h
+		LEFT JOIN users u ON u.id = h.changed_by_user_id
+		WHERE h.lead_id = $1
+		ORDER BY h.changed_at ASC, h.id
+	`, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query status history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []*LeadStatusChange{}
+	for rows.Next() {
+		c := &LeadStatusChange{}
+		var prev, actor sql.NullString
+		if err := rows.Scan(&c.ID, &c.LeadID, &prev, &c.NewStatus, &c.Source, &c.Reason, &actor, &c.ChangedByEmail, &c.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan status change: %w", err)
+		}
+		c.PreviousStatus = prev.String
+		c.ChangedByUserID = actor.String
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,299 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Lead task types
+const (
+	LeadTaskCall          = "call"
+	LeadTaskWhatsApp      = "whatsapp"
+	LeadTaskOfferDiscount = "offer_discount"
+)
+
+// LeadTaskTypes are the task types offered on forms, in display order
+var LeadTaskTypes = []string{LeadTaskCall, LeadTaskWhatsApp, LeadTaskOfferDiscount}
+
+// LeadTaskOutcomes are the outcomes a user can log when completing a task
+var LeadTaskOutcomes = []string{"reached", "callback", "no_answer", "not_interested"}
+
+// LeadTaskOutcomeClosed marks tasks closed automatically because the lead left the follow-up stages
+const LeadTaskOutcomeClosed = "closed"
+
+// leadContactOutcomes are the outcomes that count as a contact for HotLevel
+var leadContactOutcomes = []string{"reached", "callback", "not_interested"}
+
+// LeadTaskAssigneeRoles are the roles that work the follow-up queue
+var LeadTaskAssigneeRoles = []string{"admin", "moderator"}
+
+// Auto-created task timing: a call the day after testing, a WhatsApp check two days after the offer
+const (
+	autoCallTaskDue     = 24 * time.Hour
+	autoWhatsAppTaskDue = 48 * time.Hour
+)
+
+// Open reports whether the task still needs doing
+func (t *LeadTask) Open() bool {
+	return !t.CompletedAt.Valid
+}
+
+// Overdue reports whether an open task is past its due time
+func (t *LeadTask) Overdue(now time.Time) bool {
+	return t.Open() && t.DueAt.Before(now)
+}
+
+// LeadTaskTypeLabel returns the display name of a task type
+func LeadTaskTypeLabel(taskType string) string {
+	switch taskType {
+	case LeadTaskCall:
+		return "Call"
+	case LeadTaskWhatsApp:
+		return "WhatsApp"
+	case LeadTaskOfferDiscount:
+		return "Offer discount"
+	}
+	return taskType
+}
+
+// LeadTaskOutcomeLabel returns the display name of a task outcome
+func LeadTaskOutcomeLabel(outcome string) string {
+	switch outcome {
+	case "reached":
+		return "Reached"
+	case "callback":
+		return "Asked to call back"
+	case "no_answer":
+		return "No answer"
+	case "not_interested":
+		return "Not interested"
+	case LeadTaskOutcomeClosed:
+		return "Closed (lead moved on)"
+	}
+	return outcome
+}
+
+// leadNeedsFollowUp reports whether a lead in status with coursePaid paid is in the follow-up queue:
+// the same TESTED/OFFER_SENT + unpaid rule ComputeLeadFlags uses for hot leads
+func leadNeedsFollowUp(status string, coursePaid int32) bool {
+	stage := MapOldStatusToStage(status)
+	return (stage == StageTested || stage == StageOfferSent) && coursePaid == 0
+}
+
+// autoLeadTaskFor returns the task to create when a lead enters status unpaid, if any
+func autoLeadTaskFor(status string, coursePaid int32, now time.Time) (string, time.Time, bool) {
+	if !leadNeedsFollowUp(status, coursePaid) {
+		return "", time.Time{}, false
+	}
+	if MapOldStatusToStage(status) == StageTested {
+		return LeadTaskCall, now.Add(autoCallTaskDue), true
+	}
+	return LeadTaskWhatsApp, now.Add(autoWhatsAppTaskDue), true
+}
+
+// syncLeadTasksTx keeps follow-up tasks in step with a status change: it opens a task when the lead
+// enters the follow-up stages unpaid (unless one is already open) and closes open tasks once it leaves.
+// The task goes to whoever created the lead if they work the queue, else to the actor, else unassigned.
+func syncLeadTasksTx(q sqlExecer, leadID uuid.UUID, status string, coursePaid int32, actorUserID string, now time.Time) error {
+	if !leadNeedsFollowUp(status, coursePaid) {
+		_, err := q.Exec(`
+			UPDATE lead_tasks SET outcome = $1, completed_at = $2
+			WHERE lead_id = $3 AND completed_at IS NULL
+		`, LeadTaskOutcomeClosed, now, leadID)
+		if err != nil {
+			return fmt.Errorf("failed to close lead tasks: %w", err)
+		}
+		return nil
+	}
+	taskType, dueAt, _ := autoLeadTaskFor(status, coursePaid, now)
+	_, err := q.Exec(`
+		INSERT INTO lead_tasks (lead_id, task_type, due_at, assignee_user_id, auto_created, created_at)
+		SELECT l.id, $2, $3,
+			COALESCE(
+				(SELECT u.id FROM users u WHERE u.id = l.created_by_user_id AND u.role = ANY($4)),
+				(SELECT u.id FROM users u WHERE u.id::text = $5 AND u.role = ANY($4))),
+			true, $6
+		FROM leads l
+		WHERE l.id = $1 AND l.archived_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM lead_tasks t WHERE t.lead_id = l.id AND t.completed_at IS NULL)
+	`, leadID, taskType, dueAt, LeadTaskAssigneeRoles, actorUserID, now)
+	if err != nil {
+		return fmt.Errorf("failed to create follow-up task: %w", err)
+	}
+	return nil
+}
+
+const leadTaskColumns = `
+	t.id, t.lead_id, l.full_name, l.phone, l.status, t.task_type, t.due_at,
+	t.assignee_user_id::text, COALESCE(a.email, ''), t.notes, t.outcome, t.completed_at,
+	COALESCE(c.email, ''), t.auto_created, t.created_at
+	FROM lead_tasks t
+	JOIN leads l ON l.id = t.lead_id
+	LEFT JOIN users a ON a.id = t.assignee_user_id
+	LEFT JOIN users c ON c.id = t.completed_by_user_id`
+
+func scanLeadTasks(rows *sql.Rows) ([]*LeadTask, error) {
+	defer rows.Close()
+	var tasks []*LeadTask
+	for rows.Next() {
+		t := &LeadTask{}
+		err := rows.Scan(&t.ID, &t.LeadID, &t.LeadName, &t.LeadPhone, &t.LeadStatus, &t.TaskType, &t.DueAt,
+			&t.AssigneeUserID, &t.AssigneeEmail, &t.Notes, &t.Outcome, &t.CompletedAt,
+			&t.CompletedByEmail, &t.AutoCreated, &t.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lead task: %w", err)
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+// GetLeadTasks returns a lead's tasks: open ones by due time, then completed ones, latest first
+func GetLeadTasks(leadID uuid.UUID) ([]*LeadTask, error) {
+	rows, err := db.DB.Query(`SELECT `+leadTaskColumns+`
+		WHERE t.lead_id = $1
+		ORDER BY t.completed_at IS NOT NULL, t.completed_at DESC, t.due_at
+	`, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lead tasks: %w", err)
+	}
+	return scanLeadTasks(rows)
+}
+
+// Task queue scopes
+const (
+	LeadTaskScopeMine       = "mine"
+	LeadTaskScopeUnassigned = "unassigned"
+	LeadTaskScopeAll        = "all"
+)
+
+// GetLeadTaskQueue returns open tasks of leads that are not archived, by due time. scope is mine
+// (assigned to userID), unassigned, or all.
+func GetLeadTaskQueue(scope, userID string) ([]*LeadTask, error) {
+	filter := ""
+	args := []interface{}{}
+	switch scope {
+	case LeadTaskScopeUnassigned:
+		filter = " AND t.assignee_user_id IS NULL"
+	case LeadTaskScopeAll:
+	default:
+		filter = " AND t.assignee_user_id::text = $1"
+		args = append(args, userID)
+	}
+	rows, err := db.DB.Query(`SELECT `+leadTaskColumns+`
+		WHERE t.completed_at IS NULL AND l.archived_at IS NULL`+filter+`
+		ORDER BY t.due_at, l.full_name
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task queue: %w", err)
+	}
+	return scanLeadTasks(rows)
+}
+
+// GetLeadTaskAssignees returns the users tasks can be assigned to
+func GetLeadTaskAssignees() ([]*User, error) {
+	return getUsersWithRoles(LeadTaskAssigneeRoles)
+}
+
+// checkLeadTaskAssignee validates an assignee user ID ("" = unassigned)
+func checkLeadTaskAssignee(q sqlExecer, assigneeUserID string) (sql.NullString, error) {
+	if assigneeUserID == "" {
+		return sql.NullString{}, nil
+	}
+	var ok bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id::text = $1 AND role = ANY($2))`,
+		assigneeUserID, LeadTaskAssigneeRoles).Scan(&ok)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to check assignee: %w", err)
+	}
+	if !ok {
+		return sql.NullString{}, &LeadTaskError{Message: "Tasks can only be assigned to admins and moderators."}
+	}
+	return sql.NullString{String: assigneeUserID, Valid: true}, nil
+}
+
+// CreateLeadTask adds a follow-up task to a lead
+func CreateLeadTask(leadID uuid.UUID, taskType string, dueAt time.Time, assigneeUserID, notes, createdByUserID string) error {
+	if !containsString(LeadTaskTypes, taskType) {
+		return &LeadTaskError{Message: "Choose a task type."}
+	}
+	if dueAt.IsZero() {
+		return &LeadTaskError{Message: "Due date and time are required."}
+	}
+	assignee, err := checkLeadTaskAssignee(db.DB, assigneeUserID)
+	if err != nil {
+		return err
+	}
+	var notesVal, createdBy sql.NullString
+	if notes = strings.TrimSpace(notes); notes != "" {
+		notesVal = sql.NullString{String: notes, Valid: true}
+	}
+	if createdByUserID != "" {
+		createdBy = sql.NullString{String: createdByUserID, Valid: true}
+	}
+	_, err = db.DB.Exec(`
+		INSERT INTO lead_tasks (lead_id, task_type, due_at, assignee_user_id, notes, created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, leadID, taskType, dueAt, assignee, notesVal, createdBy)
+	if err != nil {
+		return fmt.Errorf("failed to create lead task: %w", err)
+	}
+	return nil
+}
+
+// CompleteLeadTask logs the outcome of an open task. Notes are appended to the task's own notes.
+func CompleteLeadTask(taskID uuid.UUID, outcome, notes, actorUserID string) error {
+	if !containsString(LeadTaskOutcomes, outcome) {
+		return &LeadTaskError{Message: "Choose an outcome for the task."}
+	}
+	var actor sql.NullString
+	if actorUserID != "" {
+		actor = sql.NullString{String: actorUserID, Valid: true}
+	}
+	res, err := db.DB.Exec(`
+		UPDATE lead_tasks
+		SET outcome = $1, completed_at = NOW(), completed_by_user_id = $2,
+			notes = CASE WHEN $3 = '' THEN notes ELSE CONCAT_WS(E'\n', notes, $3::text) END
+		WHERE id = $4 AND completed_at IS NULL
+	`, outcome, actor, strings.TrimSpace(notes), taskID)
+	if err != nil {
+		return fmt.Errorf("failed to complete lead task: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &LeadTaskError{Message: "This task has already been completed."}
+	}
+	return nil
+}
+
+// AssignLeadTask reassigns an open task ("" = unassigned)
+func AssignLeadTask(taskID uuid.UUID, assigneeUserID string) error {
+	assignee, err := checkLeadTaskAssignee(db.DB, assigneeUserID)
+	if err != nil {
+		return err
+	}
+	res, err := db.DB.Exec(`UPDATE lead_tasks SET assignee_user_id = $1 WHERE id = $2 AND completed_at IS NULL`, assignee, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to assign lead task: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &LeadTaskError{Message: "This task has already been completed."}
+	}
+	return nil
+}
+
+// LastLeadContact returns when a lead was last reached according to its completed tasks
+func LastLeadContact(tasks []*LeadTask) sql.NullTime {
+	var last sql.NullTime
+	for _, t := range tasks {
+		if t.CompletedAt.Valid && t.Outcome.Valid && containsString(leadContactOutcomes, t.Outcome.String) &&
+			(!last.Valid || t.CompletedAt.Time.After(last.Time)) {
+			last = t.CompletedAt
+		}
+	}
+	return last
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestAutoLeadTaskFor(t *testing.T) {
+	now := time.Date(2026, 5, 4, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		status   string
+		paid     int32
+		wantType string
+		wantDue  time.Time
+	}{
+		{"tested", 0, LeadTaskCall, now.Add(24 * time.Hour)},
+		{"offer_sent", 0, LeadTaskWhatsApp, now.Add(48 * time.Hour)},
+		{"booking_confirmed", 0, LeadTaskWhatsApp, now.Add(48 * time.Hour)},
+		{"offer_sent", 500, "", time.Time{}},
+		{"test_booked", 0, "", time.Time{}},
+		{"paid_full", 0, "", time.Time{}},
+		{"cancelled", 0, "", time.Time{}},
+	}
+	for _, c := range cases {
+		taskType, due, ok := autoLeadTaskFor(c.status, c.paid, now)
+		if ok != (c.wantType != "") || taskType != c.wantType || !due.Equal(c.wantDue) {
+			t.Errorf("%s paid %d: got %q due %v (ok=%v), want %q due %v", c.status, c.paid, taskType, due, ok, c.wantType, c.wantDue)
+		}
+	}
+}
+
+func TestComputeLeadFlagsUsesLastContact(t *testing.T) {
+	now := time.Now()
+	daysAgo := func(n int) sql.NullTime { return sql.NullTime{Time: now.AddDate(0, 0, -n), Valid: true} }
+	item := &LeadListItem{
+		Lead:            &Lead{Status: "offer_sent", CreatedAt: now.AddDate(0, 0, -40), UpdatedAt: now},
+		TestDate:        daysAgo(30),
+		StatusChangedAt: daysAgo(20),
+	}
+	ComputeLeadFlags(item)
+	if item.HotLevel != "COOL" || item.DaysSinceLastProgress != 20 {
+		t.Fatalf("expected COOL at 20 days despite a fresh updated_at, got %s at %d", item.HotLevel, item.DaysSinceLastProgress)
+	}
+
+	item.LastContactAt = daysAgo(2)
+	ComputeLeadFlags(item)
+	if item.HotLevel != "HOT" || item.DaysSinceLastProgress != 2 {
+		t.Errorf("expected HOT 2 days after the last contact, got %s at %d", item.HotLevel, item.DaysSinceLastProgress)
+	}
+}
+
+func TestLastLeadContact(t *testing.T) {
+	at := func(day int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2026, 5, day, 12, 0, 0, 0, time.UTC), Valid: true}
+	}
+	outcome := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+	tasks := []*LeadTask{
+		{Outcome: outcome("reached"), CompletedAt: at(3)},
+		{Outcome: outcome("no_answer"), CompletedAt: at(9)},
+		{Outcome: outcome(LeadTaskOutcomeClosed), CompletedAt: at(10)},
+		{Outcome: outcome("callback"), CompletedAt: at(6)},
+		{}, // open
+	}
+	if got := LastLeadContact(tasks); !got.Valid || !got.Time.Equal(at(6).Time) {
+		t.Errorf("expected the callback on day 6 to be the last contact, got %v", got)
+	}
+}
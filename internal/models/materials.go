@@ -0,0 +1,262 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Material formats (materials.format, matching bookings.book_format) and stock movement types
+const (
+	MaterialPrinted = "printed"
+	MaterialPDF     = "pdf"
+
+	StockPurchase   = "purchase"
+	StockShipment   = "shipment"
+	StockReturn     = "return"
+	StockAdjustment = "adjustment"
+)
+
+// IsLowStock reports whether an active printed book is at or below its low-stock threshold
+func (m *Material) IsLowStock() bool {
+	return m.Active && m.Format == MaterialPrinted && m.StockOnHand <= m.LowStockThreshold
+}
+
+// ValidateMaterial checks a catalogue item before it is created. Returns *MaterialError describing the first problem.
+func ValidateMaterial(m *Material) error {
+	if m.Level < 1 || m.Level > 8 {
+		return &MaterialError{Message: "Level must be between 1 and 8."}
+	}
+	if m.Format != MaterialPrinted && m.Format != MaterialPDF {
+		return &MaterialError{Message: "Format must be printed or PDF."}
+	}
+	return validateMaterialDetails(m)
+}
+
+// validateMaterialDetails checks the fields that can change after an item is created
+func validateMaterialDetails(m *Material) error {
+	m.Name = strings.TrimSpace(m.Name)
+	if m.Name == "" {
+		return &MaterialError{Message: "Name is required."}
+	}
+	if m.UnitCost < 0 {
+		return &MaterialError{Message: "Unit cost cannot be negative."}
+	}
+	if m.LowStockThreshold < 0 {
+		return &MaterialError{Message: "Low-stock threshold cannot be negative."}
+	}
+	return nil
+}
+
+const materialColumns = `
+	m.id, m.level, m.format, m.name, m.unit_cost, m.low_stock_threshold, m.active,
+	COALESCE((SELECT SUM(sm.quantity) FROM material_stock_movements sm WHERE sm.material_id = m.id), 0),
+	m.created_at, m.updated_at
+	FROM materials m`
+
+func scanMaterial(row rowScanner) (*Material, error) {
+	m := &Material{}
+	err := row.Scan(&m.ID, &m.Level, &m.Format, &m.Name, &m.UnitCost, &m.LowStockThreshold, &m.Active,
+		&m.StockOnHand, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetMaterials returns the catalogue by level and format, with stock on hand
+func GetMaterials() ([]*Material, error) {
+	rows, err := db.DB.Query(`SELECT ` + materialColumns + ` ORDER BY m.level, m.format`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query materials: %w", err)
+	}
+	defer rows.Close()
+	var materials []*Material
+	for rows.Next() {
+		m, err := scanMaterial(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan material: %w", err)
+		}
+		materials = append(materials, m)
+	}
+	return materials, rows.Err()
+}
+
+// GetLowStockMaterials returns the active printed books at or below their low-stock threshold
+func GetLowStockMaterials() ([]*Material, error) {
+	materials, err := GetMaterials()
+	if err != nil {
+		return nil, err
+	}
+	var low []*Material
+	for _, m := range materials {
+		if m.IsLowStock() {
+			low = append(low, m)
+		}
+	}
+	return low, nil
+}
+
+// CreateMaterial adds a catalogue item. Returns *MaterialError when it is invalid or the level
+// already has a book in that format.
+func CreateMaterial(m *Material) error {
+	if err := ValidateMaterial(m); err != nil {
+		return err
+	}
+	now := time.Now()
+	err := db.DB.QueryRow(`
+		INSERT INTO materials (level, format, name, unit_cost, low_stock_threshold, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, TRUE, $6, $6)
+		ON CONFLICT (level, format) DO NOTHING
+		RETURNING id
+	`, m.Level, m.Format, m.Name, m.UnitCost, m.LowStockThreshold, now).Scan(&m.ID)
+	if err == sql.ErrNoRows {
+		return &MaterialError{Message: fmt.Sprintf("Level %d already has a %s book in the catalogue.", m.Level, m.Format)}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create material: %w", err)
+	}
+	m.Active, m.CreatedAt, m.UpdatedAt = true, now, now
+	return nil
+}
+
+// UpdateMaterial changes a catalogue item's name, unit cost, low-stock threshold and active flag.
+// Level and format are fixed once the item exists.
+func UpdateMaterial(m *Material) error {
+	if err := validateMaterialDetails(m); err != nil {
+		return err
+	}
+	res, err := db.DB.Exec(`
+		UPDATE materials SET name = $1, unit_cost = $2, low_stock_threshold = $3, active = $4, updated_at = $5
+		WHERE id = $6
+	`, m.Name, m.UnitCost, m.LowStockThreshold, m.Active, time.Now(), m.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update material: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &MaterialError{Message: "Material not found."}
+	}
+	return nil
+}
+
+// RecordStockMovement records a purchase (quantity > 0, with the unit cost paid) or a stock count
+// adjustment (quantity of either sign) for a printed book. Shipments and returns are recorded
+// automatically from shipping. Returns *MaterialError when the movement is invalid.
+func RecordStockMovement(materialID uuid.UUID, movementType string, quantity int32, unitCost sql.NullInt32, notes, actorUserID string) error {
+	switch movementType {
+	case StockPurchase:
+		if quantity <= 0 {
+			return &MaterialError{Message: "Purchased quantity must be positive."}
+		}
+		if unitCost.Valid && unitCost.Int32 < 0 {
+			return &MaterialError{Message: "Unit cost cannot be negative."}
+		}
+	case StockAdjustment:
+		if quantity == 0 {
+			return &MaterialError{Message: "Adjustment cannot be zero."}
+		}
+		if strings.TrimSpace(notes) == "" {
+			return &MaterialError{Message: "Give a reason for the adjustment."}
+		}
+		unitCost = sql.NullInt32{}
+	default:
+		return &MaterialError{Message: "Only purchases and adjustments can be recorded by hand."}
+	}
+
+	var format string
+	err := db.DB.QueryRow(`SELECT format FROM materials WHERE id = $1`, materialID).Scan(&format)
+	if err == sql.ErrNoRows {
+		return &MaterialError{Message: "Material not found."}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get material: %w", err)
+	}
+	if format != MaterialPrinted {
+		return &MaterialError{Message: "Only printed books are stocked."}
+	}
+
+	var createdBy sql.NullString
+	if actorUserID != "" {
+		createdBy = sql.NullString{String: actorUserID, Valid: true}
+	}
+	notes = strings.TrimSpace(notes)
+	_, err = db.DB.Exec(`
+		INSERT INTO material_stock_movements (material_id, movement_type, quantity, unit_cost, notes, created_by_user_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, materialID, movementType, quantity, unitCost, sql.NullString{String: notes, Valid: notes != ""}, createdBy, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record stock movement: %w", err)
+	}
+	return nil
+}
+
+// GetStockMovements returns the most recent stock movements, newest first
+func GetStockMovements(limit int) ([]*StockMovement, error) {
+	rows, err := db.DB.Query(`
+		SELECT sm.id, sm.material_id, m.name, sm.movement_type, sm.quantity, sm.unit_cost, sm.lead_id::text,
+			l.full_name, sm.notes, u.email, sm.created_at
+		FROM material_stock_movements sm
+		JOIN materials m ON m.id = sm.material_id
+		LEFT JOIN leads l ON l.id = sm.lead_id
+		LEFT JOIN users u ON u.id = sm.created_by_user_id
+		ORDER BY sm.created_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock movements: %w", err)
+	}
+	defer rows.Close()
+	var movements []*StockMovement
+	for rows.Next() {
+		sm := &StockMovement{}
+		if err := rows.Scan(&sm.ID, &sm.MaterialID, &sm.MaterialName, &sm.MovementType, &sm.Quantity, &sm.UnitCost,
+			&sm.LeadID, &sm.LeadName, &sm.Notes, &sm.CreatedByEmail, &sm.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan stock movement: %w", err)
+		}
+		movements = append(movements, sm)
+	}
+	return movements, rows.Err()
+}
+
+// recordBatchShipmentStockTx takes one printed book of each student's assigned level out of stock
+// for every shipment in the batch. Students whose level has no active printed book in the catalogue
+// are skipped; stock may go negative so a miscount never blocks sending.
+func recordBatchShipmentStockTx(tx *sql.Tx, batchID uuid.UUID, notes string, now time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO material_stock_movements (material_id, movement_type, quantity, lead_id, ref_key, notes, created_at)
+		SELECT m.id, $2, -1, s.lead_id, 'shipment:' || s.lead_id::text || ':' || s.batch_id::text, $3, $4
+		FROM shipping s
+		JOIN placement_tests pt ON pt.lead_id = s.lead_id
+		JOIN materials m ON m.level = pt.assigned_level AND m.format = $5 AND m.active
+		WHERE s.batch_id = $1
+		ON CONFLICT (ref_key) DO NOTHING
+	`, batchID, StockShipment, notes, now, MaterialPrinted)
+	if err != nil {
+		return fmt.Errorf("failed to take shipped books out of stock: %w", err)
+	}
+	return nil
+}
+
+// recordReturnStockTx puts a returned book back into the stock its shipment was taken from.
+// No-op when the shipment never moved stock.
+func recordReturnStockTx(tx *sql.Tx, leadID uuid.UUID, batchID sql.NullString, now time.Time) error {
+	if !batchID.Valid {
+		return nil
+	}
+	suffix := leadID.String() + ":" + batchID.String
+	_, err := tx.Exec(`
+		INSERT INTO material_stock_movements (material_id, movement_type, quantity, lead_id, ref_key, notes, created_at)
+		SELECT material_id, $1, 1, lead_id, $2, notes, $3
+		FROM material_stock_movements
+		WHERE ref_key = $4
+		ON CONFLICT (ref_key) DO NOTHING
+	`, StockReturn, "return:"+suffix, now, "shipment:"+suffix)
+	if err != nil {
+		return fmt.Errorf("failed to put returned book back in stock: %w", err)
+	}
+	return nil
+}
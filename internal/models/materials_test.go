@@ -0,0 +1,58 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMaterialIsLowStock(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Material
+		want bool
+	}{
+		{"above threshold", Material{Format: MaterialPrinted, Active: true, StockOnHand: 6, LowStockThreshold: 5}, false},
+		{"at threshold", Material{Format: MaterialPrinted, Active: true, StockOnHand: 5, LowStockThreshold: 5}, true},
+		{"oversold", Material{Format: MaterialPrinted, Active: true, StockOnHand: -1, LowStockThreshold: 0}, true},
+		{"inactive", Material{Format: MaterialPrinted, Active: false, StockOnHand: 0, LowStockThreshold: 5}, false},
+		{"pdf is not stocked", Material{Format: MaterialPDF, Active: true, StockOnHand: 0, LowStockThreshold: 5}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.m.IsLowStock(); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateMaterial(t *testing.T) {
+	valid := func() *Material {
+		return &Material{Level: 3, Format: MaterialPrinted, Name: " Level 3 Student Book ", UnitCost: 150, LowStockThreshold: 5}
+	}
+	m := valid()
+	if err := ValidateMaterial(m); err != nil {
+		t.Fatalf("valid material: unexpected error %v", err)
+	}
+	if m.Name != "Level 3 Student Book" {
+		t.Errorf("name not trimmed: %q", m.Name)
+	}
+
+	tests := []struct {
+		name   string
+		change func(m *Material)
+	}{
+		{"level too low", func(m *Material) { m.Level = 0 }},
+		{"level too high", func(m *Material) { m.Level = 9 }},
+		{"unknown format", func(m *Material) { m.Format = "audio" }},
+		{"blank name", func(m *Material) { m.Name = "  " }},
+		{"negative cost", func(m *Material) { m.UnitCost = -1 }},
+		{"negative threshold", func(m *Material) { m.LowStockThreshold = -1 }},
+	}
+	for _, tt := range tests {
+		m := valid()
+		tt.change(m)
+		var materialErr *MaterialError
+		if err := ValidateMaterial(m); !errors.As(err, &materialErr) {
+			t.Errorf("%s: got %v, want *MaterialError", tt.name, err)
+		}
+	}
+}
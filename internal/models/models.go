@@ -27,6 +27,11 @@ type Lead struct {
 	LevelsConsumed       sql.NullInt32  // Levels consumed (when rounds start)
 	BundleType           sql.NullString // none, single, bundle2, bundle3, bundle4
 	HighPriorityFollowUp bool           // Set by mentor_head on round close for students with no remaining credits
+	ReferredByLeadID     sql.NullString // Lead who referred this one
+	ReferredByName       sql.NullString // Joined referrer's full_name (GetLeadByID only)
+	ArchivedAt           sql.NullTime   // Set while the lead is archived (hidden from the lead list)
+	ArchivedByEmail      string         // Joined archiving user's email (GetLeadByID only)
+	ArchiveReason        sql.NullString
 	CreatedByUserID      sql.NullString
 	CreatedAt            time.Time
 	UpdatedAt            time.Time
@@ -41,6 +46,7 @@ type PlacementTest struct {
 	AssignedLevel              sql.NullInt32
 	TestNotes                  sql.NullString
 	RunByUserID                sql.NullString
+	SlotID                     sql.NullString // placement_test_slots.id when booked through the calendar
 	PlacementTestFee           sql.NullInt32
 	PlacementTestFeePaid       sql.NullInt32
 	PlacementTestPaymentDate   sql.NullTime
@@ -49,14 +55,25 @@ type PlacementTest struct {
 }
 
 type Offer struct {
-	ID            uuid.UUID
-	LeadID        uuid.UUID
-	BundleLevels  sql.NullInt32
-	BasePrice     sql.NullInt32
-	DiscountValue sql.NullInt32
-	DiscountType  sql.NullString
-	FinalPrice    sql.NullInt32
-	UpdatedAt     time.Time
+	ID                       uuid.UUID
+	LeadID                   uuid.UUID
+	BundleLevels             sql.NullInt32
+	BasePrice                sql.NullInt32
+	DiscountValue            sql.NullInt32
+	DiscountType             sql.NullString
+	FinalPrice               sql.NullInt32
+	PricePlanID              sql.NullString // price_plans.id the bundle was priced from
+	PromoCodeID              sql.NullString // promo code the discount came from; NULL for a manual discount
+	PromoCode                string         // joined promo_codes.code
+	PromoRedeemedAt          sql.NullTime   // set when the offer is sent with the promo code
+	DiscountSetByUserID      sql.NullString // who last changed the price or discount (handlers set it before saving)
+	DiscountApprovedByUserID sql.NullString
+	DiscountApprovedByEmail  string
+	DiscountApprovedAt       sql.NullTime
+	ExpiresOn                sql.NullTime  // from the latest offer letter
+	ReferralDiscount         sql.NullInt32 // taken off because the lead was referred (included in FinalPrice)
+	ReferralCredit           sql.NullInt32 // the lead's own referral credit applied (included in FinalPrice)
+	UpdatedAt                time.Time
 }
 
 type Booking struct {
@@ -94,11 +111,118 @@ type Scheduling struct {
 type Shipping struct {
 	ID             uuid.UUID
 	LeadID         uuid.UUID
-	ShipmentStatus sql.NullString
-	ShipmentDate   sql.NullTime
+	ShipmentStatus sql.NullString // pending, packed, sent, delivered, returned
+	ShipmentDate   sql.NullTime   // date sent
+	BatchID        sql.NullString // shipment_batches.id once packed
+	TrackingNumber sql.NullString
+	ShipToAddress  sql.NullString // booking address snapshot taken when packed
+	ShipToCity     sql.NullString
+	PackedAt       sql.NullTime
+	DeliveredAt    sql.NullTime
+	ReturnedAt     sql.NullTime
 	UpdatedAt      time.Time
 }
 
+// ShipmentBatch is a set of printed books packed together and handed to one courier
+type ShipmentBatch struct {
+	ID              uuid.UUID
+	BatchNumber     int32
+	Courier         string // bosta, aramex, egypt_post, mylerz, other
+	Status          string // packing, sent
+	ShippingCost    sql.NullInt32
+	PaymentMethod   sql.NullString
+	SentOn          sql.NullTime
+	CreatedByUserID sql.NullString
+	CreatedAt       time.Time
+	ShipmentCount   int // filled by GetShipmentBatches
+	Shipments       []*Shipment
+}
+
+// Shipment is a lead's printed-book shipment with the student and booking details the shipping pages show
+type Shipment struct {
+	Shipping
+	FullName       string
+	Phone          string
+	Address        sql.NullString // booking's current address
+	City           sql.NullString
+	DeliveryNotes  sql.NullString
+	AddressProblem string        // why the address cannot be shipped to; empty when it can
+	Level          sql.NullInt32 // placement test assigned level
+	StockOnHand    sql.NullInt32 // printed book stock for Level; null when the catalogue has no book for it
+}
+
+// Material is a catalogue item: the book for one level in one format
+type Material struct {
+	ID                uuid.UUID
+	Level             int32
+	Format            string // printed, pdf
+	Name              string
+	UnitCost          int32
+	LowStockThreshold int32
+	Active            bool
+	StockOnHand       int32 // sum of stock movements, filled by GetMaterials
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// StockMovement is one change to a material's stock
+type StockMovement struct {
+	ID             uuid.UUID
+	MaterialID     uuid.UUID
+	MaterialName   string
+	MovementType   string // purchase, shipment, return, adjustment
+	Quantity       int32  // positive adds stock, negative takes it out
+	UnitCost       sql.NullInt32
+	LeadID         sql.NullString
+	LeadName       sql.NullString
+	Notes          sql.NullString
+	CreatedByEmail sql.NullString
+	CreatedAt      time.Time
+}
+
+// LeadSearchResult is one match for the referrer picker
+type LeadSearchResult struct {
+	ID       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+	Phone    string    `json:"phone"`
+	Status   string    `json:"status"`
+}
+
+// ReferralReward is what a referrer earned when a lead they referred paid in full
+type ReferralReward struct {
+	ID             uuid.UUID
+	ReferrerLeadID uuid.UUID
+	ReferredLeadID uuid.UUID
+	ReferredName   string
+	RewardType     string // credit, cashback
+	Amount         int32
+	IssuedAt       time.Time
+	SettledAt      sql.NullTime   // credit applied to the referrer's offer, or cash-back paid out
+	PaymentMethod  sql.NullString // cash-back only
+}
+
+// Referral is a lead referred by another lead, with the reward it earned once paid in full
+type Referral struct {
+	LeadID    uuid.UUID
+	FullName  string
+	Phone     string
+	Status    string
+	CreatedAt time.Time
+	Reward    *ReferralReward
+}
+
+// ReferralLeaderboardRow is one referrer's totals on the referral report
+type ReferralLeaderboardRow struct {
+	LeadID          uuid.UUID
+	FullName        string
+	Phone           string
+	Referred        int   // leads referred
+	Converted       int   // referred leads that paid in full
+	Rewarded        int32 // rewards issued (EGP)
+	CreditAvailable int32 // credit not yet applied to the referrer's offer
+	CashbackOwed    int32 // cash-back not yet paid out
+}
+
 type LeadDetail struct {
 	Lead          *Lead
 	PlacementTest *PlacementTest
@@ -122,6 +246,8 @@ type LeadListItem struct {
 	AmountPaid            sql.NullInt32 // For checking if paid
 	FinalPrice            sql.NullInt32 // For computing payment state
 	RemainingBalance      sql.NullInt32 // For computing payment state
+	StatusChangedAt       sql.NullTime  // Last pipeline status change, for computing days since progress
+	LastContactAt         sql.NullTime  // Last completed follow-up task that reached the lead
 }
 
 // ClassGroup represents a group of students with same level+days+time
@@ -166,12 +292,48 @@ type ClassStudent struct {
 	AvailableGroups []int32 // Available group indices for move (computed in handler)
 }
 
+// ClassEnrolment represents a student's membership in a class for a round
+type ClassEnrolment struct {
+	ID         uuid.UUID
+	LeadID     uuid.UUID
+	ClassKey   string
+	Round      sql.NullInt32
+	JoinedAt   time.Time
+	LeftAt     sql.NullTime
+	LeftReason sql.NullString // moved | round_closed | removed | paused
+	Outcome    sql.NullString // promote | repeat, set when the round closes
+	Absences   sql.NullInt32
+	Grade      sql.NullString
+	NextLevel  sql.NullInt32  // level the student continues at; NULL after the last level
+	FollowOn   sql.NullString // replaced | renewal_offer | completed | skipped
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// RenewalOffer is the pre-filled offer for a student who finished a round without credits left
+type RenewalOffer struct {
+	ID            uuid.UUID
+	LeadID        uuid.UUID
+	ClassKey      string
+	Level         int32
+	BundleLevels  int32
+	PricePlanID   sql.NullString
+	BasePrice     sql.NullInt32
+	FinalPrice    sql.NullInt32
+	Status        string // open, accepted, declined, merged
+	PaymentMethod sql.NullString
+	PaymentDate   sql.NullTime
+	CreatedAt     time.Time
+	ClosedAt      sql.NullTime
+	ClosedByEmail string
+}
+
 // Transaction represents a financial transaction (IN or OUT)
 type Transaction struct {
 	ID              uuid.UUID
 	TransactionDate time.Time
 	TransactionType string // "IN" or "OUT"
-	Category        string // placement_test, course_payment, teacher_salary, refund, ads, rent, software, moderator, content_creator, other
+	Category        string // placement_test, course_payment, teacher_salary, refund, shipping, ads, rent, software, moderator, content_creator, other
 	Amount          int32
 	PaymentMethod   sql.NullString // vodafone_cash, bank_transfer, paypal, other
 	LeadID          sql.NullString // Optional: link to lead for income/refunds (stored as UUID in DB, but we use string for null handling)
@@ -200,6 +362,67 @@ type LeadPayment struct {
 	UpdatedAt     time.Time
 }
 
+// LeadInstallment is one due payment of a student's installment plan. PaidAmount is the part of
+// the net course payments matched to it (earliest due first).
+type LeadInstallment struct {
+	ID         uuid.UUID
+	LeadID     uuid.UUID
+	Seq        int
+	Amount     int32
+	DueDate    time.Time
+	PaidAmount int32
+	PaidAt     sql.NullTime
+	CreatedAt  time.Time
+}
+
+// OverdueInstallment is an installment past its due date with money outstanding, for the finance list
+type OverdueInstallment struct {
+	LeadInstallment
+	FullName    string
+	Phone       string
+	Status      string
+	Outstanding int32
+	DaysOverdue int
+}
+
+// WaitlistStudent is a waiting (or ready but not yet on the classes board) student with the
+// preferred schedule and payment facts the waitlist is ranked and grouped by
+type WaitlistStudent struct {
+	LeadID              uuid.UUID
+	FullName            string
+	Phone               string
+	Status              string
+	AssignedLevel       sql.NullInt32
+	ClassDays           sql.NullString // preferred days, empty when flexible
+	ClassTime           sql.NullString // preferred time, empty when flexible
+	FirstPaymentDate    sql.NullTime
+	WaitingSince        time.Time
+	FinalPrice          int32
+	TotalCoursePaid     int32
+	HasInstallmentPlan  bool
+	OverdueInstallments int
+	Rank                int    // position in its waitlist group, 1 = first in line
+	Blocker             string // why the student cannot be scheduled yet; empty when eligible
+}
+
+// WaitlistGroup is the waitlist for one assigned level and preferred schedule
+type WaitlistGroup struct {
+	Level     int32 // 0 when no level is assigned yet
+	ClassDays string
+	ClassTime string
+	Students  []*WaitlistStudent
+}
+
+// GroupSuggestion is a class slot with enough eligible waiting students to fill a group
+type GroupSuggestion struct {
+	Level        int32
+	ClassDays    string
+	ClassTime    string
+	Students     []*WaitlistStudent // the first FullClassGroupSize candidates in waitlist order
+	Candidates   int                // all eligible students who could take this slot
+	ExactMatches int                // candidates who asked for exactly this days and time
+}
+
 // PaymentMethodBalance holds IN/OUT/Net for a payment-method bucket (e.g. Cash vs Bank)
 type PaymentMethodBalance struct {
 	Label string
@@ -375,6 +598,8 @@ type AbsenceFeedItem struct {
 	StudentID     uuid.UUID     `json:"studentId"`
 	StudentName   string        `json:"studentName"`
 	StudentPhone  string        `json:"studentPhone"`
+	GuardianName  string        `json:"guardianName,omitempty"` // the student's payer, contacted about absences
+	GuardianPhone string        `json:"guardianPhone,omitempty"`
 	Status        string        `json:"status"` // PRESENT, ABSENT, LATE, EXCUSED
 	MarkedBy      string        `json:"markedBy"`
 	MarkedAt      time.Time     `json:"markedAt"`
@@ -396,6 +621,8 @@ type FollowUpListItem struct {
 	LeadID           uuid.UUID  `json:"lead_id"`
 	StudentName      string     `json:"student_name"`
 	StudentPhone     string     `json:"student_phone"`
+	GuardianName     string     `json:"guardian_name,omitempty"` // the student's payer, contacted about absences
+	GuardianPhone    string     `json:"guardian_phone,omitempty"`
 	SessionNumber    int32      `json:"session_number"`
 	AttendanceStatus string     `json:"attendance_status"`
 	Note             string     `json:"note"`
@@ -404,3 +631,289 @@ type FollowUpListItem struct {
 	Resolved         bool       `json:"resolved"`
 	ResolvedAt       *time.Time `json:"resolved_at,omitempty"`
 }
+
+// LeadStatusChange represents one entry in a lead's status history
+type LeadStatusChange struct {
+	ID              uuid.UUID `json:"id"`
+	LeadID          uuid.UUID `json:"lead_id"`
+	PreviousStatus  string    `json:"previous_status,omitempty"` // empty for the first entry
+	NewStatus       string    `json:"new_status"`
+	Source          string    `json:"source"` // manual | auto
+	Reason          string    `json:"reason"`
+	ChangedByUserID string    `json:"changed_by_user_id,omitempty"` // empty for system changes
+	ChangedByEmail  string    `json:"changed_by_email"`
+	ChangedAt       time.Time `json:"changed_at"`
+}
+
+// LeadMerge is an audit record of a duplicate lead folded into a surviving lead
+type LeadMerge struct {
+	ID             uuid.UUID
+	SurvivorLeadID uuid.UUID
+	MergedLeadID   uuid.UUID // the deleted lead
+	MergedName     string
+	MergedPhone    string
+	MovedRows      map[string]int64 // rows re-parented per table
+	MergedByEmail  string
+	MergedAt       time.Time
+}
+
+type PlacementTestSlot struct {
+	ID             uuid.UUID
+	SlotDate       time.Time
+	SlotTime       string // HH:MM
+	TestType       string // online | live
+	ExaminerUserID uuid.UUID
+	ExaminerEmail  string
+	Capacity       int
+	Booked         int
+	Notes          sql.NullString
+	CreatedAt      time.Time
+	Bookings       []*PlacementTestSlotBooking // filled by GetExaminerAgenda
+}
+
+type PlacementTestSlotBooking struct {
+	LeadID        uuid.UUID
+	FullName      string
+	Phone         string
+	Status        string
+	AssignedLevel sql.NullInt32
+}
+
+type PlacementQuestion struct {
+	ID            uuid.UUID
+	Skill         string // grammar | vocabulary | reading
+	Level         int    // 1-8
+	Prompt        string
+	Options       []string
+	CorrectOption int // index into Options
+	Active        bool
+	CreatedAt     time.Time
+}
+
+type OnlinePlacementTest struct {
+	ID               uuid.UUID
+	LeadID           uuid.UUID
+	Token            string
+	Status           string // pending | in_progress | submitted | approved | rejected | revoked
+	QuestionIDs      []uuid.UUID
+	Answers          map[string]int // question id -> chosen option index
+	DurationMinutes  int
+	ExpiresAt        time.Time
+	StartedAt        sql.NullTime
+	SubmittedAt      sql.NullTime
+	Score            *PlacementScore
+	ProposedLevel    sql.NullInt32
+	ApprovedLevel    sql.NullInt32
+	ReviewedByUserID sql.NullString
+	ReviewedByEmail  string
+	ReviewedAt       sql.NullTime
+	CreatedAt        time.Time
+}
+
+// PlacementScore is the score breakdown of a submitted online placement test
+type PlacementScore struct {
+	Correct       int                    `json:"correct"`
+	Total         int                    `json:"total"`
+	Levels        []PlacementScoreBucket `json:"levels"`
+	Skills        []PlacementScoreBucket `json:"skills"`
+	ProposedLevel int                    `json:"proposed_level"`
+	Late          bool                   `json:"late"` // submitted after the time limit (plus grace)
+}
+
+type PlacementScoreBucket struct {
+	Key     string `json:"key"` // level number or skill name
+	Correct int    `json:"correct"`
+	Total   int    `json:"total"`
+}
+
+// PricePlan is a published price list. Bundles holds the explicit bundle prices by number of levels;
+// other bundle sizes cost PerLevelPrice per level.
+type PricePlan struct {
+	ID             uuid.UUID
+	Name           string
+	Currency       string
+	EffectiveFrom  time.Time
+	PerLevelPrice  int32
+	Bundles        map[int32]int32
+	CreatedByEmail string
+	CreatedAt      time.Time
+	OfferCount     int
+}
+
+// PromoCode is a pre-approved discount that can be applied to offers. EligibleBundles empty means
+// every bundle; Uses counts offers sent with the code.
+type PromoCode struct {
+	ID              uuid.UUID
+	Code            string
+	DiscountType    string // amount | percent
+	DiscountValue   int32
+	ValidFrom       time.Time
+	ValidUntil      sql.NullTime // inclusive
+	MaxUses         sql.NullInt32
+	EligibleBundles []int32
+	Source          sql.NullString
+	Campaign        sql.NullString
+	Active          bool
+	CreatedByEmail  string
+	CreatedAt       time.Time
+	Uses            int
+}
+
+// OfferLetter is one issued version of a lead's offer document. HTML and PDF are only loaded
+// by GetOfferLetter.
+type OfferLetter struct {
+	ID            uuid.UUID
+	LeadID        uuid.UUID
+	Version       int
+	BundleLevels  sql.NullInt32
+	AssignedLevel sql.NullInt32
+	FinalPrice    int32
+	Currency      string
+	ExpiresOn     time.Time
+	HTML          string
+	PDF           []byte
+	IssuedByEmail string
+	IssuedAt      time.Time
+}
+
+// PriceBundle is one bundle size of a price plan with its price
+type PriceBundle struct {
+	Levels int32
+	Price  int32
+}
+
+type LeadSource struct {
+	ID        uuid.UUID
+	Name      string
+	Active    bool
+	SortOrder int
+	LeadCount int
+}
+
+// LeadTask is a follow-up task (call, WhatsApp, discount offer) for a lead
+type LeadTask struct {
+	ID               uuid.UUID
+	LeadID           uuid.UUID
+	LeadName         string
+	LeadPhone        string
+	LeadStatus       string
+	TaskType         string // call | whatsapp | offer_discount
+	DueAt            time.Time
+	AssigneeUserID   sql.NullString
+	AssigneeEmail    string
+	Notes            sql.NullString
+	Outcome          sql.NullString // reached | callback | no_answer | not_interested | closed
+	CompletedAt      sql.NullTime
+	CompletedByEmail string
+	AutoCreated      bool
+	CreatedAt        time.Time
+}
+
+// ArchivedLead is one row of the archived leads page
+type ArchivedLead struct {
+	ID              uuid.UUID
+	FullName        string
+	Phone           string
+	Status          string
+	ArchivedAt      time.Time
+	ArchivedByEmail string
+	Reason          string
+	HasFinance      bool // has finance transactions, so the retention purge keeps it
+}
+
+// LeadListView is a saved view of the pre-enrolment list. OwnerUserID is empty for shared views;
+// Count is filled by CountLeadListViews.
+type LeadListView struct {
+	ID               uuid.UUID
+	Name             string
+	OwnerUserID      sql.NullString
+	Status           string
+	Search           string
+	Payment          string
+	Hot              string
+	FollowUp         string
+	IncludeCancelled bool
+	Sort             string
+	Columns          []string
+	DefaultForRole   sql.NullString
+	CreatedAt        time.Time
+	Count            int
+}
+
+// LeadCustomField is an admin-defined extra lead field. RequiredFromStage is the stage from which a
+// lead needs a value (empty when optional); LeadCount counts leads with a value.
+type LeadCustomField struct {
+	ID                uuid.UUID
+	Key               string
+	Label             string
+	FieldType         string
+	Options           []string // select fields only
+	RequiredFromStage string
+	Active            bool
+	SortOrder         int
+	LeadCount         int
+}
+
+// Payer is the parent or guardian who pays for one or more leads. LeadCount and Remaining (what
+// the family still owes on its offers) are filled by GetPayers only.
+type Payer struct {
+	ID           uuid.UUID
+	FullName     string
+	Phone        string
+	Relationship string
+	Notes        string
+	CreatedAt    time.Time
+	LeadCount    int
+	Remaining    int32
+}
+
+// FamilyMember is one of a payer's leads with its course balance
+type FamilyMember struct {
+	LeadID       uuid.UUID
+	FullName     string
+	Phone        string
+	Status       string
+	FinalPrice   int32 // 0 until the lead has a priced offer
+	Paid         int32 // net course paid
+	BundleLevels sql.NullInt32
+}
+
+// PayerPayment is one payment received from a payer and how it was split across the family
+type PayerPayment struct {
+	ID            uuid.UUID
+	PayerID       uuid.UUID
+	Amount        int32
+	PaymentMethod string
+	PaymentDate   time.Time
+	Notes         string
+	CreatedAt     time.Time
+	Allocations   []*PayerAllocation
+}
+
+// PayerAllocation is the part of a payer payment credited to one lead (one lead_payments row)
+type PayerAllocation struct {
+	LeadID   uuid.UUID
+	FullName string
+	Kind     string // deposit, full_payment or top_up
+	Amount   int32
+}
+
+// LeadPause is one pause of a paid student: why, when they expect to return and the
+// level credits frozen while they are away
+type LeadPause struct {
+	ID                 uuid.UUID
+	LeadID             uuid.UUID
+	LeadName           string
+	LeadPhone          string
+	AssignedLevel      sql.NullInt32
+	PreviousStatus     string
+	ClassKey           sql.NullString
+	Reason             string
+	ExpectedReturnDate sql.NullTime
+	FrozenCredits      int32
+	PausedAt           time.Time
+	PausedByEmail      string
+	EndedAt            sql.NullTime
+	EndedByEmail       string
+	Outcome            sql.NullString // resumed | cancelled | merged
+}
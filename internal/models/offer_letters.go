@@ -0,0 +1,254 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Settings keys for offer letters
+const (
+	OfferValidityDaysKey        = "offer_validity_days"        // days an offer stays open after it is sent
+	OfferPaymentInstructionsKey = "offer_payment_instructions" // payment methods printed on the letter
+)
+
+const (
+	defaultOfferValidityDays = 7
+	maxOfferValidityDays     = 60
+)
+
+// OfferLetterSettings are the admin-editable parts of every offer letter
+type OfferLetterSettings struct {
+	ValidityDays        int
+	PaymentInstructions string
+}
+
+// OfferExpiryDate is the last day an offer issued at issuedAt can be taken up
+func OfferExpiryDate(issuedAt time.Time, validityDays int) time.Time {
+	y, m, d := issuedAt.Date()
+	return time.Date(y, m, d+validityDays, 0, 0, 0, 0, issuedAt.Location())
+}
+
+// GetOfferLetterSettings returns the offer validity and payment instructions, with defaults for missing rows
+func GetOfferLetterSettings() (*OfferLetterSettings, error) {
+	s := &OfferLetterSettings{ValidityDays: defaultOfferValidityDays}
+	rows, err := db.DB.Query(`SELECT key, value FROM settings WHERE key IN ($1, $2)`, OfferValidityDaysKey, OfferPaymentInstructionsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offer letter settings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan offer letter setting: %w", err)
+		}
+		switch key {
+		case OfferValidityDaysKey:
+			if days, err := strconv.Atoi(value); err == nil && days > 0 {
+				s.ValidityDays = days
+			}
+		case OfferPaymentInstructionsKey:
+			s.PaymentInstructions = value
+		}
+	}
+	return s, rows.Err()
+}
+
+// SetOfferLetterSettings saves the offer validity (1-60 days) and payment instructions.
+// Returns *OfferLetterError when a value is out of range.
+func SetOfferLetterSettings(s OfferLetterSettings) error {
+	if s.ValidityDays < 1 || s.ValidityDays > maxOfferValidityDays {
+		return &OfferLetterError{Message: fmt.Sprintf("Offer validity must be between 1 and %d days.", maxOfferValidityDays)}
+	}
+	s.PaymentInstructions = strings.TrimSpace(s.PaymentInstructions)
+	if s.PaymentInstructions == "" {
+		return &OfferLetterError{Message: "Payment instructions are required."}
+	}
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	for key, value := range map[string]string{
+		OfferValidityDaysKey:        strconv.Itoa(s.ValidityDays),
+		OfferPaymentInstructionsKey: s.PaymentInstructions,
+	} {
+		_, err := tx.Exec(`
+			INSERT INTO settings (key, value, updated_at) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
+		`, key, value, now)
+		if err != nil {
+			return fmt.Errorf("failed to save %s: %w", key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// OfferLetterBuilder renders a lead's current offer as a letter issued at now. The handlers package
+// owns the letter templates and registers its builder with SetOfferLetterBuilder.
+type OfferLetterBuilder func(detail *LeadDetail, plan *PricePlan, settings *OfferLetterSettings, now time.Time) (*OfferLetter, error)
+
+var offerLetterBuilder OfferLetterBuilder
+
+// SetOfferLetterBuilder sets the builder effectIssueOfferLetter renders letters with
+func SetOfferLetterBuilder(b OfferLetterBuilder) {
+	offerLetterBuilder = b
+}
+
+// effectIssueOfferLetter issues a letter for the offer being sent, which also restarts its expiry.
+// Does nothing when no builder is registered (tools that run without the web handlers).
+func effectIssueOfferLetter(q sqlExecer, leadID uuid.UUID, actorUserID string, now time.Time) error {
+	if offerLetterBuilder == nil {
+		return nil
+	}
+	detail, err := getLeadDetail(q, leadID)
+	if err != nil {
+		return err
+	}
+	plan, err := getOfferPricePlan(q, leadID)
+	if err != nil {
+		return err
+	}
+	settings, err := GetOfferLetterSettings()
+	if err != nil {
+		return err
+	}
+	letter, err := offerLetterBuilder(detail, plan, settings, now)
+	if err != nil {
+		return err
+	}
+	return issueOfferLetter(q, letter, actorUserID)
+}
+
+// issueOfferLetter stores l as the lead's next letter version and moves the offer's expiry to l.ExpiresOn.
+// ID, Version and IssuedAt are set on l. Returns *OfferLetterError when the lead has no offer.
+func issueOfferLetter(q sqlExecer, l *OfferLetter, actorUserID string) error {
+	// Locking the offer serialises version numbers per lead
+	var offerID uuid.UUID
+	err := q.QueryRow(`SELECT id FROM offers WHERE lead_id = $1 FOR UPDATE`, l.LeadID).Scan(&offerID)
+	if err == sql.ErrNoRows {
+		return &OfferLetterError{Message: "This lead has no offer."}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock offer: %w", err)
+	}
+
+	var issuedBy sql.NullString
+	if actorUserID != "" {
+		issuedBy = sql.NullString{String: actorUserID, Valid: true}
+	}
+	err = q.QueryRow(`
+		INSERT INTO offer_letters (lead_id, version, bundle_levels, assigned_level, final_price, currency, expires_on, html, pdf, issued_by_user_id)
+		VALUES ($1, (SELECT COALESCE(MAX(version), 0) + 1 FROM offer_letters WHERE lead_id = $1), $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, version, issued_at
+	`, l.LeadID, l.BundleLevels, l.AssignedLevel, l.FinalPrice, l.Currency, l.ExpiresOn, l.HTML, l.PDF, issuedBy).Scan(&l.ID, &l.Version, &l.IssuedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert offer letter: %w", err)
+	}
+	if _, err := q.Exec(`UPDATE offers SET expires_on = $1 WHERE id = $2`, l.ExpiresOn, offerID); err != nil {
+		return fmt.Errorf("failed to set offer expiry: %w", err)
+	}
+	return nil
+}
+
+// GetOfferLetters lists the lead's issued letters, newest first, without their documents
+func GetOfferLetters(leadID uuid.UUID) ([]*OfferLetter, error) {
+	rows, err := db.DB.Query(`
+		SELECT ol.id, ol.lead_id, ol.version, ol.bundle_levels, ol.assigned_level, ol.final_price, ol.currency,
+		       ol.expires_on, COALESCE(u.email, ''), ol.issued_at
+		FROM offer_letters ol
+		LEFT JOIN users u ON u.id = ol.issued_by_user_id
+		WHERE ol.lead_id = $1
+		ORDER BY ol.issued_at DESC
+	`, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offer letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []*OfferLetter
+	for rows.Next() {
+		l := &OfferLetter{}
+		if err := rows.Scan(&l.ID, &l.LeadID, &l.Version, &l.BundleLevels, &l.AssignedLevel, &l.FinalPrice, &l.Currency,
+			&l.ExpiresOn, &l.IssuedByEmail, &l.IssuedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan offer letter: %w", err)
+		}
+		letters = append(letters, l)
+	}
+	return letters, rows.Err()
+}
+
+// GetOfferLetter returns one of the lead's letters with its HTML and PDF, or nil when it does not exist
+func GetOfferLetter(leadID, letterID uuid.UUID) (*OfferLetter, error) {
+	l := &OfferLetter{}
+	err := db.DB.QueryRow(`
+		SELECT ol.id, ol.lead_id, ol.version, ol.bundle_levels, ol.assigned_level, ol.final_price, ol.currency,
+		       ol.expires_on, ol.html, ol.pdf, COALESCE(u.email, ''), ol.issued_at
+		FROM offer_letters ol
+		LEFT JOIN users u ON u.id = ol.issued_by_user_id
+		WHERE ol.lead_id = $1 AND ol.id = $2
+	`, leadID, letterID).Scan(&l.ID, &l.LeadID, &l.Version, &l.BundleLevels, &l.AssignedLevel, &l.FinalPrice, &l.Currency,
+		&l.ExpiresOn, &l.HTML, &l.PDF, &l.IssuedByEmail, &l.IssuedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offer letter: %w", err)
+	}
+	return l, nil
+}
+
+// ExpireOffers moves leads whose offer expired before today and who have paid nothing back to "tested"
+// through EventExpireOffer. Leads the pipeline rejects (e.g. a payment arrived) and archived leads are skipped.
+// Returns the number of leads moved.
+func ExpireOffers(now time.Time) (int, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	rows, err := db.DB.Query(`
+		SELECT l.id, o.expires_on
+		FROM leads l
+		JOIN offers o ON o.lead_id = l.id
+		WHERE l.status IN ('offer_sent', 'booking_confirmed') AND o.expires_on < $1 AND l.archived_at IS NULL
+	`, today)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired offers: %w", err)
+	}
+	type expired struct {
+		leadID    uuid.UUID
+		expiresOn time.Time
+	}
+	var due []expired
+	for rows.Next() {
+		var e expired
+		if err := rows.Scan(&e.leadID, &e.expiresOn); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan expired offer: %w", err)
+		}
+		due = append(due, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read expired offers: %w", err)
+	}
+
+	moved := 0
+	for _, e := range due {
+		reason := fmt.Sprintf("Offer expired unpaid on %s", e.expiresOn.Format("02 Jan 2006"))
+		err := ApplyLeadEvent(e.leadID, EventExpireOffer, RoleSystem, "", reason)
+		var transitionErr *LeadTransitionError
+		if errors.As(err, &transitionErr) {
+			continue
+		}
+		if err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
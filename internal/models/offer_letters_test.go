@@ -0,0 +1,25 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOfferExpiryDate(t *testing.T) {
+	cairo := time.FixedZone("EET", 2*60*60)
+	tests := []struct {
+		issued time.Time
+		days   int
+		want   string
+	}{
+		{time.Date(2026, 3, 10, 9, 30, 0, 0, cairo), 7, "2026-03-17"},
+		{time.Date(2026, 3, 10, 23, 59, 0, 0, cairo), 1, "2026-03-11"}, // time of day is ignored
+		{time.Date(2026, 2, 26, 12, 0, 0, 0, cairo), 3, "2026-03-01"},  // month rollover
+	}
+	for _, tt := range tests {
+		got := OfferExpiryDate(tt.issued, tt.days)
+		if got.Format("2006-01-02") != tt.want || got.Hour() != 0 || got.Location() != cairo {
+			t.Errorf("OfferExpiryDate(%s, %d) = %s, want %s at midnight", tt.issued, tt.days, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,607 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// OnlineTestQuestionsPerLevel is how many questions are drawn from each level of the bank
+	OnlineTestQuestionsPerLevel = 4
+	// OnlineTestDuration is the time limit once the candidate starts the test
+	OnlineTestDuration = 40 * time.Minute
+	// OnlineTestLinkValidity is how long a generated link can be opened for
+	OnlineTestLinkValidity = 7 * 24 * time.Hour
+	// onlineTestGrace absorbs slow connections on the auto-submit at the end of the timer
+	onlineTestGrace = 2 * time.Minute
+	// placementPassRate is the share of a level's questions needed to pass that level
+	placementPassRate = 0.6
+)
+
+// PlacementSkills are the skills questions are written for
+var PlacementSkills = []string{"grammar", "vocabulary", "reading"}
+
+// Percent returns the bucket's score as a whole percentage
+func (b PlacementScoreBucket) Percent() int {
+	if b.Total == 0 {
+		return 0
+	}
+	return b.Correct * 100 / b.Total
+}
+
+// Deadline returns when the running test must be submitted (zero if not started)
+func (t *OnlinePlacementTest) Deadline() time.Time {
+	if !t.StartedAt.Valid {
+		return time.Time{}
+	}
+	return t.StartedAt.Time.Add(time.Duration(t.DurationMinutes) * time.Minute)
+}
+
+// ScorePlacementAnswers scores answers (question id -> option index) against the questions drawn
+// for a test and proposes a level: the highest level passed with every lower level passed too.
+// Levels with no questions do not break the ladder; the proposal is never below level 1.
+func ScorePlacementAnswers(questions []*PlacementQuestion, answers map[string]int) *PlacementScore {
+	levelBuckets := make(map[int]*PlacementScoreBucket)
+	skillBuckets := make(map[string]*PlacementScoreBucket)
+	score := &PlacementScore{}
+	for _, q := range questions {
+		lb := levelBuckets[q.Level]
+		if lb == nil {
+			lb = &PlacementScoreBucket{Key: strconv.Itoa(q.Level)}
+			levelBuckets[q.Level] = lb
+		}
+		sb := skillBuckets[q.Skill]
+		if sb == nil {
+			sb = &PlacementScoreBucket{Key: q.Skill}
+			skillBuckets[q.Skill] = sb
+		}
+		lb.Total++
+		sb.Total++
+		score.Total++
+		if chosen, ok := answers[q.ID.String()]; ok && chosen == q.CorrectOption {
+			lb.Correct++
+			sb.Correct++
+			score.Correct++
+		}
+	}
+
+	// Levels above the first failed one are still reported so the reviewer sees the whole picture
+	score.ProposedLevel = 1
+	passing := true
+	for level := 1; level <= 8; level++ {
+		lb := levelBuckets[level]
+		if lb == nil {
+			continue
+		}
+		score.Levels = append(score.Levels, *lb)
+		if !passing {
+			continue
+		}
+		if float64(lb.Correct) < placementPassRate*float64(lb.Total) {
+			passing = false
+		} else {
+			score.ProposedLevel = level
+		}
+	}
+	for _, skill := range PlacementSkills {
+		if sb := skillBuckets[skill]; sb != nil {
+			score.Skills = append(score.Skills, *sb)
+		}
+	}
+	return score
+}
+
+// pickPlacementQuestions draws up to perLevel random questions from each level of the bank,
+// ordered easiest level first
+func pickPlacementQuestions(bank []*PlacementQuestion, perLevel int, rng *mathrand.Rand) []*PlacementQuestion {
+	byLevel := make(map[int][]*PlacementQuestion)
+	for _, q := range bank {
+		byLevel[q.Level] = append(byLevel[q.Level], q)
+	}
+	var picked []*PlacementQuestion
+	for level := 1; level <= 8; level++ {
+		pool := append([]*PlacementQuestion(nil), byLevel[level]...)
+		rng.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+		if len(pool) > perLevel {
+			pool = pool[:perLevel]
+		}
+		picked = append(picked, pool...)
+	}
+	return picked
+}
+
+// newOnlineTestToken returns a random URL-safe token for a public test link
+func newOnlineTestToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GetPlacementQuestions returns the whole question bank, by level then skill
+func GetPlacementQuestions() ([]*PlacementQuestion, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, skill, level, prompt, options, correct_option, active, created_at
+		FROM placement_questions
+		ORDER BY level, skill, created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query placement questions: %w", err)
+	}
+	defer rows.Close()
+	return scanPlacementQuestions(rows)
+}
+
+func scanPlacementQuestions(rows *sql.Rows) ([]*PlacementQuestion, error) {
+	var questions []*PlacementQuestion
+	for rows.Next() {
+		q := &PlacementQuestion{}
+		var optionsJSON string
+		if err := rows.Scan(&q.ID, &q.Skill, &q.Level, &q.Prompt, &optionsJSON, &q.CorrectOption, &q.Active, &q.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan placement question: %w", err)
+		}
+		if err := json.Unmarshal([]byte(optionsJSON), &q.Options); err != nil {
+			return nil, fmt.Errorf("failed to decode options for question %s: %w", q.ID, err)
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+// CreatePlacementQuestion validates and adds a question to the bank
+func CreatePlacementQuestion(q *PlacementQuestion, createdByUserID string) error {
+	if !containsString(PlacementSkills, q.Skill) {
+		return &OnlineTestError{Message: "Skill must be grammar, vocabulary or reading."}
+	}
+	if q.Level < 1 || q.Level > 8 {
+		return &OnlineTestError{Message: "Level must be between 1 and 8."}
+	}
+	q.Prompt = strings.TrimSpace(q.Prompt)
+	if q.Prompt == "" {
+		return &OnlineTestError{Message: "Question text is required."}
+	}
+	// Blank options are dropped; the correct index is remapped onto the remaining ones
+	var options []string
+	correct := -1
+	for i, o := range q.Options {
+		if o = strings.TrimSpace(o); o != "" {
+			if i == q.CorrectOption {
+				correct = len(options)
+			}
+			options = append(options, o)
+		}
+	}
+	if len(options) < 2 {
+		return &OnlineTestError{Message: "A question needs at least two answer options."}
+	}
+	if correct < 0 {
+		return &OnlineTestError{Message: "Pick which of the filled-in options is correct."}
+	}
+	q.Options = options
+	q.CorrectOption = correct
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return fmt.Errorf("failed to encode options: %w", err)
+	}
+
+	var createdBy sql.NullString
+	if createdByUserID != "" {
+		createdBy = sql.NullString{String: createdByUserID, Valid: true}
+	}
+	err = db.DB.QueryRow(`
+		INSERT INTO placement_questions (skill, level, prompt, options, correct_option, created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, active, created_at
+	`, q.Skill, q.Level, q.Prompt, string(optionsJSON), q.CorrectOption, createdBy).Scan(&q.ID, &q.Active, &q.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create placement question: %w", err)
+	}
+	return nil
+}
+
+// SetPlacementQuestionActive retires or restores a question. Retired questions are not drawn
+// for new tests but still score the tests that already include them.
+func SetPlacementQuestionActive(questionID uuid.UUID, active bool) error {
+	_, err := db.DB.Exec(`UPDATE placement_questions SET active = $1 WHERE id = $2`, active, questionID)
+	if err != nil {
+		return fmt.Errorf("failed to update placement question: %w", err)
+	}
+	return nil
+}
+
+// getPlacementQuestionsByID loads questions in the order of ids (unknown ids are skipped)
+func getPlacementQuestionsByID(q sqlExecer, ids []uuid.UUID) ([]*PlacementQuestion, error) {
+	idStrs := make([]string, len(ids))
+	for i, id := range ids {
+		idStrs[i] = id.String()
+	}
+	rows, err := q.Query(`
+		SELECT id, skill, level, prompt, options, correct_option, active, created_at
+		FROM placement_questions WHERE id::text = ANY($1)
+	`, idStrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query test questions: %w", err)
+	}
+	defer rows.Close()
+	found, err := scanPlacementQuestions(rows)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*PlacementQuestion, len(found))
+	for _, fq := range found {
+		byID[fq.ID] = fq
+	}
+	ordered := make([]*PlacementQuestion, 0, len(ids))
+	for _, id := range ids {
+		if fq, ok := byID[id]; ok {
+			ordered = append(ordered, fq)
+		}
+	}
+	return ordered, nil
+}
+
+// GetOnlinePlacementTestQuestions returns the questions drawn for a test, in display order
+func GetOnlinePlacementTestQuestions(test *OnlinePlacementTest) ([]*PlacementQuestion, error) {
+	return getPlacementQuestionsByID(db.DB, test.QuestionIDs)
+}
+
+const onlinePlacementTestColumns = `
+	t.id, t.lead_id, t.token, t.status, t.question_ids, t.answers, t.duration_minutes, t.expires_at,
+	t.started_at, t.submitted_at, t.score_breakdown, t.proposed_level, t.approved_level,
+	t.reviewed_by_user_id, COALESCE(u.email, ''), t.reviewed_at, t.created_at`
+
+func scanOnlinePlacementTest(row *sql.Row) (*OnlinePlacementTest, error) {
+	t := &OnlinePlacementTest{}
+	var questionIDsJSON string
+	var answersJSON, scoreJSON sql.NullString
+	err := row.Scan(
+		&t.ID, &t.LeadID, &t.Token, &t.Status, &questionIDsJSON, &answersJSON, &t.DurationMinutes, &t.ExpiresAt,
+		&t.StartedAt, &t.SubmittedAt, &scoreJSON, &t.ProposedLevel, &t.ApprovedLevel,
+		&t.ReviewedByUserID, &t.ReviewedByEmail, &t.ReviewedAt, &t.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(questionIDsJSON), &t.QuestionIDs); err != nil {
+		return nil, fmt.Errorf("failed to decode question ids: %w", err)
+	}
+	if answersJSON.Valid {
+		if err := json.Unmarshal([]byte(answersJSON.String), &t.Answers); err != nil {
+			return nil, fmt.Errorf("failed to decode answers: %w", err)
+		}
+	}
+	if scoreJSON.Valid {
+		t.Score = &PlacementScore{}
+		if err := json.Unmarshal([]byte(scoreJSON.String), t.Score); err != nil {
+			return nil, fmt.Errorf("failed to decode score breakdown: %w", err)
+		}
+	}
+	return t, nil
+}
+
+// GetLatestOnlinePlacementTest returns the lead's most recent online test, or nil if none
+func GetLatestOnlinePlacementTest(leadID uuid.UUID) (*OnlinePlacementTest, error) {
+	t, err := scanOnlinePlacementTest(db.DB.QueryRow(`
+		SELECT `+onlinePlacementTestColumns+`
+		FROM online_placement_tests t
+		LEFT JOIN users u ON u.id = t.reviewed_by_user_id
+		WHERE t.lead_id = $1
+		ORDER BY t.created_at DESC
+		LIMIT 1
+	`, leadID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get online placement test: %w", err)
+	}
+	return t, nil
+}
+
+// GetOnlinePlacementTestByToken returns the test behind a public link
+func GetOnlinePlacementTestByToken(token string) (*OnlinePlacementTest, error) {
+	t, err := scanOnlinePlacementTest(db.DB.QueryRow(`
+		SELECT `+onlinePlacementTestColumns+`
+		FROM online_placement_tests t
+		LEFT JOIN users u ON u.id = t.reviewed_by_user_id
+		WHERE t.token = $1
+	`, token))
+	if err == sql.ErrNoRows {
+		return nil, &OnlineTestError{Message: "This test link is not valid."}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get online placement test: %w", err)
+	}
+	return t, nil
+}
+
+// lockOnlinePlacementTestTx loads and locks a test by token inside tx
+func lockOnlinePlacementTestTx(tx *sql.Tx, token string) (*OnlinePlacementTest, error) {
+	t, err := scanOnlinePlacementTest(tx.QueryRow(`
+		SELECT `+onlinePlacementTestColumns+`
+		FROM online_placement_tests t
+		LEFT JOIN users u ON u.id = t.reviewed_by_user_id
+		WHERE t.token = $1
+		FOR UPDATE OF t
+	`, token))
+	if err == sql.ErrNoRows {
+		return nil, &OnlineTestError{Message: "This test link is not valid."}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock online placement test: %w", err)
+	}
+	return t, nil
+}
+
+// CreateOnlinePlacementTest draws questions from the active bank and issues a new public link
+// for the lead. Any unfinished link is revoked; a submitted test must be reviewed first.
+func CreateOnlinePlacementTest(leadID uuid.UUID, createdByUserID string) (*OnlinePlacementTest, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the lead so two admins cannot issue links at the same time
+	if _, err := getLeadStatusForUpdate(tx, leadID); err != nil {
+		return nil, err
+	}
+	var awaitingReview int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM online_placement_tests WHERE lead_id = $1 AND status = 'submitted'`, leadID).Scan(&awaitingReview)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check submitted tests: %w", err)
+	}
+	if awaitingReview > 0 {
+		return nil, &OnlineTestError{Message: "A submitted online test is waiting for review. Approve or reject it before sending a new link."}
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, skill, level, prompt, options, correct_option, active, created_at
+		FROM placement_questions WHERE active
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query question bank: %w", err)
+	}
+	bank, err := scanPlacementQuestions(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	questions := pickPlacementQuestions(bank, OnlineTestQuestionsPerLevel, mathrand.New(mathrand.NewSource(now.UnixNano())))
+	if len(questions) == 0 {
+		return nil, &OnlineTestError{Message: "The question bank has no active questions yet."}
+	}
+	ids := make([]uuid.UUID, len(questions))
+	for i, q := range questions {
+		ids[i] = q.ID
+	}
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode question ids: %w", err)
+	}
+	token, err := newOnlineTestToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE online_placement_tests SET status = 'revoked'
+		WHERE lead_id = $1 AND status IN ('pending', 'in_progress')
+	`, leadID); err != nil {
+		return nil, fmt.Errorf("failed to revoke previous links: %w", err)
+	}
+
+	var createdBy sql.NullString
+	if createdByUserID != "" {
+		createdBy = sql.NullString{String: createdByUserID, Valid: true}
+	}
+	t := &OnlinePlacementTest{
+		LeadID:          leadID,
+		Token:           token,
+		Status:          "pending",
+		QuestionIDs:     ids,
+		DurationMinutes: int(OnlineTestDuration / time.Minute),
+		ExpiresAt:       now.Add(OnlineTestLinkValidity),
+		CreatedAt:       now,
+	}
+	err = tx.QueryRow(`
+		INSERT INTO online_placement_tests (lead_id, token, status, question_ids, duration_minutes, expires_at, created_by_user_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, leadID, token, t.Status, string(idsJSON), t.DurationMinutes, t.ExpiresAt, createdBy, now).Scan(&t.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create online placement test: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return t, nil
+}
+
+// StartOnlinePlacementTest starts the timer the first time the candidate opens the test.
+// Reopening a running test keeps the original start time.
+func StartOnlinePlacementTest(token string) (*OnlinePlacementTest, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	t, err := lockOnlinePlacementTestTx(tx, token)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	switch t.Status {
+	case "pending":
+		if now.After(t.ExpiresAt) {
+			return nil, &OnlineTestError{Message: "This test link has expired. Please contact us for a new one."}
+		}
+		t.Status = "in_progress"
+		t.StartedAt = sql.NullTime{Time: now, Valid: true}
+		if _, err := tx.Exec(`UPDATE online_placement_tests SET status = $1, started_at = $2 WHERE id = $3`, t.Status, now, t.ID); err != nil {
+			return nil, fmt.Errorf("failed to start online placement test: %w", err)
+		}
+	case "in_progress":
+	default:
+		return nil, onlineTestClosedError(t.Status)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return t, nil
+}
+
+func onlineTestClosedError(status string) error {
+	if status == "revoked" {
+		return &OnlineTestError{Message: "This test link has been replaced by a newer one."}
+	}
+	return &OnlineTestError{Message: "This test has already been submitted. Thank you!"}
+}
+
+// SubmitOnlinePlacementTest scores the candidate's answers and stores the breakdown and proposed
+// level on both the attempt and the lead's placement test. The lead's status is not changed until
+// an admin approves the result.
+func SubmitOnlinePlacementTest(token string, answers map[string]int) (*OnlinePlacementTest, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	t, err := lockOnlinePlacementTestTx(tx, token)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != "in_progress" {
+		if t.Status == "pending" {
+			return nil, &OnlineTestError{Message: "This test has not been started."}
+		}
+		return nil, onlineTestClosedError(t.Status)
+	}
+
+	questions, err := getPlacementQuestionsByID(tx, t.QuestionIDs)
+	if err != nil {
+		return nil, err
+	}
+	// Only keep answers to questions that were actually asked
+	asked := make(map[string]int, len(answers))
+	for _, q := range questions {
+		if chosen, ok := answers[q.ID.String()]; ok && chosen >= 0 && chosen < len(q.Options) {
+			asked[q.ID.String()] = chosen
+		}
+	}
+	now := time.Now()
+	score := ScorePlacementAnswers(questions, asked)
+	score.Late = now.After(t.Deadline().Add(onlineTestGrace))
+
+	answersJSON, err := json.Marshal(asked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode answers: %w", err)
+	}
+	scoreJSON, err := json.Marshal(score)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode score: %w", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE online_placement_tests
+		SET status = 'submitted', answers = $1, score_breakdown = $2, proposed_level = $3, submitted_at = $4
+		WHERE id = $5
+	`, string(answersJSON), string(scoreJSON), score.ProposedLevel, now, t.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save online placement test: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO placement_tests (id, lead_id, test_type, proposed_level, score_breakdown, placement_test_fee, placement_test_fee_paid, updated_at)
+		VALUES (COALESCE((SELECT id FROM placement_tests WHERE lead_id = $1), gen_random_uuid()), $1, 'online', $2, $3, 100, 0, $4)
+		ON CONFLICT (lead_id) DO UPDATE SET
+			proposed_level = EXCLUDED.proposed_level,
+			score_breakdown = EXCLUDED.score_breakdown,
+			updated_at = EXCLUDED.updated_at
+	`, t.LeadID, score.ProposedLevel, string(scoreJSON), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store score on placement test: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	t.Status = "submitted"
+	t.Answers = asked
+	t.Score = score
+	t.ProposedLevel = sql.NullInt32{Int32: int32(score.ProposedLevel), Valid: true}
+	t.SubmittedAt = sql.NullTime{Time: now, Valid: true}
+	return t, nil
+}
+
+// ReviewOnlinePlacementTest records the admin's decision on a submitted test. Approving sets the
+// lead's assigned level (the proposal or an override) and moves the lead to tested through the
+// pipeline; rejecting leaves the lead where it is so a new link can be sent.
+func ReviewOnlinePlacementTest(leadID, testID uuid.UUID, approve bool, level int, role, actorUserID string) error {
+	if approve && (level < 1 || level > 8) {
+		return &OnlineTestError{Message: "Invalid assigned level. Allowed: 1–8."}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM online_placement_tests WHERE id = $1 AND lead_id = $2 FOR UPDATE`, testID, leadID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return &OnlineTestError{Message: "Online test not found for this lead."}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load online placement test: %w", err)
+	}
+	if status != "submitted" {
+		return &OnlineTestError{Message: "Only submitted online tests can be reviewed."}
+	}
+
+	var actor sql.NullString
+	if actorUserID != "" {
+		actor = sql.NullString{String: actorUserID, Valid: true}
+	}
+	now := time.Now()
+	if !approve {
+		_, err = tx.Exec(`
+			UPDATE online_placement_tests SET status = 'rejected', reviewed_by_user_id = $1, reviewed_at = $2 WHERE id = $3
+		`, actor, now, testID)
+		if err != nil {
+			return fmt.Errorf("failed to reject online placement test: %w", err)
+		}
+		return tx.Commit()
+	}
+
+	_, err = tx.Exec(`
+		UPDATE online_placement_tests
+		SET status = 'approved', approved_level = $1, reviewed_by_user_id = $2, reviewed_at = $3
+		WHERE id = $4
+	`, level, actor, now, testID)
+	if err != nil {
+		return fmt.Errorf("failed to approve online placement test: %w", err)
+	}
+	_, err = tx.Exec(`UPDATE placement_tests SET assigned_level = $1, updated_at = $2 WHERE lead_id = $3`, level, now, leadID)
+	if err != nil {
+		return fmt.Errorf("failed to set assigned level: %w", err)
+	}
+	reason := fmt.Sprintf("Online placement test approved (Level %d)", level)
+	if err := applyLeadEventTx(tx, leadID, EventMarkTested, role, actorUserID, reason, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
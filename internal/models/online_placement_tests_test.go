@@ -0,0 +1,93 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// testQuestionBank builds n questions per level (1..levels), cycling skills; option 0 is correct
+func testQuestionBank(levels, n int) []*PlacementQuestion {
+	var bank []*PlacementQuestion
+	for level := 1; level <= levels; level++ {
+		for i := 0; i < n; i++ {
+			bank = append(bank, &PlacementQuestion{
+				ID:      uuid.New(),
+				Skill:   PlacementSkills[i%len(PlacementSkills)],
+				Level:   level,
+				Options: []string{"right", "wrong"},
+			})
+		}
+	}
+	return bank
+}
+
+// answerLevels answers `correct` questions right on each level in the map; the rest wrong
+func answerLevels(bank []*PlacementQuestion, correct map[int]int) map[string]int {
+	answers := make(map[string]int)
+	given := make(map[int]int)
+	for _, q := range bank {
+		if given[q.Level] < correct[q.Level] {
+			answers[q.ID.String()] = 0
+			given[q.Level]++
+		} else {
+			answers[q.ID.String()] = 1
+		}
+	}
+	return answers
+}
+
+func TestScorePlacementAnswers(t *testing.T) {
+	bank := testQuestionBank(8, 5)
+	tests := []struct {
+		name    string
+		correct map[int]int
+		want    int
+	}{
+		{"nothing right", map[int]int{}, 1},
+		{"passes 1-3", map[int]int{1: 5, 2: 4, 3: 3, 4: 2}, 3},
+		{"gap stops the ladder", map[int]int{1: 5, 2: 2, 3: 5, 4: 5}, 1},
+		{"passes everything", map[int]int{1: 5, 2: 5, 3: 5, 4: 5, 5: 5, 6: 5, 7: 5, 8: 3}, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := ScorePlacementAnswers(bank, answerLevels(bank, tt.correct))
+			if score.ProposedLevel != tt.want {
+				t.Errorf("ProposedLevel = %d, want %d", score.ProposedLevel, tt.want)
+			}
+			if score.Total != 40 || len(score.Levels) != 8 || len(score.Skills) != 3 {
+				t.Errorf("breakdown = %d total, %d levels, %d skills", score.Total, len(score.Levels), len(score.Skills))
+			}
+		})
+	}
+}
+
+func TestScorePlacementAnswersSkipsEmptyLevels(t *testing.T) {
+	var bank []*PlacementQuestion
+	for _, q := range testQuestionBank(4, 2) {
+		if q.Level != 2 {
+			bank = append(bank, q)
+		}
+	}
+	score := ScorePlacementAnswers(bank, answerLevels(bank, map[int]int{1: 2, 3: 2, 4: 1}))
+	if score.ProposedLevel != 3 {
+		t.Errorf("ProposedLevel = %d, want 3", score.ProposedLevel)
+	}
+}
+
+func TestPickPlacementQuestions(t *testing.T) {
+	bank := testQuestionBank(3, 6)
+	picked := pickPlacementQuestions(bank, 4, rand.New(rand.NewSource(1)))
+	if len(picked) != 12 {
+		t.Fatalf("picked %d questions, want 12", len(picked))
+	}
+	for i := 1; i < len(picked); i++ {
+		if picked[i].Level < picked[i-1].Level {
+			t.Fatalf("questions not ordered by level: %d after %d", picked[i].Level, picked[i-1].Level)
+		}
+	}
+	if got := pickPlacementQuestions(testQuestionBank(1, 2), 4, rand.New(rand.NewSource(1))); len(got) != 2 {
+		t.Errorf("small bank: picked %d questions, want 2", len(got))
+	}
+}
@@ -0,0 +1,467 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"eighty-twenty-ops/internal/db"
+	"eighty-twenty-ops/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// familyMemberPaid is a lead's net course paid (payments minus refunds), for queries over leads l
+const familyMemberPaid = `GREATEST(
+	COALESCE((SELECT SUM(amount) FROM lead_payments WHERE lead_id = l.id), 0) -
+	COALESCE((SELECT SUM(amount) FROM transactions WHERE lead_id = l.id AND category = 'refund' AND transaction_type = 'OUT'), 0),
+	0)`
+
+// Remaining returns what the lead still owes on its offer
+func (m *FamilyMember) Remaining() int32 {
+	if m.FinalPrice <= 0 || m.Paid >= m.FinalPrice {
+		return 0
+	}
+	return m.FinalPrice - m.Paid
+}
+
+// FamilyTotals returns the family's offer total, net paid and remaining balance
+func FamilyTotals(members []*FamilyMember) (price, paid, remaining int32) {
+	for _, m := range members {
+		price += m.FinalPrice
+		paid += m.Paid
+		remaining += m.Remaining()
+	}
+	return price, paid, remaining
+}
+
+// normalizePayerPhone trims a payer's phone and stores Egyptian mobiles in local form; other
+// numbers (guardians abroad) are kept as typed
+func normalizePayerPhone(raw string) string {
+	phone := strings.TrimSpace(raw)
+	if normalized, err := util.NormalizeEgyptianPhone(phone); err == nil {
+		return normalized
+	}
+	return phone
+}
+
+// checkPayer validates a payer's contact before it is saved and normalises it
+func checkPayer(p *Payer) error {
+	p.FullName = strings.TrimSpace(p.FullName)
+	p.Phone = normalizePayerPhone(p.Phone)
+	p.Relationship = strings.TrimSpace(p.Relationship)
+	p.Notes = strings.TrimSpace(p.Notes)
+	if p.Phone == "" {
+		return &PayerError{Message: "A phone number is required for the payer."}
+	}
+	if p.FullName == "" {
+		return &PayerError{Message: "A payer name is required."}
+	}
+	if len(p.FullName) > 100 || len(p.Relationship) > 40 {
+		return &PayerError{Message: "Payer names can be at most 100 characters and relationships 40."}
+	}
+	return nil
+}
+
+// payerAllocationKind is the lead_payments kind of an allocation: the lead's first payment is a
+// deposit, or a full payment when it settles the offer; later payments are top-ups
+func payerAllocationKind(m *FamilyMember, amount int32) string {
+	if m.Paid > 0 {
+		return "top_up"
+	}
+	if amount >= m.Remaining() {
+		return "full_payment"
+	}
+	return "deposit"
+}
+
+// AllocatePayerPayment splits a payer payment across the family. requested holds the amounts
+// entered per lead; when it is empty the payment fills each lead's remaining balance in family
+// order. Returns *PayerError when the split does not add up to the payment, a lead would be paid
+// more than it owes, or the family owes less than the payment.
+func AllocatePayerPayment(amount int32, members []*FamilyMember, requested map[uuid.UUID]int32) ([]*PayerAllocation, error) {
+	if amount <= 0 {
+		return nil, &PayerError{Message: "Enter the amount received."}
+	}
+	var allocations []*PayerAllocation
+
+	if len(requested) > 0 {
+		var total int32
+		inFamily := make(map[uuid.UUID]bool, len(members))
+		for _, m := range members {
+			inFamily[m.LeadID] = true
+			a := requested[m.LeadID]
+			switch {
+			case a == 0:
+				continue
+			case a < 0:
+				return nil, &PayerError{Message: fmt.Sprintf("%s: amounts cannot be negative.", m.FullName)}
+			case m.FinalPrice <= 0:
+				return nil, &PayerError{Message: fmt.Sprintf("%s has no priced offer yet.", m.FullName)}
+			case a > m.Remaining():
+				return nil, &PayerError{Message: fmt.Sprintf("%s owes %d EGP; %d EGP is more than that.", m.FullName, m.Remaining(), a)}
+			}
+			allocations = append(allocations, &PayerAllocation{LeadID: m.LeadID, FullName: m.FullName, Kind: payerAllocationKind(m, a), Amount: a})
+			total += a
+		}
+		for id := range requested {
+			if !inFamily[id] {
+				return nil, &PayerError{Message: "The split names a lead that is not part of this family."}
+			}
+		}
+		if total != amount {
+			return nil, &PayerError{Message: fmt.Sprintf("The split adds up to %d EGP but the payment is %d EGP.", total, amount)}
+		}
+		return allocations, nil
+	}
+
+	if _, _, remaining := FamilyTotals(members); amount > remaining {
+		return nil, &PayerError{Message: fmt.Sprintf("The payment (%d EGP) is more than the family owes (%d EGP).", amount, remaining)}
+	}
+	left := amount
+	for _, m := range members {
+		a := m.Remaining()
+		if left == 0 || a == 0 {
+			continue
+		}
+		if a > left {
+			a = left
+		}
+		allocations = append(allocations, &PayerAllocation{LeadID: m.LeadID, FullName: m.FullName, Kind: payerAllocationKind(m, a), Amount: a})
+		left -= a
+	}
+	return allocations, nil
+}
+
+const payerColumns = `p.id, p.full_name, p.phone, p.relationship, p.notes, p.created_at`
+
+func scanPayer(row interface{ Scan(...interface{}) error }) (*Payer, error) {
+	p := &Payer{}
+	if err := row.Scan(&p.ID, &p.FullName, &p.Phone, &p.Relationship, &p.Notes, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetPayers returns payers matching search (name or phone; empty for all), by name, with how many
+// leads they pay for and what the family still owes. Archived leads are left out.
+func GetPayers(search string) ([]*Payer, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+payerColumns+`, COUNT(l.id),
+		       COALESCE(SUM(GREATEST(COALESCE(o.final_price, 0) - `+familyMemberPaid+`, 0)), 0)
+		FROM payers p
+		LEFT JOIN leads l ON l.payer_id = p.id AND l.archived_at IS NULL
+		LEFT JOIN offers o ON o.lead_id = l.id
+		WHERE $1 = '' OR p.full_name ILIKE $2 OR p.phone ILIKE $2
+		GROUP BY p.id
+		ORDER BY LOWER(p.full_name), p.id
+	`, strings.TrimSpace(search), likePattern(search))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payers: %w", err)
+	}
+	defer rows.Close()
+
+	var payers []*Payer
+	for rows.Next() {
+		p := &Payer{}
+		if err := rows.Scan(&p.ID, &p.FullName, &p.Phone, &p.Relationship, &p.Notes, &p.CreatedAt, &p.LeadCount, &p.Remaining); err != nil {
+			return nil, fmt.Errorf("failed to scan payer: %w", err)
+		}
+		payers = append(payers, p)
+	}
+	return payers, rows.Err()
+}
+
+// GetPayer returns a payer, or nil when it does not exist
+func GetPayer(id uuid.UUID) (*Payer, error) {
+	p, err := scanPayer(db.DB.QueryRow(`SELECT `+payerColumns+` FROM payers p WHERE p.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payer: %w", err)
+	}
+	return p, nil
+}
+
+// GetLeadPayer returns the lead's payer, or nil when it has none
+func GetLeadPayer(leadID uuid.UUID) (*Payer, error) {
+	p, err := scanPayer(db.DB.QueryRow(`
+		SELECT `+payerColumns+` FROM payers p JOIN leads l ON l.payer_id = p.id WHERE l.id = $1
+	`, leadID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lead payer: %w", err)
+	}
+	return p, nil
+}
+
+// GetFamilyMembers returns the payer's leads, oldest first, with their course balances.
+// Archived leads are left out.
+func GetFamilyMembers(payerID uuid.UUID) ([]*FamilyMember, error) {
+	return getFamilyMembers(db.DB, payerID)
+}
+
+func getFamilyMembers(q sqlExecer, payerID uuid.UUID) ([]*FamilyMember, error) {
+	rows, err := q.Query(`
+		SELECT l.id, l.full_name, l.phone, l.status, COALESCE(o.final_price, 0), `+familyMemberPaid+`, o.bundle_levels
+		FROM leads l
+		LEFT JOIN offers o ON o.lead_id = l.id
+		WHERE l.payer_id = $1 AND l.archived_at IS NULL
+		ORDER BY l.created_at, l.id
+	`, payerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query family members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*FamilyMember
+	for rows.Next() {
+		m := &FamilyMember{}
+		if err := rows.Scan(&m.LeadID, &m.FullName, &m.Phone, &m.Status, &m.FinalPrice, &m.Paid, &m.BundleLevels); err != nil {
+			return nil, fmt.Errorf("failed to scan family member: %w", err)
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// LinkLeadPayer sets the lead's payer to the payer with p's phone, creating the payer from p when
+// no payer has that phone yet (an existing payer keeps its details). p is filled with the linked
+// payer. Returns *PayerError when the phone is missing or a new payer has no name.
+func LinkLeadPayer(leadID uuid.UUID, p *Payer, actorUserID string) error {
+	phone := normalizePayerPhone(p.Phone)
+	if phone == "" {
+		return &PayerError{Message: "A phone number is required for the payer."}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	existing, err := scanPayer(tx.QueryRow(`SELECT `+payerColumns+` FROM payers p WHERE p.phone = $1`, phone))
+	switch {
+	case err == sql.ErrNoRows:
+		p.Phone = phone
+		if err := checkPayer(p); err != nil {
+			return err
+		}
+		var createdBy sql.NullString
+		if actorUserID != "" {
+			createdBy = sql.NullString{String: actorUserID, Valid: true}
+		}
+		err = tx.QueryRow(`
+			INSERT INTO payers (full_name, phone, relationship, notes, created_by_user_id)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at
+		`, p.FullName, p.Phone, p.Relationship, p.Notes, createdBy).Scan(&p.ID, &p.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create payer: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to find payer: %w", err)
+	default:
+		*p = *existing
+	}
+
+	res, err := tx.Exec(`UPDATE leads SET payer_id = $1, updated_at = $2 WHERE id = $3`, p.ID, time.Now(), leadID)
+	if err != nil {
+		return fmt.Errorf("failed to link payer: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("lead not found: %s", leadID)
+	}
+	return tx.Commit()
+}
+
+// UnlinkLeadPayer removes the lead's payer. The payer and its past payments are kept.
+func UnlinkLeadPayer(leadID uuid.UUID) error {
+	if _, err := db.DB.Exec(`UPDATE leads SET payer_id = NULL, updated_at = $1 WHERE id = $2`, time.Now(), leadID); err != nil {
+		return fmt.Errorf("failed to unlink payer: %w", err)
+	}
+	return nil
+}
+
+// UpdatePayer saves a payer's contact details. Returns *PayerError when they are invalid or the
+// phone belongs to another payer.
+func UpdatePayer(p *Payer) error {
+	if err := checkPayer(p); err != nil {
+		return err
+	}
+	var taken bool
+	err := db.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM payers WHERE phone = $1 AND id <> $2)`, p.Phone, p.ID).Scan(&taken)
+	if err != nil {
+		return fmt.Errorf("failed to check payer phone: %w", err)
+	}
+	if taken {
+		return &PayerError{Message: fmt.Sprintf("Another payer already uses %s.", p.Phone)}
+	}
+	res, err := db.DB.Exec(`
+		UPDATE payers SET full_name = $1, phone = $2, relationship = $3, notes = $4, updated_at = $5
+		WHERE id = $6
+	`, p.FullName, p.Phone, p.Relationship, p.Notes, time.Now(), p.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update payer: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &PayerError{Message: "This payer does not exist."}
+	}
+	return nil
+}
+
+// RecordPayerPayment records one payment from a payer and splits it across the family (see
+// AllocatePayerPayment). Each share becomes a lead payment with its own IN transaction, after
+// which each lead's status and credits follow its new balance like any course payment.
+// Returns *PayerError when the payment is invalid or cannot be split.
+func RecordPayerPayment(payerID uuid.UUID, amount int32, paymentMethod string, paymentDate time.Time, notes string, requested map[uuid.UUID]int32, actorUserID string) (*PayerPayment, error) {
+	allowedMethods := map[string]bool{
+		"vodafone_cash": true, "bank_transfer": true, "paypal": true, "other": true,
+	}
+	if !allowedMethods[paymentMethod] {
+		return nil, &PayerError{Message: "Choose how the payment was received."}
+	}
+	if err := util.ValidateNotFutureDate(paymentDate); err != nil {
+		return nil, &PayerError{Message: "Payment date cannot be in the future."}
+	}
+	notes = strings.TrimSpace(notes)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the payer keeps two payments for the same family from splitting the same balance
+	var payerName string
+	err = tx.QueryRow(`SELECT full_name FROM payers WHERE id = $1 FOR UPDATE`, payerID).Scan(&payerName)
+	if err == sql.ErrNoRows {
+		return nil, &PayerError{Message: "This payer does not exist."}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock payer: %w", err)
+	}
+	members, err := getFamilyMembers(tx, payerID)
+	if err != nil {
+		return nil, err
+	}
+	allocations, err := AllocatePayerPayment(amount, members, requested)
+	if err != nil {
+		return nil, err
+	}
+
+	var createdBy sql.NullString
+	if actorUserID != "" {
+		createdBy = sql.NullString{String: actorUserID, Valid: true}
+	}
+	payment := &PayerPayment{
+		PayerID:       payerID,
+		Amount:        amount,
+		PaymentMethod: paymentMethod,
+		PaymentDate:   paymentDate,
+		Notes:         notes,
+		Allocations:   allocations,
+	}
+	err = tx.QueryRow(`
+		INSERT INTO payer_payments (payer_id, amount, payment_method, payment_date, notes, created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, payerID, amount, paymentMethod, paymentDate, notes, createdBy).Scan(&payment.ID, &payment.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record payer payment: %w", err)
+	}
+
+	leadNotes := "Family payment from " + payerName
+	if notes != "" {
+		leadNotes += ": " + notes
+	}
+	bundleLevels := make(map[uuid.UUID]sql.NullInt32, len(members))
+	for _, m := range members {
+		bundleLevels[m.LeadID] = m.BundleLevels
+	}
+	now := payment.CreatedAt
+	payerPaymentID := sql.NullString{String: payment.ID.String(), Valid: true}
+	for _, a := range allocations {
+		leadPayment := &LeadPayment{
+			ID:            uuid.New(),
+			LeadID:        a.LeadID,
+			Kind:          a.Kind,
+			Amount:        a.Amount,
+			PaymentMethod: paymentMethod,
+			PaymentDate:   paymentDate,
+			Notes:         sql.NullString{String: leadNotes, Valid: true},
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := createLeadPaymentTx(tx, leadPayment, payerPaymentID); err != nil {
+			return nil, err
+		}
+		// Status and credits move with the payment, so a family payment never lands half-applied
+		if err := updateLeadStatusFromPaymentTx(tx, a.LeadID, now); err != nil {
+			return nil, err
+		}
+		if err := updateLeadCreditsFromPaymentsTx(tx, a.LeadID, bundleLevels[a.LeadID]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit payer payment: %w", err)
+	}
+	return payment, nil
+}
+
+// GetPayerPayments returns the payer's payments, newest first, with how each was split
+func GetPayerPayments(payerID uuid.UUID) ([]*PayerPayment, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, payer_id, amount, payment_method, payment_date, notes, created_at
+		FROM payer_payments
+		WHERE payer_id = $1
+		ORDER BY payment_date DESC, created_at DESC
+	`, payerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payer payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []*PayerPayment
+	byID := map[uuid.UUID]*PayerPayment{}
+	for rows.Next() {
+		p := &PayerPayment{}
+		if err := rows.Scan(&p.ID, &p.PayerID, &p.Amount, &p.PaymentMethod, &p.PaymentDate, &p.Notes, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan payer payment: %w", err)
+		}
+		payments = append(payments, p)
+		byID[p.ID] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	allocRows, err := db.DB.Query(`
+		SELECT lp.payer_payment_id, lp.lead_id, l.full_name, lp.kind, lp.amount
+		FROM lead_payments lp
+		JOIN payer_payments pp ON pp.id = lp.payer_payment_id
+		JOIN leads l ON l.id = lp.lead_id
+		WHERE pp.payer_id = $1
+		ORDER BY l.created_at, l.id
+	`, payerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payer allocations: %w", err)
+	}
+	defer allocRows.Close()
+	for allocRows.Next() {
+		var paymentID uuid.UUID
+		a := &PayerAllocation{}
+		if err := allocRows.Scan(&paymentID, &a.LeadID, &a.FullName, &a.Kind, &a.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan payer allocation: %w", err)
+		}
+		if p := byID[paymentID]; p != nil {
+			p.Allocations = append(p.Allocations, a)
+		}
+	}
+	return payments, allocRows.Err()
+}
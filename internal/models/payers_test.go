@@ -0,0 +1,127 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFamilyTotals(t *testing.T) {
+	members := []*FamilyMember{
+		{FinalPrice: 3000, Paid: 1000},
+		{FinalPrice: 2000, Paid: 2500}, // overpaid: owes nothing
+		{FinalPrice: 0, Paid: 0},       // no offer yet
+	}
+	wantRemaining := []int32{2000, 0, 0}
+	for i, m := range members {
+		if got := m.Remaining(); got != wantRemaining[i] {
+			t.Errorf("member %d Remaining() = %d, want %d", i, got, wantRemaining[i])
+		}
+	}
+	price, paid, remaining := FamilyTotals(members)
+	if price != 5000 || paid != 3500 || remaining != 2000 {
+		t.Errorf("FamilyTotals = %d, %d, %d, want 5000, 3500, 2000", price, paid, remaining)
+	}
+}
+
+func TestPayerAllocationKind(t *testing.T) {
+	tests := []struct {
+		name   string
+		member FamilyMember
+		amount int32
+		want   string
+	}{
+		{"first partial payment", FamilyMember{FinalPrice: 3000}, 1000, "deposit"},
+		{"first payment settles", FamilyMember{FinalPrice: 3000}, 3000, "full_payment"},
+		{"later payment", FamilyMember{FinalPrice: 3000, Paid: 1000}, 2000, "top_up"},
+	}
+	for _, tt := range tests {
+		if got := payerAllocationKind(&tt.member, tt.amount); got != tt.want {
+			t.Errorf("%s: payerAllocationKind = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAllocatePayerPayment(t *testing.T) {
+	older := &FamilyMember{LeadID: uuid.New(), FullName: "Older", FinalPrice: 3000, Paid: 1000}
+	younger := &FamilyMember{LeadID: uuid.New(), FullName: "Younger", FinalPrice: 2500}
+	noOffer := &FamilyMember{LeadID: uuid.New(), FullName: "New"}
+	family := []*FamilyMember{older, noOffer, younger}
+
+	type share struct {
+		lead   uuid.UUID
+		kind   string
+		amount int32
+	}
+	tests := []struct {
+		name      string
+		amount    int32
+		requested map[uuid.UUID]int32
+		want      []share
+		wantErr   bool
+	}{
+		{"fills oldest first", 1500, nil, []share{{older.LeadID, "top_up", 1500}}, false},
+		{"spills to the next sibling", 3000, nil, []share{{older.LeadID, "top_up", 2000}, {younger.LeadID, "deposit", 1000}}, false},
+		{"settles the whole family", 4500, nil, []share{{older.LeadID, "top_up", 2000}, {younger.LeadID, "full_payment", 2500}}, false},
+		{"more than the family owes", 5000, nil, nil, true},
+		{"zero amount", 0, nil, nil, true},
+		{"explicit split", 2000, map[uuid.UUID]int32{older.LeadID: 500, younger.LeadID: 1500},
+			[]share{{older.LeadID, "top_up", 500}, {younger.LeadID, "deposit", 1500}}, false},
+		{"split skips blank shares", 2500, map[uuid.UUID]int32{older.LeadID: 0, younger.LeadID: 2500},
+			[]share{{younger.LeadID, "full_payment", 2500}}, false},
+		{"split does not add up", 2000, map[uuid.UUID]int32{older.LeadID: 500}, nil, true},
+		{"split above a lead's balance", 2500, map[uuid.UUID]int32{older.LeadID: 2500}, nil, true},
+		{"split to a lead without an offer", 100, map[uuid.UUID]int32{noOffer.LeadID: 100}, nil, true},
+		{"negative share", 100, map[uuid.UUID]int32{older.LeadID: 200, younger.LeadID: -100}, nil, true},
+		{"lead outside the family", 100, map[uuid.UUID]int32{uuid.New(): 100}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := AllocatePayerPayment(tt.amount, family, tt.requested)
+		if tt.wantErr {
+			var payerErr *PayerError
+			if !errors.As(err, &payerErr) {
+				t.Errorf("%s: err = %v, want *PayerError", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d allocations, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, w := range tt.want {
+			if got[i].LeadID != w.lead || got[i].Kind != w.kind || got[i].Amount != w.amount {
+				t.Errorf("%s: allocation %d = %s %s %d, want %s %s %d", tt.name, i,
+					got[i].FullName, got[i].Kind, got[i].Amount, w.lead, w.kind, w.amount)
+			}
+		}
+	}
+}
+
+func TestCheckPayer(t *testing.T) {
+	tests := []struct {
+		name      string
+		payer     Payer
+		wantPhone string
+		wantErr   bool
+	}{
+		{"egyptian mobile in international form", Payer{FullName: " Mona ", Phone: "+20 100 123 4567"}, "01001234567", false},
+		{"number abroad kept as typed", Payer{FullName: "Mona", Phone: " +44 7700 900123 "}, "+44 7700 900123", false},
+		{"missing phone", Payer{FullName: "Mona", Phone: "  "}, "", true},
+		{"missing name", Payer{Phone: "01001234567"}, "01001234567", true},
+	}
+	for _, tt := range tests {
+		p := tt.payer
+		err := checkPayer(&p)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if p.Phone != tt.wantPhone {
+			t.Errorf("%s: phone = %q, want %q", tt.name, p.Phone, tt.wantPhone)
+		}
+	}
+}
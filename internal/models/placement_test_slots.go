@@ -0,0 +1,304 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PlacementTestSlotLength is how long one placement test slot blocks its examiner
+const PlacementTestSlotLength = 30 * time.Minute
+
+// PlacementExaminerRoles are the roles that can run placement tests
+var PlacementExaminerRoles = []string{"admin", "mentor_head", "mentor"}
+
+// slotTimesOverlap reports whether two slot start times (HH:MM) on the same day block each other
+func slotTimesOverlap(a, b string) bool {
+	ta, errA := time.Parse("15:04", a)
+	tb, errB := time.Parse("15:04", b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	diff := ta.Sub(tb)
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff < PlacementTestSlotLength
+}
+
+// CreatePlacementTestSlot adds a slot after checking the examiner has no overlapping slot that day
+func CreatePlacementTestSlot(slot *PlacementTestSlot, createdByUserID string) error {
+	if slot.TestType != "online" && slot.TestType != "live" {
+		return &PlacementSlotError{Message: "Test type must be online or live."}
+	}
+	if slot.Capacity < 1 {
+		return &PlacementSlotError{Message: "Capacity must be at least 1."}
+	}
+	if _, err := time.Parse("15:04", slot.SlotTime); err != nil {
+		return &PlacementSlotError{Message: "Invalid slot time. Use HH:MM."}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise slot creation per examiner so two admins cannot add overlapping slots at once
+	if _, err := tx.Exec(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, slot.ExaminerUserID); err != nil {
+		return fmt.Errorf("failed to lock examiner: %w", err)
+	}
+	rows, err := tx.Query(`
+		SELECT to_char(slot_time, 'HH24:MI')
+		FROM placement_test_slots
+		WHERE examiner_user_id = $1 AND slot_date = $2
+	`, slot.ExaminerUserID, slot.SlotDate)
+	if err != nil {
+		return fmt.Errorf("failed to query examiner slots: %w", err)
+	}
+	var existing []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan slot: %w", err)
+		}
+		existing = append(existing, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, t := range existing {
+		if slotTimesOverlap(t, slot.SlotTime) {
+			return &PlacementSlotError{Message: fmt.Sprintf("Examiner already has a slot at %s on %s.", t, slot.SlotDate.Format("2006-01-02"))}
+		}
+	}
+
+	var createdBy sql.NullString
+	if createdByUserID != "" {
+		createdBy = sql.NullString{String: createdByUserID, Valid: true}
+	}
+	err = tx.QueryRow(`
+		INSERT INTO placement_test_slots (slot_date, slot_time, test_type, examiner_user_id, capacity, notes, created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, slot.SlotDate, slot.SlotTime, slot.TestType, slot.ExaminerUserID, slot.Capacity, slot.Notes, createdBy).Scan(&slot.ID, &slot.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create slot: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeletePlacementTestSlot removes a slot that has no bookings
+func DeletePlacementTestSlot(slotID uuid.UUID) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var booked int
+	err = tx.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM placement_tests WHERE slot_id = s.id)
+		FROM placement_test_slots s WHERE s.id = $1 FOR UPDATE
+	`, slotID).Scan(&booked)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load slot: %w", err)
+	}
+	if booked > 0 {
+		return &PlacementSlotError{Message: fmt.Sprintf("Slot has %d booking(s). Rebook those leads first.", booked)}
+	}
+	if _, err := tx.Exec(`DELETE FROM placement_test_slots WHERE id = $1`, slotID); err != nil {
+		return fmt.Errorf("failed to delete slot: %w", err)
+	}
+	return tx.Commit()
+}
+
+const placementTestSlotSelect = `
+	SELECT s.id, s.slot_date, to_char(s.slot_time, 'HH24:MI'), s.test_type, s.examiner_user_id, COALESCE(u.email, ''),
+	       s.capacity, (SELECT COUNT(*) FROM placement_tests pt WHERE pt.slot_id = s.id), s.notes, s.created_at
+	FROM placement_test_slots s
+	LEFT JOIN users u ON u.id = s.examiner_user_id
+`
+
+func scanPlacementTestSlots(rows *sql.Rows) ([]*PlacementTestSlot, error) {
+	defer rows.Close()
+	slots := []*PlacementTestSlot{}
+	for rows.Next() {
+		s := &PlacementTestSlot{}
+		if err := rows.Scan(&s.ID, &s.SlotDate, &s.SlotTime, &s.TestType, &s.ExaminerUserID, &s.ExaminerEmail,
+			&s.Capacity, &s.Booked, &s.Notes, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan slot: %w", err)
+		}
+		slots = append(slots, s)
+	}
+	return slots, rows.Err()
+}
+
+// GetPlacementTestSlots returns all slots with from <= slot_date < to, ordered by date and time
+func GetPlacementTestSlots(from, to time.Time) ([]*PlacementTestSlot, error) {
+	rows, err := db.DB.Query(placementTestSlotSelect+`
+		WHERE s.slot_date >= $1 AND s.slot_date < $2
+		ORDER BY s.slot_date, s.slot_time, u.email
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query slots: %w", err)
+	}
+	return scanPlacementTestSlots(rows)
+}
+
+// GetBookablePlacementTestSlots returns slots from today on that still have room,
+// plus currentSlotID (the lead's own booking) so it stays selectable
+func GetBookablePlacementTestSlots(currentSlotID string) ([]*PlacementTestSlot, error) {
+	var current sql.NullString
+	if currentSlotID != "" {
+		current = sql.NullString{String: currentSlotID, Valid: true}
+	}
+	rows, err := db.DB.Query(placementTestSlotSelect+`
+		WHERE (s.slot_date >= CURRENT_DATE
+		       AND s.capacity > (SELECT COUNT(*) FROM placement_tests pt WHERE pt.slot_id = s.id))
+		   OR s.id::text = $1
+		ORDER BY s.slot_date, s.slot_time, u.email
+	`, current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bookable slots: %w", err)
+	}
+	return scanPlacementTestSlots(rows)
+}
+
+// GetExaminerAgenda returns an examiner's slots for one day with the leads booked into each
+func GetExaminerAgenda(examinerUserID uuid.UUID, day time.Time) ([]*PlacementTestSlot, error) {
+	rows, err := db.DB.Query(placementTestSlotSelect+`
+		WHERE s.examiner_user_id = $1 AND s.slot_date = $2
+		ORDER BY s.slot_time
+	`, examinerUserID, day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agenda: %w", err)
+	}
+	slots, err := scanPlacementTestSlots(rows)
+	if err != nil || len(slots) == 0 {
+		return slots, err
+	}
+
+	slotIDs := make([]string, len(slots))
+	byID := map[uuid.UUID]*PlacementTestSlot{}
+	for i, s := range slots {
+		slotIDs[i] = s.ID.String()
+		byID[s.ID] = s
+	}
+	bookingRows, err := db.DB.Query(`
+		SELECT pt.slot_id, l.id, l.full_name, l.phone, l.status, pt.assigned_level
+		FROM placement_tests pt
+		INNER JOIN leads l ON l.id = pt.lead_id
+		WHERE pt.slot_id::text = ANY($1)
+		ORDER BY l.full_name
+	`, slotIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query slot bookings: %w", err)
+	}
+	defer bookingRows.Close()
+	for bookingRows.Next() {
+		var slotID uuid.UUID
+		b := &PlacementTestSlotBooking{}
+		if err := bookingRows.Scan(&slotID, &b.LeadID, &b.FullName, &b.Phone, &b.Status, &b.AssignedLevel); err != nil {
+			return nil, fmt.Errorf("failed to scan slot booking: %w", err)
+		}
+		if s, ok := byID[slotID]; ok {
+			s.Bookings = append(s.Bookings, b)
+		}
+	}
+	return slots, bookingRows.Err()
+}
+
+// GetPlacementExaminers returns users who can be assigned as placement test examiners
+func GetPlacementExaminers() ([]*User, error) {
+	return getUsersWithRoles(PlacementExaminerRoles)
+}
+
+// getUsersWithRoles returns the users holding any of roles, by email
+func getUsersWithRoles(roles []string) ([]*User, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, email, password_hash, role, created_at
+		FROM users
+		WHERE role = ANY($1)
+		ORDER BY email
+	`, roles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		u := &User{}
+		if err := rows.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// bookPlacementTestSlotTx books a lead into a slot: locks the slot, checks capacity and date,
+// and copies date/time/type/examiner onto the lead's placement test
+func bookPlacementTestSlotTx(tx *sql.Tx, leadID, slotID uuid.UUID, testNotes sql.NullString, now time.Time) error {
+	var slotDate time.Time
+	var slotTime, testType string
+	var examinerID uuid.UUID
+	var capacity int
+	err := tx.QueryRow(`
+		SELECT slot_date, to_char(slot_time, 'HH24:MI'), test_type, examiner_user_id, capacity
+		FROM placement_test_slots WHERE id = $1 FOR UPDATE
+	`, slotID).Scan(&slotDate, &slotTime, &testType, &examinerID, &capacity)
+	if err == sql.ErrNoRows {
+		return &PlacementSlotError{Message: "The selected test slot no longer exists."}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load slot: %w", err)
+	}
+
+	var booked int
+	var alreadyBooked bool
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE lead_id != $2), COALESCE(BOOL_OR(lead_id = $2), false)
+		FROM placement_tests WHERE slot_id = $1
+	`, slotID, leadID).Scan(&booked, &alreadyBooked)
+	if err != nil {
+		return fmt.Errorf("failed to count slot bookings: %w", err)
+	}
+	if !alreadyBooked {
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		if slotDate.Before(today) {
+			return &PlacementSlotError{Message: "The selected test slot is in the past."}
+		}
+		if booked >= capacity {
+			return &PlacementSlotError{Message: fmt.Sprintf("The %s %s slot is full (%d of %d booked).", slotDate.Format("2006-01-02"), slotTime, booked, capacity)}
+		}
+	}
+
+	// Default fee of 100 if the placement test row does not exist yet
+	_, err = tx.Exec(`
+		INSERT INTO placement_tests (id, lead_id, test_date, test_time, test_type, test_notes, run_by_user_id, slot_id, placement_test_fee, placement_test_fee_paid, updated_at)
+		VALUES (COALESCE((SELECT id FROM placement_tests WHERE lead_id = $1), gen_random_uuid()), $1, $2, $3, $4, $5, $6, $7, 100, 0, $8)
+		ON CONFLICT (lead_id) DO UPDATE SET
+			test_date = EXCLUDED.test_date,
+			test_time = EXCLUDED.test_time,
+			test_type = EXCLUDED.test_type,
+			test_notes = EXCLUDED.test_notes,
+			run_by_user_id = EXCLUDED.run_by_user_id,
+			slot_id = EXCLUDED.slot_id,
+			placement_test_fee = COALESCE(placement_tests.placement_test_fee, 100),
+			updated_at = EXCLUDED.updated_at
+	`, leadID, slotDate, slotTime, testType, testNotes, examinerID, slotID, now)
+	if err != nil {
+		return fmt.Errorf("failed to upsert placement test: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import "testing"
+
+func TestSlotTimesOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"10:00", "10:00", true},
+		{"10:00", "10:29", true},
+		{"10:29", "10:00", true},
+		{"10:00", "10:30", false},
+		{"09:30", "10:00", false},
+		{"07:30", "10:00", false},
+	}
+	for _, tt := range tests {
+		if got := slotTimesOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("slotTimesOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
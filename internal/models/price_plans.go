@@ -0,0 +1,239 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxBundleLevels is the largest bundle an offer can be made for (offers.bundle_levels CHECK)
+const MaxBundleLevels = 4
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// BundlePrice returns the plan's price for a bundle of the given number of levels: the explicit
+// bundle price when the plan has one, otherwise levels * PerLevelPrice. ok is false for sizes outside 1..MaxBundleLevels.
+func (p *PricePlan) BundlePrice(levels int32) (price int32, ok bool) {
+	if levels < 1 || levels > MaxBundleLevels {
+		return 0, false
+	}
+	if price, ok := p.Bundles[levels]; ok {
+		return price, true
+	}
+	return levels * p.PerLevelPrice, true
+}
+
+// BundleOptions lists every bundle size of the plan with its price, smallest first
+func (p *PricePlan) BundleOptions() []PriceBundle {
+	options := make([]PriceBundle, 0, MaxBundleLevels)
+	for levels := int32(1); levels <= MaxBundleLevels; levels++ {
+		price, _ := p.BundlePrice(levels)
+		options = append(options, PriceBundle{Levels: levels, Price: price})
+	}
+	return options
+}
+
+// LevelsForAmount returns the largest bundle fully covered by amount, 0 when it covers none
+func (p *PricePlan) LevelsForAmount(amount int32) int32 {
+	var levels int32
+	for _, b := range p.BundleOptions() {
+		if b.Price <= amount {
+			levels = b.Levels
+		}
+	}
+	return levels
+}
+
+// ApplyPricePlan prices the offer's bundle from plan and records the plan on the offer.
+// An offer already priced for the same bundle keeps its snapshot, so publishing a new
+// price list does not reprice offers that were already made.
+func (o *Offer) ApplyPricePlan(plan *PricePlan, bundleLevels int32) error {
+	if o.PricePlanID.Valid && o.BundleLevels.Valid && o.BundleLevels.Int32 == bundleLevels && o.BasePrice.Valid {
+		return nil
+	}
+	if plan == nil {
+		return &PricePlanError{Message: "No price list is in effect. Publish one under Prices first."}
+	}
+	price, ok := plan.BundlePrice(bundleLevels)
+	if !ok {
+		return &PricePlanError{Message: fmt.Sprintf("Bundles are 1 to %d levels.", MaxBundleLevels)}
+	}
+	o.BundleLevels = sql.NullInt32{Int32: bundleLevels, Valid: true}
+	o.BasePrice = sql.NullInt32{Int32: price, Valid: true}
+	o.PricePlanID = sql.NullString{String: plan.ID.String(), Valid: true}
+	return nil
+}
+
+// checkPricePlan validates a price list before it is published; effectiveFrom cannot be before today
+func checkPricePlan(p *PricePlan, today time.Time) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Currency = strings.ToUpper(strings.TrimSpace(p.Currency))
+	if p.Name == "" {
+		return &PricePlanError{Message: "Price list name is required."}
+	}
+	if !currencyCodePattern.MatchString(p.Currency) {
+		return &PricePlanError{Message: "Currency must be a three-letter code such as EGP."}
+	}
+	if p.EffectiveFrom.IsZero() {
+		return &PricePlanError{Message: "Effective-from date is required."}
+	}
+	if p.EffectiveFrom.Before(today) {
+		return &PricePlanError{Message: "Effective-from date cannot be in the past."}
+	}
+	if p.PerLevelPrice <= 0 {
+		return &PricePlanError{Message: "Per-level price must be greater than zero."}
+	}
+	for levels, price := range p.Bundles {
+		if levels < 1 || levels > MaxBundleLevels {
+			return &PricePlanError{Message: fmt.Sprintf("Bundles are 1 to %d levels.", MaxBundleLevels)}
+		}
+		if price <= 0 {
+			return &PricePlanError{Message: fmt.Sprintf("Bundle %d price must be greater than zero.", levels)}
+		}
+	}
+	return nil
+}
+
+// PublishPricePlan publishes a new price list. It applies to offers priced on or after its
+// effective-from date; existing plans are never changed. Returns *PricePlanError for an invalid list.
+func PublishPricePlan(p *PricePlan, actorUserID string) error {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if err := checkPricePlan(p, today); err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var actor sql.NullString
+	if actorUserID != "" {
+		actor = sql.NullString{String: actorUserID, Valid: true}
+	}
+	err = tx.QueryRow(`
+		INSERT INTO price_plans (name, currency, effective_from, per_level_price, created_by_user_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, p.Name, p.Currency, p.EffectiveFrom, p.PerLevelPrice, actor, now).Scan(&p.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create price plan: %w", err)
+	}
+	for levels, price := range p.Bundles {
+		_, err = tx.Exec(`INSERT INTO price_plan_bundles (plan_id, levels, price) VALUES ($1, $2, $3)`, p.ID, levels, price)
+		if err != nil {
+			return fmt.Errorf("failed to create bundle price: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+const pricePlanColumns = `
+	p.id, p.name, p.currency, p.effective_from, p.per_level_price, COALESCE(u.email, ''), p.created_at,
+	(SELECT COUNT(*) FROM offers o WHERE o.price_plan_id = p.id)
+	FROM price_plans p
+	LEFT JOIN users u ON u.id = p.created_by_user_id`
+
+// queryPricePlans loads plans with their bundle prices
+func queryPricePlans(query string, args ...interface{}) ([]*PricePlan, error) {
+	rows, err := db.DB.Query(`SELECT `+pricePlanColumns+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price plans: %w", err)
+	}
+	defer rows.Close()
+	var plans []*PricePlan
+	byID := make(map[uuid.UUID]*PricePlan)
+	for rows.Next() {
+		p := &PricePlan{Bundles: make(map[int32]int32)}
+		err := rows.Scan(&p.ID, &p.Name, &p.Currency, &p.EffectiveFrom, &p.PerLevelPrice, &p.CreatedByEmail, &p.CreatedAt, &p.OfferCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan price plan: %w", err)
+		}
+		plans = append(plans, p)
+		byID[p.ID] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(plans) == 0 {
+		return plans, nil
+	}
+
+	// Price lists are small; load all bundle prices and keep the ones for these plans
+	bundleRows, err := db.DB.Query(`SELECT plan_id, levels, price FROM price_plan_bundles`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bundle prices: %w", err)
+	}
+	defer bundleRows.Close()
+	for bundleRows.Next() {
+		var planID uuid.UUID
+		var b PriceBundle
+		if err := bundleRows.Scan(&planID, &b.Levels, &b.Price); err != nil {
+			return nil, fmt.Errorf("failed to scan bundle price: %w", err)
+		}
+		if p, ok := byID[planID]; ok {
+			p.Bundles[b.Levels] = b.Price
+		}
+	}
+	return plans, bundleRows.Err()
+}
+
+// GetPricePlans returns every published price list, latest effective date first
+func GetPricePlans() ([]*PricePlan, error) {
+	return queryPricePlans(` ORDER BY p.effective_from DESC, p.created_at DESC`)
+}
+
+// GetCurrentPricePlan returns the price list in effect on asOf (the latest effective_from on or
+// before it), or nil when none has been published yet
+func GetCurrentPricePlan(asOf time.Time) (*PricePlan, error) {
+	plans, err := queryPricePlans(`
+		WHERE p.effective_from <= $1::date
+		ORDER BY p.effective_from DESC, p.created_at DESC
+		LIMIT 1
+	`, asOf.Format("2006-01-02"))
+	if err != nil || len(plans) == 0 {
+		return nil, err
+	}
+	return plans[0], nil
+}
+
+// GetPricePlan returns a price list by ID, or nil when it does not exist
+func GetPricePlan(id string) (*PricePlan, error) {
+	planID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, nil
+	}
+	plans, err := queryPricePlans(` WHERE p.id = $1`, planID)
+	if err != nil || len(plans) == 0 {
+		return nil, err
+	}
+	return plans[0], nil
+}
+
+// GetOfferPricePlan returns the plan a lead's offer was priced from, falling back to the plan in
+// effect today for leads without a priced offer
+func GetOfferPricePlan(leadID uuid.UUID) (*PricePlan, error) {
+	return getOfferPricePlan(db.DB, leadID)
+}
+
+func getOfferPricePlan(q sqlExecer, leadID uuid.UUID) (*PricePlan, error) {
+	var planID sql.NullString
+	err := q.QueryRow(`SELECT price_plan_id FROM offers WHERE lead_id = $1`, leadID).Scan(&planID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get offer price plan: %w", err)
+	}
+	if planID.Valid {
+		plan, err := GetPricePlan(planID.String)
+		if err != nil || plan != nil {
+			return plan, err
+		}
+	}
+	return GetCurrentPricePlan(time.Now())
+}
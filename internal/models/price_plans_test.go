@@ -0,0 +1,130 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testPricePlan() *PricePlan {
+	return &PricePlan{
+		ID:            uuid.New(),
+		Name:          "Standard prices",
+		Currency:      "EGP",
+		PerLevelPrice: 1300,
+		Bundles:       map[int32]int32{2: 2400, 4: 4000},
+	}
+}
+
+func TestPricePlanBundlePrice(t *testing.T) {
+	plan := testPricePlan()
+	tests := []struct {
+		levels int32
+		want   int32
+		wantOK bool
+	}{
+		{1, 1300, true}, // per-level fallback
+		{2, 2400, true},
+		{3, 3900, true}, // per-level fallback
+		{4, 4000, true},
+		{0, 0, false},
+		{5, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := plan.BundlePrice(tt.levels)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("BundlePrice(%d) = %d, %v; want %d, %v", tt.levels, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCalculateLevelsPurchased(t *testing.T) {
+	plan := testPricePlan()
+	tests := []struct {
+		name       string
+		plan       *PricePlan
+		bundle     sql.NullInt32
+		totalPaid  int32
+		wantLevels sql.NullInt32
+		wantType   string
+	}{
+		{"bundle wins over payments", plan, sql.NullInt32{Int32: 3, Valid: true}, 100, sql.NullInt32{Int32: 3, Valid: true}, "bundle3"},
+		{"single bundle", plan, sql.NullInt32{Int32: 1, Valid: true}, 0, sql.NullInt32{Int32: 1, Valid: true}, "single"},
+		{"bundle outside plan", plan, sql.NullInt32{Int32: 6, Valid: true}, 0, sql.NullInt32{}, "none"},
+		{"no bundle, payments cover two levels", plan, sql.NullInt32{}, 2500, sql.NullInt32{Int32: 2, Valid: true}, "bundle2"},
+		{"no bundle, payments cover nothing", plan, sql.NullInt32{}, 1000, sql.NullInt32{}, "none"},
+		{"no bundle, no plan", nil, sql.NullInt32{}, 5000, sql.NullInt32{}, "none"},
+	}
+	for _, tt := range tests {
+		levels, bundleType := CalculateLevelsPurchased(tt.plan, tt.bundle, tt.totalPaid)
+		if levels != tt.wantLevels || bundleType.String != tt.wantType {
+			t.Errorf("%s: got %v, %q; want %v, %q", tt.name, levels, bundleType.String, tt.wantLevels, tt.wantType)
+		}
+	}
+}
+
+func TestOfferApplyPricePlan(t *testing.T) {
+	oldPlanID := uuid.New().String()
+	newPlan := testPricePlan()
+
+	offer := &Offer{
+		BundleLevels: sql.NullInt32{Int32: 2, Valid: true},
+		BasePrice:    sql.NullInt32{Int32: 2200, Valid: true},
+		PricePlanID:  sql.NullString{String: oldPlanID, Valid: true},
+	}
+	if err := offer.ApplyPricePlan(newPlan, 2); err != nil {
+		t.Fatalf("unchanged bundle: unexpected error %v", err)
+	}
+	if offer.BasePrice.Int32 != 2200 || offer.PricePlanID.String != oldPlanID {
+		t.Errorf("unchanged bundle repriced: base %d plan %s", offer.BasePrice.Int32, offer.PricePlanID.String)
+	}
+
+	if err := offer.ApplyPricePlan(newPlan, 4); err != nil {
+		t.Fatalf("changed bundle: unexpected error %v", err)
+	}
+	if offer.BasePrice.Int32 != 4000 || offer.PricePlanID.String != newPlan.ID.String() || offer.BundleLevels.Int32 != 4 {
+		t.Errorf("changed bundle: got base %d plan %s levels %d", offer.BasePrice.Int32, offer.PricePlanID.String, offer.BundleLevels.Int32)
+	}
+
+	var planErr *PricePlanError
+	if err := (&Offer{}).ApplyPricePlan(nil, 1); !errors.As(err, &planErr) {
+		t.Errorf("no plan: got %v, want *PricePlanError", err)
+	}
+}
+
+func TestCheckPricePlan(t *testing.T) {
+	today := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	valid := func() *PricePlan {
+		return &PricePlan{Name: " Summer ", Currency: "egp", EffectiveFrom: today, PerLevelPrice: 1400, Bundles: map[int32]int32{2: 2600}}
+	}
+	p := valid()
+	if err := checkPricePlan(p, today); err != nil {
+		t.Fatalf("valid plan: unexpected error %v", err)
+	}
+	if p.Name != "Summer" || p.Currency != "EGP" {
+		t.Errorf("valid plan not normalised: %q %q", p.Name, p.Currency)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *PricePlan)
+	}{
+		{"missing name", func(p *PricePlan) { p.Name = " " }},
+		{"bad currency", func(p *PricePlan) { p.Currency = "EURO" }},
+		{"effective in the past", func(p *PricePlan) { p.EffectiveFrom = today.AddDate(0, 0, -1) }},
+		{"zero per-level price", func(p *PricePlan) { p.PerLevelPrice = 0 }},
+		{"bundle too large", func(p *PricePlan) { p.Bundles[5] = 5000 }},
+		{"negative bundle price", func(p *PricePlan) { p.Bundles[3] = -1 }},
+	}
+	for _, tt := range tests {
+		p := valid()
+		tt.modify(p)
+		var planErr *PricePlanError
+		if err := checkPricePlan(p, today); !errors.As(err, &planErr) {
+			t.Errorf("%s: got %v, want *PricePlanError", tt.name, err)
+		}
+	}
+}
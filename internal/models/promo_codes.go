@@ -0,0 +1,371 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DiscountApprovalThresholdKey is the settings key holding the manual discount (percent of the base
+// price) above which an offer needs a second admin's approval before it is sent
+const DiscountApprovalThresholdKey = "discount_approval_threshold_percent"
+
+const defaultDiscountApprovalThreshold = 20
+
+// OfferDiscountAmount converts a discount into the amount it takes off basePrice
+// (percent discounts round down, never more than the base price)
+func OfferDiscountAmount(basePrice int32, discountType string, discountValue int32) int32 {
+	amount := discountValue
+	if discountType == "percent" {
+		amount = basePrice * discountValue / 100
+	}
+	if amount > basePrice {
+		amount = basePrice
+	}
+	if amount < 0 {
+		amount = 0
+	}
+	return amount
+}
+
+// ManualDiscountPercent is the share of basePrice taken off finalPrice beyond promoDiscount.
+// This covers both a typed discount and a final price edited by hand.
+func ManualDiscountPercent(basePrice, finalPrice, promoDiscount int32) float64 {
+	if basePrice <= 0 {
+		return 0
+	}
+	manual := basePrice - promoDiscount - finalPrice
+	if manual <= 0 {
+		return 0
+	}
+	return float64(manual) * 100 / float64(basePrice)
+}
+
+// ManualDiscountPercent returns the offer's discount not covered by its promo code or referrals, as a percent of the base price
+func (o *Offer) ManualDiscountPercent() float64 {
+	if !o.BasePrice.Valid || !o.FinalPrice.Valid {
+		return 0
+	}
+	var promoDiscount int32
+	if o.PromoCodeID.Valid {
+		promoDiscount = OfferDiscountAmount(o.BasePrice.Int32, o.DiscountType.String, o.DiscountValue.Int32)
+	}
+	return ManualDiscountPercent(o.BasePrice.Int32, o.FinalPrice.Int32, promoDiscount+o.ReferralDeduction())
+}
+
+// checkPromoCode reports why p cannot be applied to a bundle of bundleLevels on today
+func checkPromoCode(p *PromoCode, bundleLevels int32, today time.Time) error {
+	if !p.Active {
+		return &PromoCodeError{Message: fmt.Sprintf("Promo code %s is no longer active.", p.Code)}
+	}
+	if today.Before(p.ValidFrom) {
+		return &PromoCodeError{Message: fmt.Sprintf("Promo code %s is valid from %s.", p.Code, p.ValidFrom.Format("02 Jan 2006"))}
+	}
+	if p.ValidUntil.Valid && today.After(p.ValidUntil.Time) {
+		return &PromoCodeError{Message: fmt.Sprintf("Promo code %s expired on %s.", p.Code, p.ValidUntil.Time.Format("02 Jan 2006"))}
+	}
+	if len(p.EligibleBundles) > 0 {
+		eligible := false
+		for _, levels := range p.EligibleBundles {
+			eligible = eligible || levels == bundleLevels
+		}
+		if !eligible {
+			return &PromoCodeError{Message: fmt.Sprintf("Promo code %s does not apply to bundle %d.", p.Code, bundleLevels)}
+		}
+	}
+	if p.MaxUses.Valid && int32(p.Uses) >= p.MaxUses.Int32 {
+		return &PromoCodeError{Message: fmt.Sprintf("Promo code %s has been used up.", p.Code)}
+	}
+	return nil
+}
+
+// ApplyPromoCode sets the offer's discount and final price from p. The bundle must be chosen first.
+// Referral deductions stay on top of the promo discount.
+// An offer that already redeemed p keeps it even if the code has since expired or been used up.
+func (o *Offer) ApplyPromoCode(p *PromoCode, today time.Time) error {
+	redeemed := o.PromoCodeID.Valid && o.PromoCodeID.String == p.ID.String() && o.PromoRedeemedAt.Valid
+	if !redeemed {
+		if !o.BundleLevels.Valid {
+			return &PromoCodeError{Message: "Select a bundle before applying a promo code."}
+		}
+		if err := checkPromoCode(p, o.BundleLevels.Int32, today); err != nil {
+			return err
+		}
+	}
+	o.PromoCodeID = sql.NullString{String: p.ID.String(), Valid: true}
+	o.PromoCode = p.Code
+	o.DiscountType = sql.NullString{String: p.DiscountType, Valid: true}
+	o.DiscountValue = sql.NullInt32{Int32: p.DiscountValue, Valid: true}
+	if o.BasePrice.Valid {
+		final := o.BasePrice.Int32 - OfferDiscountAmount(o.BasePrice.Int32, p.DiscountType, p.DiscountValue) - o.ReferralDeduction()
+		if final < 0 {
+			final = 0
+		}
+		o.FinalPrice = sql.NullInt32{Int32: final, Valid: true}
+	}
+	return nil
+}
+
+// NormalizePromoCode upper-cases and trims a code as typed on a form
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// checkNewPromoCode validates a promo code before it is created
+func checkNewPromoCode(p *PromoCode) error {
+	p.Code = NormalizePromoCode(p.Code)
+	if p.Code == "" || strings.ContainsAny(p.Code, " \t") {
+		return &PromoCodeError{Message: "Code is required and cannot contain spaces."}
+	}
+	switch p.DiscountType {
+	case "amount":
+	case "percent":
+		if p.DiscountValue > 100 {
+			return &PromoCodeError{Message: "A percent discount cannot be more than 100%."}
+		}
+	default:
+		return &PromoCodeError{Message: "Discount type must be amount or percent."}
+	}
+	if p.DiscountValue <= 0 {
+		return &PromoCodeError{Message: "Discount must be greater than zero."}
+	}
+	if p.ValidFrom.IsZero() {
+		return &PromoCodeError{Message: "Valid-from date is required."}
+	}
+	if p.ValidUntil.Valid && p.ValidUntil.Time.Before(p.ValidFrom) {
+		return &PromoCodeError{Message: "Valid-until date cannot be before valid-from."}
+	}
+	if p.MaxUses.Valid && p.MaxUses.Int32 <= 0 {
+		return &PromoCodeError{Message: "Max uses must be greater than zero."}
+	}
+	for _, levels := range p.EligibleBundles {
+		if levels < 1 || levels > MaxBundleLevels {
+			return &PromoCodeError{Message: fmt.Sprintf("Bundles are 1 to %d levels.", MaxBundleLevels)}
+		}
+	}
+	return nil
+}
+
+// CreatePromoCode adds a promo code. Returns *PromoCodeError for an invalid or duplicate code.
+func CreatePromoCode(p *PromoCode, actorUserID string) error {
+	if err := checkNewPromoCode(p); err != nil {
+		return err
+	}
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var clash int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM promo_codes WHERE code = $1`, p.Code).Scan(&clash); err != nil {
+		return fmt.Errorf("failed to check promo code: %w", err)
+	}
+	if clash > 0 {
+		return &PromoCodeError{Message: fmt.Sprintf("Promo code %s already exists.", p.Code)}
+	}
+	var actor sql.NullString
+	if actorUserID != "" {
+		actor = sql.NullString{String: actorUserID, Valid: true}
+	}
+	err = tx.QueryRow(`
+		INSERT INTO promo_codes (code, discount_type, discount_value, valid_from, valid_until, max_uses, source, campaign, created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, p.Code, p.DiscountType, p.DiscountValue, p.ValidFrom, p.ValidUntil, p.MaxUses, p.Source, p.Campaign, actor).Scan(&p.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create promo code: %w", err)
+	}
+	for _, levels := range p.EligibleBundles {
+		_, err = tx.Exec(`
+			INSERT INTO promo_code_bundles (promo_code_id, levels) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, p.ID, levels)
+		if err != nil {
+			return fmt.Errorf("failed to add promo code bundle: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SetPromoCodeActive deactivates or reactivates a promo code. Offers already sent with it keep their discount.
+func SetPromoCodeActive(id uuid.UUID, active bool) error {
+	if _, err := db.DB.Exec(`UPDATE promo_codes SET active = $1 WHERE id = $2`, active, id); err != nil {
+		return fmt.Errorf("failed to update promo code: %w", err)
+	}
+	return nil
+}
+
+const promoCodeColumns = `
+	pc.id, pc.code, pc.discount_type, pc.discount_value, pc.valid_from, pc.valid_until, pc.max_uses,
+	COALESCE((SELECT string_agg(b.levels::text, ',' ORDER BY b.levels) FROM promo_code_bundles b WHERE b.promo_code_id = pc.id), ''),
+	pc.source, pc.campaign, pc.active, COALESCE(u.email, ''), pc.created_at,
+	(SELECT COUNT(*) FROM offers o WHERE o.promo_code_id = pc.id AND o.promo_redeemed_at IS NOT NULL)
+	FROM promo_codes pc
+	LEFT JOIN users u ON u.id = pc.created_by_user_id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPromoCode(row rowScanner) (*PromoCode, error) {
+	p := &PromoCode{}
+	var bundles string
+	err := row.Scan(&p.ID, &p.Code, &p.DiscountType, &p.DiscountValue, &p.ValidFrom, &p.ValidUntil, &p.MaxUses,
+		&bundles, &p.Source, &p.Campaign, &p.Active, &p.CreatedByEmail, &p.CreatedAt, &p.Uses)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range strings.Split(bundles, ",") {
+		if levels, err := strconv.Atoi(s); err == nil {
+			p.EligibleBundles = append(p.EligibleBundles, int32(levels))
+		}
+	}
+	return p, nil
+}
+
+// GetPromoCodes returns every promo code with its redemption count, active codes first
+func GetPromoCodes() ([]*PromoCode, error) {
+	rows, err := db.DB.Query(`SELECT ` + promoCodeColumns + ` ORDER BY pc.active DESC, pc.created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query promo codes: %w", err)
+	}
+	defer rows.Close()
+	var codes []*PromoCode
+	for rows.Next() {
+		p, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan promo code: %w", err)
+		}
+		codes = append(codes, p)
+	}
+	return codes, rows.Err()
+}
+
+// GetPromoCodeByCode looks a code up as typed on the offer form.
+// Returns *PromoCodeError when no such code exists.
+func GetPromoCodeByCode(code string) (*PromoCode, error) {
+	code = NormalizePromoCode(code)
+	p, err := scanPromoCode(db.DB.QueryRow(`SELECT `+promoCodeColumns+` WHERE pc.code = $1`, code))
+	if err == sql.ErrNoRows {
+		return nil, &PromoCodeError{Message: fmt.Sprintf("Unknown promo code %s.", code)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promo code: %w", err)
+	}
+	return p, nil
+}
+
+// effectRedeemPromoCode redeems the offer's promo code when the offer is sent. The code row is
+// locked so concurrent offers cannot exceed max_uses; returns *PromoCodeError when the code no
+// longer applies.
+func effectRedeemPromoCode(q sqlExecer, leadID uuid.UUID, actorUserID string, now time.Time) error {
+	var promoID sql.NullString
+	var redeemedAt sql.NullTime
+	var bundleLevels sql.NullInt32
+	err := q.QueryRow(`
+		SELECT promo_code_id, promo_redeemed_at, bundle_levels FROM offers WHERE lead_id = $1 FOR UPDATE
+	`, leadID).Scan(&promoID, &redeemedAt, &bundleLevels)
+	if err == sql.ErrNoRows || (err == nil && (!promoID.Valid || redeemedAt.Valid)) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get offer promo code: %w", err)
+	}
+	p, err := scanPromoCode(q.QueryRow(`SELECT `+promoCodeColumns+` WHERE pc.id = $1 FOR UPDATE OF pc`, promoID.String))
+	if err != nil {
+		return fmt.Errorf("failed to lock promo code: %w", err)
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if err := checkPromoCode(p, bundleLevels.Int32, today); err != nil {
+		return err
+	}
+	if _, err := q.Exec(`UPDATE offers SET promo_redeemed_at = $1 WHERE lead_id = $2`, now, leadID); err != nil {
+		return fmt.Errorf("failed to redeem promo code: %w", err)
+	}
+	return nil
+}
+
+// GetDiscountApprovalThreshold returns the manual discount percent above which offers need approval
+func GetDiscountApprovalThreshold() (int, error) {
+	return getDiscountApprovalThreshold(db.DB)
+}
+
+func getDiscountApprovalThreshold(q sqlExecer) (int, error) {
+	var value string
+	err := q.QueryRow(`SELECT value FROM settings WHERE key = $1`, DiscountApprovalThresholdKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return defaultDiscountApprovalThreshold, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get discount approval threshold: %w", err)
+	}
+	threshold, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultDiscountApprovalThreshold, nil
+	}
+	return threshold, nil
+}
+
+// SetDiscountApprovalThreshold changes the manual discount approval threshold (0-100 percent)
+func SetDiscountApprovalThreshold(percent int) error {
+	if percent < 0 || percent > 100 {
+		return &PromoCodeError{Message: "Approval threshold must be between 0 and 100%."}
+	}
+	_, err := db.DB.Exec(`
+		INSERT INTO settings (key, value, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
+	`, DiscountApprovalThresholdKey, strconv.Itoa(percent), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to set discount approval threshold: %w", err)
+	}
+	return nil
+}
+
+// ApproveOfferDiscount records a second admin's approval of the lead's manual discount.
+// Returns *PromoCodeError when no approval is needed or the approver set the discount themselves.
+func ApproveOfferDiscount(leadID uuid.UUID, approverUserID string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	o := &Offer{LeadID: leadID}
+	err = tx.QueryRow(`
+		SELECT base_price, final_price, discount_type, discount_value, promo_code_id, discount_set_by_user_id, discount_approved_by_user_id
+		FROM offers WHERE lead_id = $1 FOR UPDATE
+	`, leadID).Scan(&o.BasePrice, &o.FinalPrice, &o.DiscountType, &o.DiscountValue, &o.PromoCodeID,
+		&o.DiscountSetByUserID, &o.DiscountApprovedByUserID)
+	if err == sql.ErrNoRows {
+		return &PromoCodeError{Message: "This lead has no offer."}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get offer: %w", err)
+	}
+	threshold, err := getDiscountApprovalThreshold(tx)
+	if err != nil {
+		return err
+	}
+	if o.ManualDiscountPercent() <= float64(threshold) {
+		return &PromoCodeError{Message: "This discount does not need approval."}
+	}
+	if o.DiscountApprovedByUserID.Valid {
+		return &PromoCodeError{Message: "This discount is already approved."}
+	}
+	if o.DiscountSetByUserID.Valid && o.DiscountSetByUserID.String == approverUserID {
+		return &PromoCodeError{Message: "A discount must be approved by a different admin than the one who set it."}
+	}
+	_, err = tx.Exec(`
+		UPDATE offers SET discount_approved_by_user_id = $1, discount_approved_at = $2 WHERE lead_id = $3
+	`, approverUserID, time.Now(), leadID)
+	if err != nil {
+		return fmt.Errorf("failed to approve discount: %w", err)
+	}
+	return tx.Commit()
+}
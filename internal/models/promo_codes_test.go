@@ -0,0 +1,130 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestManualDiscountPercent(t *testing.T) {
+	tests := []struct {
+		name  string
+		offer Offer
+		want  float64
+	}{
+		{"no discount", Offer{BasePrice: sql.NullInt32{Int32: 4000, Valid: true}, FinalPrice: sql.NullInt32{Int32: 4000, Valid: true}}, 0},
+		{"lowered final price", Offer{BasePrice: sql.NullInt32{Int32: 4000, Valid: true}, FinalPrice: sql.NullInt32{Int32: 3000, Valid: true}}, 25},
+		{"promo discount is not manual", Offer{
+			BasePrice: sql.NullInt32{Int32: 4000, Valid: true}, FinalPrice: sql.NullInt32{Int32: 3600, Valid: true},
+			DiscountType: sql.NullString{String: "percent", Valid: true}, DiscountValue: sql.NullInt32{Int32: 10, Valid: true},
+			PromoCodeID: sql.NullString{String: uuid.New().String(), Valid: true},
+		}, 0},
+		{"manual discount on top of promo", Offer{
+			BasePrice: sql.NullInt32{Int32: 4000, Valid: true}, FinalPrice: sql.NullInt32{Int32: 3200, Valid: true},
+			DiscountType: sql.NullString{String: "amount", Valid: true}, DiscountValue: sql.NullInt32{Int32: 400, Valid: true},
+			PromoCodeID: sql.NullString{String: uuid.New().String(), Valid: true},
+		}, 10},
+		{"no base price", Offer{FinalPrice: sql.NullInt32{Int32: 1000, Valid: true}}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.offer.ManualDiscountPercent(); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOfferApplyPromoCode(t *testing.T) {
+	today := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	promo := &PromoCode{
+		ID: uuid.New(), Code: "SPRING10", DiscountType: "percent", DiscountValue: 10,
+		ValidFrom: today.AddDate(0, 0, -7), ValidUntil: sql.NullTime{Time: today.AddDate(0, 0, 7), Valid: true},
+		MaxUses: sql.NullInt32{Int32: 5, Valid: true}, EligibleBundles: []int32{2, 4}, Active: true, Uses: 4,
+	}
+	bundle := func(levels, base int32) *Offer {
+		return &Offer{
+			BundleLevels: sql.NullInt32{Int32: levels, Valid: true},
+			BasePrice:    sql.NullInt32{Int32: base, Valid: true},
+			FinalPrice:   sql.NullInt32{Int32: base, Valid: true},
+		}
+	}
+
+	offer := bundle(2, 2400)
+	if err := offer.ApplyPromoCode(promo, today); err != nil {
+		t.Fatalf("eligible offer: unexpected error %v", err)
+	}
+	if offer.FinalPrice.Int32 != 2160 || offer.DiscountType.String != "percent" || offer.PromoCodeID.String != promo.ID.String() {
+		t.Errorf("eligible offer: got final %d type %q promo %q", offer.FinalPrice.Int32, offer.DiscountType.String, offer.PromoCodeID.String)
+	}
+
+	tests := []struct {
+		name   string
+		offer  *Offer
+		modify func(p *PromoCode)
+	}{
+		{"bundle not eligible", bundle(3, 3300), nil},
+		{"no bundle", &Offer{}, nil},
+		{"used up", bundle(2, 2400), func(p *PromoCode) { p.Uses = 5 }},
+		{"expired", bundle(2, 2400), func(p *PromoCode) { p.ValidUntil.Time = today.AddDate(0, 0, -1) }},
+		{"not yet valid", bundle(2, 2400), func(p *PromoCode) { p.ValidFrom = today.AddDate(0, 0, 1) }},
+		{"inactive", bundle(2, 2400), func(p *PromoCode) { p.Active = false }},
+	}
+	for _, tt := range tests {
+		p := *promo
+		if tt.modify != nil {
+			tt.modify(&p)
+		}
+		var promoErr *PromoCodeError
+		if err := tt.offer.ApplyPromoCode(&p, today); !errors.As(err, &promoErr) {
+			t.Errorf("%s: got %v, want *PromoCodeError", tt.name, err)
+		}
+	}
+
+	// An offer that already redeemed the code keeps it after the code is used up
+	redeemed := bundle(2, 2400)
+	redeemed.PromoCodeID = sql.NullString{String: promo.ID.String(), Valid: true}
+	redeemed.PromoRedeemedAt = sql.NullTime{Time: today, Valid: true}
+	usedUp := *promo
+	usedUp.Uses = 5
+	if err := redeemed.ApplyPromoCode(&usedUp, today); err != nil {
+		t.Errorf("redeemed offer: unexpected error %v", err)
+	}
+}
+
+func TestCheckNewPromoCode(t *testing.T) {
+	from := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	valid := func() *PromoCode {
+		return &PromoCode{Code: " spring10 ", DiscountType: "percent", DiscountValue: 10, ValidFrom: from}
+	}
+	p := valid()
+	if err := checkNewPromoCode(p); err != nil {
+		t.Fatalf("valid code: unexpected error %v", err)
+	}
+	if p.Code != "SPRING10" {
+		t.Errorf("code not normalised: %q", p.Code)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *PromoCode)
+	}{
+		{"blank code", func(p *PromoCode) { p.Code = " " }},
+		{"code with space", func(p *PromoCode) { p.Code = "SPRING 10" }},
+		{"percent over 100", func(p *PromoCode) { p.DiscountValue = 120 }},
+		{"zero discount", func(p *PromoCode) { p.DiscountValue = 0 }},
+		{"unknown type", func(p *PromoCode) { p.DiscountType = "free" }},
+		{"until before from", func(p *PromoCode) { p.ValidUntil = sql.NullTime{Time: from.AddDate(0, 0, -1), Valid: true} }},
+		{"zero max uses", func(p *PromoCode) { p.MaxUses = sql.NullInt32{Int32: 0, Valid: true} }},
+		{"bundle too large", func(p *PromoCode) { p.EligibleBundles = []int32{5} }},
+	}
+	for _, tt := range tests {
+		p := valid()
+		tt.modify(p)
+		var promoErr *PromoCodeError
+		if err := checkNewPromoCode(p); !errors.As(err, &promoErr) {
+			t.Errorf("%s: got %v, want *PromoCodeError", tt.name, err)
+		}
+	}
+}
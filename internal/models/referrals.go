@@ -0,0 +1,475 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Settings keys for the referral programme
+const (
+	ReferralDiscountAmountKey = "referral_discount_amount" // EGP off a referred lead's offer
+	ReferralRewardTypeKey     = "referral_reward_type"     // credit | cashback
+	ReferralRewardAmountKey   = "referral_reward_amount"   // EGP the referrer earns per referred lead that pays in full
+)
+
+// Referral reward types (referral_rewards.reward_type)
+const (
+	ReferralCredit   = "credit"
+	ReferralCashback = "cashback"
+)
+
+// maxReferralAmount caps the referral discount and reward so a typo cannot wipe out an offer
+const maxReferralAmount = 100000
+
+// ReferralSettings are the admin-editable referral discount and reward
+type ReferralSettings struct {
+	DiscountAmount int32  // EGP off the referred lead's offer; 0 gives no discount
+	RewardType     string // credit (applied to the referrer's offer) or cashback (paid out)
+	RewardAmount   int32  // EGP per referred lead that pays in full; 0 issues no rewards
+}
+
+// ValidateReferralSettings checks the settings before they are saved. Returns *ReferralError.
+func ValidateReferralSettings(s ReferralSettings) error {
+	if s.DiscountAmount < 0 || s.DiscountAmount > maxReferralAmount {
+		return &ReferralError{Message: fmt.Sprintf("Referral discount must be between 0 and %d EGP.", maxReferralAmount)}
+	}
+	if s.RewardType != ReferralCredit && s.RewardType != ReferralCashback {
+		return &ReferralError{Message: "Reward must be a credit or a cash-back."}
+	}
+	if s.RewardAmount < 0 || s.RewardAmount > maxReferralAmount {
+		return &ReferralError{Message: fmt.Sprintf("Referral reward must be between 0 and %d EGP.", maxReferralAmount)}
+	}
+	return nil
+}
+
+// GetReferralSettings returns the referral discount and reward, with defaults (nothing) for missing rows
+func GetReferralSettings() (ReferralSettings, error) {
+	return getReferralSettings(db.DB)
+}
+
+func getReferralSettings(q sqlExecer) (ReferralSettings, error) {
+	s := ReferralSettings{RewardType: ReferralCredit}
+	var discount, rewardType, reward sql.NullString
+	err := q.QueryRow(`
+		SELECT (SELECT value FROM settings WHERE key = $1), (SELECT value FROM settings WHERE key = $2),
+			(SELECT value FROM settings WHERE key = $3)
+	`, ReferralDiscountAmountKey, ReferralRewardTypeKey, ReferralRewardAmountKey).Scan(&discount, &rewardType, &reward)
+	if err != nil {
+		return s, fmt.Errorf("failed to get referral settings: %w", err)
+	}
+	if n, err := strconv.Atoi(discount.String); err == nil && n > 0 {
+		s.DiscountAmount = int32(n)
+	}
+	if rewardType.String == ReferralCashback {
+		s.RewardType = ReferralCashback
+	}
+	if n, err := strconv.Atoi(reward.String); err == nil && n > 0 {
+		s.RewardAmount = int32(n)
+	}
+	return s, nil
+}
+
+// SetReferralSettings saves the referral discount and reward. Offers already discounted and
+// rewards already issued keep their amounts. Returns *ReferralError when a value is out of range.
+func SetReferralSettings(s ReferralSettings) error {
+	if err := ValidateReferralSettings(s); err != nil {
+		return err
+	}
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	for key, value := range map[string]string{
+		ReferralDiscountAmountKey: strconv.Itoa(int(s.DiscountAmount)),
+		ReferralRewardTypeKey:     s.RewardType,
+		ReferralRewardAmountKey:   strconv.Itoa(int(s.RewardAmount)),
+	} {
+		_, err := tx.Exec(`
+			INSERT INTO settings (key, value, updated_at) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
+		`, key, value, now)
+		if err != nil {
+			return fmt.Errorf("failed to set referral settings: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ReferralDeduction is what the offer's final price already has taken off for referrals:
+// the discount for being referred plus any referral credit applied
+func (o *Offer) ReferralDeduction() int32 {
+	return o.ReferralDiscount.Int32 + o.ReferralCredit.Int32
+}
+
+// SearchLeadsForReferrer returns up to limit leads whose name or phone contains query, for the
+// referrer picker. exclude (the lead being edited, or uuid.Nil) is left out.
+func SearchLeadsForReferrer(query string, exclude uuid.UUID, limit int) ([]*LeadSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if len(query) < 2 {
+		return nil, nil
+	}
+	rows, err := db.DB.Query(`
+		SELECT id, full_name, phone, status
+		FROM leads
+		WHERE (full_name ILIKE '%' || $1 || '%' OR phone LIKE '%' || $1 || '%') AND id <> $2 AND archived_at IS NULL
+		ORDER BY (status IN ('in_classes', 'paused')) DESC, full_name
+		LIMIT $3
+	`, query, exclude, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search leads: %w", err)
+	}
+	defer rows.Close()
+	var results []*LeadSearchResult
+	for rows.Next() {
+		l := &LeadSearchResult{}
+		if err := rows.Scan(&l.ID, &l.FullName, &l.Phone, &l.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan lead: %w", err)
+		}
+		results = append(results, l)
+	}
+	return results, rows.Err()
+}
+
+// SetLeadReferrer records who referred a lead (uuid.Nil clears it). Setting the first referrer takes
+// the referral discount off the lead's offer while nothing has been paid on it; clearing the referrer
+// puts the discount back. Returns *ReferralError for a self-referral, a referral loop, an unknown
+// referrer or a lead whose referral reward has already been issued.
+func SetLeadReferrer(leadID, referrerID uuid.UUID) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current sql.NullString
+	err = tx.QueryRow(`SELECT referred_by_lead_id::text FROM leads WHERE id = $1 FOR UPDATE`, leadID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return &ReferralError{Message: "Lead not found."}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get lead referrer: %w", err)
+	}
+	if (referrerID == uuid.Nil && !current.Valid) || (current.Valid && current.String == referrerID.String()) {
+		return tx.Commit()
+	}
+	var rewarded bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM referral_rewards WHERE referred_lead_id = $1)`, leadID).Scan(&rewarded); err != nil {
+		return fmt.Errorf("failed to check referral reward: %w", err)
+	}
+	if rewarded {
+		return &ReferralError{Message: "The referral reward for this lead has already been issued, so the referrer can no longer change."}
+	}
+
+	now := time.Now()
+	if referrerID == uuid.Nil {
+		if _, err := tx.Exec(`UPDATE leads SET referred_by_lead_id = NULL, updated_at = $1 WHERE id = $2`, now, leadID); err != nil {
+			return fmt.Errorf("failed to clear lead referrer: %w", err)
+		}
+		_, err := tx.Exec(`
+			UPDATE offers SET final_price = final_price + referral_discount, referral_discount = NULL, updated_at = $1
+			WHERE lead_id = $2 AND referral_discount IS NOT NULL
+		`, now, leadID)
+		if err != nil {
+			return fmt.Errorf("failed to remove referral discount: %w", err)
+		}
+		return tx.Commit()
+	}
+
+	if referrerID == leadID {
+		return &ReferralError{Message: "A lead cannot refer themselves."}
+	}
+	// Walk up the referrer's own chain; finding this lead there would close a loop
+	var loop, found bool
+	err = tx.QueryRow(`
+		WITH RECURSIVE chain(id) AS (
+			SELECT id FROM leads WHERE id = $1
+			UNION
+			SELECT l.referred_by_lead_id FROM leads l JOIN chain c ON l.id = c.id WHERE l.referred_by_lead_id IS NOT NULL
+		)
+		SELECT EXISTS(SELECT 1 FROM chain WHERE id = $1), EXISTS(SELECT 1 FROM chain WHERE id = $2)
+	`, referrerID, leadID).Scan(&found, &loop)
+	if err != nil {
+		return fmt.Errorf("failed to check referral chain: %w", err)
+	}
+	if !found {
+		return &ReferralError{Message: "Referrer not found."}
+	}
+	if loop {
+		return &ReferralError{Message: "That lead was referred (directly or indirectly) by this lead."}
+	}
+	if _, err := tx.Exec(`UPDATE leads SET referred_by_lead_id = $1, updated_at = $2 WHERE id = $3`, referrerID, now, leadID); err != nil {
+		return fmt.Errorf("failed to set lead referrer: %w", err)
+	}
+	if !current.Valid {
+		settings, err := getReferralSettings(tx)
+		if err != nil {
+			return err
+		}
+		if settings.DiscountAmount > 0 {
+			_, err := tx.Exec(`
+				UPDATE offers
+				SET referral_discount = LEAST($1, final_price), final_price = final_price - LEAST($1, final_price), updated_at = $2
+				WHERE lead_id = $3 AND referral_discount IS NULL AND final_price > 0
+				AND NOT EXISTS (SELECT 1 FROM lead_payments WHERE lead_id = $3)
+			`, settings.DiscountAmount, now, leadID)
+			if err != nil {
+				return fmt.Errorf("failed to apply referral discount: %w", err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// GetNewOfferReferralDiscount returns the referral discount a first priced offer for leadID gets:
+// the configured amount when the lead has a referrer, otherwise nothing.
+func GetNewOfferReferralDiscount(leadID uuid.UUID) (sql.NullInt32, error) {
+	var referred bool
+	err := db.DB.QueryRow(`SELECT referred_by_lead_id IS NOT NULL FROM leads WHERE id = $1`, leadID).Scan(&referred)
+	if err != nil {
+		return sql.NullInt32{}, fmt.Errorf("failed to get lead referrer: %w", err)
+	}
+	if !referred {
+		return sql.NullInt32{}, nil
+	}
+	settings, err := GetReferralSettings()
+	if err != nil {
+		return sql.NullInt32{}, err
+	}
+	return sql.NullInt32{Int32: settings.DiscountAmount, Valid: settings.DiscountAmount > 0}, nil
+}
+
+// effectIssueReferralReward issues the referrer's reward when a referred lead pays in full.
+// One reward per referred lead; no-op without a referrer or when rewards are off.
+func effectIssueReferralReward(q sqlExecer, leadID uuid.UUID, actorUserID string, now time.Time) error {
+	settings, err := getReferralSettings(q)
+	if err != nil {
+		return err
+	}
+	if settings.RewardAmount <= 0 {
+		return nil
+	}
+	_, err = q.Exec(`
+		INSERT INTO referral_rewards (referrer_lead_id, referred_lead_id, reward_type, amount, issued_at)
+		SELECT referred_by_lead_id, id, $2, $3, $4 FROM leads WHERE id = $1 AND referred_by_lead_id IS NOT NULL
+		ON CONFLICT (referred_lead_id) DO NOTHING
+	`, leadID, settings.RewardType, settings.RewardAmount, now)
+	if err != nil {
+		return fmt.Errorf("failed to issue referral reward: %w", err)
+	}
+	return nil
+}
+
+// GetReferrals returns the leads referrerID referred, newest first, with the reward each earned
+func GetReferrals(referrerID uuid.UUID) ([]*Referral, error) {
+	rows, err := db.DB.Query(`
+		SELECT l.id, l.full_name, l.phone, l.status, l.created_at,
+			rr.id, rr.reward_type, rr.amount, rr.issued_at, rr.settled_at, rr.payment_method
+		FROM leads l
+		LEFT JOIN referral_rewards rr ON rr.referred_lead_id = l.id
+		WHERE l.referred_by_lead_id = $1
+		ORDER BY l.created_at DESC
+	`, referrerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query referrals: %w", err)
+	}
+	defer rows.Close()
+	var referrals []*Referral
+	for rows.Next() {
+		ref := &Referral{}
+		var rewardID, rewardType, paymentMethod sql.NullString
+		var amount sql.NullInt32
+		var issuedAt, settledAt sql.NullTime
+		if err := rows.Scan(&ref.LeadID, &ref.FullName, &ref.Phone, &ref.Status, &ref.CreatedAt,
+			&rewardID, &rewardType, &amount, &issuedAt, &settledAt, &paymentMethod); err != nil {
+			return nil, fmt.Errorf("failed to scan referral: %w", err)
+		}
+		if rewardID.Valid {
+			ref.Reward = &ReferralReward{
+				ID:             uuid.MustParse(rewardID.String),
+				ReferrerLeadID: referrerID,
+				ReferredLeadID: ref.LeadID,
+				ReferredName:   ref.FullName,
+				RewardType:     rewardType.String,
+				Amount:         amount.Int32,
+				IssuedAt:       issuedAt.Time,
+				SettledAt:      settledAt,
+				PaymentMethod:  paymentMethod,
+			}
+		}
+		referrals = append(referrals, ref)
+	}
+	return referrals, rows.Err()
+}
+
+// ApplyReferralCredit takes all of the lead's unused referral credit off their own offer's final
+// price. Returns *ReferralError when there is no credit or it is more than is still due on the offer.
+func ApplyReferralCredit(leadID uuid.UUID, actorUserID string) (int32, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var finalPrice sql.NullInt32
+	err = tx.QueryRow(`SELECT final_price FROM offers WHERE lead_id = $1 FOR UPDATE`, leadID).Scan(&finalPrice)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to get offer: %w", err)
+	}
+	if !finalPrice.Valid || finalPrice.Int32 <= 0 {
+		return 0, &ReferralError{Message: "The lead needs a priced offer before referral credit can be applied."}
+	}
+	var credit int32
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0) FROM referral_rewards
+		WHERE referrer_lead_id = $1 AND reward_type = $2 AND settled_at IS NULL
+	`, leadID, ReferralCredit).Scan(&credit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get referral credit: %w", err)
+	}
+	if credit == 0 {
+		return 0, &ReferralError{Message: "This lead has no referral credit to apply."}
+	}
+	facts, err := loadLeadFactsTx(tx, leadID)
+	if err != nil {
+		return 0, err
+	}
+	if due := finalPrice.Int32 - facts.TotalCoursePaid; credit > due {
+		return 0, &ReferralError{Message: fmt.Sprintf("Referral credit of %d EGP is more than the %d EGP still due on the offer.", credit, due)}
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE offers SET referral_credit = COALESCE(referral_credit, 0) + $1, final_price = final_price - $1, updated_at = $2
+		WHERE lead_id = $3
+	`, credit, now, leadID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply referral credit: %w", err)
+	}
+	var settledBy sql.NullString
+	if actorUserID != "" {
+		settledBy = sql.NullString{String: actorUserID, Valid: true}
+	}
+	_, err = tx.Exec(`
+		UPDATE referral_rewards SET settled_at = $1, settled_by_user_id = $2
+		WHERE referrer_lead_id = $3 AND reward_type = $4 AND settled_at IS NULL
+	`, now, settledBy, leadID, ReferralCredit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to settle referral credit: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit referral credit: %w", err)
+	}
+	return credit, nil
+}
+
+// PayReferralCashback records paying out a cash-back reward: the reward is settled and the
+// payout is posted as an OUT transaction (category referral) against the referrer.
+// Returns *ReferralError when the reward is not an unpaid cash-back.
+func PayReferralCashback(rewardID uuid.UUID, paymentMethod, actorUserID string) error {
+	allowedMethods := map[string]bool{
+		"vodafone_cash": true, "bank_transfer": true, "paypal": true, "other": true,
+	}
+	if !allowedMethods[paymentMethod] {
+		return &ReferralError{Message: "Choose how the cash-back was paid."}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var referrerID uuid.UUID
+	var rewardType, referredName string
+	var amount int32
+	var settledAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT rr.referrer_lead_id, rr.reward_type, rr.amount, rr.settled_at, l.full_name
+		FROM referral_rewards rr
+		JOIN leads l ON l.id = rr.referred_lead_id
+		WHERE rr.id = $1
+		FOR UPDATE OF rr
+	`, rewardID).Scan(&referrerID, &rewardType, &amount, &settledAt, &referredName)
+	if err == sql.ErrNoRows {
+		return &ReferralError{Message: "Referral reward not found."}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get referral reward: %w", err)
+	}
+	if rewardType != ReferralCashback {
+		return &ReferralError{Message: "This reward is a credit; apply it to the referrer's offer instead."}
+	}
+	if settledAt.Valid {
+		return &ReferralError{Message: "This cash-back has already been paid."}
+	}
+
+	now := time.Now()
+	var settledBy sql.NullString
+	if actorUserID != "" {
+		settledBy = sql.NullString{String: actorUserID, Valid: true}
+	}
+	_, err = tx.Exec(`
+		UPDATE referral_rewards SET settled_at = $1, payment_method = $2, settled_by_user_id = $3 WHERE id = $4
+	`, now, paymentMethod, settledBy, rewardID)
+	if err != nil {
+		return fmt.Errorf("failed to settle referral cash-back: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO transactions (id, transaction_date, transaction_type, category, amount, payment_method, lead_id, ref_type, ref_id, ref_key, notes, created_at, updated_at)
+		VALUES (gen_random_uuid(), $1::date, 'OUT', 'referral', $2::integer, $3::text, $4::uuid, 'referral_reward', $5::text, $6::text, $7, $8::timestamp with time zone, $8::timestamp with time zone)
+		ON CONFLICT (ref_key) DO NOTHING
+	`, now.Format("2006-01-02"), amount, paymentMethod, referrerID, rewardID.String(), "referral:"+rewardID.String(),
+		"Referral cash-back for "+referredName, now)
+	if err != nil {
+		return fmt.Errorf("failed to post referral cash-back: %w", err)
+	}
+	return tx.Commit()
+}
+
+// GetReferralLeaderboard returns every lead who has referred someone, most referrals that paid in full first
+func GetReferralLeaderboard() ([]*ReferralLeaderboardRow, error) {
+	rows, err := db.DB.Query(`
+		SELECT r.id, r.full_name, r.phone,
+			COUNT(l.id),
+			COUNT(l.id) FILTER (WHERE rr.id IS NOT NULL
+				OR EXISTS (SELECT 1 FROM lead_status_history h WHERE h.lead_id = l.id AND h.new_status = 'paid_full')),
+			COALESCE(SUM(rr.amount), 0),
+			COALESCE(SUM(rr.amount) FILTER (WHERE rr.reward_type = $1 AND rr.settled_at IS NULL), 0),
+			COALESCE(SUM(rr.amount) FILTER (WHERE rr.reward_type = $2 AND rr.settled_at IS NULL), 0)
+		FROM leads r
+		JOIN leads l ON l.referred_by_lead_id = r.id
+		LEFT JOIN referral_rewards rr ON rr.referred_lead_id = l.id
+		GROUP BY r.id, r.full_name, r.phone
+		ORDER BY 5 DESC, 4 DESC, r.full_name
+	`, ReferralCredit, ReferralCashback)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query referral leaderboard: %w", err)
+	}
+	defer rows.Close()
+	var board []*ReferralLeaderboardRow
+	for rows.Next() {
+		row := &ReferralLeaderboardRow{}
+		if err := rows.Scan(&row.LeadID, &row.FullName, &row.Phone, &row.Referred, &row.Converted,
+			&row.Rewarded, &row.CreditAvailable, &row.CashbackOwed); err != nil {
+			return nil, fmt.Errorf("failed to scan referral leaderboard: %w", err)
+		}
+		board = append(board, row)
+	}
+	return board, rows.Err()
+}
+
+// ReferralConversionRate is the share of referred leads that paid in full, as a whole percent
+func (r *ReferralLeaderboardRow) ReferralConversionRate() int {
+	if r.Referred == 0 {
+		return 0
+	}
+	return r.Converted * 100 / r.Referred
+}
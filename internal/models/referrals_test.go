@@ -0,0 +1,115 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateReferralSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings ReferralSettings
+		wantErr  bool
+	}{
+		{"credit reward", ReferralSettings{DiscountAmount: 200, RewardType: ReferralCredit, RewardAmount: 300}, false},
+		{"cash-back reward", ReferralSettings{DiscountAmount: 0, RewardType: ReferralCashback, RewardAmount: 250}, false},
+		{"programme off", ReferralSettings{RewardType: ReferralCredit}, false},
+		{"negative discount", ReferralSettings{DiscountAmount: -1, RewardType: ReferralCredit}, true},
+		{"discount too large", ReferralSettings{DiscountAmount: maxReferralAmount + 1, RewardType: ReferralCredit}, true},
+		{"unknown reward type", ReferralSettings{RewardType: "voucher", RewardAmount: 100}, true},
+		{"negative reward", ReferralSettings{RewardType: ReferralCashback, RewardAmount: -50}, true},
+	}
+	for _, tt := range tests {
+		err := ValidateReferralSettings(tt.settings)
+		var referralErr *ReferralError
+		if tt.wantErr && !errors.As(err, &referralErr) {
+			t.Errorf("%s: want *ReferralError, got %v", tt.name, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+	}
+}
+
+func TestOfferReferralDeduction(t *testing.T) {
+	tests := []struct {
+		name  string
+		offer Offer
+		want  int32
+	}{
+		{"none", Offer{}, 0},
+		{"discount only", Offer{ReferralDiscount: sql.NullInt32{Int32: 200, Valid: true}}, 200},
+		{"credit only", Offer{ReferralCredit: sql.NullInt32{Int32: 300, Valid: true}}, 300},
+		{"both", Offer{ReferralDiscount: sql.NullInt32{Int32: 200, Valid: true}, ReferralCredit: sql.NullInt32{Int32: 300, Valid: true}}, 500},
+	}
+	for _, tt := range tests {
+		if got := tt.offer.ReferralDeduction(); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReferralDeductionIsNotManualDiscount(t *testing.T) {
+	offer := Offer{
+		BasePrice:        sql.NullInt32{Int32: 4000, Valid: true},
+		FinalPrice:       sql.NullInt32{Int32: 3800, Valid: true},
+		ReferralDiscount: sql.NullInt32{Int32: 200, Valid: true},
+	}
+	if got := offer.ManualDiscountPercent(); got != 0 {
+		t.Errorf("referral discount only: got %v%%, want 0", got)
+	}
+	offer.FinalPrice.Int32 = 3400
+	if got := offer.ManualDiscountPercent(); got != 10 {
+		t.Errorf("manual discount on top of referral: got %v%%, want 10", got)
+	}
+}
+
+func TestApplyPromoCodeKeepsReferralDeduction(t *testing.T) {
+	today := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	promo := &PromoCode{
+		ID: uuid.New(), Code: "SPRING10", DiscountType: "percent", DiscountValue: 10,
+		ValidFrom: today.AddDate(0, 0, -7), Active: true,
+	}
+	offer := &Offer{
+		BundleLevels:     sql.NullInt32{Int32: 2, Valid: true},
+		BasePrice:        sql.NullInt32{Int32: 2400, Valid: true},
+		FinalPrice:       sql.NullInt32{Int32: 2200, Valid: true},
+		ReferralDiscount: sql.NullInt32{Int32: 200, Valid: true},
+	}
+	if err := offer.ApplyPromoCode(promo, today); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if offer.FinalPrice.Int32 != 1960 {
+		t.Errorf("final price: got %d, want 1960", offer.FinalPrice.Int32)
+	}
+
+	offer.ReferralCredit = sql.NullInt32{Int32: 5000, Valid: true}
+	if err := offer.ApplyPromoCode(promo, today); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if offer.FinalPrice.Int32 != 0 {
+		t.Errorf("deductions above the price: got final %d, want 0", offer.FinalPrice.Int32)
+	}
+}
+
+func TestReferralConversionRate(t *testing.T) {
+	tests := []struct {
+		referred, converted int
+		want                int
+	}{
+		{0, 0, 0},
+		{4, 1, 25},
+		{3, 2, 66},
+		{5, 5, 100},
+	}
+	for _, tt := range tests {
+		row := &ReferralLeaderboardRow{Referred: tt.referred, Converted: tt.converted}
+		if got := row.ReferralConversionRate(); got != tt.want {
+			t.Errorf("%d of %d: got %d%%, want %d%%", tt.converted, tt.referred, got, tt.want)
+		}
+	}
+}
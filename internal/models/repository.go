@@ -32,33 +32,12 @@ const (
 	PaymentStateUnpaid   = "UNPAID"
 	PaymentStateDeposit  = "DEPOSIT"
 	PaymentStatePaidFull = "PAID_FULL"
-)
-
-// MapOldStatusToStage maps legacy status values to new workflow stages for backward compatibility
-// Old statuses that don't map directly are converted to the nearest equivalent stage
-func MapOldStatusToStage(oldStatus string) string {
-	mapping := map[string]string{
-		// Direct mappings
-		"lead_created":   StageNewLead,
-		"test_booked":    StageTestBooked,
-		"tested":         StageTested,
-		"offer_sent":     StageOfferSent,
-		"ready_to_start": StageReadyToStart,
-		// Payment-based statuses need context (handled separately with payment state)
-		"booking_confirmed": StageOfferSent, // Default mapping, will be upgraded based on payment
-		"paid_full":         StageBookingConfirmedPaidFull,
-		"deposit_paid":      StageBookingConfirmedDeposit,
-		// Schedule-based statuses
-		"waiting_for_round": StageScheduleSet,
-		"schedule_assigned": StageScheduleSet,
-	}
 
-	if mapped, ok := mapping[oldStatus]; ok {
-		return mapped
-	}
-	// Default: treat unknown status as new lead
-	return StageNewLead
-}
+	// PaymentFilterBookNotShipped is the lead list's payment filter for paid students whose printed book has not been sent
+	PaymentFilterBookNotShipped = "PAID_BOOK_NOT_SHIPPED"
+	// PaymentFilterRenewalDue is the lead list's payment filter for students with an open renewal offer
+	PaymentFilterRenewalDue = "RENEWAL_DUE"
+)
 
 // GetPaymentState computes payment state from amount_paid and final_price
 // Returns: UNPAID, DEPOSIT, or PAID_FULL
@@ -82,154 +61,11 @@ func GetPaymentState(amountPaid sql.NullInt32, finalPrice sql.NullInt32) string
 	return PaymentStateDeposit
 }
 
-// ComputeStageFromFormCompletion computes the appropriate workflow stage based on form completion
-// Rules: Compute stage from the furthest completed block, never downgrade
-// Returns the new stage and the old status (for DB compatibility)
-func ComputeStageFromFormCompletion(detail *LeadDetail, currentStatus string) (newStage string, dbStatus string) {
-	// Start with current stage (mapped from old status)
-	currentStage := MapOldStatusToStage(currentStatus)
-
-	// Stage progression rules (check furthest completed block)
-
-	// 1. If test date + test time exist -> at least TEST_BOOKED
-	if detail.PlacementTest != nil && detail.PlacementTest.TestDate.Valid && detail.PlacementTest.TestTime.Valid {
-		if currentStage == StageNewLead {
-			currentStage = StageTestBooked
-		}
-	}
-
-	// 2. If assigned level exists (and/or test notes exist) -> at least TESTED
-	if detail.PlacementTest != nil && detail.PlacementTest.AssignedLevel.Valid {
-		stagesBeforeTested := map[string]bool{
-			StageNewLead:    true,
-			StageTestBooked: true,
-		}
-		if stagesBeforeTested[currentStage] {
-			currentStage = StageTested
-		}
-	}
-
-	// 3. If offer final price exists (or bundle selected + final price) -> at least OFFER_SENT
-	if detail.Offer != nil && detail.Offer.FinalPrice.Valid && detail.Offer.FinalPrice.Int32 > 0 {
-		stagesBeforeOfferSent := map[string]bool{
-			StageNewLead:    true,
-			StageTestBooked: true,
-			StageTested:     true,
-		}
-		if stagesBeforeOfferSent[currentStage] {
-			currentStage = StageOfferSent
-		}
-	}
-
-	// 4. If payment amount exists:
-	//    - if amountPaid >= finalPrice -> BOOKING_CONFIRMED_PAID_FULL
-	//    - else if amountPaid > 0 -> BOOKING_CONFIRMED_DEPOSIT
-	if detail.Payment != nil && detail.Payment.AmountPaid.Valid && detail.Payment.AmountPaid.Int32 > 0 {
-		var finalPrice int32 = 0
-		if detail.Offer != nil && detail.Offer.FinalPrice.Valid {
-			finalPrice = detail.Offer.FinalPrice.Int32
-		}
-
-		if finalPrice > 0 && detail.Payment.AmountPaid.Int32 >= finalPrice {
-			// Paid in full
-			stagesBeforePaidFull := map[string]bool{
-				StageNewLead:                 true,
-				StageTestBooked:              true,
-				StageTested:                  true,
-				StageOfferSent:               true,
-				StageBookingConfirmedDeposit: true,
-			}
-			if stagesBeforePaidFull[currentStage] {
-				currentStage = StageBookingConfirmedPaidFull
-			}
-		} else if detail.Payment.AmountPaid.Int32 > 0 {
-			// Deposit paid
-			stagesBeforeDeposit := map[string]bool{
-				StageNewLead:    true,
-				StageTestBooked: true,
-				StageTested:     true,
-				StageOfferSent:  true,
-			}
-			if stagesBeforeDeposit[currentStage] {
-				currentStage = StageBookingConfirmedDeposit
-			}
-		}
-	}
-
-	// 5. If schedule (class days + class time) selected -> SCHEDULE_SET, then READY_TO_START
-	if detail.Scheduling != nil && detail.Scheduling.ClassDays.Valid && detail.Scheduling.ClassTime.Valid {
-		// First upgrade to SCHEDULE_SET if before it
-		stagesBeforeSchedule := map[string]bool{
-			StageNewLead:                  true,
-			StageTestBooked:               true,
-			StageTested:                   true,
-			StageOfferSent:                true,
-			StageBookingConfirmedPaidFull: true,
-			StageBookingConfirmedDeposit:  true,
-		}
-		if stagesBeforeSchedule[currentStage] {
-			currentStage = StageScheduleSet
-		}
-
-		// Then upgrade to READY_TO_START (schedule fully filled)
-		stagesBeforeReady := map[string]bool{
-			StageNewLead:                  true,
-			StageTestBooked:               true,
-			StageTested:                   true,
-			StageOfferSent:                true,
-			StageBookingConfirmedPaidFull: true,
-			StageBookingConfirmedDeposit:  true,
-			StageScheduleSet:              true,
-		}
-		if stagesBeforeReady[currentStage] {
-			currentStage = StageReadyToStart
-		}
-	}
-
-	// Map new stage back to DB status for storage
-	stageToStatusMap := map[string]string{
-		StageNewLead:                  "lead_created",
-		StageTestBooked:               "test_booked",
-		StageTested:                   "tested",
-		StageOfferSent:                "offer_sent",
-		StageBookingConfirmedPaidFull: "paid_full",
-		StageBookingConfirmedDeposit:  "deposit_paid",
-		StageScheduleSet:              "schedule_assigned",
-		StageReadyToStart:             "ready_to_start",
-	}
-
-	dbStatus = stageToStatusMap[currentStage]
-	if dbStatus == "" {
-		dbStatus = "lead_created" // Fallback
-	}
-
-	return currentStage, dbStatus
-}
-
-func GetNextAction(status string) string {
-	// Map to canonical stage first for consistent actions
-	stage := MapOldStatusToStage(status)
-
-	actions := map[string]string{
-		StageNewLead:                  "Book placement test",
-		StageTestBooked:               "Run placement test",
-		StageTested:                   "Send offer",
-		StageOfferSent:                "Wait for booking",
-		StageBookingConfirmedPaidFull: "Assign schedule",
-		StageBookingConfirmedDeposit:  "Collect remaining",
-		StageScheduleSet:              "Mark ready to start",
-		StageReadyToStart:             "Ready for activation",
-	}
-	if action, ok := actions[stage]; ok {
-		return action
-	}
-	return "Review"
-}
-
 // ComputeLeadFlags computes hot lead flags based on status and payment.
 // Business definition: Hot Lead = (status = TESTED OR OFFER_SENT) AND payment_state = UNPAID.
 // All such leads are hot immediately (no 2-day gate): they appear in Hot Leads filter, banner count, and detail callout.
-// Days since progress are used only for HotLevel (HOT/WARM/COOL) and suggested next action.
+// Days since progress are used only for HotLevel (HOT/WARM/COOL) and suggested next action;
+// a logged contact (completed follow-up task that reached the lead) resets them.
 func ComputeLeadFlags(item *LeadListItem) {
 	// Map to canonical stage for consistent checking
 	stage := MapOldStatusToStage(item.Lead.Status)
@@ -260,14 +96,12 @@ func ComputeLeadFlags(item *LeadListItem) {
 		return
 	}
 
-	// Calculate days since last progress (test_date or updated_at)
-	var progressTime time.Time
-	if item.TestDate.Valid {
-		progressTime = item.TestDate.Time
-	} else {
-		progressTime = item.Lead.UpdatedAt
-		if progressTime.IsZero() {
-			progressTime = item.Lead.CreatedAt
+	// Calculate days since last progress: the latest of the last logged contact, the last status
+	// change and the test date. Edits to the lead (updated_at) do not count as progress.
+	progressTime := item.Lead.CreatedAt
+	for _, t := range []sql.NullTime{item.LastContactAt, item.StatusChangedAt, item.TestDate} {
+		if t.Valid && t.Time.After(progressTime) {
+			progressTime = t.Time
 		}
 	}
 
@@ -301,200 +135,27 @@ func GetPaymentStatus(remainingBalance, amountPaid sql.NullInt32) string {
 	return "Unpaid"
 }
 
-func GetAllLeads(statusFilter, searchFilter, paymentFilter, hotFilter string, includeCancelled bool, followUpFilter string) ([]*LeadListItem, error) {
-	query := `
-		SELECT 
-			l.id, l.full_name, l.phone, l.source, l.notes, l.status, l.sent_to_classes,
-			l.created_by_user_id, l.created_at, l.updated_at,
-			pt.assigned_level, pt.test_date,
-			p.remaining_balance, p.amount_paid,
-			o.final_price
-		FROM leads l
-		LEFT JOIN placement_tests pt ON l.id = pt.lead_id
-		LEFT JOIN payments p ON l.id = p.lead_id
-		LEFT JOIN offers o ON l.id = o.lead_id
-		WHERE 1=1
-		AND l.status != 'in_classes'
-		AND (l.sent_to_classes IS NULL OR l.sent_to_classes = false)
-	`
-
-	args := []interface{}{}
-	argIndex := 1
-
-	// Apply follow-up filter (high priority follow-up)
-	if followUpFilter == "high_priority" {
-		query += fmt.Sprintf(" AND l.high_priority_follow_up = true")
-	}
-
-	// Exclude cancelled by default. Include if includeCancelled=true OR explicitly filtering by status=cancelled.
-	excludeCancelled := !includeCancelled && statusFilter != "cancelled"
-	if excludeCancelled {
-		query += " AND l.status != 'cancelled'"
-	}
-
-	// Apply status filter - map new stage names to old status values for DB query
-	if statusFilter != "" {
-		// Map new stage constants to old DB status values
-		stageToStatusMap := map[string]string{
-			StageNewLead:                  "lead_created",
-			StageTestBooked:               "test_booked",
-			StageTested:                   "tested",
-			StageOfferSent:                "offer_sent",
-			StageBookingConfirmedPaidFull: "paid_full",
-			StageBookingConfirmedDeposit:  "deposit_paid",
-			StageScheduleSet:              "schedule_assigned",
-			StageReadyToStart:             "ready_to_start",
-		}
-
-		// If it's a new stage constant, map it; otherwise use as-is (backward compat)
-		dbStatus := statusFilter
-		if mapped, ok := stageToStatusMap[statusFilter]; ok {
-			dbStatus = mapped
-		}
-
-		// For booking confirmed stages, we'll filter by status in SQL
-		// but payment state filtering happens after computing flags
-		query += fmt.Sprintf(" AND l.status = $%d", argIndex)
-		args = append(args, dbStatus)
-		argIndex++
-	}
-
-	// Apply search filter (name or phone)
-	if searchFilter != "" {
-		query += fmt.Sprintf(" AND (LOWER(l.full_name) LIKE LOWER($%d) OR l.phone LIKE $%d)", argIndex, argIndex)
-		searchPattern := "%" + searchFilter + "%"
-		args = append(args, searchPattern)
-		argIndex++
-	}
-
-	// Default sorting (unless hot filter is active, then we sort after computing flags in Go)
-	if hotFilter != "hot" {
-		query += " ORDER BY l.created_at DESC"
-	} else {
-		// For hot filter, we'll sort in Go after computing flags, but still need an ORDER BY for SQL
-		query += " ORDER BY l.created_at DESC"
-	}
-
-	var rows *sql.Rows
-	var err error
-	if len(args) > 0 {
-		rows, err = db.DB.Query(query, args...)
-	} else {
-		rows, err = db.DB.Query(query)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to query leads: %w", err)
-	}
-	defer rows.Close()
-
-	var leads []*LeadListItem
-	for rows.Next() {
-		lead := &Lead{}
-		var assignedLevel sql.NullInt32
-		var remainingBalance, amountPaid, finalPrice sql.NullInt32
-		var testDate sql.NullTime
-
-		err := rows.Scan(
-			&lead.ID, &lead.FullName, &lead.Phone, &lead.Source, &lead.Notes, &lead.Status, &lead.SentToClasses,
-			&lead.CreatedByUserID, &lead.CreatedAt, &lead.UpdatedAt,
-			&assignedLevel, &testDate,
-			&remainingBalance, &amountPaid,
-			&finalPrice,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan lead: %w", err)
-		}
-
-		// Compute payment state
-		paymentState := GetPaymentState(amountPaid, finalPrice)
-
-		item := &LeadListItem{
-			Lead:             lead,
-			AssignedLevel:    assignedLevel,
-			PaymentStatus:    GetPaymentStatus(remainingBalance, amountPaid),
-			PaymentState:     paymentState,
-			NextAction:       GetNextAction(lead.Status),
-			TestDate:         testDate,
-			AmountPaid:       amountPaid,
-			FinalPrice:       finalPrice,
-			RemainingBalance: remainingBalance,
-		}
-
-		// Compute hot lead flags (needs finalPrice for proper payment state)
-		ComputeLeadFlags(item)
-
-		leads = append(leads, item)
-	}
-
-	// Apply payment filter if requested (after computing payment states)
-	if paymentFilter != "" {
-		var filteredLeads []*LeadListItem
-		for _, lead := range leads {
-			if lead.PaymentState == paymentFilter {
-				filteredLeads = append(filteredLeads, lead)
-			}
-		}
-		leads = filteredLeads
-	}
-
-	// For BOOKING_CONFIRMED_PAID_FULL and BOOKING_CONFIRMED_DEPOSIT status filters,
-	// also filter by payment state after computing it
-	if statusFilter == StageBookingConfirmedPaidFull {
-		var filteredLeads []*LeadListItem
-		for _, lead := range leads {
-			// Must have booking_confirmed/paid_full status AND paid_full payment state
-			if (lead.Lead.Status == "paid_full" || lead.Lead.Status == "booking_confirmed") && lead.PaymentState == PaymentStatePaidFull {
-				filteredLeads = append(filteredLeads, lead)
-			}
-		}
-		leads = filteredLeads
-	} else if statusFilter == StageBookingConfirmedDeposit {
-		var filteredLeads []*LeadListItem
-		for _, lead := range leads {
-			// Must have booking_confirmed/deposit_paid status AND deposit payment state
-			if (lead.Lead.Status == "deposit_paid" || lead.Lead.Status == "booking_confirmed") && lead.PaymentState == PaymentStateDeposit {
-				filteredLeads = append(filteredLeads, lead)
-			}
-		}
-		leads = filteredLeads
-	}
-
-	// Apply hot filter if requested (after payment filter)
-	if hotFilter == "hot" || hotFilter == "1" {
-		var filteredLeads []*LeadListItem
-		for _, lead := range leads {
-			if lead.FollowUpDue {
-				filteredLeads = append(filteredLeads, lead)
-			}
-		}
-		leads = filteredLeads
-	}
-
-	// Sort by hot level and days if hot filter is active
-	if hotFilter == "hot" || hotFilter == "1" {
-		sort.Slice(leads, func(i, j int) bool {
-			// Sort by hot level priority (HOT > WARM > COOL)
-			levelPriority := map[string]int{"HOT": 3, "WARM": 2, "COOL": 1, "": 0}
-			if levelPriority[leads[i].HotLevel] != levelPriority[leads[j].HotLevel] {
-				return levelPriority[leads[i].HotLevel] > levelPriority[leads[j].HotLevel]
-			}
-			// Then by days descending (most urgent first)
-			return leads[i].DaysSinceLastProgress > leads[j].DaysSinceLastProgress
-		})
-	}
-
-	return leads, nil
+func GetLeadByID(id uuid.UUID) (*LeadDetail, error) {
+	return getLeadDetail(db.DB, id)
 }
 
-func GetLeadByID(id uuid.UUID) (*LeadDetail, error) {
+// getLeadDetail loads a lead and its one-per-lead sections through q
+func getLeadDetail(q sqlExecer, id uuid.UUID) (*LeadDetail, error) {
 	// Get lead
 	lead := &Lead{}
-	err := db.DB.QueryRow(`
-		SELECT id, full_name, phone, source, notes, status, sent_to_classes, high_priority_follow_up, created_by_user_id, created_at, updated_at
-		FROM leads WHERE id = $1
+	err := q.QueryRow(`
+		SELECT l.id, l.full_name, l.phone, l.source, l.notes, l.status, l.sent_to_classes, l.high_priority_follow_up,
+		       l.referred_by_lead_id::text, r.full_name, l.archived_at, COALESCE(au.email, ''), l.archive_reason,
+		       l.created_by_user_id, l.created_at, l.updated_at
+		FROM leads l
+		LEFT JOIN leads r ON r.id = l.referred_by_lead_id
+		LEFT JOIN users au ON au.id = l.archived_by_user_id
+		WHERE l.id = $1
 	`, id).Scan(
 		&lead.ID, &lead.FullName, &lead.Phone, &lead.Source, &lead.Notes, &lead.Status,
-		&lead.SentToClasses, &lead.HighPriorityFollowUp, &lead.CreatedByUserID, &lead.CreatedAt, &lead.UpdatedAt,
+		&lead.SentToClasses, &lead.HighPriorityFollowUp, &lead.ReferredByLeadID, &lead.ReferredByName,
+		&lead.ArchivedAt, &lead.ArchivedByEmail, &lead.ArchiveReason,
+		&lead.CreatedByUserID, &lead.CreatedAt, &lead.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get lead: %w", err)
@@ -504,12 +165,12 @@ func GetLeadByID(id uuid.UUID) (*LeadDetail, error) {
 
 	// Get placement test
 	pt := &PlacementTest{}
-	err = db.DB.QueryRow(`
-		SELECT id, lead_id, test_date, test_time, test_type, assigned_level, test_notes, run_by_user_id, placement_test_fee, placement_test_fee_paid, placement_test_payment_date, placement_test_payment_method, updated_at
+	err = q.QueryRow(`
+		SELECT id, lead_id, test_date, test_time, test_type, assigned_level, test_notes, run_by_user_id, slot_id, placement_test_fee, placement_test_fee_paid, placement_test_payment_date, placement_test_payment_method, updated_at
 		FROM placement_tests WHERE lead_id = $1
 	`, id).Scan(
 		&pt.ID, &pt.LeadID, &pt.TestDate, &pt.TestTime, &pt.TestType, &pt.AssignedLevel,
-		&pt.TestNotes, &pt.RunByUserID, &pt.PlacementTestFee, &pt.PlacementTestFeePaid, &pt.PlacementTestPaymentDate, &pt.PlacementTestPaymentMethod, &pt.UpdatedAt,
+		&pt.TestNotes, &pt.RunByUserID, &pt.SlotID, &pt.PlacementTestFee, &pt.PlacementTestFeePaid, &pt.PlacementTestPaymentDate, &pt.PlacementTestPaymentMethod, &pt.UpdatedAt,
 	)
 	if err == nil {
 		detail.PlacementTest = pt
@@ -519,12 +180,21 @@ func GetLeadByID(id uuid.UUID) (*LeadDetail, error) {
 
 	// Get offer
 	offer := &Offer{}
-	err = db.DB.QueryRow(`
-		SELECT id, lead_id, bundle_levels, base_price, discount_value, discount_type, final_price, updated_at
-		FROM offers WHERE lead_id = $1
+	err = q.QueryRow(`
+		SELECT o.id, o.lead_id, o.bundle_levels, o.base_price, o.discount_value, o.discount_type, o.final_price, o.price_plan_id,
+		       o.promo_code_id, COALESCE(pc.code, ''), o.promo_redeemed_at, o.discount_set_by_user_id,
+		       o.discount_approved_by_user_id, COALESCE(u.email, ''), o.discount_approved_at, o.expires_on,
+		       o.referral_discount, o.referral_credit, o.updated_at
+		FROM offers o
+		LEFT JOIN promo_codes pc ON pc.id = o.promo_code_id
+		LEFT JOIN users u ON u.id = o.discount_approved_by_user_id
+		WHERE o.lead_id = $1
 	`, id).Scan(
 		&offer.ID, &offer.LeadID, &offer.BundleLevels, &offer.BasePrice, &offer.DiscountValue,
-		&offer.DiscountType, &offer.FinalPrice, &offer.UpdatedAt,
+		&offer.DiscountType, &offer.FinalPrice, &offer.PricePlanID,
+		&offer.PromoCodeID, &offer.PromoCode, &offer.PromoRedeemedAt, &offer.DiscountSetByUserID,
+		&offer.DiscountApprovedByUserID, &offer.DiscountApprovedByEmail, &offer.DiscountApprovedAt, &offer.ExpiresOn,
+		&offer.ReferralDiscount, &offer.ReferralCredit, &offer.UpdatedAt,
 	)
 	if err == nil {
 		detail.Offer = offer
@@ -534,7 +204,7 @@ func GetLeadByID(id uuid.UUID) (*LeadDetail, error) {
 
 	// Get booking
 	booking := &Booking{}
-	err = db.DB.QueryRow(`
+	err = q.QueryRow(`
 		SELECT id, lead_id, book_format, address, city, delivery_notes, updated_at
 		FROM bookings WHERE lead_id = $1
 	`, id).Scan(
@@ -549,7 +219,7 @@ func GetLeadByID(id uuid.UUID) (*LeadDetail, error) {
 
 	// Get payment
 	payment := &Payment{}
-	err = db.DB.QueryRow(`
+	err = q.QueryRow(`
 		SELECT id, lead_id, payment_type, amount_paid, remaining_balance, payment_date, updated_at
 		FROM payments WHERE lead_id = $1
 	`, id).Scan(
@@ -566,7 +236,7 @@ func GetLeadByID(id uuid.UUID) (*LeadDetail, error) {
 	scheduling := &Scheduling{}
 	var classTimeRaw sql.NullString
 	var startTimeRaw sql.NullString
-	err = db.DB.QueryRow(`
+	err = q.QueryRow(`
 		SELECT id, lead_id, expected_round, class_days, 
 		       TO_CHAR(class_time, 'HH24:MI') as class_time,
 		       start_date, 
@@ -588,11 +258,13 @@ func GetLeadByID(id uuid.UUID) (*LeadDetail, error) {
 
 	// Get shipping
 	shipping := &Shipping{}
-	err = db.DB.QueryRow(`
-		SELECT id, lead_id, shipment_status, shipment_date, updated_at
+	err = q.QueryRow(`
+		SELECT id, lead_id, shipment_status, shipment_date, batch_id::text, tracking_number,
+			ship_to_address, ship_to_city, packed_at, delivered_at, returned_at, updated_at
 		FROM shipping WHERE lead_id = $1
 	`, id).Scan(
-		&shipping.ID, &shipping.LeadID, &shipping.ShipmentStatus, &shipping.ShipmentDate, &shipping.UpdatedAt,
+		&shipping.ID, &shipping.LeadID, &shipping.ShipmentStatus, &shipping.ShipmentDate, &shipping.BatchID, &shipping.TrackingNumber,
+		&shipping.ShipToAddress, &shipping.ShipToCity, &shipping.PackedAt, &shipping.DeliveredAt, &shipping.ReturnedAt, &shipping.UpdatedAt,
 	)
 	if err == nil {
 		detail.Shipping = shipping
@@ -603,7 +275,26 @@ func GetLeadByID(id uuid.UUID) (*LeadDetail, error) {
 	return detail, nil
 }
 
+// CreateLead inserts a new lead with status lead_created
 func CreateLead(fullName, phone, source, notes, createdByUserID string) (*Lead, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	lead, err := createLead(tx, fullName, phone, source, notes, createdByUserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit lead: %w", err)
+	}
+	return lead, nil
+}
+
+// createLead inserts a lead using q, so bulk imports can create many leads in one transaction
+func createLead(q sqlExecer, fullName, phone, source, notes, createdByUserID string) (*Lead, error) {
 	leadID := uuid.New()
 	now := time.Now()
 
@@ -628,13 +319,16 @@ func CreateLead(fullName, phone, source, notes, createdByUserID string) (*Lead,
 		createdByID = sql.NullString{String: createdByUUID.String(), Valid: true}
 	}
 
-	_, err := db.DB.Exec(`
+	_, err := q.Exec(`
 		INSERT INTO leads (id, full_name, phone, source, notes, status, sent_to_classes, created_by_user_id, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`, leadID, fullName, phone, sourceVal, notesVal, "lead_created", false, createdByID, now, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create lead: %w", err)
 	}
+	if err := recordLeadStatusChange(q, leadID, "", "lead_created", StatusSourceManual, "Lead created", createdByUserID, now); err != nil {
+		return nil, err
+	}
 
 	return &Lead{
 		ID:              leadID,
@@ -649,7 +343,10 @@ func CreateLead(fullName, phone, source, notes, createdByUserID string) (*Lead,
 	}, nil
 }
 
-func UpdateLeadDetail(detail *LeadDetail) error {
+// UpdateLeadDetail saves all lead sections in one transaction. A status change (computed from form
+// completion) fires the matching pipeline event for role; returns *LeadTransitionError when the
+// pipeline rejects it, and nothing is saved.
+func UpdateLeadDetail(detail *LeadDetail, role, actorUserID string) error {
 	tx, err := db.DB.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -658,11 +355,16 @@ func UpdateLeadDetail(detail *LeadDetail) error {
 
 	now := time.Now()
 
-	// Update lead
+	previousStatus, err := getLeadStatusForUpdate(tx, detail.Lead.ID)
+	if err != nil {
+		return err
+	}
+
+	// Update lead; the status moves through the pipeline once the sections below are saved
 	_, err = tx.Exec(`
-		UPDATE leads SET full_name = $1, phone = $2, source = $3, notes = $4, status = $5, sent_to_classes = $6, updated_at = $7
-		WHERE id = $8
-	`, detail.Lead.FullName, detail.Lead.Phone, detail.Lead.Source, detail.Lead.Notes, detail.Lead.Status, detail.Lead.SentToClasses, now, detail.Lead.ID)
+		UPDATE leads SET full_name = $1, phone = $2, source = $3, notes = $4, sent_to_classes = $5, updated_at = $6
+		WHERE id = $7
+	`, detail.Lead.FullName, detail.Lead.Phone, detail.Lead.Source, detail.Lead.Notes, detail.Lead.SentToClasses, now, detail.Lead.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update lead: %w", err)
 	}
@@ -696,19 +398,13 @@ func UpdateLeadDetail(detail *LeadDetail) error {
 	}
 
 	// Upsert offer
+	offerChanged := false
 	if detail.Offer != nil {
-		_, err = tx.Exec(`
-			INSERT INTO offers (id, lead_id, bundle_levels, base_price, discount_value, discount_type, final_price, updated_at)
-			VALUES (COALESCE((SELECT id FROM offers WHERE lead_id = $1), gen_random_uuid()), $1, $2, $3, $4, $5, $6, $7)
-			ON CONFLICT (lead_id) DO UPDATE SET
-				bundle_levels = EXCLUDED.bundle_levels,
-				base_price = EXCLUDED.base_price,
-				discount_value = EXCLUDED.discount_value,
-				discount_type = EXCLUDED.discount_type,
-				final_price = EXCLUDED.final_price,
-				updated_at = EXCLUDED.updated_at
-		`, detail.Lead.ID, detail.Offer.BundleLevels, detail.Offer.BasePrice,
-			detail.Offer.DiscountValue, detail.Offer.DiscountType, detail.Offer.FinalPrice, now)
+		offerChanged, err = offerPricingChangedTx(tx, detail.Offer)
+		if err != nil {
+			return err
+		}
+		err = upsertOffer(tx, detail.Offer, now)
 		if err != nil {
 			return fmt.Errorf("failed to upsert offer: %w", err)
 		}
@@ -785,36 +481,29 @@ func UpdateLeadDetail(detail *LeadDetail) error {
 		}
 	}
 
-	// Upsert shipping
-	if detail.Shipping != nil {
-		_, err = tx.Exec(`
-			INSERT INTO shipping (id, lead_id, shipment_status, shipment_date, updated_at)
-			VALUES (COALESCE((SELECT id FROM shipping WHERE lead_id = $1), gen_random_uuid()), $1, $2, $3, $4)
-			ON CONFLICT (lead_id) DO UPDATE SET
-				shipment_status = EXCLUDED.shipment_status,
-				shipment_date = EXCLUDED.shipment_date,
-				updated_at = EXCLUDED.updated_at
-		`, detail.Lead.ID, detail.Shipping.ShipmentStatus, detail.Shipping.ShipmentDate, now)
-		if err != nil {
-			return fmt.Errorf("failed to upsert shipping: %w", err)
+	// The status computed from form completion fires its pipeline event, so guards see the saved
+	// sections and effects and follow-up tasks apply as they do for the action buttons
+	if detail.Lead.Status != previousStatus {
+		if event, ok := formCompletionEvent(detail.Lead.Status); ok {
+			if err := applyLeadEventTx(tx, detail.Lead.ID, event, role, actorUserID, "Computed from form completion", now); err != nil {
+				return err
+			}
+		}
+	} else if offerChanged && MapOldStatusToStage(previousStatus) == StageOfferSent {
+		// New terms on an offer already out are sent again, so the discount approval guard and
+		// promo code redemption apply to them
+		if err := applyLeadEventTx(tx, detail.Lead.ID, EventSendOffer, role, actorUserID, "Offer changed", now); err != nil {
+			return err
 		}
 	}
 
 	return tx.Commit()
 }
 
-func UpdateLeadStatus(leadID uuid.UUID, status string) error {
-	_, err := db.DB.Exec(`
-		UPDATE leads SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2
-	`, status, leadID)
-	return err
-}
-
-// UpsertSchedulingClassDaysTime updates only class_days and class_time for a lead.
+// upsertSchedulingClassDaysTime updates only class_days and class_time for a lead.
 // Used when marking ready to start; preserves expected_round, start_date, start_time.
-func UpsertSchedulingClassDaysTime(leadID uuid.UUID, classDays, classTime string) error {
-	now := time.Now()
-	_, err := db.DB.Exec(`
+func upsertSchedulingClassDaysTime(q sqlExecer, leadID uuid.UUID, classDays, classTime string, now time.Time) error {
+	_, err := q.Exec(`
 		INSERT INTO scheduling (id, lead_id, class_days, class_time, updated_at)
 		VALUES (COALESCE((SELECT id FROM scheduling WHERE lead_id = $1), gen_random_uuid()), $1, $2, $3, $4)
 		ON CONFLICT (lead_id) DO UPDATE SET
@@ -822,7 +511,29 @@ func UpsertSchedulingClassDaysTime(leadID uuid.UUID, classDays, classTime string
 			class_time = EXCLUDED.class_time,
 			updated_at = EXCLUDED.updated_at
 	`, leadID, classDays, classTime, now)
-	return err
+	if err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return nil
+}
+
+// MarkLeadReady saves class days/time and fires EventMarkReady in one transaction,
+// so a rejected transition leaves the schedule untouched
+func MarkLeadReady(leadID uuid.UUID, classDays, classTime, role, actorUserID string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if err := upsertSchedulingClassDaysTime(tx, leadID, classDays, classTime, now); err != nil {
+		return err
+	}
+	if err := applyLeadEventTx(tx, leadID, EventMarkReady, role, actorUserID, "", now); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // UpdateLeadBasicInfo updates only lead basic info (name, phone, source, notes) - for moderators
@@ -849,27 +560,64 @@ func UpdatePlacementTest(pt *PlacementTest) error {
 	return err
 }
 
-// UpdateOffer updates only offer fields
-func UpdateOffer(offer *Offer) error {
-	now := time.Now()
-	_, err := db.DB.Exec(`
-		INSERT INTO offers (id, lead_id, bundle_levels, base_price, discount_value, discount_type, final_price, updated_at)
-		VALUES (COALESCE((SELECT id FROM offers WHERE lead_id = $1), gen_random_uuid()), $1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (lead_id) DO UPDATE SET
-			bundle_levels = EXCLUDED.bundle_levels,
-			base_price = EXCLUDED.base_price,
-			discount_value = EXCLUDED.discount_value,
-			discount_type = EXCLUDED.discount_type,
-			final_price = EXCLUDED.final_price,
-			updated_at = EXCLUDED.updated_at
-	`, offer.LeadID, offer.BundleLevels, offer.BasePrice,
-		offer.DiscountValue, offer.DiscountType, offer.FinalPrice, now)
+// upsertOfferSQL writes the lead's offer. A change to the price, discount or promo code makes the
+// actor the discount's owner and clears any discount approval; a different promo code is not yet redeemed.
+const upsertOfferSQL = `
+	INSERT INTO offers (id, lead_id, bundle_levels, base_price, discount_value, discount_type, final_price, price_plan_id,
+		promo_code_id, discount_set_by_user_id, referral_discount, updated_at)
+	VALUES (COALESCE((SELECT id FROM offers WHERE lead_id = $1), gen_random_uuid()), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	ON CONFLICT (lead_id) DO UPDATE SET
+		bundle_levels = EXCLUDED.bundle_levels,
+		base_price = EXCLUDED.base_price,
+		discount_value = EXCLUDED.discount_value,
+		discount_type = EXCLUDED.discount_type,
+		final_price = EXCLUDED.final_price,
+		price_plan_id = EXCLUDED.price_plan_id,
+		promo_code_id = EXCLUDED.promo_code_id,
+		referral_discount = EXCLUDED.referral_discount,
+		promo_redeemed_at = CASE WHEN offers.promo_code_id IS DISTINCT FROM EXCLUDED.promo_code_id
+			THEN NULL ELSE offers.promo_redeemed_at END,
+		discount_set_by_user_id = CASE WHEN ` + offerPricingChanged + `
+			THEN EXCLUDED.discount_set_by_user_id ELSE offers.discount_set_by_user_id END,
+		discount_approved_by_user_id = CASE WHEN ` + offerPricingChanged + `
+			THEN NULL ELSE offers.discount_approved_by_user_id END,
+		discount_approved_at = CASE WHEN ` + offerPricingChanged + `
+			THEN NULL ELSE offers.discount_approved_at END,
+		updated_at = EXCLUDED.updated_at
+`
+
+const offerPricingChanged = `(offers.base_price, offers.final_price, offers.discount_value, offers.discount_type, offers.promo_code_id)
+	IS DISTINCT FROM (EXCLUDED.base_price, EXCLUDED.final_price, EXCLUDED.discount_value, EXCLUDED.discount_type, EXCLUDED.promo_code_id)`
+
+func upsertOffer(q sqlExecer, offer *Offer, now time.Time) error {
+	_, err := q.Exec(upsertOfferSQL, offer.LeadID, offer.BundleLevels, offer.BasePrice,
+		offer.DiscountValue, offer.DiscountType, offer.FinalPrice, offer.PricePlanID,
+		offer.PromoCodeID, offer.DiscountSetByUserID, offer.ReferralDiscount, now)
 	return err
 }
 
-// BookPlacementTest updates placement test fields and sets status to "test_booked"
-// This is a lightweight update that doesn't require offer/pricing fields
-func BookPlacementTest(leadID uuid.UUID, testDate sql.NullTime, testTime sql.NullString, testType sql.NullString, testNotes sql.NullString) error {
+// offerPricingChangedTx reports whether saving offer changes the lead's stored price, discount or
+// promo code (as offerPricingChanged does in the upsert); true when the lead has no offer yet
+func offerPricingChangedTx(q sqlExecer, offer *Offer) (bool, error) {
+	var unchanged bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM offers
+			WHERE lead_id = $1
+			AND (base_price, final_price, discount_value, discount_type, promo_code_id)
+				IS NOT DISTINCT FROM ($2::INTEGER, $3::INTEGER, $4::INTEGER, $5::TEXT, $6::UUID)
+		)
+	`, offer.LeadID, offer.BasePrice, offer.FinalPrice, offer.DiscountValue, offer.DiscountType, offer.PromoCodeID).Scan(&unchanged)
+	if err != nil {
+		return false, fmt.Errorf("failed to compare offer: %w", err)
+	}
+	return !unchanged, nil
+}
+
+// SendOffer saves the offer and fires EventSendOffer (status "offer_sent") in one transaction, so a
+// rejected send (e.g. a discount awaiting approval) leaves the previous offer in place. The transition
+// redeems the promo code and issues the offer letter.
+func SendOffer(offer *Offer, role, actorUserID string) error {
 	tx, err := db.DB.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -877,27 +625,31 @@ func BookPlacementTest(leadID uuid.UUID, testDate sql.NullTime, testTime sql.Nul
 	defer tx.Rollback()
 
 	now := time.Now()
+	if err := upsertOffer(tx, offer, now); err != nil {
+		return fmt.Errorf("failed to update offer: %w", err)
+	}
+	if err := applyLeadEventTx(tx, offer.LeadID, EventSendOffer, role, actorUserID, "", now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	// Update or insert placement test with default fee of 100 if not exists
-	_, err = tx.Exec(`
-		INSERT INTO placement_tests (id, lead_id, test_date, test_time, test_type, test_notes, placement_test_fee, placement_test_fee_paid, updated_at)
-		VALUES (COALESCE((SELECT id FROM placement_tests WHERE lead_id = $1), gen_random_uuid()), $1, $2, $3, $4, $5, 100, 0, $6)
-		ON CONFLICT (lead_id) DO UPDATE SET
-			test_date = EXCLUDED.test_date,
-			test_time = EXCLUDED.test_time,
-			test_type = EXCLUDED.test_type,
-			test_notes = EXCLUDED.test_notes,
-			placement_test_fee = COALESCE(placement_tests.placement_test_fee, 100),
-			updated_at = EXCLUDED.updated_at
-	`, leadID, testDate, testTime, testType, testNotes, now)
+// BookPlacementTest books a lead into a placement test slot and fires EventBookTest (status "test_booked").
+// Test date/time/type and run_by_user_id come from the slot; returns *PlacementSlotError when the slot is full or past.
+// This is a lightweight update that doesn't require offer/pricing fields
+func BookPlacementTest(leadID, slotID uuid.UUID, testNotes sql.NullString, role, actorUserID string) error {
+	tx, err := db.DB.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to upsert placement test: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	// Update lead status to test_booked
-	_, err = tx.Exec(`UPDATE leads SET status = $1, updated_at = $2 WHERE id = $3`, "test_booked", now, leadID)
-	if err != nil {
-		return fmt.Errorf("failed to update lead status: %w", err)
+	now := time.Now()
+	if err := bookPlacementTestSlotTx(tx, leadID, slotID, testNotes, now); err != nil {
+		return err
+	}
+	if err := applyLeadEventTx(tx, leadID, EventBookTest, role, actorUserID, "", now); err != nil {
+		return err
 	}
 
 	return tx.Commit()
@@ -933,56 +685,6 @@ func CreateUser(email, passwordHash, role string) (*User, error) {
 	}, nil
 }
 
-// DeleteLead deletes a lead and all associated data (cascade delete)
-func DeleteLead(leadID uuid.UUID) error {
-	tx, err := db.DB.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	// Delete related records first (in reverse order of dependencies)
-	// Note: If foreign keys have CASCADE DELETE, some of these may be automatic
-	// but we'll be explicit for safety
-	_, err = tx.Exec(`DELETE FROM shipping WHERE lead_id = $1`, leadID)
-	if err != nil {
-		return fmt.Errorf("failed to delete shipping: %w", err)
-	}
-
-	_, err = tx.Exec(`DELETE FROM scheduling WHERE lead_id = $1`, leadID)
-	if err != nil {
-		return fmt.Errorf("failed to delete scheduling: %w", err)
-	}
-
-	_, err = tx.Exec(`DELETE FROM payments WHERE lead_id = $1`, leadID)
-	if err != nil {
-		return fmt.Errorf("failed to delete payments: %w", err)
-	}
-
-	_, err = tx.Exec(`DELETE FROM bookings WHERE lead_id = $1`, leadID)
-	if err != nil {
-		return fmt.Errorf("failed to delete bookings: %w", err)
-	}
-
-	_, err = tx.Exec(`DELETE FROM offers WHERE lead_id = $1`, leadID)
-	if err != nil {
-		return fmt.Errorf("failed to delete offers: %w", err)
-	}
-
-	_, err = tx.Exec(`DELETE FROM placement_tests WHERE lead_id = $1`, leadID)
-	if err != nil {
-		return fmt.Errorf("failed to delete placement_tests: %w", err)
-	}
-
-	// Finally delete the lead
-	_, err = tx.Exec(`DELETE FROM leads WHERE id = $1`, leadID)
-	if err != nil {
-		return fmt.Errorf("failed to delete lead: %w", err)
-	}
-
-	return tx.Commit()
-}
-
 // GetCurrentRound returns the current round number (defaults to 1)
 func GetCurrentRound() (int, error) {
 	var roundStr string
@@ -1271,8 +973,14 @@ func MoveStudentBetweenGroups(leadID uuid.UUID, targetGroupIndex int32) error {
 		return fmt.Errorf("target group is locked (6 students)")
 	}
 
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	// Move student
-	_, err = db.DB.Exec(`
+	_, err = tx.Exec(`
 		UPDATE scheduling SET class_group_index = $1, updated_at = CURRENT_TIMESTAMP
 		WHERE lead_id = $2
 	`, targetGroupIndex, leadID)
@@ -1280,11 +988,47 @@ func MoveStudentBetweenGroups(leadID uuid.UUID, targetGroupIndex int32) error {
 		return fmt.Errorf("failed to move student: %w", err)
 	}
 
-	return nil
+	// If the student is already enrolled in a started class, they leave it. The enrolment moves to the
+	// target class only while its round is active; otherwise the target's round start enrols them.
+	var currentClassKey string
+	err = tx.QueryRow(`
+		SELECT class_key FROM class_enrolments WHERE lead_id = $1 AND left_at IS NULL
+	`, leadID).Scan(&currentClassKey)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get current enrolment: %w", err)
+	}
+	if err == nil {
+		var targetClassKey string
+		var targetActive bool
+		err = tx.QueryRow(`
+			SELECT class_key, COALESCE(round_status, 'not_started') = 'active' FROM class_groups
+			WHERE level = $1 AND class_days = $2 AND class_time = $3 AND class_number = $4
+		`, assignedLevel.Int32, classDays.String, classTime.String, targetGroupIndex).Scan(&targetClassKey, &targetActive)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to get target class: %w", err)
+		}
+		if targetClassKey != currentClassKey {
+			now := time.Now()
+			if err := leaveActiveEnrolmentTx(tx, leadID, EnrolmentLeftMoved, now); err != nil {
+				return err
+			}
+			if targetClassKey != "" && targetActive {
+				_, err = tx.Exec(`
+					INSERT INTO class_enrolments (lead_id, class_key, round, joined_at, created_at, updated_at)
+					VALUES ($1, $2, (SELECT CAST(value AS INTEGER) FROM settings WHERE key = 'current_round'), $3, $3, $3)
+				`, leadID, targetClassKey, now)
+				if err != nil {
+					return fmt.Errorf("failed to enrol student in target class: %w", err)
+				}
+			}
+		}
+	}
+
+	return tx.Commit()
 }
 
 // StartRound moves students in READY/LOCKED groups to in_classes status and increments round
-func StartRound() error {
+func StartRound(actorUserID string) error {
 	tx, err := db.DB.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -1418,13 +1162,20 @@ func StartRound() error {
 	if len(leadIDsToUpdate) > 0 {
 		// Update each lead individually (PostgreSQL array handling can be tricky)
 		for _, leadID := range leadIDsToUpdate {
-			_, err = tx.Exec(`UPDATE leads SET status = 'in_classes', updated_at = CURRENT_TIMESTAMP WHERE id = $1`, leadID)
-			if err != nil {
+			if err := applyLeadEventTx(tx, leadID, EventStartClasses, RoleSystem, actorUserID, "", time.Now()); err != nil {
 				return fmt.Errorf("failed to update status for lead %s: %w", leadID, err)
 			}
 		}
 	}
 
+	// Record class membership for each class
+	enrolledAt := time.Now()
+	for classKey := range classGroups {
+		if err := enrolClassFromSchedulingTx(tx, classKey, enrolledAt); err != nil {
+			return err
+		}
+	}
+
 	// Create 8 sessions for each class
 	for classKey, schedule := range classGroups {
 		for i := 1; i <= 8; i++ {
@@ -1511,9 +1262,17 @@ func GetAvailableGroupsForMove(leadID uuid.UUID) ([]int32, error) {
 	return availableGroups, rows.Err()
 }
 
-// SendLeadToClasses marks a lead as sent to classes board
+// SendLeadToClasses marks a lead as sent to classes board.
+// Returns *InstallmentError when an overdue installment blocks class start.
 func SendLeadToClasses(leadID uuid.UUID) error {
-	_, err := db.DB.Exec(`
+	facts, err := loadLeadFactsTx(db.DB, leadID)
+	if err != nil {
+		return err
+	}
+	if facts.InstallmentOverdueBlocked() {
+		return &InstallmentError{Message: "An installment is overdue; collect it before sending the student to classes."}
+	}
+	_, err = db.DB.Exec(`
 		UPDATE leads SET sent_to_classes = true, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1
 	`, leadID)
@@ -1639,46 +1398,52 @@ func GetClassGroupWorkflowsBatch(classKeys []string) (map[string]*ClassGroupWork
 	return result, rows.Err()
 }
 
-// UpdateLeadStatusFromPayment updates lead status based on payment state.
-// When total_course_paid >= offer_final_price: set status to paid_full.
-// When paid_full but total now < final: revert to offer_sent.
-// Does nothing if lead is cancelled.
+// UpdateLeadStatusFromPayment moves a lead through the pipeline based on course payments.
+// When total_course_paid >= offer_final_price: EventPaymentComplete (status paid_full).
+// When paid_full but total now < final: EventPaymentReversed (back to offer_sent).
+// Does nothing when the pipeline does not allow either event from the lead's status.
+// Follow-up tasks are synced with the new paid amount either way.
 func UpdateLeadStatusFromPayment(leadID uuid.UUID) error {
-	var currentStatus string
-	err := db.DB.QueryRow(`SELECT status FROM leads WHERE id = $1`, leadID).Scan(&currentStatus)
+	tx, err := db.DB.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to get lead status: %w", err)
-	}
-	if currentStatus == "cancelled" {
-		return nil
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	var finalPrice sql.NullInt32
-	err = db.DB.QueryRow(`SELECT final_price FROM offers WHERE lead_id = $1`, leadID).Scan(&finalPrice)
-	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("failed to get offer: %w", err)
+	defer tx.Rollback()
+	if err := updateLeadStatusFromPaymentTx(tx, leadID, time.Now()); err != nil {
+		return err
 	}
-	if err == sql.ErrNoRows || !finalPrice.Valid || finalPrice.Int32 <= 0 {
-		return nil
+	return tx.Commit()
+}
+
+func updateLeadStatusFromPaymentTx(tx *sql.Tx, leadID uuid.UUID, now time.Time) error {
+	currentStatus, err := getLeadStatusForUpdate(tx, leadID)
+	if err != nil {
+		return err
 	}
-	totalCoursePaid, err := GetTotalCoursePaid(leadID)
+	facts, err := loadLeadFactsTx(tx, leadID)
 	if err != nil {
-		return fmt.Errorf("failed to get total course paid: %w", err)
+		return err
 	}
-	var newStatus string
-	if totalCoursePaid >= finalPrice.Int32 {
-		newStatus = "paid_full"
-	} else if currentStatus == "paid_full" {
-		newStatus = "offer_sent"
-	} else {
+	// A deposit takes the lead out of the follow-up queue even when the status stays put
+	if err := syncLeadTasksTx(tx, leadID, currentStatus, facts.TotalCoursePaid, "", now); err != nil {
+		return err
+	}
+	if err := syncLeadInstallmentsTx(tx, leadID, facts.TotalCoursePaid, now); err != nil {
+		return err
+	}
+	if facts.OfferFinalPrice <= 0 {
 		return nil
 	}
-	if newStatus != currentStatus {
-		_, err = db.DB.Exec(`UPDATE leads SET status = $1, updated_at = $2 WHERE id = $3`, newStatus, time.Now(), leadID)
-		if err != nil {
-			return fmt.Errorf("failed to update lead status: %w", err)
-		}
+	event := EventPaymentReversed
+	reason := fmt.Sprintf("Course paid dropped to %d of %d (refund)", facts.TotalCoursePaid, facts.OfferFinalPrice)
+	if facts.FullyPaid() {
+		event = EventPaymentComplete
+		reason = fmt.Sprintf("Course paid %d of %d", facts.TotalCoursePaid, facts.OfferFinalPrice)
 	}
-	return nil
+	if _, err := CheckLeadTransition(currentStatus, event, RoleSystem, facts); err != nil {
+		return nil
+	}
+	return applyLeadEventTx(tx, leadID, event, RoleSystem, "", reason, now)
 }
 
 // GetTotalCoursePaid returns the net course payments for a lead (sum of payments - sum of refunds)
@@ -1726,6 +1491,62 @@ func GetTotalCoursePaid(leadID uuid.UUID) (int32, error) {
 	return netTotal, nil
 }
 
+// GetTotalCoursePaidBatch returns net course paid (payments minus refunds) for many leads in two queries.
+// Leads with nothing paid are omitted from the map.
+func GetTotalCoursePaidBatch(leadIDs []uuid.UUID) (map[uuid.UUID]int32, error) {
+	result := make(map[uuid.UUID]int32)
+	if len(leadIDs) == 0 {
+		return result, nil
+	}
+	ids := make([]string, len(leadIDs))
+	for i, id := range leadIDs {
+		ids[i] = id.String()
+	}
+
+	sumByLead := func(query string, sign int32) error {
+		rows, err := db.DB.Query(query, ids)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var leadID uuid.UUID
+			var total int32
+			if err := rows.Scan(&leadID, &total); err != nil {
+				return err
+			}
+			result[leadID] += sign * total
+		}
+		return rows.Err()
+	}
+
+	if err := sumByLead(`
+		SELECT lead_id, COALESCE(SUM(amount), 0)
+		FROM lead_payments
+		WHERE lead_id = ANY($1::uuid[])
+		GROUP BY lead_id
+	`, 1); err != nil {
+		return nil, fmt.Errorf("failed to get total course payments: %w", err)
+	}
+	if err := sumByLead(`
+		SELECT lead_id, COALESCE(SUM(amount), 0)
+		FROM transactions
+		WHERE lead_id = ANY($1::uuid[])
+		AND transaction_type = 'OUT'
+		AND category = 'refund'
+		GROUP BY lead_id
+	`, -1); err != nil {
+		return nil, fmt.Errorf("failed to get total refunds: %w", err)
+	}
+
+	for leadID, total := range result {
+		if total < 0 {
+			result[leadID] = 0
+		}
+	}
+	return result, nil
+}
+
 // GetLeadPayments returns all course payments for a lead
 func GetLeadPayments(leadID uuid.UUID) ([]*LeadPayment, error) {
 	rows, err := db.DB.Query(`
@@ -1802,29 +1623,16 @@ func CreateLeadPayment(leadID uuid.UUID, kind string, amount int32, paymentMetho
 		UpdatedAt:     time.Now(),
 	}
 
-	// Insert payment record
-	_, err := db.DB.Exec(`
-		INSERT INTO lead_payments (id, lead_id, kind, amount, payment_method, payment_date, notes, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
-	`, payment.ID, payment.LeadID, payment.Kind, payment.Amount, payment.PaymentMethod, payment.PaymentDate, payment.Notes, payment.CreatedAt)
+	tx, err := db.DB.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create lead payment: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-
-	// Create corresponding finance transaction (IN)
-	refKey := fmt.Sprintf("lead:%s:course_payment:%s", leadID.String(), payment.ID.String())
-	refIDStr := leadID.String()
-	paymentDateValue := paymentDate.Format("2006-01-02")
-	now := payment.CreatedAt
-
-	_, err = db.DB.Exec(`
-		INSERT INTO transactions (id, transaction_date, transaction_type, category, amount, payment_method, lead_id, ref_type, ref_id, ref_sub_type, ref_key, notes, created_at, updated_at)
-		VALUES ($1, $2::date, $3::text, $4::text, $5::integer, $6::text, $7::uuid, $8::text, $9::text, $10::text, $11::text, $12, $13::timestamp with time zone, $13::timestamp with time zone)
-	`, uuid.New(), paymentDateValue, "IN", "course_payment", amount, paymentMethod, leadID, "lead", refIDStr, "course_payment", refKey, payment.Notes, now)
-	if err != nil {
-		// Rollback payment if transaction creation fails
-		db.DB.Exec(`DELETE FROM lead_payments WHERE id = $1`, payment.ID)
-		return nil, fmt.Errorf("failed to create finance transaction: %w", err)
+	defer tx.Rollback()
+	if err := createLeadPaymentTx(tx, payment, sql.NullString{}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit lead payment: %w", err)
 	}
 
 	if err := UpdateLeadStatusFromPayment(leadID); err != nil {
@@ -1835,6 +1643,29 @@ func CreateLeadPayment(leadID uuid.UUID, kind string, amount int32, paymentMetho
 	return payment, nil
 }
 
+// createLeadPaymentTx inserts a course payment and its IN finance transaction. payerPaymentID links
+// the payment to the family payment it was split from, when there is one.
+func createLeadPaymentTx(q sqlExecer, payment *LeadPayment, payerPaymentID sql.NullString) error {
+	_, err := q.Exec(`
+		INSERT INTO lead_payments (id, lead_id, kind, amount, payment_method, payment_date, notes, payer_payment_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+	`, payment.ID, payment.LeadID, payment.Kind, payment.Amount, payment.PaymentMethod, payment.PaymentDate, payment.Notes, payerPaymentID, payment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create lead payment: %w", err)
+	}
+
+	// Corresponding finance transaction (IN)
+	refKey := fmt.Sprintf("lead:%s:course_payment:%s", payment.LeadID.String(), payment.ID.String())
+	_, err = q.Exec(`
+		INSERT INTO transactions (id, transaction_date, transaction_type, category, amount, payment_method, lead_id, ref_type, ref_id, ref_sub_type, ref_key, notes, created_at, updated_at)
+		VALUES ($1, $2::date, $3::text, $4::text, $5::integer, $6::text, $7::uuid, $8::text, $9::text, $10::text, $11::text, $12, $13::timestamp with time zone, $13::timestamp with time zone)
+	`, uuid.New(), payment.PaymentDate.Format("2006-01-02"), "IN", "course_payment", payment.Amount, payment.PaymentMethod, payment.LeadID, "lead", payment.LeadID.String(), "course_payment", refKey, payment.Notes, payment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create finance transaction: %w", err)
+	}
+	return nil
+}
+
 // CreateRefund creates a refund transaction (OUT) for a lead
 func CreateRefund(leadID uuid.UUID, amount int32, paymentMethod string, transactionDate time.Time, notes string) (*Transaction, error) {
 	if amount <= 0 {
@@ -1951,34 +1782,6 @@ func CreateCancelRefundIdempotent(leadID uuid.UUID, amount int32, paymentMethod
 	return nil
 }
 
-// CancelLead soft-cancels a lead (sets status to cancelled, does not delete)
-func CancelLead(leadID uuid.UUID) error {
-	now := time.Now()
-	_, err := db.DB.Exec(`
-		UPDATE leads 
-		SET status = 'cancelled', cancelled_at = $1, updated_at = $1
-		WHERE id = $2
-	`, now, leadID)
-	if err != nil {
-		return fmt.Errorf("failed to cancel lead: %w", err)
-	}
-	return nil
-}
-
-// ReopenLead reopens a cancelled lead (sets status back to a valid active status)
-func ReopenLead(leadID uuid.UUID) error {
-	// Set status to lead_created as default, admin can update later
-	_, err := db.DB.Exec(`
-		UPDATE leads 
-		SET status = 'lead_created', cancelled_at = NULL, updated_at = $1
-		WHERE id = $2 AND status = 'cancelled'
-	`, time.Now(), leadID)
-	if err != nil {
-		return fmt.Errorf("failed to reopen lead: %w", err)
-	}
-	return nil
-}
-
 // CreateExpense creates an OUT transaction for an expense
 func CreateExpense(category string, amount int32, paymentMethod string, transactionDate time.Time, notes string) (*Transaction, error) {
 	if amount <= 0 {
@@ -2070,38 +1873,66 @@ func UpsertPlacementTestIncome(leadID uuid.UUID, amountPaid int32, paymentDate s
 	return nil
 }
 
-// CalculateLevelsPurchased calculates levels purchased and bundle type from total paid amount
-// Bundle prices: 1 level = 1300, 2 levels = 2400, 3 levels = 3300, 4 levels = 4000
-func CalculateLevelsPurchased(bundleLevels sql.NullInt32, totalPaid int32) (levelsPurchased sql.NullInt32, bundleType sql.NullString) {
-	if !bundleLevels.Valid || bundleLevels.Int32 <= 0 {
+// CalculateLevelsPurchased calculates levels purchased and bundle type for a lead.
+// The offer's bundle wins when it is one the plan prices; without a bundle, the levels are the
+// largest bundle of the plan that totalPaid fully covers.
+func CalculateLevelsPurchased(plan *PricePlan, bundleLevels sql.NullInt32, totalPaid int32) (levelsPurchased sql.NullInt32, bundleType sql.NullString) {
+	var levels int32
+	if bundleLevels.Valid && bundleLevels.Int32 > 0 {
+		if plan != nil {
+			if _, ok := plan.BundlePrice(bundleLevels.Int32); !ok {
+				return sql.NullInt32{Valid: false}, sql.NullString{String: "none", Valid: true}
+			}
+		}
+		levels = bundleLevels.Int32
+	} else if plan != nil {
+		levels = plan.LevelsForAmount(totalPaid)
+	}
+	if levels <= 0 {
 		return sql.NullInt32{Valid: false}, sql.NullString{String: "none", Valid: true}
 	}
 
-	// If bundle levels is specified, use it
-	levelsPurchased = bundleLevels
-	bundleType = sql.NullString{String: fmt.Sprintf("bundle%d", bundleLevels.Int32), Valid: true}
-	if bundleLevels.Int32 == 1 {
+	levelsPurchased = sql.NullInt32{Int32: levels, Valid: true}
+	bundleType = sql.NullString{String: fmt.Sprintf("bundle%d", levels), Valid: true}
+	if levels == 1 {
 		bundleType = sql.NullString{String: "single", Valid: true}
 	}
 
 	return levelsPurchased, bundleType
 }
 
-// UpdateLeadCreditsFromPayments updates lead's levels_purchased_total and bundle_type based on payments
+// UpdateLeadCreditsFromPayments updates lead's levels_purchased_total and bundle_type based on payments,
+// priced from the plan the lead's offer was made from
 func UpdateLeadCreditsFromPayments(leadID uuid.UUID, bundleLevels sql.NullInt32) error {
-	payments, err := GetLeadPayments(leadID)
+	return updateLeadCreditsFromPaymentsTx(db.DB, leadID, bundleLevels)
+}
+
+func updateLeadCreditsFromPaymentsTx(q sqlExecer, leadID uuid.UUID, bundleLevels sql.NullInt32) error {
+	var totalPaid int32
+	err := q.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM lead_payments WHERE lead_id = $1`, leadID).Scan(&totalPaid)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to get total lead payments: %w", err)
 	}
 
-	var totalPaid int32 = 0
-	for _, p := range payments {
-		totalPaid += p.Amount
+	plan, err := getOfferPricePlan(q, leadID)
+	if err != nil {
+		return err
 	}
+	levelsPurchased, bundleType := CalculateLevelsPurchased(plan, bundleLevels, totalPaid)
 
-	levelsPurchased, bundleType := CalculateLevelsPurchased(bundleLevels, totalPaid)
+	// Accepted renewals are paid outside lead_payments; their levels stack on top
+	var renewalLevels int32
+	err = q.QueryRow(`
+		SELECT COALESCE(SUM(bundle_levels), 0) FROM renewal_offers WHERE lead_id = $1 AND status = 'accepted'
+	`, leadID).Scan(&renewalLevels)
+	if err != nil {
+		return fmt.Errorf("failed to get renewal levels: %w", err)
+	}
+	if renewalLevels > 0 {
+		levelsPurchased = sql.NullInt32{Int32: levelsPurchased.Int32 + renewalLevels, Valid: true}
+	}
 
-	_, err = db.DB.Exec(`
+	_, err = q.Exec(`
 		UPDATE leads SET 
 			levels_purchased_total = $1,
 			bundle_type = $2,
@@ -2629,15 +2460,9 @@ func CompleteSession(sessionID uuid.UUID, actualDate time.Time, actualTime strin
 			UPDATE leads
 			SET levels_consumed = COALESCE(levels_consumed, 0) + 1, updated_at = $1
 			WHERE id IN (
-				SELECT s.lead_id
-				FROM scheduling s
-				INNER JOIN class_groups cg ON (
-					cg.level = (SELECT pt.assigned_level FROM placement_tests pt WHERE pt.lead_id = s.lead_id)
-					AND cg.class_days = s.class_days
-					AND cg.class_time = s.class_time::text::text
-					AND COALESCE(cg.class_number, 1) = COALESCE(s.class_group_index, 1)
-				)
-				WHERE cg.class_key = $2
+				SELECT ce.lead_id
+				FROM class_enrolments ce
+				WHERE ce.class_key = $2 AND ce.left_at IS NULL
 			)
 		`, now, classKey)
 		if err != nil {
@@ -2649,15 +2474,9 @@ func CompleteSession(sessionID uuid.UUID, actualDate time.Time, actualTime strin
 	// This ensures students who weren't manually marked are treated as present by default
 	_, err = tx.Exec(`
 		INSERT INTO attendance (id, session_id, lead_id, status, created_at, updated_at)
-		SELECT gen_random_uuid(), $1, s.lead_id, 'PRESENT', $2, $2
-		FROM scheduling s
-		INNER JOIN class_groups cg ON (
-			cg.level = (SELECT pt.assigned_level FROM placement_tests pt WHERE pt.lead_id = s.lead_id)
-			AND cg.class_days = s.class_days
-			AND cg.class_time = s.class_time::text
-			AND COALESCE(cg.class_number, 1) = COALESCE(s.class_group_index, 1)
-		)
-		WHERE cg.class_key = $3
+		SELECT gen_random_uuid(), $1, ce.lead_id, 'PRESENT', $2, $2
+		FROM class_enrolments ce
+		WHERE ce.class_key = $3 AND ce.left_at IS NULL
 		ON CONFLICT (session_id, lead_id) DO NOTHING
 	`, sessionID, now, classKey)
 	if err != nil {
@@ -2922,19 +2741,13 @@ func GetRefundableAmount(leadID uuid.UUID) (int32, error) {
 		return 0, fmt.Errorf("failed to get total course paid: %w", err)
 	}
 
-	// Get student's class_key
+	// Get student's class_key (active enrolment first, otherwise the most recent one)
 	var classKey sql.NullString
 	err = db.DB.QueryRow(`
-		SELECT cg.class_key
-		FROM scheduling s
-		INNER JOIN placement_tests pt ON pt.lead_id = s.lead_id
-		INNER JOIN class_groups cg ON (
-			cg.level = pt.assigned_level
-			AND cg.class_days = s.class_days
-			AND cg.class_time = s.class_time::text
-			AND COALESCE(cg.class_number, 1) = COALESCE(s.class_group_index, 1)
-		)
-		WHERE s.lead_id = $1
+		SELECT class_key
+		FROM class_enrolments
+		WHERE lead_id = $1
+		ORDER BY (left_at IS NULL) DESC, joined_at DESC
 		LIMIT 1
 	`, leadID).Scan(&classKey)
 	if err == sql.ErrNoRows || !classKey.Valid {
@@ -3097,27 +2910,27 @@ func GetMentorClasses(mentorUserID uuid.UUID) ([]*ClassGroupWorkflow, error) {
 	return classes, rows.Err()
 }
 
-// CloseRound computes outcomes for all students in a class and sets high_priority_follow_up flag.
-// Returns to Operations by setting sent_to_mentor = false and round_status = 'closed'.
+// CloseRound records each student's outcome (promote or repeat) and moves them on: students with
+// credits left go back on the classes board at their next level, students without get a renewal offer
+// and the high_priority_follow_up flag. Returns to Operations by setting sent_to_mentor = false and round_status = 'closed'.
 func CloseRound(classKey string, closedByUserID uuid.UUID) error {
+	// Renewal offers are priced from the price list in effect today
+	plan, err := GetCurrentPricePlan(time.Now())
+	if err != nil {
+		return err
+	}
+
 	tx, err := db.DB.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Get all students in the class
+	// Get all students enrolled in the class
 	rows, err := tx.Query(`
-		SELECT s.lead_id
-		FROM scheduling s
-		INNER JOIN placement_tests pt ON pt.lead_id = s.lead_id
-		INNER JOIN class_groups cg ON (
-			cg.level = pt.assigned_level
-			AND cg.class_days = s.class_days
-			AND cg.class_time = s.class_time::text
-			AND COALESCE(cg.class_number, 1) = COALESCE(s.class_group_index, 1)
-		)
-		WHERE cg.class_key = $1
+		SELECT lead_id
+		FROM class_enrolments
+		WHERE class_key = $1 AND left_at IS NULL
 	`, classKey)
 	if err != nil {
 		return fmt.Errorf("failed to query students: %w", err)
@@ -3134,8 +2947,14 @@ func CloseRound(classKey string, closedByUserID uuid.UUID) error {
 	}
 	rows.Close()
 
+	var classLevel int32
+	err = tx.QueryRow(`SELECT level FROM class_groups WHERE class_key = $1`, classKey).Scan(&classLevel)
+	if err != nil {
+		return fmt.Errorf("failed to get class level: %w", err)
+	}
+
 	now := time.Now()
-	// For each student, compute outcome and set follow-up flag if needed
+	// For each student, record the outcome, move them on and set the follow-up flag if needed
 	for _, leadID := range leadIDs {
 		// Count absences
 		var absences int
@@ -3158,11 +2977,7 @@ func CloseRound(classKey string, closedByUserID uuid.UUID) error {
 			return fmt.Errorf("failed to get grade: %w", err)
 		}
 
-		// Compute decision: repeat if absences > 2 OR grade = 'F'
-		shouldRepeat := absences > 2 || (grade.Valid && grade.String == "F")
-		_ = shouldRepeat // outcome: repeat vs promote (stored when outcome column exists)
-
-		// Check if student has no remaining credits
+		// Check remaining credits
 		var levelsPurchased, levelsConsumed sql.NullInt32
 		err = tx.QueryRow(`
 			SELECT levels_purchased_total, levels_consumed FROM leads WHERE id = $1
@@ -3180,8 +2995,14 @@ func CloseRound(classKey string, closedByUserID uuid.UUID) error {
 			consumed = levelsConsumed.Int32
 		}
 
-		// Set high_priority_follow_up if no remaining credits
-		highPriority := consumed >= purchased
+		// Repeat if absences > 2 OR grade = 'F'; re-place with credits, otherwise open a renewal offer
+		followOn, err := closeRoundStudentTx(tx, leadID, classKey, classLevel, absences, grade, purchased-consumed, plan, now)
+		if err != nil {
+			return err
+		}
+
+		// Set high_priority_follow_up while a renewal offer is waiting
+		highPriority := followOn == RoundFollowOnRenewalOffer
 		_, err = tx.Exec(`
 			UPDATE leads SET high_priority_follow_up = $1, updated_at = $2 WHERE id = $3
 		`, highPriority, now, leadID)
@@ -3190,6 +3011,16 @@ func CloseRound(classKey string, closedByUserID uuid.UUID) error {
 		}
 	}
 
+	// End enrolments for this round
+	_, err = tx.Exec(`
+		UPDATE class_enrolments
+		SET left_at = $1, left_reason = $2, updated_at = $1
+		WHERE class_key = $3 AND left_at IS NULL
+	`, now, EnrolmentLeftRoundClosed, classKey)
+	if err != nil {
+		return fmt.Errorf("failed to close enrolments: %w", err)
+	}
+
 	// Return class to Operations and mark round closed
 	_, err = tx.Exec(`
 		UPDATE class_groups
@@ -3264,16 +3095,10 @@ func GetPendingFeedback(sessionNumber int32) ([]struct {
 	rows, err := db.DB.Query(`
 		SELECT DISTINCT l.id, l.full_name, l.phone, cs.class_key
 		FROM leads l
-		INNER JOIN scheduling s ON s.lead_id = l.id
-		INNER JOIN placement_tests pt ON pt.lead_id = l.id
-		INNER JOIN class_groups cg ON (
-			cg.level = pt.assigned_level
-			AND cg.class_days = s.class_days
-			AND cg.class_time = s.class_time::text
-			AND COALESCE(cg.class_number, 1) = COALESCE(s.class_group_index, 1)
-		)
-		INNER JOIN class_sessions cs ON cs.class_key = cg.class_key AND cs.session_number = $1
+		INNER JOIN class_enrolments ce ON ce.lead_id = l.id
+		INNER JOIN class_sessions cs ON cs.class_key = ce.class_key AND cs.session_number = $1
 		WHERE cs.status = 'completed'
+		AND (ce.left_at IS NULL OR ce.left_reason = 'round_closed')
 		AND NOT EXISTS (
 			SELECT 1 FROM community_officer_feedback cof
 			WHERE cof.lead_id = l.id AND cof.class_key = cs.class_key AND cof.session_number = $1
@@ -3712,8 +3537,18 @@ func GetStudentSuccessClassDetail(classKey string) (classGroup *ClassGroupWorkfl
 	return classGroup, students, sessions, missedSessions, feedbackRecords, completedCount, nil
 }
 
-// GetStudentsInClassGroup returns all students in a class group
+// GetStudentsInClassGroup returns all students in a class group.
+// Once a round has started, membership comes from class_enrolments (students who moved out are excluded,
+// students whose round closed are kept). Before that, the classes board assignment in scheduling is used.
 func GetStudentsInClassGroup(classKey string) ([]*ClassStudent, error) {
+	enrolled, err := HasClassEnrolments(classKey)
+	if err != nil {
+		return nil, err
+	}
+	if enrolled {
+		return getEnrolledStudents(classKey)
+	}
+
 	rows, err := db.DB.Query(`
 		SELECT l.id, l.full_name, l.phone, s.class_group_index
 		FROM leads l
@@ -3752,7 +3587,38 @@ func GetStudentsInClassGroup(classKey string) ([]*ClassStudent, error) {
 	return students, nil
 }
 
-// StartClassRound starts the round for a class group: sets status to 'active' and creates 8 sessions
+// getEnrolledStudents returns students enrolled in a class's current round, excluding those who moved to another class
+func getEnrolledStudents(classKey string) ([]*ClassStudent, error) {
+	rows, err := db.DB.Query(`
+		SELECT l.id, l.full_name, l.phone, cg.class_number
+		FROM class_enrolments ce
+		INNER JOIN leads l ON l.id = ce.lead_id
+		INNER JOIN class_groups cg ON cg.class_key = ce.class_key
+		WHERE ce.class_key = $1
+		AND `+currentRoundEnrolment+`
+		AND (ce.left_at IS NULL OR ce.left_reason = $2)
+		ORDER BY l.full_name
+	`, classKey, EnrolmentLeftRoundClosed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query enrolled students: %w", err)
+	}
+	defer rows.Close()
+
+	var students []*ClassStudent
+	for rows.Next() {
+		s := &ClassStudent{}
+		if err := rows.Scan(&s.LeadID, &s.FullName, &s.Phone, &s.GroupIndex); err != nil {
+			return nil, fmt.Errorf("failed to scan student: %w", err)
+		}
+		students = append(students, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return students, nil
+}
+
+// StartClassRound starts the round for a class group: sets status to 'active', enrols its students and creates 8 sessions
 func StartClassRound(classKey string, startedByUserID uuid.UUID, startDate time.Time, startTime string) error {
 	tx, err := db.DB.Begin()
 	if err != nil {
@@ -3782,7 +3648,12 @@ func StartClassRound(classKey string, startedByUserID uuid.UUID, startDate time.
 		return fmt.Errorf("class group not found: %s", classKey)
 	}
 
-	// 2. Create 8 sessions
+	// 2. Record class membership
+	if err := enrolClassFromSchedulingTx(tx, classKey, now); err != nil {
+		return err
+	}
+
+	// 3. Create 8 sessions
 	// Parse time ensuring HH:MM format
 	parsedTime, err := time.Parse("15:04", startTime)
 	if err != nil {
@@ -3821,6 +3692,8 @@ func GetAbsenceFeed(classKey, filter, search string) ([]*AbsenceFeedItem, error)
 			l.id,
 			l.full_name,
 			l.phone,
+			COALESCE(g.full_name, ''),
+			COALESCE(g.phone, ''),
 			a.status,
 			COALESCE(u.email, 'unknown'),
 			a.created_at,
@@ -3834,6 +3707,7 @@ func GetAbsenceFeed(classKey, filter, search string) ([]*AbsenceFeedItem, error)
 		FROM class_sessions s
 		JOIN attendance a ON s.id = a.session_id
 		JOIN leads l ON a.lead_id = l.id
+		LEFT JOIN payers g ON g.id = l.payer_id
 		LEFT JOIN users u ON a.marked_by_user_id = u.id
 		LEFT JOIN followups f ON f.class_key = s.class_key AND f.lead_id = l.id AND f.session_number = s.session_number
 		WHERE s.class_key = $1 
@@ -3890,6 +3764,8 @@ func GetAbsenceFeed(classKey, filter, search string) ([]*AbsenceFeedItem, error)
 			&item.StudentID,
 			&item.StudentName,
 			&item.StudentPhone,
+			&item.GuardianName,
+			&item.GuardianPhone,
 			&item.Status,
 			&item.MarkedBy,
 			&item.MarkedAt,
@@ -3978,10 +3854,11 @@ func UpdateFollowUp(id uuid.UUID, status, note string, resolved bool, userID uui
 func GetFollowUps(classKey string, resolved bool) ([]*FollowUpListItem, error) {
 	rows, err := db.DB.Query(`
 		SELECT 
-			f.id, f.lead_id, l.full_name, l.phone, f.session_number, 
+			f.id, f.lead_id, l.full_name, l.phone, COALESCE(g.full_name, ''), COALESCE(g.phone, ''), f.session_number, 
 			a.status as attendance_status, f.note, f.status, f.created_at, f.resolved, f.resolved_at
 		FROM followups f
 		JOIN leads l ON f.lead_id = l.id
+		LEFT JOIN payers g ON g.id = l.payer_id
 		LEFT JOIN class_sessions s ON s.class_key = f.class_key AND s.session_number = f.session_number
 		LEFT JOIN attendance a ON a.session_id = s.id AND a.lead_id = f.lead_id
 		WHERE f.class_key = $1 AND f.resolved = $2 AND f.status = 'no_response'
@@ -3999,7 +3876,7 @@ func GetFollowUps(classKey string, resolved bool) ([]*FollowUpListItem, error) {
 		var resolvedAt sql.NullTime
 		var attStatus sql.NullString
 		if err := rows.Scan(
-			&item.ID, &item.LeadID, &item.StudentName, &item.StudentPhone, &item.SessionNumber,
+			&item.ID, &item.LeadID, &item.StudentName, &item.StudentPhone, &item.GuardianName, &item.GuardianPhone, &item.SessionNumber,
 			&attStatus, &note, &item.Status, &item.CreatedAt, &item.Resolved, &resolvedAt,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan follow-up: %w", err)
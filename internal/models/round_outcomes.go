@@ -0,0 +1,314 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"eighty-twenty-ops/internal/db"
+	"eighty-twenty-ops/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// Round outcomes (class_enrolments.outcome)
+const (
+	RoundOutcomePromote = "promote"
+	RoundOutcomeRepeat  = "repeat"
+)
+
+// What happened to a student after their round closed (class_enrolments.follow_on)
+const (
+	RoundFollowOnReplaced     = "replaced"      // back on the classes board, credits left
+	RoundFollowOnRenewalOffer = "renewal_offer" // no credits left, renewal offer opened
+	RoundFollowOnCompleted    = "completed"     // passed the last level
+	RoundFollowOnSkipped      = "skipped"       // the lead could not be moved (e.g. no class schedule)
+)
+
+// Renewal offer statuses
+const (
+	RenewalOfferOpen     = "open"
+	RenewalOfferAccepted = "accepted"
+	RenewalOfferDeclined = "declined"
+	RenewalOfferMerged   = "merged" // closed when the lead was merged into one that already had an open offer
+)
+
+// MaxLevel is the last course level
+const MaxLevel = 8
+
+// maxRoundAbsences is the number of absences a student can have and still move up a level
+const maxRoundAbsences = 2
+
+// RenewalDueCond matches leads with an open renewal offer (alias l)
+const RenewalDueCond = `EXISTS (SELECT 1 FROM renewal_offers ro WHERE ro.lead_id = l.id AND ro.status = 'open')`
+
+// RoundOutcomeFor decides whether a student repeats their level: more than 2 absences or an F at session 8
+func RoundOutcomeFor(absences int, grade sql.NullString) string {
+	if absences > maxRoundAbsences || (grade.Valid && grade.String == "F") {
+		return RoundOutcomeRepeat
+	}
+	return RoundOutcomePromote
+}
+
+// NextRoundLevel returns the level a student continues at after a round at level.
+// ok is false when they passed the last level and the course is complete.
+func NextRoundLevel(level int32, outcome string) (next int32, ok bool) {
+	if outcome == RoundOutcomeRepeat {
+		return level, true
+	}
+	if level >= MaxLevel {
+		return 0, false
+	}
+	return level + 1, true
+}
+
+// RenewalBundleLevels pre-fills a renewal's bundle: the size the student bought last time (one level
+// when unknown), capped at the bundle maximum and at the levels left from nextLevel
+func RenewalBundleLevels(previous sql.NullInt32, nextLevel int32) int32 {
+	bundle := int32(1)
+	if previous.Valid && previous.Int32 > 0 {
+		bundle = previous.Int32
+	}
+	if bundle > MaxBundleLevels {
+		bundle = MaxBundleLevels
+	}
+	if left := MaxLevel - nextLevel + 1; bundle > left {
+		bundle = left
+	}
+	if bundle < 1 {
+		bundle = 1
+	}
+	return bundle
+}
+
+// closeRoundStudentTx records one student's round outcome and moves them on: with credits left they go
+// back on the classes board at the next level, without credits a renewal offer is opened. Returns the
+// follow-on recorded on the enrolment.
+func closeRoundStudentTx(tx *sql.Tx, leadID uuid.UUID, classKey string, classLevel int32, absences int, grade sql.NullString, credits int32, plan *PricePlan, now time.Time) (string, error) {
+	outcome := RoundOutcomeFor(absences, grade)
+	nextLevel, hasNext := NextRoundLevel(classLevel, outcome)
+
+	followOn := RoundFollowOnCompleted
+	switch {
+	case !hasNext:
+	case credits > 0:
+		followOn = RoundFollowOnReplaced
+		err := placeForNextRoundTx(tx, leadID, nextLevel, fmt.Sprintf("Round closed: Level %d (%s)", nextLevel, outcome), "", now)
+		var transErr *LeadTransitionError
+		if errors.As(err, &transErr) {
+			followOn = RoundFollowOnSkipped
+		} else if err != nil {
+			return "", err
+		}
+	default:
+		followOn = RoundFollowOnRenewalOffer
+		if err := openRenewalOfferTx(tx, leadID, classKey, nextLevel, plan, now); err != nil {
+			return "", err
+		}
+	}
+
+	var next sql.NullInt32
+	if hasNext {
+		next = sql.NullInt32{Int32: nextLevel, Valid: true}
+	}
+	_, err := tx.Exec(`
+		UPDATE class_enrolments
+		SET outcome = $1, absences = $2, grade = $3, next_level = $4, follow_on = $5, updated_at = $6
+		WHERE lead_id = $7 AND class_key = $8 AND left_at IS NULL
+	`, outcome, absences, grade, next, followOn, now, leadID, classKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to record round outcome: %w", err)
+	}
+	return followOn, nil
+}
+
+// placeForNextRoundTx sets the student's level and puts them back on the classes board with the same
+// class days and time. A *LeadTransitionError means the lead cannot move (not in classes, no schedule);
+// the transaction is still usable because the check runs before anything is written.
+func placeForNextRoundTx(tx *sql.Tx, leadID uuid.UUID, level int32, reason, actorUserID string, now time.Time) error {
+	status, err := getLeadStatusForUpdate(tx, leadID)
+	if err != nil {
+		return err
+	}
+	facts, err := loadLeadFactsTx(tx, leadID)
+	if err != nil {
+		return err
+	}
+	if _, err := CheckLeadTransition(status, EventNextRound, RoleSystem, facts); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE placement_tests SET assigned_level = $1, updated_at = $2 WHERE lead_id = $3`, level, now, leadID)
+	if err != nil {
+		return fmt.Errorf("failed to set next level: %w", err)
+	}
+	return applyLeadEventTx(tx, leadID, EventNextRound, RoleSystem, actorUserID, reason, now)
+}
+
+// openRenewalOfferTx opens (or refreshes) the lead's renewal offer for level, sized like their last
+// purchase and priced from plan when one is in effect
+func openRenewalOfferTx(tx *sql.Tx, leadID uuid.UUID, classKey string, level int32, plan *PricePlan, now time.Time) error {
+	var previous sql.NullInt32
+	err := tx.QueryRow(`
+		SELECT COALESCE(
+			(SELECT bundle_levels FROM renewal_offers WHERE lead_id = $1 AND status = 'accepted' ORDER BY closed_at DESC LIMIT 1),
+			(SELECT bundle_levels FROM offers WHERE lead_id = $1)
+		)
+	`, leadID).Scan(&previous)
+	if err != nil {
+		return fmt.Errorf("failed to get previous bundle: %w", err)
+	}
+	bundle := RenewalBundleLevels(previous, level)
+
+	var planID sql.NullString
+	var basePrice sql.NullInt32
+	if plan != nil {
+		if price, ok := plan.BundlePrice(bundle); ok {
+			planID = sql.NullString{String: plan.ID.String(), Valid: true}
+			basePrice = sql.NullInt32{Int32: price, Valid: true}
+		}
+	}
+	_, err = tx.Exec(`
+		INSERT INTO renewal_offers (lead_id, class_key, level, bundle_levels, price_plan_id, base_price, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (lead_id) WHERE status = 'open' DO UPDATE SET
+			class_key = EXCLUDED.class_key, level = EXCLUDED.level, bundle_levels = EXCLUDED.bundle_levels,
+			price_plan_id = EXCLUDED.price_plan_id, base_price = EXCLUDED.base_price, created_at = EXCLUDED.created_at
+	`, leadID, classKey, level, bundle, planID, basePrice, now)
+	if err != nil {
+		return fmt.Errorf("failed to open renewal offer: %w", err)
+	}
+	return nil
+}
+
+// GetLeadRenewalOffers returns a lead's renewal offers, newest first
+func GetLeadRenewalOffers(leadID uuid.UUID) ([]*RenewalOffer, error) {
+	rows, err := db.DB.Query(`
+		SELECT ro.id, ro.lead_id, ro.class_key, ro.level, ro.bundle_levels, ro.price_plan_id::text,
+		       ro.base_price, ro.final_price, ro.status, ro.payment_method, ro.payment_date,
+		       ro.created_at, ro.closed_at, COALESCE(u.email, '')
+		FROM renewal_offers ro
+		LEFT JOIN users u ON u.id = ro.closed_by_user_id
+		WHERE ro.lead_id = $1
+		ORDER BY ro.created_at DESC
+	`, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get renewal offers: %w", err)
+	}
+	defer rows.Close()
+
+	var offers []*RenewalOffer
+	for rows.Next() {
+		ro := &RenewalOffer{}
+		if err := rows.Scan(&ro.ID, &ro.LeadID, &ro.ClassKey, &ro.Level, &ro.BundleLevels, &ro.PricePlanID,
+			&ro.BasePrice, &ro.FinalPrice, &ro.Status, &ro.PaymentMethod, &ro.PaymentDate,
+			&ro.CreatedAt, &ro.ClosedAt, &ro.ClosedByEmail); err != nil {
+			return nil, fmt.Errorf("failed to scan renewal offer: %w", err)
+		}
+		offers = append(offers, ro)
+	}
+	return offers, rows.Err()
+}
+
+// AcceptRenewalOffer records the renewal payment for the lead's open offer: the payment is posted to
+// finance, the bundle's levels are added to the student's credits and the student goes back on the
+// classes board at the offer's level. Returns *RenewalOfferError for a bad request and
+// *LeadTransitionError when the student cannot be placed (e.g. no class schedule).
+func AcceptRenewalOffer(leadID uuid.UUID, finalPrice int32, paymentMethod string, paymentDate time.Time, actorUserID string) error {
+	if finalPrice <= 0 {
+		return &RenewalOfferError{Message: "Final price must be greater than zero."}
+	}
+	allowedMethods := map[string]bool{
+		"vodafone_cash": true, "bank_transfer": true, "paypal": true, "other": true,
+	}
+	if !allowedMethods[paymentMethod] {
+		return &RenewalOfferError{Message: "Choose how the renewal was paid."}
+	}
+	if err := util.ValidateNotFutureDate(paymentDate); err != nil {
+		return &RenewalOfferError{Message: "Payment date cannot be in the future."}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var renewalID uuid.UUID
+	var level, bundle int32
+	err = tx.QueryRow(`
+		SELECT id, level, bundle_levels FROM renewal_offers WHERE lead_id = $1 AND status = 'open' FOR UPDATE
+	`, leadID).Scan(&renewalID, &level, &bundle)
+	if err == sql.ErrNoRows {
+		return &RenewalOfferError{Message: "This lead has no open renewal offer."}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get renewal offer: %w", err)
+	}
+
+	now := time.Now()
+	if err := placeForNextRoundTx(tx, leadID, level, fmt.Sprintf("Renewal accepted: Level %d", level), actorUserID, now); err != nil {
+		return err
+	}
+
+	var closedBy sql.NullString
+	if actorUserID != "" {
+		closedBy = sql.NullString{String: actorUserID, Valid: true}
+	}
+	_, err = tx.Exec(`
+		UPDATE renewal_offers
+		SET status = $1, final_price = $2, payment_method = $3, payment_date = $4::date, closed_at = $5, closed_by_user_id = $6
+		WHERE id = $7
+	`, RenewalOfferAccepted, finalPrice, paymentMethod, paymentDate.Format("2006-01-02"), now, closedBy, renewalID)
+	if err != nil {
+		return fmt.Errorf("failed to accept renewal offer: %w", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE leads
+		SET levels_purchased_total = COALESCE(levels_purchased_total, 0) + $1, high_priority_follow_up = false, updated_at = $2
+		WHERE id = $3
+	`, bundle, now, leadID)
+	if err != nil {
+		return fmt.Errorf("failed to add renewal credits: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO transactions (id, transaction_date, transaction_type, category, amount, payment_method, lead_id, ref_type, ref_id, ref_key, notes, created_at, updated_at)
+		VALUES (gen_random_uuid(), $1::date, 'IN', 'course_payment', $2::integer, $3::text, $4::uuid, 'renewal_offer', $5::text, $6::text, $7, $8::timestamp with time zone, $8::timestamp with time zone)
+		ON CONFLICT (ref_key) DO NOTHING
+	`, paymentDate.Format("2006-01-02"), finalPrice, paymentMethod, leadID, renewalID.String(), "renewal:"+renewalID.String(),
+		fmt.Sprintf("Renewal: Level %d, %d level(s)", level, bundle), now)
+	if err != nil {
+		return fmt.Errorf("failed to post renewal payment: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeclineRenewalOffer closes the lead's open renewal offer without payment and clears the follow-up flag
+func DeclineRenewalOffer(leadID uuid.UUID, actorUserID string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var closedBy sql.NullString
+	if actorUserID != "" {
+		closedBy = sql.NullString{String: actorUserID, Valid: true}
+	}
+	res, err := tx.Exec(`
+		UPDATE renewal_offers SET status = $1, closed_at = $2, closed_by_user_id = $3
+		WHERE lead_id = $4 AND status = 'open'
+	`, RenewalOfferDeclined, now, closedBy, leadID)
+	if err != nil {
+		return fmt.Errorf("failed to decline renewal offer: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &RenewalOfferError{Message: "This lead has no open renewal offer."}
+	}
+	_, err = tx.Exec(`UPDATE leads SET high_priority_follow_up = false, updated_at = $1 WHERE id = $2`, now, leadID)
+	if err != nil {
+		return fmt.Errorf("failed to clear follow-up flag: %w", err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestRoundOutcomeFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		absences int
+		grade    sql.NullString
+		want     string
+	}{
+		{"good round", 1, sql.NullString{String: "B", Valid: true}, RoundOutcomePromote},
+		{"two absences still promote", 2, sql.NullString{String: "C", Valid: true}, RoundOutcomePromote},
+		{"too many absences", 3, sql.NullString{String: "A", Valid: true}, RoundOutcomeRepeat},
+		{"failed final", 0, sql.NullString{String: "F", Valid: true}, RoundOutcomeRepeat},
+		{"no grade recorded", 0, sql.NullString{}, RoundOutcomePromote},
+	}
+	for _, tt := range tests {
+		if got := RoundOutcomeFor(tt.absences, tt.grade); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNextRoundLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   int32
+		outcome string
+		want    int32
+		wantOK  bool
+	}{
+		{"promote", 3, RoundOutcomePromote, 4, true},
+		{"repeat", 3, RoundOutcomeRepeat, 3, true},
+		{"promote from last level completes", MaxLevel, RoundOutcomePromote, 0, false},
+		{"repeat last level", MaxLevel, RoundOutcomeRepeat, MaxLevel, true},
+	}
+	for _, tt := range tests {
+		got, ok := NextRoundLevel(tt.level, tt.outcome)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: got (%d, %v), want (%d, %v)", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRenewalBundleLevels(t *testing.T) {
+	tests := []struct {
+		name      string
+		previous  sql.NullInt32
+		nextLevel int32
+		want      int32
+	}{
+		{"same as last purchase", sql.NullInt32{Int32: 2, Valid: true}, 3, 2},
+		{"unknown bundle buys one level", sql.NullInt32{}, 3, 1},
+		{"capped at bundle maximum", sql.NullInt32{Int32: 6, Valid: true}, 1, MaxBundleLevels},
+		{"capped at levels left", sql.NullInt32{Int32: 4, Valid: true}, 7, 2},
+		{"last level", sql.NullInt32{Int32: 3, Valid: true}, MaxLevel, 1},
+	}
+	for _, tt := range tests {
+		if got := RenewalBundleLevels(tt.previous, tt.nextLevel); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
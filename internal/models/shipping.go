@@ -0,0 +1,516 @@
+package models
+
+import (
+	"database/sql"
+	"eighty-twenty-ops/internal/db"
+	"eighty-twenty-ops/internal/util"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Shipment statuses (shipping.shipment_status) and batch statuses (shipment_batches.status)
+const (
+	ShipmentPending   = "pending"
+	ShipmentPacked    = "packed"
+	ShipmentSent      = "sent"
+	ShipmentDelivered = "delivered"
+	ShipmentReturned  = "returned"
+
+	BatchPacking = "packing"
+	BatchSent    = "sent"
+)
+
+// shipmentTransitions lists where a shipment can go from each status. Packing and sending happen
+// per batch; a packed shipment can be taken out of its batch (back to pending), and a returned
+// book can be packed into a new batch.
+var shipmentTransitions = map[string][]string{
+	ShipmentPending:   {ShipmentPacked},
+	ShipmentPacked:    {ShipmentSent, ShipmentPending},
+	ShipmentSent:      {ShipmentDelivered, ShipmentReturned},
+	ShipmentDelivered: {ShipmentReturned},
+	ShipmentReturned:  {ShipmentPacked},
+}
+
+// CanTransitionShipment reports whether a shipment may move from one status to another.
+// A lead without a shipment yet counts as pending.
+func CanTransitionShipment(from, to string) bool {
+	if from == "" {
+		from = ShipmentPending
+	}
+	for _, next := range shipmentTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ShippingCouriers are the couriers a batch can be handed to, with display names
+var ShippingCouriers = []struct{ Value, Name string }{
+	{"bosta", "Bosta"},
+	{"aramex", "Aramex"},
+	{"egypt_post", "Egypt Post"},
+	{"mylerz", "Mylerz"},
+	{"other", "Other"},
+}
+
+func isShippingCourier(courier string) bool {
+	for _, c := range ShippingCouriers {
+		if c.Value == courier {
+			return true
+		}
+	}
+	return false
+}
+
+// CourierName returns the display name of the batch's courier
+func (b *ShipmentBatch) CourierName() string {
+	for _, c := range ShippingCouriers {
+		if c.Value == b.Courier {
+			return c.Name
+		}
+	}
+	return b.Courier
+}
+
+// Label is how a batch is referred to on screen and on the manifest
+func (b *ShipmentBatch) Label() string {
+	return fmt.Sprintf("Batch #%d", b.BatchNumber)
+}
+
+const minShippingAddressLength = 10
+
+// ValidateShippingAddress checks that a booking address is good enough to hand to a courier:
+// a street address of a reasonable length with a building or street number, and a city.
+// Returns *ShippingError describing the first problem.
+func ValidateShippingAddress(address, city string) error {
+	address, city = strings.TrimSpace(address), strings.TrimSpace(city)
+	if address == "" {
+		return &ShippingError{Message: "Address is missing."}
+	}
+	if len([]rune(address)) < minShippingAddressLength {
+		return &ShippingError{Message: "Address is too short to deliver to."}
+	}
+	if !strings.ContainsFunc(address, unicode.IsDigit) {
+		return &ShippingError{Message: "Address needs a building or street number."}
+	}
+	if city == "" {
+		return &ShippingError{Message: "City is missing."}
+	}
+	return nil
+}
+
+// paidPrintedBookCond matches leads (alias l) who ordered a printed book and whose course is paid in
+// full (net of refunds) or on an installment plan. Cancelled and archived leads are left out.
+const paidPrintedBookCond = `
+	l.status <> 'cancelled'
+	AND l.archived_at IS NULL
+	AND EXISTS (SELECT 1 FROM bookings b WHERE b.lead_id = l.id AND b.book_format = 'printed')
+	AND (
+		EXISTS (SELECT 1 FROM lead_installments i WHERE i.lead_id = l.id)
+		OR COALESCE((SELECT SUM(amount) FROM lead_payments WHERE lead_id = l.id), 0) -
+		   COALESCE((SELECT SUM(amount) FROM transactions WHERE lead_id = l.id AND category = 'refund' AND transaction_type = 'OUT'), 0)
+		   >= (SELECT final_price FROM offers WHERE lead_id = l.id AND final_price > 0)
+	)`
+
+// PaidBookNotShippedCond matches paid leads with a printed book that has not gone out yet
+// (no shipment, pending, packed or returned). Used by the lead list's "paid but book not shipped" filter.
+const PaidBookNotShippedCond = paidPrintedBookCond + `
+	AND NOT EXISTS (SELECT 1 FROM shipping s WHERE s.lead_id = l.id AND s.shipment_status IN ('sent', 'delivered'))`
+
+// toPackCond matches paid leads whose book is waiting to be packed (no shipment, pending or returned)
+const toPackCond = paidPrintedBookCond + `
+	AND NOT EXISTS (SELECT 1 FROM shipping s WHERE s.lead_id = l.id AND s.shipment_status IN ('packed', 'sent', 'delivered'))`
+
+// GetShipmentsToPack returns paid students whose printed book is waiting to be packed, oldest booking
+// first, with AddressProblem set when their address cannot be shipped to yet and the stock of their level's book
+func GetShipmentsToPack() ([]*Shipment, error) {
+	rows, err := db.DB.Query(`
+		SELECT l.id, l.full_name, l.phone, b.address, b.city, b.delivery_notes, s.shipment_status, s.returned_at,
+			pt.assigned_level,
+			CASE WHEN m.id IS NOT NULL THEN COALESCE((SELECT SUM(sm.quantity) FROM material_stock_movements sm WHERE sm.material_id = m.id), 0) END
+		FROM leads l
+		JOIN bookings b ON b.lead_id = l.id
+		LEFT JOIN shipping s ON s.lead_id = l.id
+		LEFT JOIN placement_tests pt ON pt.lead_id = l.id
+		LEFT JOIN materials m ON m.level = pt.assigned_level AND m.format = 'printed' AND m.active
+		WHERE ` + toPackCond + `
+		ORDER BY b.updated_at, l.full_name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shipments to pack: %w", err)
+	}
+	defer rows.Close()
+
+	var shipments []*Shipment
+	for rows.Next() {
+		s := &Shipment{}
+		if err := rows.Scan(&s.LeadID, &s.FullName, &s.Phone, &s.Address, &s.City, &s.DeliveryNotes,
+			&s.ShipmentStatus, &s.ReturnedAt, &s.Level, &s.StockOnHand); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment to pack: %w", err)
+		}
+		if err := ValidateShippingAddress(s.Address.String, s.City.String); err != nil {
+			s.AddressProblem = err.Error()
+		}
+		shipments = append(shipments, s)
+	}
+	return shipments, rows.Err()
+}
+
+const shipmentColumns = `
+	s.id, s.lead_id, s.shipment_status, s.shipment_date, s.batch_id::text, s.tracking_number,
+	s.ship_to_address, s.ship_to_city, s.packed_at, s.delivered_at, s.returned_at, s.updated_at,
+	l.full_name, l.phone, b.address, b.city, b.delivery_notes
+	FROM shipping s
+	JOIN leads l ON l.id = s.lead_id
+	LEFT JOIN bookings b ON b.lead_id = s.lead_id`
+
+func scanShipments(rows *sql.Rows) ([]*Shipment, error) {
+	defer rows.Close()
+	var shipments []*Shipment
+	for rows.Next() {
+		s := &Shipment{}
+		err := rows.Scan(&s.ID, &s.LeadID, &s.ShipmentStatus, &s.ShipmentDate, &s.BatchID, &s.TrackingNumber,
+			&s.ShipToAddress, &s.ShipToCity, &s.PackedAt, &s.DeliveredAt, &s.ReturnedAt, &s.UpdatedAt,
+			&s.FullName, &s.Phone, &s.Address, &s.City, &s.DeliveryNotes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shipment: %w", err)
+		}
+		shipments = append(shipments, s)
+	}
+	return shipments, rows.Err()
+}
+
+const shipmentBatchColumns = `
+	sb.id, sb.batch_number, sb.courier, sb.status, sb.shipping_cost, sb.payment_method, sb.sent_on,
+	sb.created_by_user_id::text, sb.created_at`
+
+func scanShipmentBatch(scan func(dest ...interface{}) error, extra ...interface{}) (*ShipmentBatch, error) {
+	b := &ShipmentBatch{}
+	dest := append([]interface{}{&b.ID, &b.BatchNumber, &b.Courier, &b.Status, &b.ShippingCost, &b.PaymentMethod, &b.SentOn,
+		&b.CreatedByUserID, &b.CreatedAt}, extra...)
+	if err := scan(dest...); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// GetShipmentBatches returns batches newest first, with how many shipments each holds
+func GetShipmentBatches(limit int) ([]*ShipmentBatch, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+shipmentBatchColumns+`, (SELECT COUNT(*) FROM shipping s WHERE s.batch_id = sb.id)
+		FROM shipment_batches sb
+		ORDER BY sb.batch_number DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shipment batches: %w", err)
+	}
+	defer rows.Close()
+
+	var batches []*ShipmentBatch
+	for rows.Next() {
+		var count int
+		b, err := scanShipmentBatch(rows.Scan, &count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shipment batch: %w", err)
+		}
+		b.ShipmentCount = count
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+// GetShipmentBatch returns a batch with its shipments by student name. Returns sql.ErrNoRows when not found.
+func GetShipmentBatch(id uuid.UUID) (*ShipmentBatch, error) {
+	b, err := scanShipmentBatch(db.DB.QueryRow(`SELECT `+shipmentBatchColumns+` FROM shipment_batches sb WHERE sb.id = $1`, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shipment batch: %w", err)
+	}
+	rows, err := db.DB.Query(`SELECT `+shipmentColumns+` WHERE s.batch_id = $1 ORDER BY l.full_name`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch shipments: %w", err)
+	}
+	b.Shipments, err = scanShipments(rows)
+	if err != nil {
+		return nil, err
+	}
+	b.ShipmentCount = len(b.Shipments)
+	return b, nil
+}
+
+// CreateShipmentBatch packs the given students' books into a new batch for courier. Every lead must
+// still be waiting to be packed and have a shippable address; the booking address is snapshotted onto
+// the shipment. Returns *ShippingError otherwise.
+func CreateShipmentBatch(courier string, leadIDs []uuid.UUID, actorUserID string) (*ShipmentBatch, error) {
+	if !isShippingCourier(courier) {
+		return nil, &ShippingError{Message: "Choose a courier for the batch."}
+	}
+	if len(leadIDs) == 0 {
+		return nil, &ShippingError{Message: "Select at least one book to pack."}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var createdBy sql.NullString
+	if actorUserID != "" {
+		createdBy = sql.NullString{String: actorUserID, Valid: true}
+	}
+	// Lock the table so concurrent batches cannot take the same batch number
+	if _, err := tx.Exec(`LOCK TABLE shipment_batches IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return nil, fmt.Errorf("failed to lock shipment batches: %w", err)
+	}
+	now := time.Now()
+	batch := &ShipmentBatch{Courier: courier, Status: BatchPacking, CreatedByUserID: createdBy, CreatedAt: now}
+	err = tx.QueryRow(`
+		INSERT INTO shipment_batches (batch_number, courier, status, created_by_user_id, created_at)
+		VALUES ((SELECT COALESCE(MAX(batch_number), 0) + 1 FROM shipment_batches), $1, $2, $3, $4)
+		RETURNING id, batch_number
+	`, courier, BatchPacking, createdBy, now).Scan(&batch.ID, &batch.BatchNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shipment batch: %w", err)
+	}
+
+	for _, leadID := range leadIDs {
+		var name string
+		var address, city sql.NullString
+		err := tx.QueryRow(`
+			SELECT l.full_name, b.address, b.city
+			FROM leads l
+			JOIN bookings b ON b.lead_id = l.id
+			WHERE l.id = $1 AND `+toPackCond+`
+			FOR UPDATE OF l
+		`, leadID).Scan(&name, &address, &city)
+		if err == sql.ErrNoRows {
+			return nil, &ShippingError{Message: "A selected book is no longer waiting to be packed; reload the page and try again."}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load shipment lead: %w", err)
+		}
+		if err := ValidateShippingAddress(address.String, city.String); err != nil {
+			return nil, &ShippingError{Message: fmt.Sprintf("%s: %s", name, err.Error())}
+		}
+		_, err = tx.Exec(`
+			INSERT INTO shipping (id, lead_id, shipment_status, batch_id, ship_to_address, ship_to_city, packed_at, updated_at)
+			VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $6)
+			ON CONFLICT (lead_id) DO UPDATE SET
+				shipment_status = EXCLUDED.shipment_status,
+				shipment_date = NULL,
+				batch_id = EXCLUDED.batch_id,
+				tracking_number = NULL,
+				ship_to_address = EXCLUDED.ship_to_address,
+				ship_to_city = EXCLUDED.ship_to_city,
+				packed_at = EXCLUDED.packed_at,
+				delivered_at = NULL,
+				returned_at = NULL,
+				updated_at = EXCLUDED.updated_at
+		`, leadID, ShipmentPacked, batch.ID, strings.TrimSpace(address.String), strings.TrimSpace(city.String), now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack shipment: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit shipment batch: %w", err)
+	}
+	return batch, nil
+}
+
+// getPackingBatchTx locks a batch and checks it is still being packed
+func getPackingBatchTx(tx *sql.Tx, batchID uuid.UUID) (*ShipmentBatch, error) {
+	b, err := scanShipmentBatch(tx.QueryRow(`SELECT `+shipmentBatchColumns+` FROM shipment_batches sb WHERE sb.id = $1 FOR UPDATE`, batchID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, &ShippingError{Message: "Shipment batch not found."}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shipment batch: %w", err)
+	}
+	if b.Status != BatchPacking {
+		return nil, &ShippingError{Message: fmt.Sprintf("%s has already been sent.", b.Label())}
+	}
+	return b, nil
+}
+
+// SaveBatchTrackingNumbers sets the courier tracking numbers of a batch still being packed, keyed by
+// lead. A blank number clears it.
+func SaveBatchTrackingNumbers(batchID uuid.UUID, tracking map[uuid.UUID]string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := getPackingBatchTx(tx, batchID); err != nil {
+		return err
+	}
+	now := time.Now()
+	for leadID, number := range tracking {
+		number = strings.TrimSpace(number)
+		_, err := tx.Exec(`
+			UPDATE shipping SET tracking_number = $1, updated_at = $2
+			WHERE lead_id = $3 AND batch_id = $4 AND shipment_status = $5
+		`, sql.NullString{String: number, Valid: number != ""}, now, leadID, batchID, ShipmentPacked)
+		if err != nil {
+			return fmt.Errorf("failed to save tracking number: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// RemoveFromShipmentBatch takes a packed book out of its batch; it goes back to the to-pack list
+func RemoveFromShipmentBatch(batchID, leadID uuid.UUID) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := getPackingBatchTx(tx, batchID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE shipping SET shipment_status = $1, batch_id = NULL, tracking_number = NULL, packed_at = NULL, updated_at = $2
+		WHERE lead_id = $3 AND batch_id = $4 AND shipment_status = $5
+	`, ShipmentPending, time.Now(), leadID, batchID, ShipmentPacked)
+	if err != nil {
+		return fmt.Errorf("failed to remove shipment from batch: %w", err)
+	}
+	return tx.Commit()
+}
+
+// MarkShipmentBatchSent hands a packed batch to the courier: every shipment in it becomes sent on
+// sentOn, each book is taken out of stock for the student's level, and the shipping cost is posted as
+// an OUT transaction (category shipping). Every shipment needs a tracking number first. Returns *ShippingError when the batch cannot be sent.
+func MarkShipmentBatchSent(batchID uuid.UUID, cost int32, paymentMethod string, sentOn time.Time) error {
+	if cost < 0 {
+		return &ShippingError{Message: "Shipping cost cannot be negative."}
+	}
+	if err := util.ValidateNotFutureDate(sentOn); err != nil {
+		return &ShippingError{Message: "Sent date cannot be in the future."}
+	}
+	allowedMethods := map[string]bool{
+		"vodafone_cash": true, "bank_transfer": true, "paypal": true, "other": true,
+	}
+	if cost > 0 && !allowedMethods[paymentMethod] {
+		return &ShippingError{Message: "Choose how the shipping cost was paid."}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	batch, err := getPackingBatchTx(tx, batchID)
+	if err != nil {
+		return err
+	}
+	rows, err := tx.Query(`SELECT `+shipmentColumns+` WHERE s.batch_id = $1 ORDER BY l.full_name`, batchID)
+	if err != nil {
+		return fmt.Errorf("failed to get batch shipments: %w", err)
+	}
+	shipments, err := scanShipments(rows)
+	if err != nil {
+		return err
+	}
+	if len(shipments) == 0 {
+		return &ShippingError{Message: fmt.Sprintf("%s has no books in it.", batch.Label())}
+	}
+	var missing []string
+	for _, s := range shipments {
+		if !s.TrackingNumber.Valid || strings.TrimSpace(s.TrackingNumber.String) == "" {
+			missing = append(missing, s.FullName)
+		}
+	}
+	if len(missing) > 0 {
+		return &ShippingError{Message: "Tracking number missing for: " + strings.Join(missing, ", ")}
+	}
+
+	now := time.Now()
+	sentOnValue := sentOn.Format("2006-01-02")
+	if _, err := tx.Exec(`
+		UPDATE shipping SET shipment_status = $1, shipment_date = $2::date, updated_at = $3
+		WHERE batch_id = $4 AND shipment_status = $5
+	`, ShipmentSent, sentOnValue, now, batchID, ShipmentPacked); err != nil {
+		return fmt.Errorf("failed to mark shipments sent: %w", err)
+	}
+	notes := fmt.Sprintf("%s via %s", batch.Label(), batch.CourierName())
+	if err := recordBatchShipmentStockTx(tx, batchID, notes, now); err != nil {
+		return err
+	}
+	var method sql.NullString
+	if cost > 0 {
+		method = sql.NullString{String: paymentMethod, Valid: true}
+	}
+	if _, err := tx.Exec(`
+		UPDATE shipment_batches SET status = $1, shipping_cost = $2, payment_method = $3, sent_on = $4::date
+		WHERE id = $5
+	`, BatchSent, cost, method, sentOnValue, batchID); err != nil {
+		return fmt.Errorf("failed to mark shipment batch sent: %w", err)
+	}
+	if cost > 0 {
+		_, err := tx.Exec(`
+			INSERT INTO transactions (id, transaction_date, transaction_type, category, amount, payment_method, ref_type, ref_id, ref_key, notes, created_at, updated_at)
+			VALUES (gen_random_uuid(), $1::date, 'OUT', 'shipping', $2::integer, $3::text, 'shipment_batch', $4::text, $5::text, $6, $7::timestamp with time zone, $7::timestamp with time zone)
+			ON CONFLICT (ref_key) DO NOTHING
+		`, sentOnValue, cost, paymentMethod, batchID.String(), "shipment_batch:"+batchID.String(),
+			fmt.Sprintf("%s (%d books)", notes, len(shipments)), now)
+		if err != nil {
+			return fmt.Errorf("failed to post shipping cost: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SetShipmentStatus records what happened to a sent book: delivered or returned. Packing and sending
+// go through batches; a returned book goes back into stock and shows up on the to-pack list again.
+// Returns *ShippingError when the transition is not allowed.
+func SetShipmentStatus(leadID uuid.UUID, status string) error {
+	if status != ShipmentDelivered && status != ShipmentReturned {
+		return &ShippingError{Message: "Books are packed and sent through shipment batches."}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current, batchID sql.NullString
+	err = tx.QueryRow(`SELECT shipment_status, batch_id::text FROM shipping WHERE lead_id = $1 FOR UPDATE`, leadID).Scan(&current, &batchID)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get shipment: %w", err)
+	}
+	if !CanTransitionShipment(current.String, status) {
+		from := current.String
+		if from == "" {
+			from = ShipmentPending
+		}
+		return &ShippingError{Message: fmt.Sprintf("A %s shipment cannot be marked %s.", from, status)}
+	}
+
+	now := time.Now()
+	switch status {
+	case ShipmentDelivered:
+		_, err = tx.Exec(`UPDATE shipping SET shipment_status = $1, delivered_at = $2, updated_at = $2 WHERE lead_id = $3`, status, now, leadID)
+	case ShipmentReturned:
+		_, err = tx.Exec(`UPDATE shipping SET shipment_status = $1, returned_at = $2, updated_at = $2 WHERE lead_id = $3`, status, now, leadID)
+		if err == nil {
+			err = recordReturnStockTx(tx, leadID, batchID, now)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update shipment status: %w", err)
+	}
+	return tx.Commit()
+}
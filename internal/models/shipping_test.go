@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanTransitionShipment(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"", ShipmentPacked, true},
+		{"", ShipmentSent, false},
+		{ShipmentPending, ShipmentPacked, true},
+		{ShipmentPending, ShipmentDelivered, false},
+		{ShipmentPacked, ShipmentSent, true},
+		{ShipmentPacked, ShipmentPending, true},
+		{ShipmentPacked, ShipmentDelivered, false},
+		{ShipmentSent, ShipmentDelivered, true},
+		{ShipmentSent, ShipmentReturned, true},
+		{ShipmentSent, ShipmentPending, false},
+		{ShipmentDelivered, ShipmentReturned, true},
+		{ShipmentDelivered, ShipmentSent, false},
+		{ShipmentReturned, ShipmentPacked, true},
+		{ShipmentReturned, ShipmentDelivered, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransitionShipment(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransitionShipment(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestValidateShippingAddress(t *testing.T) {
+	tests := []struct {
+		name, address, city string
+		ok                  bool
+	}{
+		{"valid", "12 Abbas El Akkad St, Nasr City", "Cairo", true},
+		{"missing address", "  ", "Cairo", false},
+		{"too short", "5 Tahrir", "Cairo", false},
+		{"no number", "Abbas El Akkad Street, Nasr City", "Cairo", false},
+		{"missing city", "12 Abbas El Akkad St, Nasr City", "", false},
+	}
+	for _, tt := range tests {
+		err := ValidateShippingAddress(tt.address, tt.city)
+		if tt.ok {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		var shippingErr *ShippingError
+		if !errors.As(err, &shippingErr) {
+			t.Errorf("%s: got %v, want *ShippingError", tt.name, err)
+		}
+	}
+}
+
+func TestShipmentBatchCourierName(t *testing.T) {
+	if got := (&ShipmentBatch{Courier: "egypt_post"}).CourierName(); got != "Egypt Post" {
+		t.Errorf("CourierName() = %q, want %q", got, "Egypt Post")
+	}
+	if got := (&ShipmentBatch{Courier: "dhl"}).CourierName(); got != "dhl" {
+		t.Errorf("CourierName() for unknown courier = %q, want %q", got, "dhl")
+	}
+}
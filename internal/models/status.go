@@ -71,6 +71,18 @@ func GetStatusDisplayInfo(status string) StatusDisplayInfo {
 			TextColor:   "#006600",
 			BorderColor: "#28a745",
 		},
+		"in_classes": {
+			DisplayName: "In Classes",
+			BgColor:     "#E6F3FF",
+			TextColor:   "#0052A3",
+			BorderColor: "#0052A3",
+		},
+		"paused": {
+			DisplayName: "Paused",
+			BgColor:     "#FFF9E6",
+			TextColor:   "#8B6914",
+			BorderColor: "#FFC107",
+		},
 		"cancelled": {
 			DisplayName: "Cancelled",
 			BgColor:     "#F5F5F5",
@@ -0,0 +1,269 @@
+package models
+
+import (
+	"eighty-twenty-ops/internal/db"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FullClassGroupSize is the number of students that fills (locks) a class group; see GetClassGroups
+const FullClassGroupSize = 6
+
+// ClassDaysOptions and ClassTimeOptions are the class slots students can be scheduled into
+var (
+	ClassDaysOptions = []string{"Sun/Wed", "Sat/Tues", "Mon/Thu"}
+	ClassTimeOptions = []string{"07:30", "10:00"}
+)
+
+// GetWaitlist returns students waiting for a round, plus ready students not yet sent to the classes board,
+// in waitlist order: earliest course payment first, then longest waiting.
+func GetWaitlist() ([]*WaitlistStudent, error) {
+	rows, err := db.DB.Query(`
+		SELECT l.id, l.full_name, l.phone, l.status, pt.assigned_level, s.class_days, s.class_time,
+			(SELECT MIN(payment_date) FROM lead_payments WHERE lead_id = l.id),
+			COALESCE((SELECT MAX(changed_at) FROM lead_status_history WHERE lead_id = l.id AND new_status = l.status), l.updated_at),
+			COALESCE(o.final_price, 0),
+			GREATEST(
+				COALESCE((SELECT SUM(amount) FROM lead_payments WHERE lead_id = l.id), 0) -
+				COALESCE((SELECT SUM(amount) FROM transactions WHERE lead_id = l.id AND category = 'refund' AND transaction_type = 'OUT'), 0),
+				0),
+			EXISTS(SELECT 1 FROM lead_installments WHERE lead_id = l.id),
+			(SELECT COUNT(*) FROM lead_installments WHERE lead_id = l.id AND paid_amount < amount AND due_date < CURRENT_DATE)
+		FROM leads l
+		LEFT JOIN placement_tests pt ON pt.lead_id = l.id
+		LEFT JOIN offers o ON o.lead_id = l.id
+		LEFT JOIN scheduling s ON s.lead_id = l.id
+		WHERE (l.status = 'waiting_for_round' OR (l.status = 'ready_to_start' AND l.sent_to_classes = false))
+		  AND l.archived_at IS NULL
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get waitlist: %w", err)
+	}
+	defer rows.Close()
+
+	overdueBlocks, err := GetInstallmentOverdueBlock()
+	if err != nil {
+		return nil, err
+	}
+	var students []*WaitlistStudent
+	for rows.Next() {
+		st := &WaitlistStudent{}
+		if err := rows.Scan(&st.LeadID, &st.FullName, &st.Phone, &st.Status, &st.AssignedLevel, &st.ClassDays, &st.ClassTime,
+			&st.FirstPaymentDate, &st.WaitingSince, &st.FinalPrice, &st.TotalCoursePaid, &st.HasInstallmentPlan, &st.OverdueInstallments); err != nil {
+			return nil, fmt.Errorf("failed to scan waitlist student: %w", err)
+		}
+		st.Blocker = waitlistBlocker(st, overdueBlocks)
+		students = append(students, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	SortWaitlist(students)
+	return students, nil
+}
+
+// waitlistBlocker explains why a student cannot be marked ready, using the same rules as EventMarkReady
+func waitlistBlocker(st *WaitlistStudent, overdueBlocks bool) string {
+	facts := LeadFacts{
+		HasAssignedLevel:     st.AssignedLevel.Valid,
+		OfferFinalPrice:      st.FinalPrice,
+		TotalCoursePaid:      st.TotalCoursePaid,
+		HasInstallmentPlan:   st.HasInstallmentPlan,
+		OverdueInstallments:  st.OverdueInstallments,
+		OverdueBlocksClasses: overdueBlocks,
+	}
+	switch {
+	case !facts.HasAssignedLevel:
+		return "No assigned level"
+	case !facts.PaidOrOnPlan():
+		return "Not fully paid or on an installment plan"
+	case facts.InstallmentOverdueBlocked():
+		return "Installment overdue"
+	}
+	return ""
+}
+
+// SortWaitlist orders students by first course payment (unpaid last), then by how long they have waited
+func SortWaitlist(students []*WaitlistStudent) {
+	sort.SliceStable(students, func(i, j int) bool {
+		a, b := students[i], students[j]
+		if a.FirstPaymentDate.Valid != b.FirstPaymentDate.Valid {
+			return a.FirstPaymentDate.Valid
+		}
+		if a.FirstPaymentDate.Valid && !a.FirstPaymentDate.Time.Equal(b.FirstPaymentDate.Time) {
+			return a.FirstPaymentDate.Time.Before(b.FirstPaymentDate.Time)
+		}
+		return a.WaitingSince.Before(b.WaitingSince)
+	})
+}
+
+// GroupWaitlist splits an ordered waitlist by assigned level and preferred days/time, and ranks
+// students within each group. Groups are ordered by level, then days, then time; flexible last.
+func GroupWaitlist(students []*WaitlistStudent) []*WaitlistGroup {
+	byKey := map[string]*WaitlistGroup{}
+	var groups []*WaitlistGroup
+	for _, st := range students {
+		g := &WaitlistGroup{Level: st.AssignedLevel.Int32, ClassDays: st.ClassDays.String, ClassTime: st.ClassTime.String}
+		key := fmt.Sprintf("%d|%s|%s", g.Level, g.ClassDays, g.ClassTime)
+		if existing, ok := byKey[key]; ok {
+			g = existing
+		} else {
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.Students = append(g.Students, st)
+		st.Rank = len(g.Students)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if a.Level != b.Level {
+			return a.Level < b.Level
+		}
+		if a.ClassDays != b.ClassDays {
+			return a.ClassDays != "" && (b.ClassDays == "" || a.ClassDays < b.ClassDays)
+		}
+		return a.ClassTime != "" && (b.ClassTime == "" || a.ClassTime < b.ClassTime)
+	})
+	return groups
+}
+
+// fitsSlot reports whether a student's preference allows the slot (no preference fits any slot)
+func (st *WaitlistStudent) fitsSlot(classDays, classTime string) bool {
+	return (!st.ClassDays.Valid || st.ClassDays.String == classDays) &&
+		(!st.ClassTime.Valid || st.ClassTime.String == classTime)
+}
+
+// SuggestClassGroups finds class slots that enough eligible students could fill. students must be
+// in waitlist order. A student is used in at most one suggestion: slots that more students asked for
+// by name are filled first, and each suggestion takes the first size candidates in line.
+func SuggestClassGroups(students []*WaitlistStudent, size int) []*GroupSuggestion {
+	var levels []int32
+	seen := map[int32]bool{}
+	for _, st := range students {
+		if st.Blocker == "" && !seen[st.AssignedLevel.Int32] {
+			seen[st.AssignedLevel.Int32] = true
+			levels = append(levels, st.AssignedLevel.Int32)
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+	used := map[uuid.UUID]bool{}
+	var suggestions []*GroupSuggestion
+	for {
+		var best *GroupSuggestion
+		for _, level := range levels {
+			for _, days := range ClassDaysOptions {
+				for _, classTime := range ClassTimeOptions {
+					s := &GroupSuggestion{Level: level, ClassDays: days, ClassTime: classTime}
+					for _, st := range students {
+						if used[st.LeadID] || st.Blocker != "" || st.AssignedLevel.Int32 != level || !st.fitsSlot(days, classTime) {
+							continue
+						}
+						s.Candidates++
+						if st.ClassDays.Valid && st.ClassTime.Valid {
+							s.ExactMatches++
+						}
+						if len(s.Students) < size {
+							s.Students = append(s.Students, st)
+						}
+					}
+					if s.Candidates >= size && betterSuggestion(s, best) {
+						best = s
+					}
+				}
+			}
+		}
+		if best == nil {
+			break
+		}
+		for _, st := range best.Students {
+			used[st.LeadID] = true
+		}
+		suggestions = append(suggestions, best)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Level != b.Level {
+			return a.Level < b.Level
+		}
+		return slotIndex(a.ClassDays, a.ClassTime) < slotIndex(b.ClassDays, b.ClassTime)
+	})
+	return suggestions
+}
+
+// betterSuggestion prefers more exact-preference matches, then more candidates; on a tie the slot
+// found first (lower level, earlier in ClassDaysOptions/ClassTimeOptions) wins
+func betterSuggestion(s, best *GroupSuggestion) bool {
+	if best == nil {
+		return true
+	}
+	if s.ExactMatches != best.ExactMatches {
+		return s.ExactMatches > best.ExactMatches
+	}
+	return s.Candidates > best.Candidates
+}
+
+// slotIndex orders class slots as listed in ClassDaysOptions and ClassTimeOptions
+func slotIndex(classDays, classTime string) int {
+	for i, days := range ClassDaysOptions {
+		for j, t := range ClassTimeOptions {
+			if days == classDays && t == classTime {
+				return i*len(ClassTimeOptions) + j
+			}
+		}
+	}
+	return len(ClassDaysOptions) * len(ClassTimeOptions)
+}
+
+// AssignSuggestedGroup schedules the suggested group for a slot: each student gets the class days/time,
+// is marked ready (if waiting) and sent to the classes board in one transaction, then placed in a class group.
+// Returns *WaitlistError when the waitlist no longer fills the slot, or *LeadTransitionError when a student
+// cannot be marked ready.
+func AssignSuggestedGroup(level int32, classDays, classTime, role, actorUserID string) ([]*WaitlistStudent, error) {
+	students, err := GetWaitlist()
+	if err != nil {
+		return nil, err
+	}
+	var group *GroupSuggestion
+	for _, s := range SuggestClassGroups(students, FullClassGroupSize) {
+		if s.Level == level && s.ClassDays == classDays && s.ClassTime == classTime {
+			group = s
+		}
+	}
+	if group == nil {
+		return nil, &WaitlistError{Message: fmt.Sprintf("There are no longer enough waiting students to fill Level %d %s %s.", level, classDays, classTime)}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, st := range group.Students {
+		if err := upsertSchedulingClassDaysTime(tx, st.LeadID, classDays, classTime, now); err != nil {
+			return nil, err
+		}
+		if st.Status != "ready_to_start" {
+			if err := applyLeadEventTx(tx, st.LeadID, EventMarkReady, role, actorUserID, "Scheduled from waitlist suggestion", now); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := tx.Exec(`UPDATE leads SET sent_to_classes = true, updated_at = $1 WHERE id = $2`, now, st.LeadID); err != nil {
+			return nil, fmt.Errorf("failed to send lead to classes: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit group assignment: %w", err)
+	}
+
+	for _, st := range group.Students {
+		if _, err := AssignClassGroup(st.LeadID); err != nil {
+			return nil, err
+		}
+	}
+	return group.Students, nil
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func waitlistStudent(name string, level int32, days, classTime string, paid time.Time) *WaitlistStudent {
+	st := &WaitlistStudent{
+		LeadID:        uuid.New(),
+		FullName:      name,
+		AssignedLevel: sql.NullInt32{Int32: level, Valid: level > 0},
+		ClassDays:     sql.NullString{String: days, Valid: days != ""},
+		ClassTime:     sql.NullString{String: classTime, Valid: classTime != ""},
+		WaitingSince:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if !paid.IsZero() {
+		st.FirstPaymentDate = sql.NullTime{Time: paid, Valid: true}
+	}
+	return st
+}
+
+func TestSortWaitlist(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	unpaid := waitlistStudent("unpaid", 1, "", "", time.Time{})
+	late := waitlistStudent("late", 1, "", "", day(9))
+	early := waitlistStudent("early", 1, "", "", day(2))
+	earlyLongerWait := waitlistStudent("early, waiting longer", 1, "", "", day(2))
+	earlyLongerWait.WaitingSince = earlyLongerWait.WaitingSince.AddDate(0, 0, -5)
+
+	students := []*WaitlistStudent{unpaid, late, early, earlyLongerWait}
+	SortWaitlist(students)
+	want := []string{"early, waiting longer", "early", "late", "unpaid"}
+	for i, st := range students {
+		if st.FullName != want[i] {
+			t.Errorf("position %d = %q, want %q", i, st.FullName, want[i])
+		}
+	}
+}
+
+func TestGroupWaitlist(t *testing.T) {
+	students := []*WaitlistStudent{
+		waitlistStudent("a", 2, "Sun/Wed", "07:30", time.Time{}),
+		waitlistStudent("b", 1, "", "", time.Time{}),
+		waitlistStudent("c", 2, "Sun/Wed", "07:30", time.Time{}),
+		waitlistStudent("d", 0, "", "", time.Time{}),
+		waitlistStudent("e", 1, "Mon/Thu", "10:00", time.Time{}),
+	}
+	groups := GroupWaitlist(students)
+	want := []string{"0||", "1|Mon/Thu|10:00", "1||", "2|Sun/Wed|07:30"}
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d", len(groups), len(want))
+	}
+	for i, g := range groups {
+		if key := fmt.Sprintf("%d|%s|%s", g.Level, g.ClassDays, g.ClassTime); key != want[i] {
+			t.Errorf("group %d = %s, want %s", i, key, want[i])
+		}
+	}
+	last := groups[3].Students
+	if len(last) != 2 || last[0].FullName != "a" || last[0].Rank != 1 || last[1].Rank != 2 {
+		t.Errorf("level 2 group not ranked in waitlist order: %+v", last)
+	}
+}
+
+func TestSuggestClassGroups(t *testing.T) {
+	var students []*WaitlistStudent
+	for i := 0; i < 4; i++ {
+		students = append(students, waitlistStudent(fmt.Sprintf("exact%d", i), 3, "Sat/Tues", "10:00", time.Time{}))
+	}
+	for i := 0; i < 3; i++ {
+		students = append(students, waitlistStudent(fmt.Sprintf("flex%d", i), 3, "", "", time.Time{}))
+	}
+	blocked := waitlistStudent("blocked", 3, "", "", time.Time{})
+	blocked.Blocker = "Installment overdue"
+	students = append(students, blocked)
+	for i := 0; i < 5; i++ {
+		students = append(students, waitlistStudent(fmt.Sprintf("level4-%d", i), 4, "", "", time.Time{}))
+	}
+
+	got := SuggestClassGroups(students, 6)
+	if len(got) != 1 {
+		t.Fatalf("got %d suggestions, want 1", len(got))
+	}
+	s := got[0]
+	if s.Level != 3 || s.ClassDays != "Sat/Tues" || s.ClassTime != "10:00" {
+		t.Errorf("suggested Level %d %s %s, want Level 3 Sat/Tues 10:00", s.Level, s.ClassDays, s.ClassTime)
+	}
+	if s.Candidates != 7 || s.ExactMatches != 4 || len(s.Students) != 6 {
+		t.Errorf("candidates %d, exact %d, students %d; want 7, 4, 6", s.Candidates, s.ExactMatches, len(s.Students))
+	}
+	for _, st := range s.Students {
+		if st == blocked {
+			t.Errorf("blocked student suggested")
+		}
+	}
+
+	// A second full group of flexible students fills the first slot in option order
+	for i := 0; i < 6; i++ {
+		students = append(students, waitlistStudent(fmt.Sprintf("flexmore%d", i), 3, "", "", time.Time{}))
+	}
+	got = SuggestClassGroups(students, 6)
+	if len(got) != 2 || got[0].ClassDays != "Sun/Wed" || got[0].ClassTime != "07:30" || got[1].ClassDays != "Sat/Tues" {
+		t.Errorf("unexpected suggestions for two level 3 groups: %+v", got)
+	}
+}
+
+func TestWaitlistBlocker(t *testing.T) {
+	level := sql.NullInt32{Int32: 2, Valid: true}
+	tests := []struct {
+		name          string
+		st            WaitlistStudent
+		overdueBlocks bool
+		want          string
+	}{
+		{"paid", WaitlistStudent{AssignedLevel: level, FinalPrice: 4000, TotalCoursePaid: 4000}, true, ""},
+		{"no level", WaitlistStudent{FinalPrice: 4000, TotalCoursePaid: 4000}, true, "No assigned level"},
+		{"deposit only", WaitlistStudent{AssignedLevel: level, FinalPrice: 4000, TotalCoursePaid: 1000}, true, "Not fully paid or on an installment plan"},
+		{"on plan", WaitlistStudent{AssignedLevel: level, FinalPrice: 4000, TotalCoursePaid: 1000, HasInstallmentPlan: true}, true, ""},
+		{"overdue", WaitlistStudent{AssignedLevel: level, FinalPrice: 4000, HasInstallmentPlan: true, OverdueInstallments: 1}, true, "Installment overdue"},
+		{"overdue, block off", WaitlistStudent{AssignedLevel: level, FinalPrice: 4000, HasInstallmentPlan: true, OverdueInstallments: 1}, false, ""},
+	}
+	for _, tt := range tests {
+		if got := waitlistBlocker(&tt.st, tt.overdueBlocks); got != tt.want {
+			t.Errorf("%s: waitlistBlocker() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CSVSafe prefixes a CSV cell with an apostrophe when Excel or Sheets would run it as a formula
+// (leading =, +, -, @, tab or carriage return). Numbers and valid phone numbers such as "-5" or
+// "+20 101 234 5678" are left as they are.
+func CSVSafe(s string) string {
+	if s == "" || !strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return s
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return s
+	}
+	if _, err := NormalizeEgyptianPhone(s); err == nil {
+		return s
+	}
+	return "'" + s
+}
@@ -0,0 +1,27 @@
+package util
+
+import "testing"
+
+func TestCSVSafe(t *testing.T) {
+	tests := map[string]string{
+		"":                   "",
+		"Sara":               "Sara",
+		"a=b":                "a=b",
+		"01012345678":        "01012345678",
+		"+201012345678":      "+201012345678",
+		"+20 101 234 5678":   "+20 101 234 5678",
+		"-5":                 "-5",
+		"+1.5":               "+1.5",
+		"=HYPERLINK(\"x\")":  "'=HYPERLINK(\"x\")",
+		"+cmd|' /C calc'!A0": "'+cmd|' /C calc'!A0",
+		"-1+1":               "'-1+1",
+		"@SUM(A1)":           "'@SUM(A1)",
+		"\t=1+1":             "'\t=1+1",
+		"\r=1+1":             "'\r=1+1",
+	}
+	for in, want := range tests {
+		if got := CSVSafe(in); got != want {
+			t.Errorf("CSVSafe(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PDFLine is one paragraph of a document written by WritePDF
+type PDFLine struct {
+	Text string
+	Size float64 // font size in points; 0 means 11
+	Bold bool
+	Gap  float64 // extra space above the paragraph, in points
+}
+
+const (
+	pdfPageWidth  = 595 // A4
+	pdfPageHeight = 842
+	pdfMargin     = 56
+)
+
+// WritePDF writes an A4 text document using the built-in Helvetica fonts.
+// Long paragraphs wrap at word boundaries and overflow onto new pages.
+// Text is encoded as WinAnsi, so characters outside Latin-1 (e.g. Arabic) print as "?".
+func WritePDF(w io.Writer, title string, lines []PDFLine) error {
+	var pages []string
+	var page strings.Builder
+	y := float64(pdfPageHeight - pdfMargin)
+	for _, line := range lines {
+		size := line.Size
+		if size == 0 {
+			size = 11
+		}
+		font := "F1"
+		if line.Bold {
+			font = "F2"
+		}
+		y -= line.Gap
+		for _, text := range pdfWrap(line.Text, size) {
+			y -= size * 1.4
+			if y < pdfMargin {
+				pages = append(pages, page.String())
+				page.Reset()
+				y = float64(pdfPageHeight-pdfMargin) - size*1.4
+			}
+			fmt.Fprintf(&page, "BT /%s %.1f Tf %d %.1f Td (%s) Tj ET\n", font, size, pdfMargin, y, pdfEscape(text))
+		}
+	}
+	pages = append(pages, page.String())
+
+	// Objects: 1 catalog, 2 page tree, 3-4 fonts, 5 info, then a page and its content stream per page
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Title (%s) /Producer (Eighty Twenty) >>", pdfEscape(title)),
+	)
+	for i, content := range pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, 7+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content),
+		)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// pdfWrap splits text into lines that fit the page width, estimating Helvetica's
+// average glyph width as half the font size
+func pdfWrap(text string, size float64) []string {
+	maxChars := int(float64(pdfPageWidth-2*pdfMargin) / (size * 0.5))
+	var lines []string
+	for _, para := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			if line != "" && len([]rune(line))+1+len([]rune(word)) > maxChars {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfWinAnsi maps the non-Latin-1 characters WinAnsiEncoding supports
+var pdfWinAnsi = map[rune]byte{'€': 0x80, '•': 0x95, '–': 0x96, '—': 0x97, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94}
+
+// pdfEscape encodes s as the body of a PDF literal string
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case pdfWinAnsi[r] != 0:
+			fmt.Fprintf(&b, "\\%03o", pdfWinAnsi[r])
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
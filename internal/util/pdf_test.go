@@ -0,0 +1,59 @@
+package util
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPDFEscape(t *testing.T) {
+	tests := map[string]string{
+		"Level 3 (B1)":   `Level 3 \(B1\)`,
+		`C:\path`:        `C:\\path`,
+		"Valid – 7 days": `Valid \226 7 days`,
+		"Café":           `Caf\351`,
+		"سارة":           "????",
+	}
+	for in, want := range tests {
+		if got := pdfEscape(in); got != want {
+			t.Errorf("pdfEscape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPDFWrap(t *testing.T) {
+	long := strings.Repeat("word ", 60)
+	lines := pdfWrap(long, 11)
+	if len(lines) < 2 {
+		t.Fatalf("expected a long paragraph to wrap, got %d line(s)", len(lines))
+	}
+	for _, l := range lines {
+		if len(l) > 88 {
+			t.Errorf("line too long (%d chars): %q", len(l), l)
+		}
+	}
+	if got := pdfWrap("a\nb", 11); len(got) != 2 {
+		t.Errorf("explicit newline: got %v", got)
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	var buf bytes.Buffer
+	lines := []PDFLine{{Text: "Offer letter", Size: 18, Bold: true}}
+	for i := 0; i < 80; i++ {
+		lines = append(lines, PDFLine{Text: "Line of text"})
+	}
+	if err := WritePDF(&buf, "Offer", lines); err != nil {
+		t.Fatalf("WritePDF: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatalf("missing PDF header or trailer")
+	}
+	if !strings.Contains(out, "/Count 2") {
+		t.Errorf("expected 80 lines to overflow onto a second page")
+	}
+	if !strings.Contains(out, "(Offer letter) Tj") {
+		t.Errorf("title text not written")
+	}
+}
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// egyptianMobilePrefixes are the valid operator prefixes for Egyptian mobile numbers (after the leading 0)
+var egyptianMobilePrefixes = map[string]bool{
+	"10": true, // Vodafone
+	"11": true, // Etisalat
+	"12": true, // Orange
+	"15": true, // WE
+}
+
+// NormalizeEgyptianPhone converts common Egyptian mobile formats to the local 11-digit form (01XXXXXXXXX).
+// Accepted inputs include "01012345678", "+20 101 234 5678", "00201012345678", "201012345678" and "1012345678".
+// Spaces, dashes, dots and parentheses are ignored; Arabic-Indic digits are converted.
+func NormalizeEgyptianPhone(raw string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r >= '٠' && r <= '٩': // Arabic-Indic digits
+			b.WriteRune('0' + (r - '٠'))
+		case r == '+' && b.Len() == 0:
+			// Leading + is implied by the country code handling below
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+			// Formatting characters
+		default:
+			return "", fmt.Errorf("invalid character %q in phone", r)
+		}
+	}
+	digits := b.String()
+
+	switch {
+	case strings.HasPrefix(digits, "0020"):
+		digits = "0" + strings.TrimPrefix(digits, "0020")
+	case strings.HasPrefix(digits, "20") && len(digits) == 12:
+		digits = "0" + strings.TrimPrefix(digits, "20")
+	case strings.HasPrefix(digits, "1") && len(digits) == 10:
+		digits = "0" + digits
+	}
+
+	if len(digits) != 11 || !strings.HasPrefix(digits, "0") || !egyptianMobilePrefixes[digits[1:3]] {
+		return "", fmt.Errorf("not a valid Egyptian mobile number: %q", raw)
+	}
+	return digits, nil
+}
@@ -0,0 +1,38 @@
+package util
+
+import "testing"
+
+func TestNormalizeEgyptianPhone(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{name: "local format", raw: "01012345678", want: "01012345678"},
+		{name: "international with plus", raw: "+201112345678", want: "01112345678"},
+		{name: "international with spaces", raw: "+20 122 345 6789", want: "01223456789"},
+		{name: "double zero prefix", raw: "00201512345678", want: "01512345678"},
+		{name: "country code without plus", raw: "201012345678", want: "01012345678"},
+		{name: "missing leading zero", raw: "1012345678", want: "01012345678"},
+		{name: "dashes and parentheses", raw: "(010) 1234-5678", want: "01012345678"},
+		{name: "arabic-indic digits", raw: "٠١٠١٢٣٤٥٦٧٨", want: "01012345678"},
+		{name: "unknown operator prefix", raw: "01312345678", wantErr: true},
+		{name: "too short", raw: "0101234567", wantErr: true},
+		{name: "letters", raw: "0101234abcd", wantErr: true},
+		{name: "empty", raw: "", wantErr: true},
+		{name: "landline", raw: "0223456789", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeEgyptianPhone(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeEgyptianPhone(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeEgyptianPhone(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
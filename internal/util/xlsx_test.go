@@ -0,0 +1,64 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestXLSXColumnName(t *testing.T) {
+	tests := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for i, want := range tests {
+		if got := xlsxColumnName(i); got != want {
+			t.Errorf("xlsxColumnName(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteXLSX(&buf, "Leads", []string{"Name", "Phone", "Paid"}, [][]interface{}{
+		{"Sara & Co <test>", "01012345678", int32(1500)},
+		{"Omar", "01112345678", nil},
+	})
+	if err != nil {
+		t.Fatalf("WriteXLSX: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("output is not a zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing %s", name)
+		}
+	}
+
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr"><is><t xml:space="preserve">Name</t></is></c>`,
+		`Sara &amp; Co &lt;test&gt;`,
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">01012345678</t></is></c>`,
+		`<c r="C2"><v>1500</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet missing %q", want)
+		}
+	}
+	if strings.Contains(sheet, `r="C3"`) {
+		t.Errorf("nil cell should be omitted")
+	}
+}